@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Runs "config init" without a terminal, returning its exit code, standard
+// output and standard error.
+func runInit(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := configInit(args, strings.NewReader(""), &stdout, &stderr, false)
+	return code, stdout.String(), stderr.String()
+}
+
+var secretRefRegex = regexp.MustCompile(`\$\{(PROJECT_[A-Z0-9_]+)\}`)
+
+func TestConfigInit_Loads(t *testing.T) {
+	testCases := []struct {
+		name       string
+		args       []string
+		sourceType string
+		authType   string
+		secrets    []string // settings left blank for the user
+	}{
+		{name: "database", args: []string{"--source", "database"}, sourceType: "database"},
+		{name: "api without auth", args: []string{"--source", "api"}, sourceType: "api", authType: "none"},
+		{name: "api with bearer auth", args: []string{"--source", "api", "--api-auth-type", "bearer"}, sourceType: "api", authType: "bearer",
+			secrets: []string{"API_AUTH_SECRET"}},
+		{name: "api with header auth", args: []string{"--source", "api", "--api-auth-type", "header"}, sourceType: "api", authType: "header",
+			secrets: []string{"API_AUTH_SECRET"}},
+		{name: "api with basic auth", args: []string{"--source", "api", "--api-auth-type", "basic"}, sourceType: "api", authType: "basic",
+			secrets: []string{"API_AUTH_USERNAME", "API_AUTH_PASSWORD"}},
+		{name: "api with hmac auth", args: []string{"--source", "api", "--api-auth-type", "hmac"}, sourceType: "api", authType: "hmac",
+			secrets: []string{"API_AUTH_SECRET"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name+" as yaml", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "stratum.yaml")
+			code, _, stderr := runInit(t, append([]string{"-y", "--route", "/avatars/{user_id}", "--output", path}, tc.args...)...)
+			require.Equal(t, 0, code, stderr)
+
+			raw, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var refs []string
+			for _, m := range secretRefRegex.FindAllStringSubmatch(string(raw), -1) {
+				refs = append(refs, strings.TrimPrefix(m[1], "PROJECT_1_"))
+				t.Setenv(m[1], "secret")
+			}
+			assert.ElementsMatch(t, tc.secrets, refs)
+
+			cfg, err := config.LoadFile(path)
+			require.NoError(t, err)
+			require.Len(t, cfg.Projects, 1)
+			p := cfg.Projects[0]
+			assert.Equal(t, "/avatars/{user_id}", p.Route)
+			assert.Equal(t, "user_id", p.IdColumn)
+			assert.Equal(t, tc.sourceType, p.SourceType)
+			if tc.authType != "" {
+				assert.Equal(t, tc.authType, p.APIAuthType)
+				assert.Equal(t, "https://api.example.com/items/{user_id}", p.APIEndpoint)
+			}
+		})
+
+		t.Run(tc.name+" as env", func(t *testing.T) {
+			code, stdout, stderr := runInit(t, append([]string{"-y", "--format", "env", "--route", "/avatars/{user_id}"}, tc.args...)...)
+			require.Equal(t, 0, code, stderr)
+
+			vars, err := godotenv.Unmarshal(stdout)
+			require.NoError(t, err)
+			var blank []string
+			var keys []string
+			for key, value := range vars {
+				if value == "" {
+					blank = append(blank, strings.TrimPrefix(key, "PROJECT_1_"))
+					vars[key] = "secret"
+				}
+				keys = append(keys, key)
+			}
+			assert.ElementsMatch(t, tc.secrets, blank)
+
+			cfg, err := config.LoadFrom(func(key string) string { return vars[key] }, keys...)
+			require.NoError(t, err)
+			require.Len(t, cfg.Projects, 1)
+			p := cfg.Projects[0]
+			assert.Equal(t, "user_id", p.IdColumn)
+			assert.Equal(t, tc.sourceType, p.SourceType)
+			if tc.authType != "" {
+				assert.Equal(t, tc.authType, p.APIAuthType)
+			}
+		})
+	}
+}
+
+func TestConfigInit_BlankSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stratum.yaml")
+	code, _, stderr := runInit(t, "-y", "--name", "Avatars", "--source", "api", "--api-auth-type", "bearer", "--output", path)
+	require.Equal(t, 0, code, stderr)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "name: avatars\n")
+	assert.Contains(t, string(raw), "api_auth_secret: ${PROJECT_AVATARS_API_AUTH_SECRET}")
+
+	// The file does not load until the secret is set.
+	_, err = config.LoadFile(path)
+	assert.ErrorContains(t, err, "PROJECT_AVATARS_API_AUTH_SECRET")
+
+	t.Setenv("PROJECT_AVATARS_API_AUTH_SECRET", "token")
+	cfg, err := config.LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "avatars", cfg.Projects[0].Name)
+	assert.Equal(t, "token", cfg.Projects[0].APIAuthSecret)
+}
+
+func TestConfigInit_InvalidName(t *testing.T) {
+	for _, name := range []string{"123", "bad-name", "with space", "a.b"} {
+		code, stdout, stderr := runInit(t, "-y", "--name", name)
+		assert.Equal(t, 2, code, name)
+		assert.Empty(t, stdout, name)
+		assert.Contains(t, stderr, "invalid project name", name)
+	}
+
+	code, _, stderr := runInit(t, "-y", "--name", "Avatars_2")
+	assert.Equal(t, 0, code, stderr)
+}
+
+func TestConfigInit_Overwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stratum.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("existing"), 0o600))
+
+	code, _, stderr := runInit(t, "-y", "--output", path)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "failed to write")
+	raw, _ := os.ReadFile(path)
+	assert.Equal(t, "existing", string(raw))
+
+	code, _, stderr = runInit(t, "-y", "--force", "--output", path)
+	assert.Equal(t, 0, code, stderr)
+	raw, _ = os.ReadFile(path)
+	assert.Contains(t, string(raw), "projects:")
+}
+
+func TestConfigInit_Prompts(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := configInit([]string{"-y"}, strings.NewReader("ignored\n"), &stdout, &stderr, true)
+		assert.Equal(t, 0, code)
+		assert.Empty(t, stderr.String(), "no questions with -y")
+		assert.Contains(t, stdout.String(), "route: /items/{id}")
+	})
+
+	t.Run("Interactive", func(t *testing.T) {
+		// Name, route, source, endpoint, auth type, content type, TTL and
+		// Redis URL; blank answers take the defaults.
+		answers := "avatars\n/avatars/{id}\napi\n\nbearer\nimage/png\n60\n\n"
+		var stdout, stderr bytes.Buffer
+		code := configInit(nil, strings.NewReader(answers), &stdout, &stderr, true)
+		assert.Equal(t, 0, code, stderr.String())
+		assert.Contains(t, stderr.String(), "Project name (blank for a numbered project) []: ")
+		assert.Contains(t, stderr.String(), "Upstream auth (none, bearer, header, basic, hmac) [none]: ")
+
+		out := stdout.String()
+		assert.Contains(t, out, "name: avatars\n")
+		assert.Contains(t, out, "api_endpoint: https://api.example.com/items/{id}")
+		assert.Contains(t, out, "api_auth_type: bearer")
+		assert.Contains(t, out, "content_type: image/png")
+		assert.Contains(t, out, "cache_ttl_seconds: 60")
+	})
+}
@@ -1,64 +1,269 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/PythonicVarun/Stratum/internal/api"
 	"github.com/PythonicVarun/Stratum/internal/cache"
 	"github.com/PythonicVarun/Stratum/internal/config"
 	"github.com/PythonicVarun/Stratum/internal/database"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
 	"github.com/PythonicVarun/Stratum/pkg/utils"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "validate" {
+		os.Exit(runValidate(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(runConfig(args[1:]))
+	}
+
+	devMode := len(args) > 0 && args[0] == "dev"
+	if devMode {
+		args = args[1:]
+	}
+
+	if _, err := os.Stat(".env"); err != nil {
+		utils.StratumLog("INFO", "No .env file found, using environment variables.")
 	}
 
-	cfg, err := config.Load()
+	source := &config.Source{}
+	flags := flag.NewFlagSet("Stratum", flag.ExitOnError)
+	flags.StringVar(&source.Path, "config", "", "YAML or JSON configuration file, or its consul://, etcd://, s3:// or https:// URL (default $STRATUM_CONFIG); environment variables override its settings")
+	flags.Parse(args)
+
+	cfg, err := source.Load()
 	if err != nil {
-		log.Fatalf("Error loading configuration: %v", err)
+		fatalf("Error loading configuration: %v", err)
+	}
+	configureLogging(cfg)
+	if path := source.ConfigPath(); path != "" {
+		utils.StratumLog("INFO", "Loaded configuration from %s.", redactURL(path))
 	}
 
 	if len(cfg.Projects) == 0 {
-		log.Println("Warning: No projects configured. Server will start but serve no routes.")
+		utils.StratumLog("WARN", "No projects configured. Server will start but serve no routes.")
 	}
+	warnChangedDefaults(cfg)
 
 	dbManager := database.NewConnectionManager()
 
 	var redisCache cache.Cache
 	if cfg.RedisURL != "" {
 		var err error
-		redisCache, err = cache.NewRedisCache(cfg.RedisURL)
+		redisCache, err = cache.NewRedisCache(cfg.RedisURL, cfg.Redis)
+		if err != nil && cfg.StrictStartup {
+			fatalf("Could not connect to Redis: %v", err)
+		}
 		if err != nil {
-			log.Printf("Warning: Could not connect to Redis. Caching will be disabled. Error: %v", err)
+			utils.StratumLog("WARN", "Could not connect to Redis. Caching will be disabled. Error: %v", err)
 			redisCache = &cache.NoOpCache{}
 		}
 	} else {
-		log.Println("Warning: REDIS_URL not set. Caching is disabled.")
+		utils.StratumLog("WARN", "REDIS_URL not set. Caching is disabled.")
 		redisCache = &cache.NoOpCache{}
 	}
+	redisCache = cache.NewPrefixedCache(redisCache, cfg.CacheKeyPrefix)
+	redisCache, err = cache.NewCompressedCache(redisCache, cfg.CacheCompression, cfg.CacheCompressionMinBytes)
+	if err != nil {
+		fatalf("Error setting up cache compression: %v", err)
+	}
+	if cfg.CacheAsyncWrites {
+		redisCache = cache.NewAsyncCache(redisCache, cfg.CacheWriteWorkers, cfg.CacheWriteQueueSize)
+	}
+	if cfg.CacheL1MaxEntries > 0 {
+		redisCache = cache.NewTieredCache(cache.NewMemoryCache(cfg.CacheL1MaxEntries, cfg.CacheL1MaxBytes), redisCache, cfg.CacheL1TTL)
+		utils.StratumLog("INFO", "In-memory cache enabled for up to %d hot keys (TTL %s).", cfg.CacheL1MaxEntries, cfg.CacheL1TTL)
+	}
+
+	if cfg.StrictStartup && !probeUpstreams(cfg) {
+		fatalf("Refusing to start: upstreams are unreachable (STRICT_STARTUP is set).")
+	}
+
+	// Databases are connected to here, which stops the server if one with
+	// DB_CONNECT=eager cannot be reached.
+	var server *api.Server
+	if devMode {
+		server = api.NewDevServer(cfg, dbManager, redisCache)
+		utils.StratumLog("INFO", "Dev mode enabled. Open http://localhost:%s/_dev to edit projects.", cfg.ServerPort)
+	} else {
+		server = api.NewServer(cfg, dbManager, redisCache)
+	}
+	server.SetProjectStore(source)
+	if cfg.StrictStartup && !databasesUp(dbManager) {
+		fatalf("Refusing to start: databases are unreachable (STRICT_STARTUP is set).")
+	}
 
-	server := api.NewServer(cfg, dbManager, redisCache)
+	server.Warm(context.Background())
 
 	go func() {
 		server.Start()
 	}()
 
+	if cfg.ConfigWatch {
+		if err := watchConfig(source, func() { reload(server, source) }); err != nil {
+			utils.StratumLog("WARN", "Could not watch the configuration files: %v", err)
+		} else {
+			utils.StratumLog("INFO", "Watching the configuration files for changes.")
+		}
+		if path := source.ConfigPath(); config.IsRemote(path) {
+			go pollConfig(path, cfg.ConfigPollInterval, func() { reload(server, source) })
+			utils.StratumLog("INFO", "Checking the configuration at %s for changes every %s.", redactURL(path), cfg.ConfigPollInterval)
+		}
+	}
+
+	go refreshSecrets(server, source)
+
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			reload(server, source)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	utils.StratumLog("INFO", "Server is shutting down...")
 
+	// Requests and background fetches still use the caches and databases,
+	// so they are let finish first.
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	if err := server.Shutdown(ctx); err != nil {
+		utils.StratumLog("WARN", "Requests still in progress after %s: %v", shutdownTimeout, err)
+	}
+	cancel()
+
 	dbManager.CloseAll()
+	if err := server.Close(); err != nil {
+		utils.StratumLog("INFO", "Error closing project caches: %v", err)
+	}
 	if err := redisCache.Close(); err != nil {
 		utils.StratumLog("INFO", "Error closing Redis cache: %v", err)
 	}
 
 	utils.StratumLog("INFO", "Server gracefully stopped.")
 }
+
+// How long requests in progress are given to finish on shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// Sends a HEAD request to every API project's upstream, logging the ones
+// that cannot be reached. Returns whether all of them could.
+func probeUpstreams(cfg *config.AppConfig) bool {
+	ok := true
+	for _, p := range cfg.Projects {
+		if p.SourceType != "api" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := datasource.ProbeUpstream(ctx, p, cfg.UpstreamTransport)
+		cancel()
+		if err != nil {
+			utils.StratumLog("ERROR", "Upstream of project '%s' is unreachable: %v", p.Name, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Warns about projects relying on defaults that changed in ways that would
+// otherwise go unnoticed.
+func warnChangedDefaults(cfg *config.AppConfig) {
+	for _, p := range cfg.Projects {
+		if p.SourceType == "database" && p.ValueEncodingDefaulted && !p.ServeColumnBinary {
+			utils.StratumLog("WARN", "Project '%s' has no VALUE_ENCODING, so its values are served raw. Earlier versions decoded base64, data URIs and URLs automatically; set its VALUE_ENCODING to auto to keep doing so, or to raw to silence this warning.", p.Name)
+		}
+	}
+}
+
+// Reports whether the databases of every project answered when they were
+// connected to, logging the ones that did not. Replicas may still be down.
+func databasesUp(dbManager *database.ConnectionManager) bool {
+	ok := true
+	for _, status := range dbManager.Statuses() {
+		if status.Role == "primary" && !status.Healthy {
+			utils.StratumLog("ERROR", "Database %s is unreachable: %s", status.Host, status.Error)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Applies the LOG_* settings of a configuration.
+func configureLogging(cfg *config.AppConfig) {
+	err := utils.ConfigureLogging(utils.LogOptions{Level: cfg.LogLevel, Format: cfg.LogFormat, Modules: cfg.LogLevels})
+	if err != nil {
+		utils.StratumLog("ERROR", "Could not configure logging: %v", err)
+	}
+}
+
+// Logs a fatal error and exits.
+func fatalf(format string, args ...interface{}) {
+	utils.StratumLog("FATAL", format, args...)
+	os.Exit(1)
+}
+
+// Serializes reloads triggered by signals and the config watcher.
+var reloadMu sync.Mutex
+
+// Loads the configuration again and swaps in the new project routes if it is
+// valid, logging what changed. Server settings such as SERVER_PORT and
+// REDIS_URL only take effect on restart. Returns whether the configuration
+// was applied.
+func reload(server *api.Server, source *config.Source) bool {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	previous := server.Config()
+	cfg, err := source.Load()
+	if err == nil {
+		err = server.Reload(cfg)
+	}
+	if err != nil {
+		utils.StratumLog("ERROR", "Configuration not reloaded: %v", err)
+		return false
+	}
+
+	configureLogging(cfg)
+	changes := config.Diff(previous, cfg)
+	if len(changes) == 0 {
+		utils.StratumLog("INFO", "Configuration reloaded, nothing changed.")
+		return true
+	}
+	utils.StratumLog("INFO", "Configuration reloaded with %d projects:", len(cfg.Projects))
+	for _, change := range changes {
+		utils.StratumLog("INFO", "  %s", change)
+	}
+	return true
+}
+
+// How often refreshSecrets checks for secrets with leases, and retries when
+// they could not be refreshed.
+const secretsRetryDelay = time.Minute
+
+// Loads the configuration again whenever the leases of its secrets are about
+// to run out.
+func refreshSecrets(server *api.Server, source *config.Source) {
+	for {
+		refresh := server.Config().SecretsRefresh
+		if refresh == 0 {
+			time.Sleep(secretsRetryDelay)
+			continue
+		}
+		time.Sleep(refresh)
+		for !reload(server, source) {
+			time.Sleep(secretsRetryDelay)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/api"
+	"github.com/PythonicVarun/Stratum/internal/cache"
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/database"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+)
+
+// How long validate waits to connect to an upstream.
+const pingTimeout = 5 * time.Second
+
+var placeholderRegex = regexp.MustCompile(`\{[^}]*\}`)
+
+// Checks the configuration and, with --ping, that every database, Redis
+// server and upstream can be reached. Prints a report and returns the exit
+// code: non-zero if anything is wrong, so CI can catch mistakes before a
+// deploy.
+func runValidate(args []string) int {
+	source := &config.Source{}
+	flags := flag.NewFlagSet("Stratum validate", flag.ExitOnError)
+	flags.StringVar(&source.Path, "config", "", "YAML or JSON configuration file, or its consul://, etcd://, s3:// or https:// URL (default $STRATUM_CONFIG)")
+	ping := flags.Bool("ping", false, "also connect to every database, Redis server and upstream")
+	flags.Parse(args)
+
+	r := &report{}
+	cfg, err := source.Load()
+	if err != nil {
+		r.check("configuration", err)
+		return r.finish()
+	}
+	from := "environment"
+	if path := source.ConfigPath(); path != "" {
+		from = redactURL(path)
+	}
+	r.check(fmt.Sprintf("configuration from %s, %d projects", from, len(cfg.Projects)), nil)
+	r.check("routes", api.ValidateRoutes(cfg))
+	if cfg.TLSCertFile != "" {
+		_, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		r.check("tls certificate "+cfg.TLSCertFile, err)
+	}
+	if *ping && cfg.RedisURL != "" {
+		r.check("redis "+redactURL(cfg.RedisURL), pingRedis(cfg, cfg.RedisURL))
+	}
+
+	// Connections shared by several projects are only tried once.
+	pinged := make(map[string]error)
+	pingOnce := func(key string, ping func() error) error {
+		if err, ok := pinged[key]; ok {
+			return err
+		}
+		pinged[key] = ping()
+		return pinged[key]
+	}
+
+	for _, p := range cfg.Projects {
+		fmt.Printf("\n%s (%s, %s source)\n", p.Name, strings.Join(p.Routes, ", "), p.SourceType)
+		r.indent = "  "
+		r.check("definition", api.ValidateProject(p))
+		if !*ping {
+			continue
+		}
+
+		switch p.SourceType {
+		case "database":
+			options := datasource.DBOptions(p)
+			key := fmt.Sprintf("db|%s|%+v|%s", p.DB_DSN, options.TLS, options.Auth)
+			r.check("database", pingOnce(key, func() error { return pingDatabase(p.DB_DSN, options) }))
+		case "api":
+			address, err := upstreamAddress(p.APIEndpoint)
+			if err == nil {
+				err = pingOnce("tcp|"+address, func() error { return pingTCP(address) })
+			}
+			r.check("upstream "+address, err)
+		}
+		if p.CacheBackend == "redis" {
+			r.check("redis cache "+redactURL(p.CacheRedisURL), pingOnce("redis|"+p.CacheRedisURL, func() error { return pingRedis(cfg, p.CacheRedisURL) }))
+		}
+	}
+	r.indent = ""
+	return r.finish()
+}
+
+// Collects the outcome of each check.
+type report struct {
+	indent   string
+	failures int
+}
+
+func (r *report) check(name string, err error) {
+	if err != nil {
+		r.failures++
+		fmt.Printf("%sFAIL  %s: %v\n", r.indent, name, err)
+		return
+	}
+	fmt.Printf("%sok    %s\n", r.indent, name)
+}
+
+func (r *report) finish() int {
+	if r.failures > 0 {
+		fmt.Printf("\n%d problem(s) found.\n", r.failures)
+		return 1
+	}
+	fmt.Println("\nConfiguration is valid.")
+	return 0
+}
+
+func pingDatabase(dsn string, options database.Options) error {
+	db, err := database.NewDBLoader(dsn, options)
+	if err != nil {
+		return err
+	}
+	db.Close()
+	return nil
+}
+
+func pingRedis(cfg *config.AppConfig, redisURL string) error {
+	c, err := cache.NewRedisCache(redisURL, cfg.Redis)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// Only connects to upstreams, since requesting a made-up ID could have side
+// effects or count against rate limits.
+func pingTCP(address string) error {
+	conn, err := net.DialTimeout("tcp", address, pingTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Returns the host and port an API endpoint template points at.
+func upstreamAddress(endpoint string) (string, error) {
+	u, err := url.Parse(placeholderRegex.ReplaceAllString(endpoint, "x"))
+	if err != nil {
+		return "", fmt.Errorf("invalid API endpoint: %w", err)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("API endpoint has no host")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// Hides the password in a URL printed in the report.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	return u.Redacted()
+}
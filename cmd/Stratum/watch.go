@@ -0,0 +1,86 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/fsnotify/fsnotify"
+)
+
+// How long the watcher waits for writes to settle before reloading, since
+// editors often save a file in several steps.
+const configReloadDelay = 500 * time.Millisecond
+
+// Calls onChange whenever one of the files the configuration is read from
+// changes.
+func watchConfig(source *config.Source, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// Directories are watched rather than the files, so files replaced by
+	// a rename (as editors and deployment tools do) are still noticed.
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, file := range source.Files() {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		files[abs] = true
+		if dir := filepath.Dir(abs); !dirs[dir] {
+			if err := watcher.Add(dir); err != nil {
+				watcher.Close()
+				return err
+			}
+			dirs[dir] = true
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		var reload <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if files[filepath.Clean(event.Name)] {
+					reload = time.After(configReloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				utils.StratumLog("WARN", "Config watcher error: %v", err)
+			case <-reload:
+				reload = nil
+				onChange()
+			}
+		}
+	}()
+	return nil
+}
+
+// Calls onChange whenever the variables of a remote configuration differ
+// from the last time they were read.
+func pollConfig(path string, interval time.Duration, onChange func()) {
+	last, _ := config.ReadConfig(path)
+	for range time.Tick(interval) {
+		values, err := config.ReadConfig(path)
+		if err != nil {
+			utils.StratumLog("WARN", "Could not check the configuration for changes: %v", err)
+			continue
+		}
+		if !reflect.DeepEqual(values, last) {
+			last = values
+			onChange()
+		}
+	}
+}
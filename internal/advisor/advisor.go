@@ -0,0 +1,233 @@
+package advisor
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of cache entries sampled per project by default.
+	defaultSampleSize = 256
+
+	// Lookups needed before a project's hit ratio is judged.
+	minLookups = 100
+
+	// Hit ratio below which caching a project is considered wasted.
+	lowHitRatio = 0.05
+
+	// Expired sampled entries needed before TTL recommendations are made.
+	minExpiredEntries = 20
+)
+
+// Advisor samples cache entries as they are stored and read, and reports on
+// how well each project's cache configuration fits its traffic.
+type Advisor struct {
+	// Number of entries sampled per project.
+	SampleSize int
+
+	mu       sync.Mutex
+	projects map[string]*projectSample
+	now      func() time.Time
+	rand     *rand.Rand
+}
+
+type projectSample struct {
+	hits   uint64
+	stores uint64
+	bytes  uint64
+
+	// A reservoir sample of the stored entries, by cache key.
+	entries map[string]*entry
+	keys    []string
+}
+
+type entry struct {
+	size    int
+	ttl     time.Duration
+	stored  time.Time
+	hits    uint64
+	lastHit time.Time
+}
+
+// New creates an Advisor.
+func New() *Advisor {
+	return &Advisor{
+		SampleSize: defaultSampleSize,
+		projects:   make(map[string]*projectSample),
+		now:        time.Now,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (a *Advisor) project(name string) *projectSample {
+	ps, ok := a.projects[name]
+	if !ok {
+		ps = &projectSample{entries: make(map[string]*entry)}
+		a.projects[name] = ps
+	}
+	return ps
+}
+
+// ObserveStore records that a payload was stored in the cache. Entries are
+// sampled so memory use stays bounded however many keys a project has.
+func (a *Advisor) ObserveStore(project, key string, size int, ttl time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ps := a.project(project)
+	ps.stores++
+	ps.bytes += uint64(size)
+	e := &entry{size: size, ttl: ttl, stored: a.now()}
+
+	switch {
+	case ps.entries[key] != nil:
+		ps.entries[key] = e
+	case len(ps.keys) < a.SampleSize:
+		ps.entries[key] = e
+		ps.keys = append(ps.keys, key)
+	default:
+		// Reservoir sampling keeps every store equally likely to be sampled.
+		if i := a.rand.Int63n(int64(ps.stores)); i < int64(len(ps.keys)) {
+			delete(ps.entries, ps.keys[i])
+			ps.keys[i] = key
+			ps.entries[key] = e
+		}
+	}
+}
+
+// ObserveHit records that a payload was served from the cache.
+func (a *Advisor) ObserveHit(project, key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ps := a.project(project)
+	ps.hits++
+	if e := ps.entries[key]; e != nil {
+		e.hits++
+		e.lastHit = a.now()
+	}
+}
+
+// ProjectReport summarizes the cache efficiency of a project.
+type ProjectReport struct {
+	Hits     uint64  `json:"hits"`
+	Stores   uint64  `json:"stores"`
+	HitRatio float64 `json:"hit_ratio"`
+
+	SampledEntries int     `json:"sampled_entries"`
+	AverageSize    float64 `json:"average_size"`
+	AverageHits    float64 `json:"average_hits_per_entry"`
+
+	// Share of sampled entries that expired without ever being read.
+	ColdEntryRatio float64 `json:"cold_entry_ratio"`
+
+	// 90th percentile of how long after being stored entries were last read.
+	LastHitAgeP90 string `json:"last_hit_age_p90,omitempty"`
+
+	CurrentTTL      string   `json:"current_ttl,omitempty"`
+	SuggestedTTL    string   `json:"suggested_ttl,omitempty"`
+	Recommendations []string `json:"recommendations"`
+}
+
+// Report analyzes the sampled entries of every project.
+func (a *Advisor) Report() map[string]ProjectReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	report := make(map[string]ProjectReport, len(a.projects))
+	for name, ps := range a.projects {
+		report[name] = ps.report(now)
+	}
+	return report
+}
+
+func (ps *projectSample) report(now time.Time) ProjectReport {
+	r := ProjectReport{
+		Hits:            ps.hits,
+		Stores:          ps.stores,
+		SampledEntries:  len(ps.entries),
+		Recommendations: []string{},
+	}
+	lookups := ps.hits + ps.stores
+	if lookups > 0 {
+		r.HitRatio = float64(ps.hits) / float64(lookups)
+	}
+	if ps.stores > 0 {
+		r.AverageSize = float64(ps.bytes) / float64(ps.stores)
+	}
+
+	// Only entries that have expired show their whole life.
+	var ttl time.Duration
+	var expired, cold int
+	var totalHits uint64
+	var lastHitAges []time.Duration
+	for _, e := range ps.entries {
+		totalHits += e.hits
+		if e.ttl > ttl {
+			ttl = e.ttl
+		}
+		if e.ttl <= 0 || now.Before(e.stored.Add(e.ttl)) {
+			continue
+		}
+		expired++
+		if e.hits == 0 {
+			cold++
+			continue
+		}
+		lastHitAges = append(lastHitAges, e.lastHit.Sub(e.stored))
+	}
+	if len(ps.entries) > 0 {
+		r.AverageHits = float64(totalHits) / float64(len(ps.entries))
+	}
+	if expired > 0 {
+		r.ColdEntryRatio = float64(cold) / float64(expired)
+	}
+	if ttl > 0 {
+		r.CurrentTTL = ttl.String()
+	}
+
+	if lookups >= minLookups && r.HitRatio < lowHitRatio {
+		r.Recommendations = append(r.Recommendations, fmt.Sprintf(
+			"Hit ratio is %.1f%% over %d lookups; caching this project mostly wastes memory. Consider disabling it (CACHE_BACKEND=none).",
+			r.HitRatio*100, lookups))
+	}
+
+	if expired < minExpiredEntries {
+		return r
+	}
+
+	if r.ColdEntryRatio >= 0.8 {
+		r.Recommendations = append(r.Recommendations, fmt.Sprintf(
+			"%.0f%% of entries expire without a single hit; consider prefetching less or caching fewer IDs.",
+			r.ColdEntryRatio*100))
+	}
+	if len(lastHitAges) == 0 {
+		return r
+	}
+
+	sort.Slice(lastHitAges, func(i, j int) bool { return lastHitAges[i] < lastHitAges[j] })
+	p90 := lastHitAges[(len(lastHitAges)*9-1)/10]
+	r.LastHitAgeP90 = p90.Round(time.Second).String()
+
+	switch {
+	case p90 < ttl/4:
+		suggested := (2 * p90).Round(time.Second)
+		if suggested < time.Second {
+			suggested = time.Second
+		}
+		r.SuggestedTTL = suggested.String()
+		r.Recommendations = append(r.Recommendations, fmt.Sprintf(
+			"Entries are rarely read more than %s after being stored; lowering the TTL from %s to %s would free memory without losing hits.",
+			r.LastHitAgeP90, ttl, r.SuggestedTTL))
+	case p90 >= ttl*9/10 && r.ColdEntryRatio < 0.5:
+		r.SuggestedTTL = (2 * ttl).String()
+		r.Recommendations = append(r.Recommendations, fmt.Sprintf(
+			"Entries are still being read when they expire after %s; raising the TTL to %s would avoid refetching them.",
+			ttl, r.SuggestedTTL))
+	}
+	return r
+}
@@ -0,0 +1,94 @@
+package advisor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAdvisor() (*Advisor, *time.Time) {
+	a := New()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	return a, &now
+}
+
+func TestAdvisor_LowHitRatio(t *testing.T) {
+	a, _ := newTestAdvisor()
+	for i := 0; i < 200; i++ {
+		a.ObserveStore("search", fmt.Sprintf("search:%d", i), 100, time.Hour)
+	}
+	a.ObserveHit("search", "search:1")
+
+	r := a.Report()["search"]
+	assert.Equal(t, uint64(1), r.Hits)
+	assert.Equal(t, uint64(200), r.Stores)
+	assert.InDelta(t, 1.0/201, r.HitRatio, 0.0001)
+	assert.Equal(t, defaultSampleSize, a.SampleSize)
+	assert.Equal(t, 200, r.SampledEntries)
+	assert.Len(t, r.Recommendations, 1)
+	assert.Contains(t, r.Recommendations[0], "CACHE_BACKEND=none")
+}
+
+func TestAdvisor_TTLRecommendations(t *testing.T) {
+	t.Run("Lower TTL", func(t *testing.T) {
+		a, now := newTestAdvisor()
+		for i := 0; i < 30; i++ {
+			a.ObserveStore("avatars", fmt.Sprintf("avatars:%d", i), 100, time.Hour)
+		}
+		*now = now.Add(time.Minute)
+		for i := 0; i < 30; i++ {
+			a.ObserveHit("avatars", fmt.Sprintf("avatars:%d", i))
+		}
+		*now = now.Add(2 * time.Hour)
+
+		r := a.Report()["avatars"]
+		assert.Equal(t, "1m0s", r.LastHitAgeP90)
+		assert.Equal(t, "2m0s", r.SuggestedTTL)
+		assert.Equal(t, float64(0), r.ColdEntryRatio)
+		assert.Len(t, r.Recommendations, 1)
+	})
+
+	t.Run("Raise TTL", func(t *testing.T) {
+		a, now := newTestAdvisor()
+		for i := 0; i < 30; i++ {
+			a.ObserveStore("avatars", fmt.Sprintf("avatars:%d", i), 100, time.Minute)
+		}
+		*now = now.Add(59 * time.Second)
+		for i := 0; i < 30; i++ {
+			a.ObserveHit("avatars", fmt.Sprintf("avatars:%d", i))
+		}
+		*now = now.Add(time.Minute)
+
+		r := a.Report()["avatars"]
+		assert.Equal(t, "2m0s", r.SuggestedTTL)
+		assert.Contains(t, r.Recommendations[0], "raising the TTL")
+	})
+
+	t.Run("Cold Entries", func(t *testing.T) {
+		a, now := newTestAdvisor()
+		for i := 0; i < 30; i++ {
+			a.ObserveStore("pages", fmt.Sprintf("pages:%d", i), 100, time.Minute)
+		}
+		*now = now.Add(2 * time.Minute)
+
+		r := a.Report()["pages"]
+		assert.Equal(t, float64(1), r.ColdEntryRatio)
+		assert.Contains(t, r.Recommendations[0], "expire without a single hit")
+	})
+}
+
+func TestAdvisor_SampleIsBounded(t *testing.T) {
+	a, _ := newTestAdvisor()
+	a.SampleSize = 10
+	for i := 0; i < 1000; i++ {
+		a.ObserveStore("avatars", fmt.Sprintf("avatars:%d", i), 1, time.Minute)
+	}
+
+	r := a.Report()["avatars"]
+	assert.Equal(t, 10, r.SampledEntries)
+	assert.Equal(t, uint64(1000), r.Stores)
+	assert.Len(t, a.projects["avatars"].keys, 10)
+}
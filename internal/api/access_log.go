@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Key under which project routes record their project for the access log.
+const accessLogProjectKey = "stratum.project"
+
+// accessLogEntry is a line of the JSON access log.
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Project   string  `json:"project,omitempty"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Bytes     int     `json:"bytes"`
+	Cache     string  `json:"cache,omitempty"`
+	ClientIP  string  `json:"client_ip"`
+	RequestID string  `json:"request_id,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Returns a middleware writing a line per request to out, as JSON or in
+// the format of gin's default logger. It returns nil if out is nil.
+func accessLogMiddleware(format string, out io.Writer) gin.HandlerFunc {
+	if out == nil {
+		return nil
+	}
+	if format == "text" {
+		return gin.LoggerWithConfig(gin.LoggerConfig{Formatter: accessLogFormat, Output: out})
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		if c.Request.URL.RawQuery != "" {
+			path += "?" + c.Request.URL.RawQuery
+		}
+
+		c.Next()
+
+		entry := accessLogEntry{
+			Time:      start.UTC().Format(time.RFC3339Nano),
+			Method:    c.Request.Method,
+			Path:      path,
+			Project:   c.GetString(accessLogProjectKey),
+			Status:    c.Writer.Status(),
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			Bytes:     max(c.Writer.Size(), 0),
+			Cache:     c.Writer.Header().Get("X-Cache-Status"),
+			ClientIP:  c.ClientIP(),
+			RequestID: utils.RequestID(c.Request.Context()),
+			Error:     c.Errors.ByType(gin.ErrorTypePrivate).String(),
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		out.Write(append(line, '\n'))
+	}
+}
+
+// Formats access log lines like gin's default logger, followed by the
+// request ID.
+func accessLogFormat(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	line := fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		param.Path,
+	)
+	if param.Request != nil {
+		if id := utils.RequestID(param.Request.Context()); id != "" {
+			line += " | " + id
+		}
+	}
+	return line + "\n" + param.ErrorMessage
+}
+
+// Returns the writer of an ACCESS_LOG_OUTPUT, or nil for "off". Files are
+// opened on first use and kept across reloads.
+func (s *Server) accessLogOutput(output string) (io.Writer, error) {
+	switch output {
+	case "off":
+		return nil, nil
+	case "", "stdout":
+		return gin.DefaultWriter, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+
+	s.accessLogsMu.Lock()
+	defer s.accessLogsMu.Unlock()
+	if f := s.accessLogs[output]; f != nil {
+		return f, nil
+	}
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open access log: %w", err)
+	}
+	if s.accessLogs == nil {
+		s.accessLogs = make(map[string]*os.File)
+	}
+	s.accessLogs[output] = f
+	return f, nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}, func(p *config.Project) {
+		p.CacheBackend = "memory"
+		p.CacheMemoryMaxEntries = 10
+	})
+	t.Cleanup(func() { s.Close() })
+	path := filepath.Join(t.TempDir(), "access.log")
+	cfg := *s.Config()
+	cfg.AccessLogFormat, cfg.AccessLogOutput = "json", path
+	require.NoError(t, s.Reload(&cfg))
+
+	for _, target := range []string{"/test/1?size=64", "/test/1?size=64", "/health"} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Request-ID", "req-1")
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	require.Len(t, lines, 3)
+
+	var entries []accessLogEntry
+	for _, line := range lines {
+		var entry accessLogEntry
+		require.NoError(t, json.Unmarshal(line, &entry))
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, "GET", entries[0].Method)
+	assert.Equal(t, "/test/1?size=64", entries[0].Path)
+	assert.Equal(t, "test_project", entries[0].Project)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Equal(t, 4, entries[0].Bytes)
+	assert.Equal(t, "MISS", entries[0].Cache)
+	assert.Equal(t, "192.0.2.1", entries[0].ClientIP)
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.Equal(t, "HIT", entries[1].Cache)
+	assert.Empty(t, entries[2].Project, "static routes belong to no project")
+
+	t.Run("Off", func(t *testing.T) {
+		cfg.AccessLogOutput = "off"
+		require.NoError(t, s.Reload(&cfg))
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+		after, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, data, after)
+	})
+}
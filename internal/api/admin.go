@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/cache"
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/database"
+	"github.com/PythonicVarun/Stratum/internal/metrics"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Registers the admin API. The admin API is only enabled when an ADMIN_TOKEN
+// is configured, and every request must present it as a bearer token.
+func (s *Server) setupAdminRoutes(router *gin.RouterGroup, cfg *config.AppConfig) {
+	if cfg.AdminToken == "" {
+		return
+	}
+
+	admin := router.Group("/admin", requireAdminToken(cfg.AdminToken))
+	admin.GET("/stats", s.handleStats)
+	admin.GET("/cache/advisor", s.handleCacheAdvisor)
+	admin.GET("/cache/stats", s.handleCacheStats)
+	admin.POST("/cache/purge", s.handleCachePurge)
+	admin.GET("/config", s.handleConfig)
+	admin.GET("/databases", s.handleDatabases)
+	admin.GET("/databases/pools", s.handleDatabasePools)
+	admin.GET("/projects", s.handleListProjects)
+	admin.PUT("/projects/:name", s.handlePutProject)
+	admin.DELETE("/projects/:name", s.handleDeleteProject)
+}
+
+// Returns a middleware rejecting requests without the configured admin token.
+func requireAdminToken(adminToken string) gin.HandlerFunc {
+	expected := []byte(adminToken)
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Serves per-project payload statistics.
+func (s *Server) handleStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"projects": s.metrics.Snapshot()})
+}
+
+// Serves the cache efficiency report, with TTL recommendations per project.
+func (s *Server) handleCacheAdvisor(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"projects": s.advisor.Report()})
+}
+
+// Serves the health of every database and replica as of its last
+// background check.
+func (s *Server) handleDatabases(c *gin.Context) {
+	statuses := []database.Status{}
+	if s.dbManager != nil {
+		statuses = append(statuses, s.dbManager.Statuses()...)
+	}
+	c.JSON(http.StatusOK, gin.H{"databases": statuses})
+}
+
+// Serves the open database pools, with their connection counts and whether
+// running projects use them.
+func (s *Server) handleDatabasePools(c *gin.Context) {
+	pools := []database.Pool{}
+	if s.dbManager != nil {
+		pools = append(pools, s.dbManager.Pools()...)
+	}
+	c.JSON(http.StatusOK, gin.H{"pools": pools})
+}
+
+// Serves the configuration the server is running with, secrets masked, so
+// operators can check what an instance actually loaded.
+func (s *Server) handleConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, configValue(reflect.ValueOf(s.Config().Redacted())))
+}
+
+// Converts a configuration value into one that reads well as JSON: structs
+// become objects keyed by field name, durations strings such as "1h0m0s".
+func configValue(v reflect.Value) interface{} {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String()
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return configValue(v.Elem())
+	case reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Type().Field(i); field.IsExported() {
+				fields[field.Name] = configValue(v.Field(i))
+			}
+		}
+		return fields
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = configValue(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		items := make(map[string]interface{}, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			items[fmt.Sprint(iter.Key().Interface())] = configValue(iter.Value())
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+// projectCacheStats is the cache usage of a project.
+type projectCacheStats struct {
+	Backend string `json:"backend"`
+	// Only counted when asked for with ?keys=true
+	*cache.KeyStats
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+
+	// Why keys could not be counted, if they could not.
+	Error string `json:"error,omitempty"`
+}
+
+// Serves the hit ratios of every project, along with highlights of the
+// cache backends' own statistics. Hits and misses are counted as requests
+// are served. With ?keys=true, the keys of every project are counted, and
+// their memory estimated, with SCAN, which takes longer the more keys
+// Redis holds.
+func (s *Server) handleCacheStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	s.mu.RLock()
+	cfg, runtimes := s.config, s.runtimes
+	s.mu.RUnlock()
+	snapshot := s.metrics.Snapshot()
+	countKeys := c.Request.URL.Query().Get("keys") == "true"
+
+	projects := make(map[string]projectCacheStats, len(cfg.Projects))
+	backends := make(map[string]map[string]string)
+	if info, err := cacheInfo(ctx, s.cache); err == nil {
+		backends["shared"] = info
+	}
+	for _, p := range cfg.Projects {
+		stats := projectCacheStats{
+			Backend: p.CacheBackend,
+			Hits:    snapshot[p.Name].CacheHits,
+			Misses:  snapshot[p.Name].CacheMisses,
+		}
+		if total := stats.Hits + stats.Misses; total > 0 {
+			stats.HitRatio = float64(stats.Hits) / float64(total)
+		}
+
+		if countKeys {
+			projectCache := s.runtimeCache(runtimes[p.Name])
+			if prefix := cacheKeyPrefix(p); prefix == "" {
+				stats.Error = "CACHE_KEY does not start with a fixed prefix"
+			} else if inspector, ok := projectCache.(cache.Inspector); !ok {
+				stats.Error = cache.ErrNotInspectable.Error()
+			} else if keyStats, err := inspector.KeyStats(ctx, prefix); err != nil {
+				stats.Error = err.Error()
+			} else {
+				stats.KeyStats = &keyStats
+			}
+		}
+		projects[p.Name] = stats
+
+		if runtimes[p.Name].cache != nil {
+			if info, err := cacheInfo(ctx, runtimes[p.Name].cache); err == nil {
+				backends["project:"+p.Name] = info
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"projects": projects, "backends": backends})
+}
+
+func cacheInfo(ctx context.Context, c cache.Cache) (map[string]string, error) {
+	inspector, ok := c.(cache.Inspector)
+	if !ok {
+		return nil, cache.ErrNotInspectable
+	}
+	return inspector.Info(ctx)
+}
+
+// Returns the prefix all cache keys of a project start with, or "" if its
+// cache key template starts with a placeholder.
+func cacheKeyPrefix(p config.Project) string {
+	if p.CacheKeyTemplate == "" {
+		return p.Name + ":"
+	}
+	if loc := config.CacheKeyPlaceholder.FindStringIndex(p.CacheKeyTemplate); loc != nil {
+		return p.CacheKeyTemplate[:loc[0]]
+	}
+	return p.CacheKeyTemplate
+}
+
+// Creates the metrics registry, logging a warning whenever a project's payload
+// sizes shift sharply (often an upstream serving error pages instead of images).
+func newMetricsRegistry(cfg *config.AppConfig) *metrics.Registry {
+	registry := metrics.NewRegistry()
+	registry.SizeAlertRatio = cfg.PayloadSizeAlertRatio
+	registry.OnSizeShift = func(project string, size int, average float64) {
+		utils.StratumLog("WARN", "Payload size shift for project '%s': got %d bytes, average is %.0f bytes.", project, size, average)
+	}
+	return registry
+}
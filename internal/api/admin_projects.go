@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectStore persists projects changed through the admin API, such as a
+// config.Source reading from Consul or etcd.
+type ProjectStore interface {
+	// Replaces the settings of a project, or removes it if settings is nil,
+	// passing the resulting configuration to apply before persisting it.
+	UpdateProject(name string, settings map[string]string, apply func(*config.AppConfig) error) error
+}
+
+// SetProjectStore enables adding, updating and removing projects through
+// the admin API. It must be called before the server starts serving.
+func (s *Server) SetProjectStore(store ProjectStore) {
+	s.projectStore = store
+}
+
+// Serves the projects the server is running with, secrets masked.
+func (s *Server) handleListProjects(c *gin.Context) {
+	projects := s.Config().Redacted().Projects
+	values := make([]interface{}, len(projects))
+	for i := range projects {
+		values[i] = configValue(reflect.ValueOf(projects[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"projects": values})
+}
+
+// Adds or replaces a project. The body is an object of its settings, named
+// as in a configuration file: {"route": "/avatars/{id}", "cache_ttl": "1h"}.
+func (s *Server) handlePutProject(c *gin.Context) {
+	var body map[string]interface{}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the body must be a JSON object of project settings"})
+		return
+	}
+	settings := make(map[string]string, len(body))
+	for key, value := range body {
+		switch v := value.(type) {
+		case string:
+			settings[key] = v
+		case float64:
+			settings[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			settings[key] = strconv.FormatBool(v)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("setting '%s' must be a string, number or boolean; lists are comma-separated", key)})
+			return
+		}
+	}
+	s.updateProject(c, settings)
+}
+
+// Removes a project.
+func (s *Server) handleDeleteProject(c *gin.Context) {
+	s.updateProject(c, nil)
+}
+
+// Applies a change to the project named in the path and persists it to the
+// project store, restoring the previous configuration if it cannot be.
+func (s *Server) updateProject(c *gin.Context, settings map[string]string) {
+	if s.projectStore == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": config.ErrReadOnly.Error()})
+		return
+	}
+	s.projectsMu.Lock()
+	defer s.projectsMu.Unlock()
+
+	name := c.Param("name")
+	previous := s.Config()
+	err := s.projectStore.UpdateProject(name, settings, s.Reload)
+	if err != nil {
+		if s.Config() != previous {
+			if err := s.Reload(previous); err != nil {
+				utils.StratumLog("ERROR", "Failed to restore the configuration: %v", err)
+			}
+		}
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, config.ErrReadOnly):
+			status = http.StatusNotImplemented
+		case errors.Is(err, config.ErrProjectNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, config.ErrStoreUnavailable):
+			status = http.StatusBadGateway
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if settings == nil {
+		utils.StratumLog("INFO", "Removed project '%s' through the admin API.", name)
+		c.Status(http.StatusNoContent)
+		return
+	}
+	utils.StratumLog("INFO", "Updated project '%s' through the admin API.", name)
+	canonical := strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+	for _, p := range s.Config().Redacted().Projects {
+		if p.Name == canonical {
+			c.JSON(http.StatusOK, gin.H{"project": configValue(reflect.ValueOf(p))})
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
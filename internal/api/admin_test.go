@@ -0,0 +1,339 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminStats(t *testing.T) {
+	t.Run("Disabled Without Token", func(t *testing.T) {
+		s := NewServer(&config.AppConfig{}, nil, &mockCache{})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/stats", nil)
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		s := NewServer(&config.AppConfig{AdminToken: "secret"}, nil, &mockCache{})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/stats", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Payload Statistics", func(t *testing.T) {
+		s := NewServer(&config.AppConfig{AdminToken: "secret"}, nil, &mockCache{})
+		s.metrics.ObservePayload("avatars", 2048)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/stats", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Projects map[string]struct {
+				Payloads uint64 `json:"payloads"`
+				Bytes    uint64 `json:"bytes"`
+			} `json:"projects"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, uint64(1), body.Projects["avatars"].Payloads)
+		assert.Equal(t, uint64(2048), body.Projects["avatars"].Bytes)
+	})
+}
+
+func TestAdminConfig(t *testing.T) {
+	s := NewServer(&config.AppConfig{
+		AdminToken: "secret",
+		Projects: []config.Project{{
+			Name:          "avatars",
+			Route:         "/avatars/{id}",
+			IdColumn:      "id",
+			IdPlaceholder: "id",
+			SourceType:    "api",
+			APIEndpoint:   "https://example.com/{id}",
+			APIAuthType:   "bearer",
+			APIAuthSecret: "upstream-token",
+			CacheTTL:      90 * time.Minute,
+		}},
+	}, nil, &mockCache{})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "upstream-token")
+	var body struct {
+		AdminToken string
+		Projects   []map[string]interface{}
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "REDACTED", body.AdminToken)
+	if assert.Len(t, body.Projects, 1) {
+		assert.Equal(t, "/avatars/{id}", body.Projects[0]["Route"])
+		assert.Equal(t, "REDACTED", body.Projects[0]["APIAuthSecret"])
+		assert.Equal(t, "1h30m0s", body.Projects[0]["CacheTTL"])
+	}
+}
+
+func TestAdminCacheAdvisor(t *testing.T) {
+	s := NewServer(&config.AppConfig{AdminToken: "secret"}, nil, &mockCache{})
+	s.advisor.ObserveStore("avatars", "avatars:1", 512, time.Hour)
+	s.advisor.ObserveHit("avatars", "avatars:1")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/cache/advisor", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Projects map[string]struct {
+			Hits     uint64  `json:"hits"`
+			HitRatio float64 `json:"hit_ratio"`
+		} `json:"projects"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, uint64(1), body.Projects["avatars"].Hits)
+	assert.Equal(t, 0.5, body.Projects["avatars"].HitRatio)
+}
+
+func TestAdminDatabases(t *testing.T) {
+	s := NewServer(&config.AppConfig{AdminToken: "secret"}, database.NewConnectionManager(), &mockCache{})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/databases", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"databases": []}`, w.Body.String())
+}
+
+func TestAdminDatabasePools(t *testing.T) {
+	s := NewServer(&config.AppConfig{AdminToken: "secret"}, database.NewConnectionManager(), &mockCache{})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/databases/pools", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"pools": []}`, w.Body.String())
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	s := NewServer(&config.AppConfig{}, nil, &mockCache{})
+	s.metrics.ObservePayload("avatars", 10)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `stratum_payload_size_bytes_count{project="avatars"} 1`)
+}
+
+func TestAdminCacheStats(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("payload"))
+	}))
+	defer upstream.Close()
+
+	project := func(name, route string) config.Project {
+		return config.Project{
+			Name: name, Route: route, IdColumn: "id", IdPlaceholder: "id",
+			SourceType: "api", APIEndpoint: upstream.URL + "/{id}", APIAuthType: "none",
+			CacheTTL: time.Minute, CacheBackend: "memory", CacheMemoryMaxEntries: 10,
+		}
+	}
+	templated := project("templated", "/templated/{id}")
+	templated.CacheKeyTemplate = "{id}:templated"
+	cfg := &config.AppConfig{AdminToken: "secret", Projects: []config.Project{project("avatars", "/avatars/{id}"), templated}}
+	s := NewServer(cfg, nil, &mockCache{})
+	defer s.Close()
+
+	for _, path := range []string{"/avatars/1", "/avatars/2", "/avatars/1"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		s.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Fetches other than requests' are not misses.
+	s.metrics.ObserveUpstreamFetch("avatars", 7, false)
+
+	stats := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := stats("/admin/cache/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"keys"`, "keys are only counted when asked for")
+	assert.NotContains(t, w.Body.String(), "fixed prefix")
+
+	w = stats("/admin/cache/stats?keys=true")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Projects map[string]struct {
+			Backend     string  `json:"backend"`
+			Keys        int64   `json:"keys"`
+			MemoryBytes int64   `json:"memory_bytes"`
+			Hits        uint64  `json:"hits"`
+			Misses      uint64  `json:"misses"`
+			HitRatio    float64 `json:"hit_ratio"`
+			Error       string  `json:"error"`
+		} `json:"projects"`
+		Backends map[string]map[string]string `json:"backends"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+	avatars := body.Projects["avatars"]
+	assert.Equal(t, "memory", avatars.Backend)
+	assert.Equal(t, int64(2), avatars.Keys)
+	assert.Greater(t, avatars.MemoryBytes, int64(0))
+	assert.Equal(t, uint64(1), avatars.Hits)
+	assert.Equal(t, uint64(2), avatars.Misses)
+	assert.InDelta(t, 1.0/3, avatars.HitRatio, 0.001)
+	assert.Equal(t, "2", body.Backends["project:avatars"]["entries"])
+
+	assert.Contains(t, body.Projects["templated"].Error, "fixed prefix")
+}
+
+// A project store keeping variables in memory.
+type memoryProjectStore struct {
+	values    map[string]string
+	failWrite bool
+}
+
+func (m *memoryProjectStore) UpdateProject(name string, settings map[string]string, apply func(*config.AppConfig) error) error {
+	id := strings.ToUpper(name)
+	values := make(map[string]string)
+	found := false
+	for key, value := range m.values {
+		if strings.HasPrefix(key, "PROJECT_"+id+"_") {
+			found = true
+			continue
+		}
+		values[key] = value
+	}
+	if settings == nil && !found {
+		return config.ErrProjectNotFound
+	}
+	for setting, value := range settings {
+		values["PROJECT_"+id+"_"+strings.ToUpper(setting)] = value
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	cfg, err := config.LoadFrom(func(key string) string { return values[key] }, keys...)
+	if err != nil {
+		return err
+	}
+	if err := apply(cfg); err != nil {
+		return err
+	}
+	if m.failWrite {
+		return fmt.Errorf("%w: consul returned 500 Internal Server Error", config.ErrStoreUnavailable)
+	}
+	m.values = values
+	return nil
+}
+
+func TestAdminProjects(t *testing.T) {
+	store := &memoryProjectStore{values: map[string]string{
+		"ADMIN_TOKEN":                  "secret",
+		"PROJECT_AVATARS_ROUTE":        "/avatars/{id}",
+		"PROJECT_AVATARS_SOURCE_TYPE":  "api",
+		"PROJECT_AVATARS_ID_COLUMN":    "id",
+		"PROJECT_AVATARS_API_ENDPOINT": "https://example.com/{id}",
+	}}
+	cfg, err := config.LoadFrom(func(key string) string { return store.values[key] }, "ADMIN_TOKEN", "PROJECT_AVATARS_ROUTE")
+	assert.NoError(t, err)
+	s := NewServer(cfg, nil, &mockCache{})
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		s.ServeHTTP(w, req)
+		return w
+	}
+	docs := `{"route": "/docs/{id}", "source_type": "api", "id_column": "id", "api_endpoint": "https://docs.example.com/{id}", "cache_ttl_seconds": 60}`
+
+	t.Run("Not Configured", func(t *testing.T) {
+		w := send("PUT", "/admin/projects/docs", docs)
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+
+	s.SetProjectStore(store)
+
+	t.Run("Add", func(t *testing.T) {
+		w := send("PUT", "/admin/projects/docs", docs)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"CacheTTL":"1m0s"`)
+		assert.Equal(t, "60", store.values["PROJECT_DOCS_CACHE_TTL_SECONDS"])
+
+		w = send("GET", "/admin/projects", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"/docs/{id}"`)
+
+		// The route is served right away.
+		w = send("GET", "/docs/1", "")
+		assert.NotEqual(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid Settings", func(t *testing.T) {
+		w := send("PUT", "/admin/projects/docs", `{"route": "/docs/{id}", "source_type": "ftp"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown SOURCE_TYPE")
+
+		w = send("PUT", "/admin/projects/docs", `{"route": ["/docs/{id}"]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "comma-separated")
+	})
+
+	t.Run("Write Failure Restores Configuration", func(t *testing.T) {
+		store.failWrite = true
+		defer func() { store.failWrite = false }()
+		previous := s.Config()
+
+		w := send("DELETE", "/admin/projects/docs", "")
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Same(t, previous, s.Config())
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		w := send("DELETE", "/admin/projects/docs", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Len(t, s.Config().Projects, 1)
+
+		w = send("GET", "/docs/1", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = send("DELETE", "/admin/projects/docs", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/PythonicVarun/Stratum/internal/cache"
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// Returns the identity of the backend a project caches in. Projects with
+// the same backend share its connections, and a SOURCE hook may only hand
+// a request to a project caching in the same backend.
+func cacheBackendKey(p config.Project) string {
+	switch p.CacheBackend {
+	case "redis":
+		return "redis|" + p.CacheRedisURL
+	case "memory":
+		// Every project gets its own LRU, so one cannot evict another's keys.
+		return fmt.Sprintf("memory|%s|%d", p.Name, p.CacheMemoryMaxEntries)
+	case "none":
+		return "none"
+	}
+	return "shared"
+}
+
+// Returns the cache backend of a project, opening it on first use, or nil
+// for projects using the shared cache. Backends are kept across reloads, so
+// a reload neither reconnects to Redis nor drops in-memory entries.
+func (s *Server) projectCache(cfg *config.AppConfig, p config.Project) (cache.Cache, error) {
+	key := cacheBackendKey(p)
+	if key == "shared" {
+		return nil, nil
+	}
+
+	s.cachesMu.Lock()
+	defer s.cachesMu.Unlock()
+	if backend := s.caches[key]; backend != nil {
+		return backend, nil
+	}
+	backend, err := openCacheBackend(cfg, p)
+	if err != nil {
+		return nil, err
+	}
+	if s.caches == nil {
+		s.caches = make(map[string]cache.Cache)
+	}
+	s.caches[key] = backend
+	return backend, nil
+}
+
+func openCacheBackend(cfg *config.AppConfig, p config.Project) (cache.Cache, error) {
+	switch p.CacheBackend {
+	case "memory":
+		return cache.NewMemoryCache(p.CacheMemoryMaxEntries, p.CacheMemoryMaxBytes), nil
+	case "none":
+		return &cache.NoOpCache{}, nil
+	}
+
+	// Like the shared cache, an unreachable Redis disables caching for the
+	// project rather than failing startup.
+	redisCache, err := cache.NewRedisCache(p.CacheRedisURL, cfg.Redis)
+	if err != nil {
+		utils.StratumLog("WARN", "Could not connect to the Redis cache of project '%s', caching is disabled for it: %v", p.Name, err)
+		return &cache.NoOpCache{}, nil
+	}
+	redisCache = cache.NewPrefixedCache(redisCache, cfg.CacheKeyPrefix)
+	redisCache, err = cache.NewCompressedCache(redisCache, cfg.CacheCompression, cfg.CacheCompressionMinBytes)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CacheAsyncWrites {
+		redisCache = cache.NewAsyncCache(redisCache, cfg.CacheWriteWorkers, cfg.CacheWriteQueueSize)
+	}
+	return redisCache, nil
+}
+
+// Returns the cache of a running project, with its namespace applied.
+// Projects that are not (or no longer) configured use the shared cache.
+func (s *Server) cacheFor(project string) cache.Cache {
+	s.mu.RLock()
+	rt := s.runtimes[project]
+	s.mu.RUnlock()
+	if rt == nil {
+		return s.cache
+	}
+	return s.runtimeCache(rt)
+}
+
+func (s *Server) runtimeCache(rt *projectRuntime) cache.Cache {
+	backend := rt.cache
+	if backend == nil {
+		backend = s.cache
+	}
+	return cache.NewPrefixedCache(backend, rt.project.CacheNamespace)
+}
+
+// Close closes the cache backends opened for individual projects, and the
+// access log files. The shared cache is owned, and closed, by the caller of
+// NewServer.
+func (s *Server) Close() error {
+	s.cachesMu.Lock()
+	defer s.cachesMu.Unlock()
+
+	var firstErr error
+	for key, c := range s.caches {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.caches, key)
+	}
+
+	s.accessLogsMu.Lock()
+	defer s.accessLogsMu.Unlock()
+	for path, f := range s.accessLogs {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.accessLogs, path)
+	}
+	return firstErr
+}
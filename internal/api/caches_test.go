@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/hooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectCacheBackends(t *testing.T) {
+	newServer := func(backend, namespace string) (*Server, map[string][]byte) {
+		s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("secret"))
+		}, func(p *config.Project) {
+			p.CacheBackend = backend
+			p.CacheMemoryMaxEntries = 10
+			p.CacheNamespace = namespace
+		})
+		shared := make(map[string][]byte)
+		s.cache = &mockCache{SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+			shared[key] = value
+			return nil
+		}}
+		t.Cleanup(func() { s.Close() })
+		return s, shared
+	}
+	get := func(s *Server) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		s.router.ServeHTTP(w, req)
+		return w.Header().Get("X-Cache-Status")
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		s, shared := newServer("memory", "")
+		assert.Equal(t, "MISS", get(s))
+		assert.Equal(t, "HIT", get(s))
+		assert.Empty(t, shared, "nothing is written to the shared cache")
+	})
+
+	t.Run("None", func(t *testing.T) {
+		s, shared := newServer("none", "")
+		assert.Equal(t, "MISS", get(s))
+		assert.Equal(t, "MISS", get(s))
+		assert.Empty(t, shared)
+	})
+
+	t.Run("Shared With Namespace", func(t *testing.T) {
+		s, shared := newServer("shared", "private:")
+		get(s)
+		assert.Equal(t, []byte("secret"), decodeCacheEntry(shared["private:test_project:1"]).Data)
+	})
+
+	t.Run("Kept Across Reloads", func(t *testing.T) {
+		s, _ := newServer("memory", "")
+		get(s)
+		assert.NoError(t, s.Reload(s.Config()))
+		assert.Equal(t, "HIT", get(s))
+	})
+}
+
+func TestCreateHandler_SourceHookAcrossCacheBackends(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer upstream.Close()
+
+	project := func(name, route string) config.Project {
+		return config.Project{
+			Name: name, Route: route, IdColumn: "id", IdPlaceholder: "id",
+			SourceType: "api", APIEndpoint: upstream.URL + "/{id}", APIAuthType: "none",
+		}
+	}
+	public := project("public", "/public/{id}")
+	public.Hooks = hooks.Spec{Source: `"private"`}
+	private := project("private", "/private/{id}")
+	private.CacheBackend = "none"
+	s := NewServer(&config.AppConfig{Projects: []config.Project{public, private}}, nil, &mockCache{})
+	defer s.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/public/1", nil)
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "payloads must not leave their cache backend")
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Base URLs of the CDN purge APIs.
+var (
+	fastlyAPI     = "https://api.fastly.com"
+	cloudflareAPI = "https://api.cloudflare.com/client/v4"
+)
+
+var cdnClient = &http.Client{Timeout: 10 * time.Second}
+
+// Returns the surrogate keys of a payload. Keys naming the ID are left out
+// for routes without one.
+func surrogateKeysFor(p config.Project, idValue string, params datasource.Params) []string {
+	keys := make([]string, 0, len(p.SurrogateKeys))
+	for _, template := range p.SurrogateKeys {
+		if idValue == "" && strings.Contains(template, "{id}") {
+			continue
+		}
+		keys = append(keys, expandKeyTemplate(template, p, idValue, params))
+	}
+	return keys
+}
+
+// Returns the surrogate keys naming a single payload, those with the ID,
+// which are purged from the CDN when its cache entry is invalidated. Keys
+// shared by many payloads, such as the project's, are left for manual
+// purges.
+func entrySurrogateKeys(p config.Project, idValue string, params datasource.Params) []string {
+	var keys []string
+	for _, template := range p.SurrogateKeys {
+		if idValue != "" && strings.Contains(template, "{id}") {
+			keys = append(keys, expandKeyTemplate(template, p, idValue, params))
+		}
+	}
+	return keys
+}
+
+// Sends the surrogate keys of a payload in header. Cloudflare's Cache-Tag
+// is comma-separated, Fastly's Surrogate-Key space-separated.
+func setSurrogateKeys(c *gin.Context, header string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	separator := " "
+	if strings.EqualFold(header, "Cache-Tag") {
+		separator = ","
+	}
+	c.Header(header, strings.Join(keys, separator))
+}
+
+// Purges the payloads the CDN cached under any of keys.
+func purgeCDN(ctx context.Context, purge config.CDNPurgeConfig, keys []string) error {
+	if purge.Provider == "" || len(keys) == 0 {
+		return nil
+	}
+
+	var endpoint string
+	var body interface{}
+	header := make(http.Header)
+	switch purge.Provider {
+	case "fastly":
+		endpoint = fastlyAPI + "/service/" + url.PathEscape(purge.Service) + "/purge"
+		body = map[string][]string{"surrogate_keys": keys}
+		header.Set("Fastly-Key", purge.Token)
+	case "cloudflare":
+		endpoint = cloudflareAPI + "/zones/" + url.PathEscape(purge.Service) + "/purge_cache"
+		body = map[string][]string{"tags": keys}
+		header.Set("Authorization", "Bearer "+purge.Token)
+	default:
+		return fmt.Errorf("unknown CDN '%s'", purge.Provider)
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cdnClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", purge.Provider, resp.Status)
+	}
+	return nil
+}
+
+// Purges surrogate keys from the CDN, if one is configured, logging
+// failures.
+func (s *Server) purgeCDN(ctx context.Context, keys []string) error {
+	purge := s.Config().CDNPurge
+	if err := purgeCDN(ctx, purge, keys); err != nil {
+		utils.StratumLogContext(ctx, "ERROR", "CDN purge of %s failed: %v", strings.Join(keys, ", "), err)
+		return err
+	}
+	if purge.Provider != "" && len(keys) > 0 {
+		utils.StratumLogContext(ctx, "INFO", "CDN PURGE: Purged %s from %s.", strings.Join(keys, ", "), purge.Provider)
+	}
+	return nil
+}
+
+// Purges the cached payload of an ID from Stratum's cache and the CDN. The
+// body names the project and the ID as it appears in URLs:
+// {"project": "avatars", "id": "123"}. Only the entry without forwarded
+// query parameters or headers is removed from Stratum's cache, while the
+// CDN purges every variant tagged with the ID's surrogate keys.
+func (s *Server) handleCachePurge(c *gin.Context) {
+	var body struct {
+		Project string `json:"project"`
+		ID      string `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Project == "" || body.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a project and an id are required"})
+		return
+	}
+
+	s.mu.RLock()
+	rt := s.runtimes[body.Project]
+	s.mu.RUnlock()
+	if rt == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("unknown project '%s'", body.Project)})
+		return
+	}
+	p, idValue := rt.project, body.ID
+	if rt.codec != nil {
+		decoded, err := rt.codec.Decode(idValue)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		idValue = decoded
+	}
+
+	ctx := c.Request.Context()
+	key := cacheKeyFor(p, idValue, datasource.Params{})
+	projectCache := s.runtimeCache(rt)
+	for _, k := range []string{key, validatorsKey(key), staleKey(key)} {
+		if err := projectCache.Delete(ctx, k); err != nil {
+			utils.StratumLogContext(ctx, "ERROR", "Failed to purge key '%s': %v", k, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	s.originsMu.Lock()
+	delete(s.origins, key)
+	s.originsMu.Unlock()
+	utils.StratumLogContext(ctx, "INFO", "PURGE: Removed '%s' from the cache.", key)
+
+	keys := entrySurrogateKeys(p, idValue, datasource.Params{})
+	if err := s.purgeCDN(ctx, keys); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"key": key, "error": "CDN purge failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"key": key, "surrogate_keys": keys})
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurrogateKeys(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("avatar"))
+	}, func(p *config.Project) {
+		p.SurrogateKeys = []string{"project:{project}", "id:{id}"}
+	})
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/42", nil)
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Empty(t, get().Header().Get("Surrogate-Key"), "no keys are sent without SURROGATE_KEY_HEADER")
+
+	cfg := *s.Config()
+	cfg.SurrogateKeyHeader = "Surrogate-Key"
+	assert.NoError(t, s.Reload(&cfg))
+	assert.Equal(t, "project:test_project id:42", get().Header().Get("Surrogate-Key"))
+
+	cfg.SurrogateKeyHeader = "Cache-Tag"
+	assert.NoError(t, s.Reload(&cfg))
+	assert.Equal(t, "project:test_project,id:42", get().Header().Get("Cache-Tag"))
+}
+
+func TestCachePurge(t *testing.T) {
+	var purged []string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/zones/zone123/purge_cache", r.URL.Path)
+		assert.Equal(t, "Bearer cf-token", r.Header.Get("Authorization"))
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		purged = append(purged, body.Tags...)
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer cdn.Close()
+	defer func(api string) { cloudflareAPI = api }(cloudflareAPI)
+	cloudflareAPI = cdn.URL
+
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("avatar"))
+	}, func(p *config.Project) {
+		p.CacheBackend = "memory"
+		p.CacheMemoryMaxEntries = 10
+		p.SurrogateKeys = []string{"project:{project}", "avatar-{id}"}
+	})
+	defer s.Close()
+	cfg := *s.Config()
+	cfg.AdminToken = "secret"
+	cfg.CDNPurge = config.CDNPurgeConfig{Provider: "cloudflare", Service: "zone123", Token: "cf-token"}
+	assert.NoError(t, s.Reload(&cfg))
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		s.ServeHTTP(w, req)
+		return w
+	}
+	send("GET", "/test/7", "")
+	assert.Equal(t, "HIT", send("GET", "/test/7", "").Header().Get("X-Cache-Status"))
+
+	w := send("POST", "/admin/cache/purge", `{"project": "test_project", "id": "7"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{"avatar-7"}, purged, "project-wide keys are not purged for one ID")
+	assert.Equal(t, "MISS", send("GET", "/test/7", "").Header().Get("X-Cache-Status"))
+
+	w = send("POST", "/admin/cache/purge", `{"project": "missing", "id": "7"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send("POST", "/admin/cache/purge", `{"project": "test_project"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPurgeCDN_Fastly(t *testing.T) {
+	fastly := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/service/svc1/purge", r.URL.Path)
+		if r.Header.Get("Fastly-Key") != "fastly-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			SurrogateKeys []string `json:"surrogate_keys"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, []string{"id:1", "id:2"}, body.SurrogateKeys)
+	}))
+	defer fastly.Close()
+	defer func(api string) { fastlyAPI = api }(fastlyAPI)
+	fastlyAPI = fastly.URL
+
+	purge := config.CDNPurgeConfig{Provider: "fastly", Service: "svc1", Token: "fastly-token"}
+	assert.NoError(t, purgeCDN(context.Background(), purge, []string{"id:1", "id:2"}))
+
+	purge.Token = "wrong"
+	assert.ErrorContains(t, purgeCDN(context.Background(), purge, []string{"id:1", "id:2"}), "fastly returned 401 Unauthorized")
+}
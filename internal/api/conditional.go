@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// validatedEntry is an expired payload together with the validators its
+// origin returned. The validators are stored under their own key, and the
+// payload is the cache entry itself, kept past its expiry for the project's
+// conditional revalidation window, so an expired entry can be refreshed
+// with a 304.
+type validatedEntry struct {
+	Origin *datasource.Origin
+	Data   []byte
+}
+
+func validatorsKey(cacheKey string) string {
+	return cacheKey + "|validators"
+}
+
+// Returns the origin to revalidate against, or nil for an unconditional fetch.
+func (e *validatedEntry) origin() *datasource.Origin {
+	if e == nil {
+		return nil
+	}
+	return e.Origin
+}
+
+// Loads the validators stored for a cache key and the payload they
+// validate, or nil if either is gone.
+func (s *Server) loadValidators(ctx context.Context, p config.Project, cacheKey string) *validatedEntry {
+	raw, err := s.cacheFor(p.Name).Get(ctx, validatorsKey(cacheKey))
+	if err != nil || raw == nil {
+		return nil
+	}
+	var origin datasource.Origin
+	if err := json.Unmarshal(raw, &origin); err != nil {
+		return nil
+	}
+	entry, err := s.loadKeptEntry(ctx, p, cacheKey)
+	if err != nil || entry == nil {
+		return nil
+	}
+	return &validatedEntry{Origin: &origin, Data: entry.Data}
+}
+
+// Stores the validators of a freshly cached payload. Origins without an ETag
+// or Last-Modified cannot answer conditional requests and are skipped.
+func (s *Server) storeValidators(ctx context.Context, p config.Project, cacheKey string, origin *datasource.Origin) {
+	if !revalidatable(origin) {
+		return
+	}
+	raw, err := json.Marshal(origin)
+	if err != nil {
+		return
+	}
+	if err := s.cacheFor(p.Name).Set(ctx, validatorsKey(cacheKey), raw, p.CacheTTL+p.ConditionalRevalidation); err != nil {
+		utils.StratumLogContext(ctx, "ERROR", "Failed to store validators for key '%s': %v", cacheKey, err)
+	}
+}
+
+// Reports whether an origin can answer conditional requests.
+func revalidatable(origin *datasource.Origin) bool {
+	return origin != nil && (origin.ETag != "" || origin.LastModified != "")
+}
+
+// Returns how long to store a fresh cache entry for. Entries that can be
+// revalidated are kept for the project's conditional revalidation window
+// after they expire, which is then recorded in the entry.
+func entryTTL(p config.Project, entry *cacheEntry, conditional bool, origin *datasource.Origin) time.Duration {
+	if !conditional || !revalidatable(origin) || p.CacheTTL <= 0 {
+		return p.CacheTTL
+	}
+	entry.ExpiresAt = entry.FetchedAt.Add(p.CacheTTL)
+	return p.CacheTTL + p.ConditionalRevalidation
+}
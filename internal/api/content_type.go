@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Sniffs a payload and reports whether it is compatible with the project's
+// configured content type. It always succeeds when the policy is off or no
+// content type is configured.
+func checkContentType(p config.Project, data []byte) (string, bool) {
+	if p.ContentTypePolicy == "" || p.ContentTypePolicy == "off" || p.ContentType == "" {
+		return "", true
+	}
+	sniffed := http.DetectContentType(data)
+	return sniffed, contentTypeMatches(p.ContentType, sniffed)
+}
+
+// Checks a freshly fetched entry against the project's configured content
+// type. A mismatching entry is flagged, so the mismatch is reported on cache
+// hits as well, or rejected with errContentTypeMismatch under the reject
+// policy.
+func (s *Server) checkEntryContentType(ctx context.Context, p config.Project, cacheKey string, entry *cacheEntry) error {
+	sniffed, ok := checkContentType(p, entry.Data)
+	if ok {
+		return nil
+	}
+	utils.StratumLogContext(ctx, "WARN", "Content type mismatch for key '%s': expected '%s', payload looks like '%s'.", cacheKey, p.ContentType, sniffed)
+	if p.ContentTypePolicy == "reject" {
+		s.metrics.ObserveContentTypeMismatch(p.Name)
+		return fmt.Errorf("%w: expected '%s', got '%s'", errContentTypeMismatch, p.ContentType, sniffed)
+	}
+	entry.ContentTypeMismatch = sniffed
+	return nil
+}
+
+// Reports a flagged entry with an X-Content-Type-Mismatch header, whether it
+// was fetched for this response or served from the cache.
+func (s *Server) flagContentType(c *gin.Context, p config.Project, entry *cacheEntry) {
+	if entry.ContentTypeMismatch == "" {
+		return
+	}
+	c.Header("X-Content-Type-Mismatch", entry.ContentTypeMismatch)
+	s.metrics.ObserveContentTypeMismatch(p.Name)
+}
+
+// Compares a configured content type with one detected by
+// http.DetectContentType. The sniffer only knows a limited set of types, so
+// unrecognized binary data and plain text are treated as compatible with any
+// binary or textual type respectively.
+func contentTypeMatches(configured, sniffed string) bool {
+	want, _, err := mime.ParseMediaType(configured)
+	if err != nil {
+		return false
+	}
+	got, _, err := mime.ParseMediaType(sniffed)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case want == got:
+		return true
+	case got == "application/octet-stream":
+		return !isTextual(want)
+	case got == "text/plain" || got == "text/xml":
+		return isTextual(want)
+	}
+	return false
+}
+
+func isTextual(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-ndjson", "application/yaml":
+		return true
+	}
+	return false
+}
+
+// Sets the charset parameter of a textual content type to utf-8. Other
+// content types are returned unchanged.
+func withUTF8Charset(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !isTextual(mediaType) {
+		return contentType
+	}
+	params["charset"] = "utf-8"
+	return mime.FormatMediaType(mediaType, params)
+}
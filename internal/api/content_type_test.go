@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/cache"
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentTypeMatches(t *testing.T) {
+	testCases := []struct {
+		name       string
+		configured string
+		sniffed    string
+		expected   bool
+	}{
+		{"Exact match", "image/png", "image/png", true},
+		{"Parameters ignored", "text/html; charset=utf-8", "text/html; charset=utf-8", true},
+		{"JSON sniffed as text", "application/json", "text/plain; charset=utf-8", true},
+		{"SVG sniffed as XML", "image/svg+xml", "text/xml; charset=utf-8", true},
+		{"Unknown binary", "application/cbor", "application/octet-stream", true},
+		{"Error page instead of image", "image/png", "text/html; charset=utf-8", false},
+		{"JPEG instead of PNG", "image/png", "image/jpeg", false},
+		{"Binary instead of JSON", "application/json", "application/octet-stream", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, contentTypeMatches(tc.configured, tc.sniffed))
+		})
+	}
+}
+
+func TestContentTypePolicy(t *testing.T) {
+	errorPage := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<!DOCTYPE html><html><body>Service Unavailable</body></html>"))
+	}
+
+	t.Run("Reject", func(t *testing.T) {
+		cacheWrites := 0
+		s := newAPIProjectServer(t, errorPage, func(p *config.Project) {
+			p.ContentType = "image/png"
+			p.ContentTypePolicy = "reject"
+		})
+		s.cache = &mockCache{SetFunc: func(_ context.Context, _ string, _ []byte, _ time.Duration) error {
+			cacheWrites++
+			return nil
+		}}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Equal(t, 0, cacheWrites)
+		assert.Equal(t, uint64(1), s.metrics.Snapshot()["test_project"].ContentTypeMismatches)
+	})
+
+	t.Run("Flag", func(t *testing.T) {
+		s := newAPIProjectServer(t, errorPage, func(p *config.Project) {
+			p.ContentType = "image/png"
+			p.ContentTypePolicy = "flag"
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("X-Content-Type-Mismatch"))
+		assert.Equal(t, uint64(1), s.metrics.Snapshot()["test_project"].ContentTypeMismatches)
+
+		// Hits report the mismatch stored with the entry.
+		s.cache = cache.NewMemoryCache(10, 0)
+		for _, status := range []string{"MISS", "HIT"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test/2", nil)
+			s.router.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Header().Get("X-Cache-Status"))
+			assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("X-Content-Type-Mismatch"), status)
+		}
+		assert.Equal(t, uint64(3), s.metrics.Snapshot()["test_project"].ContentTypeMismatches)
+	})
+}
+
+func TestSourceCharset(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Cr\xe8me br\xfbl\xe9e"))
+	}, func(p *config.Project) {
+		p.ContentType = "text/plain; charset=iso-8859-1"
+		p.SourceCharset = "latin1"
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Crème brûlée", w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+
+	assert.Equal(t, "image/png", withUTF8Charset("image/png"))
+	assert.Equal(t, "application/json; charset=utf-8", withUTF8Charset("application/json"))
+}
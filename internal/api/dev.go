@@ -0,0 +1,228 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PythonicVarun/Stratum/internal/cache"
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/database"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+)
+
+//go:embed dev.html
+var devPage []byte
+
+// NewDevServer creates a server that additionally serves a local UI under
+// /_dev for editing project definitions live and test-fetching them.
+func NewDevServer(cfg *config.AppConfig, dbManager *database.ConnectionManager, cache cache.Cache, opts ...Option) *Server {
+	return newServer(cfg, dbManager, cache, true, opts)
+}
+
+// Registers the development UI and its JSON API. They are served to local
+// clients, and to others presenting the admin token if one is configured.
+func (s *Server) setupDevRoutes(router *gin.RouterGroup, cfg *config.AppConfig) {
+	// A mock upstream API sources can point at, e.g.
+	// PROJECT_1_API_ENDPOINT=http://localhost:8080/_dev/mock/{id}
+	router.GET("/_dev/mock/*id", handleDevMock)
+
+	dev := router.Group("/_dev", requireLocalOrAdminToken(cfg.AdminToken))
+	dev.GET("", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", devPage)
+	})
+	dev.GET("/api/config", s.handleDevGetConfig)
+	dev.PUT("/api/config", s.handleDevApplyConfig)
+	dev.POST("/api/fetch", s.handleDevFetch)
+}
+
+// Returns a middleware letting through requests from the loopback
+// interface, and others presenting the admin token if one is configured.
+// Requests relayed by a proxy are not local, whatever their address.
+func requireLocalOrAdminToken(adminToken string) gin.HandlerFunc {
+	checkToken := requireAdminToken(adminToken)
+	return func(c *gin.Context) {
+		if isLocalRequest(c.Request) {
+			c.Next()
+			return
+		}
+		if adminToken == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "the development UI is only served to local clients"})
+			return
+		}
+		checkToken(c)
+	}
+}
+
+// Reports whether a request came straight from the loopback interface.
+func isLocalRequest(req *http.Request) bool {
+	if req.Header.Get("X-Forwarded-For") != "" || req.Header.Get("Forwarded") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Returns the project definitions currently applied, in .env format, with
+// their secrets masked.
+func (s *Server) handleDevGetConfig(c *gin.Context) {
+	vars, err := godotenv.Unmarshal(s.currentDevEnv())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for key, value := range vars {
+		vars[key] = config.RedactedVariable(key, value)
+	}
+	c.JSON(http.StatusOK, gin.H{"env": formatEnv(vars), "projects": s.Config().Redacted().Projects})
+}
+
+// Returns the project definitions currently applied, in .env format.
+func (s *Server) currentDevEnv() string {
+	s.mu.RLock()
+	env := s.devEnv
+	s.mu.RUnlock()
+
+	if env == "" {
+		env = projectEnvFromEnvironment()
+	}
+	return env
+}
+
+// Parses project definitions in .env format and applies them in memory.
+// Global settings that are not part of the definitions are taken from the
+// process environment. Secrets left masked as handleDevGetConfig returned
+// them keep their current values.
+func (s *Server) handleDevApplyConfig(c *gin.Context) {
+	var body struct {
+		Env string `json:"env"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	vars, err := godotenv.Unmarshal(body.Env)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid .env syntax: %v", err)})
+		return
+	}
+	if current, err := godotenv.Unmarshal(s.currentDevEnv()); err == nil {
+		for key, value := range vars {
+			if previous, ok := current[key]; ok && value != previous && value == config.RedactedVariable(key, previous) {
+				vars[key] = previous
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	cfg, err := config.LoadFrom(func(key string) string {
+		if value, ok := vars[key]; ok {
+			return value
+		}
+		if strings.HasPrefix(key, "PROJECT_") {
+			return ""
+		}
+		return os.Getenv(key)
+	}, keys...)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.Reload(cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	s.devEnv = formatEnv(vars)
+	s.mu.Unlock()
+
+	utils.StratumLog("INFO", "DEV: Applied %d project(s).", len(cfg.Projects))
+	c.JSON(http.StatusOK, gin.H{"projects": cfg.Redacted().Projects})
+}
+
+// Issues a GET request against the live router and reports the response.
+func (s *Server) handleDevFetch(c *gin.Context) {
+	var body struct {
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || !strings.HasPrefix(body.Path, "/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a path starting with '/' is required"})
+		return
+	}
+
+	req := httptest.NewRequest(http.MethodGet, body.Path, nil)
+	for name, value := range body.Headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	result := gin.H{
+		"status":  w.Code,
+		"headers": w.Header(),
+		"size":    w.Body.Len(),
+	}
+	if utf8.Valid(w.Body.Bytes()) {
+		result["body"] = w.Body.String()
+	} else {
+		result["body_base64"] = base64.StdEncoding.EncodeToString(w.Body.Bytes())
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Serves a placeholder SVG labelled with the requested ID.
+func handleDevMock(c *gin.Context) {
+	id := strings.TrimPrefix(c.Param("id"), "/")
+	if id == "404" {
+		c.String(http.StatusNotFound, "Not Found")
+		return
+	}
+
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="128" height="128">`+
+		`<rect width="128" height="128" fill="#ddd"/>`+
+		`<text x="64" y="70" font-family="sans-serif" font-size="16" text-anchor="middle">%s</text></svg>`, html.EscapeString(id))
+	c.Data(http.StatusOK, "image/svg+xml", []byte(svg))
+}
+
+// Collects PROJECT_* variables from the process environment in .env format.
+func projectEnvFromEnvironment() string {
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "PROJECT_") {
+			key, value, _ := strings.Cut(kv, "=")
+			vars[key] = value
+		}
+	}
+	return formatEnv(vars)
+}
+
+// Formats variables in .env format, ordered by name.
+func formatEnv(vars map[string]string) string {
+	lines := make([]string, 0, len(vars))
+	for key, value := range vars {
+		lines = append(lines, fmt.Sprintf("%s=%q", key, value))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
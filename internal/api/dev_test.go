@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevMode(t *testing.T) {
+	s := NewDevServer(&config.AppConfig{}, nil, &mockCache{})
+	upstream := httptest.NewServer(s)
+	defer upstream.Close()
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "127.0.0.1:52100"
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Not Mounted Outside Dev Mode", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/_dev", nil)
+		NewServer(&config.AppConfig{}, nil, &mockCache{}).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Apply And Fetch Against Mock Upstream", func(t *testing.T) {
+		env := "PROJECT_1_SOURCE_TYPE=api\n" +
+			"PROJECT_1_ROUTE=/avatars/{id}\n" +
+			"PROJECT_1_ID_COLUMN=id\n" +
+			"PROJECT_1_CONTENT_TYPE=image/svg+xml\n" +
+			"PROJECT_1_API_ENDPOINT=" + upstream.URL + "/_dev/mock/{id}\n"
+
+		w := do("PUT", "/_dev/api/config", map[string]string{"env": env})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, s.Config().Projects, 1)
+
+		w = do("POST", "/_dev/api/fetch", map[string]string{"path": "/avatars/alice"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var result struct {
+			Status int    `json:"status"`
+			Body   string `json:"body"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, http.StatusOK, result.Status)
+		assert.Contains(t, result.Body, ">alice</text>")
+
+		w = do("GET", "/_dev/api/config", nil)
+		assert.Contains(t, w.Body.String(), `PROJECT_1_ROUTE=\"/avatars/{id}\"`)
+	})
+
+	t.Run("Only Served To Local Clients", func(t *testing.T) {
+		for _, remote := range []string{"203.0.113.7:52100", "[::1]:52100"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/_dev/api/config", nil)
+			req.RemoteAddr = remote
+			s.ServeHTTP(w, req)
+			if remote == "[::1]:52100" {
+				assert.Equal(t, http.StatusOK, w.Code)
+			} else {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		}
+
+		// Relayed by a local proxy
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/_dev/api/config", nil)
+		req.RemoteAddr = "127.0.0.1:52100"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		s.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Remote Clients Need The Admin Token", func(t *testing.T) {
+		remote := NewDevServer(&config.AppConfig{AdminToken: "admin-token"}, nil, &mockCache{})
+		for token, status := range map[string]int{"": http.StatusUnauthorized, "admin-token": http.StatusOK} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/_dev/api/config", nil)
+			req.RemoteAddr = "203.0.113.7:52100"
+			req.Header.Set("Authorization", "Bearer "+token)
+			remote.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code)
+		}
+	})
+
+	t.Run("Secrets Are Masked And Kept", func(t *testing.T) {
+		env := "PROJECT_1_SOURCE_TYPE=api\n" +
+			"PROJECT_1_ROUTE=/avatars/{id}\n" +
+			"PROJECT_1_ID_COLUMN=id\n" +
+			"PROJECT_1_CONTENT_TYPE=image/svg+xml\n" +
+			"PROJECT_1_API_ENDPOINT=" + upstream.URL + "/_dev/mock/{id}\n" +
+			"PROJECT_1_API_AUTH_TYPE=bearer\n" +
+			"PROJECT_1_API_AUTH_SECRET=s3cret\n"
+		w := do("PUT", "/_dev/api/config", map[string]string{"env": env})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "s3cret")
+
+		w = do("GET", "/_dev/api/config", nil)
+		assert.NotContains(t, w.Body.String(), "s3cret")
+		var current struct {
+			Env string `json:"env"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &current))
+		assert.Contains(t, current.Env, `PROJECT_1_API_AUTH_SECRET="REDACTED"`)
+
+		// Applying the masked definitions back keeps the secret.
+		w = do("PUT", "/_dev/api/config", map[string]string{"env": current.Env})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "s3cret", s.Config().Projects[0].APIAuthSecret)
+	})
+
+	t.Run("Invalid Definitions Keep Running Config", func(t *testing.T) {
+		w := do("PUT", "/_dev/api/config", map[string]string{"env": "PROJECT_1_ROUTE=/broken/{id}\n"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "missing required database configuration")
+		assert.Equal(t, "/avatars/{id}", s.Config().Projects[0].Route)
+	})
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+)
+
+// entryMagic starts every cache value written with metadata. Values without
+// it were stored as raw bytes by older versions and are read as data only.
+var entryMagic = []byte("\x00STE")
+
+// cacheEntry is a cached payload along with what is needed to serve it
+// again: the Content-Type it was first served with (sniffed types survive
+// restarts), its ETag, when it was fetched and, for database rows with a
+// modified column, when it was last modified.
+type cacheEntry struct {
+	ContentType  string    `json:"content_type,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"` // as in the Last-Modified header
+	FetchedAt    time.Time `json:"fetched_at"`
+	// When an entry kept for conditional revalidation expires. Such entries
+	// stay in the cache past it, but are no longer served.
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// What the payload was sniffed as, if that did not match the project's
+	// CONTENT_TYPE under the flag policy.
+	ContentTypeMismatch string `json:"content_type_mismatch,omitempty"`
+	// Upstream status the payload was served with. Only successful fetches
+	// are cached for now, so this is always 200.
+	Status int `json:"status"`
+
+	Data []byte `json:"-"`
+}
+
+// Builds the entry of a freshly fetched payload.
+func newCacheEntry(data []byte, contentType string) *cacheEntry {
+	return &cacheEntry{
+		ContentType: contentType,
+		ETag:        etagFor(data),
+		FetchedAt:   time.Now(),
+		Status:      http.StatusOK,
+		Data:        data,
+	}
+}
+
+// Encodes an entry as its magic, the length of its JSON metadata, the
+// metadata and the payload.
+func (e *cacheEntry) encode() []byte {
+	meta, _ := json.Marshal(e)
+	out := make([]byte, 0, len(entryMagic)+4+len(meta)+len(e.Data))
+	out = append(out, entryMagic...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(meta)))
+	out = append(out, meta...)
+	return append(out, e.Data...)
+}
+
+// Decodes a cache value. Values without metadata, and values whose metadata
+// cannot be read, are returned as a bare payload.
+func decodeCacheEntry(raw []byte) *cacheEntry {
+	header := len(entryMagic) + 4
+	if !bytes.HasPrefix(raw, entryMagic) || len(raw) < header {
+		return &cacheEntry{Data: raw}
+	}
+	end := header + int(binary.BigEndian.Uint32(raw[len(entryMagic):header]))
+	if end > len(raw) {
+		return &cacheEntry{Data: raw}
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(raw[header:end], &e); err != nil {
+		return &cacheEntry{Data: raw}
+	}
+	e.Data = raw[end:]
+	return &e
+}
+
+// Loads a cached entry of a project, or nil if the key is not cached or its
+// entry has expired. Entries stored without metadata have no Content-Type,
+// which is left to the caller.
+func (s *Server) loadEntry(ctx context.Context, p config.Project, key string) (*cacheEntry, error) {
+	e, err := s.loadKeptEntry(ctx, p, key)
+	if e != nil && e.expired() {
+		return nil, nil
+	}
+	return e, err
+}
+
+// Reports whether an entry kept for conditional revalidation has expired.
+func (e *cacheEntry) expired() bool {
+	return !e.ExpiresAt.IsZero() && time.Now().After(e.ExpiresAt)
+}
+
+// Loads a cached entry like loadEntry, including one kept in the cache past
+// its expiry.
+func (s *Server) loadKeptEntry(ctx context.Context, p config.Project, key string) (*cacheEntry, error) {
+	raw, err := s.cacheFor(p.Name).Get(ctx, key)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	e := decodeCacheEntry(raw)
+	if e.ETag == "" {
+		e.ETag = etagFor(e.Data)
+	}
+	return e, nil
+}
+
+// Returns the Last-Modified of a payload read from a database row, or an
+// empty string if it has none. Payloads fetched from URLs have origins with
+// URLs and keep their own validators.
+func rowLastModified(origin *datasource.Origin) string {
+	if origin == nil || origin.URL != "" {
+		return ""
+	}
+	return origin.LastModified
+}
+
+// Reports whether a request is conditional on an If-Modified-Since that is
+// not before the entry's Last-Modified. If-Modified-Since is ignored when
+// the request has an If-None-Match.
+func (e *cacheEntry) notModifiedSince(header http.Header) bool {
+	if e.LastModified == "" || header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(e.LastModified)
+	return err == nil && !modified.After(since)
+}
+
+// Returns a strong ETag for a payload.
+func etagFor(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Reports whether an If-None-Match header matches an ETag. Weak comparison
+// is used, as for GET requests.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheEntryEncoding(t *testing.T) {
+	entry := newCacheEntry([]byte("<svg/>"), "image/svg+xml")
+	decoded := decodeCacheEntry(entry.encode())
+	assert.Equal(t, "image/svg+xml", decoded.ContentType)
+	assert.Equal(t, entry.ETag, decoded.ETag)
+	assert.Equal(t, http.StatusOK, decoded.Status)
+	assert.WithinDuration(t, entry.FetchedAt, decoded.FetchedAt, time.Millisecond)
+	assert.Equal(t, []byte("<svg/>"), decoded.Data)
+
+	legacy := decodeCacheEntry([]byte("raw payload"))
+	assert.Equal(t, []byte("raw payload"), legacy.Data)
+	assert.Empty(t, legacy.ContentType)
+
+	truncated := []byte("\x00STE\x00\x00\x01\x00{}")
+	assert.Equal(t, truncated, decodeCacheEntry(truncated).Data)
+}
+
+func TestETagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"x", W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(`"abd"`, `"abc"`))
+	assert.False(t, etagMatches(``, `"abc"`))
+}
+
+func TestCreateHandler_CacheEntryMetadata(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>"))
+	}, func(p *config.Project) {
+		p.ContentType = ""
+		p.ContentTypeSniff = "fallback"
+		p.CacheBackend = "memory"
+		p.CacheMemoryMaxEntries = 10
+	})
+	defer s.Close()
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	miss := get("")
+	etag := miss.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	hit := get("")
+	assert.Equal(t, "HIT", hit.Header().Get("X-Cache-Status"))
+	assert.Equal(t, etag, hit.Header().Get("ETag"))
+	assert.Equal(t, miss.Header().Get("Content-Type"), hit.Header().Get("Content-Type"))
+	assert.NotEmpty(t, hit.Header().Get("Age"))
+
+	notModified := get(etag)
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+}
+
+func TestCreateHandler_Head(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}, func(p *config.Project) {
+		p.CacheBackend = "memory"
+		p.CacheMemoryMaxEntries = 10
+	})
+	defer s.Close()
+	serve := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(method, "/test/1", nil))
+		return w
+	}
+
+	head := serve("HEAD")
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Empty(t, head.Body.String())
+	assert.Equal(t, "MISS", head.Header().Get("X-Cache-Status"))
+
+	get := serve("GET")
+	assert.Equal(t, "data", get.Body.String())
+	assert.Equal(t, "HIT", get.Header().Get("X-Cache-Status"), "HEAD requests fill the cache")
+	for _, name := range []string{"Content-Type", "Content-Length", "ETag", "Cache-Control"} {
+		assert.Equal(t, get.Header().Get(name), head.Header().Get(name), name)
+	}
+	assert.Equal(t, "4", head.Header().Get("Content-Length"))
+}
+
+func TestWriteEntry_LastModified(t *testing.T) {
+	entry := newCacheEntry([]byte("row"), "text/plain")
+	entry.LastModified = "Sun, 01 Mar 2026 12:30:00 GMT"
+	serve := func(header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/test/1", nil)
+		for name, value := range header {
+			c.Request.Header.Set(name, value)
+		}
+		writeEntry(c, config.Project{}, entry)
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	w := serve(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, entry.LastModified, w.Header().Get("Last-Modified"))
+
+	assert.Equal(t, http.StatusNotModified, serve(map[string]string{"If-Modified-Since": entry.LastModified}).Code)
+	assert.Equal(t, http.StatusNotModified, serve(map[string]string{"If-Modified-Since": "Mon, 02 Mar 2026 00:00:00 GMT"}).Code)
+	assert.Equal(t, http.StatusOK, serve(map[string]string{"If-Modified-Since": "Sat, 28 Feb 2026 00:00:00 GMT"}).Code)
+	// If-None-Match takes precedence.
+	assert.Equal(t, http.StatusOK, serve(map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": entry.LastModified}).Code)
+
+	decoded := decodeCacheEntry(entry.encode())
+	assert.Equal(t, entry.LastModified, decoded.LastModified)
+}
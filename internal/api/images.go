@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/internal/hooks"
+	"github.com/PythonicVarun/Stratum/internal/imaging"
+	"github.com/PythonicVarun/Stratum/internal/transform"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Serves a resized/converted variant of an image. Variants are cached under
+// their own key, and the original image is loaded from the cache (or the
+// source) to produce them.
+func (s *Server) serveImageVariant(c *gin.Context, p config.Project, source datasource.DataSource, chain transform.Chain, idValue, cacheKey string, params datasource.Params, opts imaging.Options, bypassCache bool) {
+	ctx := c.Request.Context()
+	variantKey := cacheKey + ":" + opts.Key()
+
+	if !bypassCache {
+		cached, err := s.loadEntry(ctx, p, variantKey)
+		if err != nil {
+			utils.StratumLogContext(ctx, "ERROR", "Cache lookup failed for key '%s': %v", variantKey, err)
+		}
+		if cached != nil {
+			utils.StratumLogContext(ctx, "DEBUG", "CACHE HIT: Serving '%s' from cache.", variantKey)
+			if cached.ContentType == "" {
+				cached.ContentType = http.DetectContentType(cached.Data)
+			}
+			c.Header("X-Cache-Status", "HIT")
+			writeEntry(c, p, cached)
+			s.metrics.ObservePayload(p.Name, len(cached.Data))
+			s.metrics.ObserveCacheHit(p.Name, len(cached.Data))
+			s.advisor.ObserveHit(p.Name, variantKey)
+			return
+		}
+		c.Header("X-Cache-Status", "MISS")
+		s.metrics.ObserveCacheMiss(p.Name)
+	} else {
+		c.Header("X-Cache-Status", "BYPASS")
+	}
+
+	var original *cacheEntry
+	stale := false
+	if !bypassCache {
+		original, _ = s.loadEntry(ctx, p, cacheKey)
+	}
+	if original == nil {
+		var err error
+		original, err = s.fetchShared(ctx, p, source, chain, idValue, cacheKey, params)
+		if err != nil {
+			if original = s.loadStale(ctx, p, cacheKey, err); original == nil {
+				s.writeFetchError(c, p, err)
+				return
+			}
+			stale = true
+			c.Header("X-Cache-Status", "STALE")
+		}
+	}
+	if original == nil {
+		c.String(http.StatusNotFound, "Not Found")
+		return
+	}
+
+	resized, contentType, err := imaging.Resize(original.Data, opts, p.ImageMaxPixels)
+	if err != nil {
+		utils.StratumLogContext(ctx, "ERROR", "Image resize failed for key '%s': %v", variantKey, err)
+		if errors.Is(err, imaging.ErrUnsupportedFormat) {
+			c.String(http.StatusBadRequest, err.Error())
+		} else if errors.Is(err, imaging.ErrTooLarge) {
+			c.String(http.StatusUnprocessableEntity, "Image is too large to resize")
+		} else {
+			c.String(http.StatusUnprocessableEntity, "Payload is not a supported image")
+		}
+		return
+	}
+
+	// Variants of a stale original are not cached, so they are made again
+	// once the source is back.
+	variant := newCacheEntry(resized, contentType)
+	if !stale && p.CacheTTL != hooks.NoCache {
+		if err := s.cacheFor(p.Name).Set(ctx, variantKey, variant.encode(), p.CacheTTL); err != nil {
+			utils.StratumLogContext(ctx, "ERROR", "Failed to set cache for key '%s': %v", variantKey, err)
+		} else {
+			s.advisor.ObserveStore(p.Name, variantKey, len(resized), p.CacheTTL)
+		}
+	}
+
+	writeEntry(c, p, variant)
+	s.metrics.ObservePayload(p.Name, len(resized))
+}
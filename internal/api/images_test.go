@@ -0,0 +1,58 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeImageVariant(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 32)))
+	original := buf.Bytes()
+
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(original)
+	}, func(p *config.Project) {
+		p.ContentType = "image/png"
+		p.ImageResize = true
+		p.ImageMaxDimension = 100
+	})
+
+	t.Run("Resize And Convert", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1?w=16&format=jpeg", nil)
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+		cfg, format, err := image.DecodeConfig(w.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, 16, cfg.Width)
+		assert.Equal(t, 8, cfg.Height)
+	})
+
+	t.Run("Original Without Options", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, original, w.Body.Bytes())
+	})
+
+	t.Run("Dimension Too Large", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1?w=500", nil)
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
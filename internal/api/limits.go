@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Returns a middleware answering requests whose target is longer than
+// MAX_URL_LENGTH with 414, whose headers are larger than MAX_HEADER_BYTES
+// with 431, and whose body is larger than MAX_BODY_BYTES with 413. Bodies
+// of unknown length are cut off at the limit while they are read. It returns
+// nil if every limit is disabled.
+func requestLimitsMiddleware(cfg *config.AppConfig) gin.HandlerFunc {
+	maxURL, maxHeader, maxBody := cfg.MaxURLLength, cfg.MaxHeaderBytes, cfg.MaxBodyBytes
+	if maxURL <= 0 && maxHeader <= 0 && maxBody <= 0 {
+		return nil
+	}
+	return func(c *gin.Context) {
+		r := c.Request
+		target := r.RequestURI
+		if target == "" {
+			target = r.URL.RequestURI()
+		}
+		switch {
+		case maxURL > 0 && len(target) > maxURL:
+			c.String(http.StatusRequestURITooLong, "URI Too Long")
+		case maxHeader > 0 && headerSize(r) > maxHeader:
+			c.String(http.StatusRequestHeaderFieldsTooLarge, "Request Header Fields Too Large")
+		case maxBody > 0 && r.ContentLength > maxBody:
+			c.String(http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+		default:
+			if maxBody > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(c.Writer, r.Body, maxBody)
+			}
+			return
+		}
+		c.Abort()
+	}
+}
+
+// Returns the size of a request's header fields as sent, including Host.
+func headerSize(r *http.Request) int {
+	size := len("Host: \r\n") + len(r.Host)
+	for name, values := range r.Header {
+		for _, value := range values {
+			size += len(name) + len(": \r\n") + len(value)
+		}
+	}
+	return size
+}
+
+// Returns the limit on the request line and headers enforced by net/http
+// while reading a request, which answers 431 past it before the router
+// sees the request. It is left to net/http's default if either limit of
+// requestLimitsMiddleware is disabled.
+func serverMaxHeaderBytes(cfg *config.AppConfig) int {
+	if cfg.MaxURLLength <= 0 || cfg.MaxHeaderBytes <= 0 {
+		return 0
+	}
+	return cfg.MaxURLLength + cfg.MaxHeaderBytes
+}
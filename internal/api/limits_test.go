@@ -0,0 +1,68 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimits(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}, nil)
+	cfg := *s.Config()
+	cfg.MaxURLLength = 64
+	cfg.MaxHeaderBytes = 256
+	cfg.MaxBodyBytes = 16
+	require.NoError(t, s.Reload(&cfg))
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Within Limits", func(t *testing.T) {
+		w := serve(httptest.NewRequest(http.MethodGet, "/test/1", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("URL Too Long", func(t *testing.T) {
+		w := serve(httptest.NewRequest(http.MethodGet, "/test/1?q="+strings.Repeat("a", 64), nil))
+		assert.Equal(t, http.StatusRequestURITooLong, w.Code)
+	})
+
+	t.Run("Headers Too Large", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test/1", nil)
+		req.Header.Set("Cookie", strings.Repeat("a", 256))
+		w := serve(req)
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, w.Code)
+	})
+
+	t.Run("Body Too Large", func(t *testing.T) {
+		w := serve(httptest.NewRequest(http.MethodPost, "/test/1", strings.NewReader(strings.Repeat("a", 17))))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+		// Bodies of unknown length are cut off while they are read.
+		handler := requestLimitsMiddleware(&cfg)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader(strings.Repeat("a", 17))))
+		c.Request.ContentLength = -1
+		handler(c)
+		_, err := io.ReadAll(c.Request.Body)
+		var tooLarge *http.MaxBytesError
+		assert.ErrorAs(t, err, &tooLarge)
+	})
+
+	t.Run("Server Header Limit", func(t *testing.T) {
+		assert.Equal(t, 320, serverMaxHeaderBytes(&cfg))
+		cfg.MaxHeaderBytes = 0
+		assert.Zero(t, serverMaxHeaderBytes(&cfg))
+	})
+}
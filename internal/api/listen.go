@@ -0,0 +1,107 @@
+package api
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Permissions of Unix sockets, so a reverse proxy in the same group can
+// connect.
+const unixSocketMode = 0o660
+
+// Listens on a TCP address or, for "unix:<path>", a Unix socket. A socket
+// left behind by an earlier run is removed first.
+func listen(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, "unix:")
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, unixSocketMode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serves handler on every address, with TLS on TCP addresses if tlsConfig
+// is set and requests' headers limited to maxHeaderBytes (zero for net/http's
+// default). Sends the first error of any of them to errs, and returns the
+// servers started, for shutting them down.
+func serveAll(addresses []string, handler http.Handler, tlsConfig *tls.Config, maxHeaderBytes int, errs chan<- error) []*http.Server {
+	var servers []*http.Server
+	for _, address := range addresses {
+		l, err := listen(address)
+		if err != nil {
+			errs <- fmt.Errorf("failed to listen on %s: %w", address, err)
+			return servers
+		}
+		server := &http.Server{Handler: handler, MaxHeaderBytes: maxHeaderBytes}
+		servers = append(servers, server)
+		useTLS := tlsConfig != nil && !strings.HasPrefix(address, "unix:")
+		go func() {
+			var err error
+			if useTLS {
+				server.TLSConfig = tlsConfig.Clone()
+				err = server.ServeTLS(l, "", "")
+			} else {
+				err = server.Serve(l)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+	}
+	return servers
+}
+
+// Reports whether a request is for the admin API or metrics, which are
+// served apart from projects when ADMIN_LISTEN is set.
+func isAdminPath(basePath, path string) bool {
+	path, ok := strings.CutPrefix(path, basePath)
+	if !ok {
+		return false
+	}
+	return path == "/metrics" || path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
+
+// Reports whether a request is for an endpoint served on every listener.
+func isProbePath(basePath, path string) bool {
+	path, ok := strings.CutPrefix(path, basePath)
+	return ok && (path == "/health" || path == "/ready")
+}
+
+// Serves the projects and health checks, but not the admin API or metrics.
+func (s *Server) publicHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAdminPath(s.Config().BasePath, r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		s.ServeHTTP(w, r)
+	})
+}
+
+// Serves the admin API, metrics and health checks only.
+func (s *Server) adminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := s.Config().BasePath
+		if !isAdminPath(base, r.URL.Path) && !isProbePath(base, r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		s.ServeHTTP(w, r)
+	})
+}
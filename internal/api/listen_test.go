@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeUnixSocket(t *testing.T) {
+	// Socket paths are limited to ~100 bytes, more than t.TempDir may take.
+	dir, err := os.MkdirTemp("", "stratum")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "stratum.sock")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	// A regular file in the way is not removed.
+	errs := make(chan error, 1)
+	serveAll([]string{"unix:" + path}, http.NotFoundHandler(), nil, 0, errs)
+	assert.Error(t, <-errs)
+
+	require.NoError(t, os.Remove(path))
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}, nil)
+	serveAll([]string{"unix:" + path}, s, nil, 0, errs)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(unixSocketMode), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://stratum/test/1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+}
+
+func TestAdminListenerSplit(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}, nil)
+	cfg := *s.Config()
+	cfg.BasePath = "/stratum"
+	require.NoError(t, s.Reload(&cfg))
+
+	get := func(handler http.Handler, path string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	public, admin := s.publicHandler(), s.adminHandler()
+	for path, want := range map[string][2]int{
+		"/stratum/test/1":      {http.StatusOK, http.StatusNotFound},
+		"/stratum/metrics":     {http.StatusNotFound, http.StatusOK},
+		"/stratum/admin/stats": {http.StatusNotFound, http.StatusNotFound}, // no ADMIN_TOKEN
+		"/stratum/health":      {http.StatusOK, http.StatusOK},
+		"/metrics":             {http.StatusNotFound, http.StatusNotFound},
+	} {
+		assert.Equal(t, want[0], get(public, path), "public %s", path)
+		assert.Equal(t, want[1], get(admin, path), "admin %s", path)
+	}
+
+	assert.True(t, isAdminPath("", "/admin/config"))
+	assert.False(t, isAdminPath("", "/administrators/1"))
+}
+
+func TestShutdown(t *testing.T) {
+	release := make(chan struct{})
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("hello"))
+	}, nil)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: s}
+	go server.Serve(l)
+	s.trackServers([]*http.Server{server})
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/test/1")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- result{body: string(body)}
+	}()
+	// Let the request reach the upstream.
+	time.Sleep(20 * time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Shutdown(context.Background()) }()
+	select {
+	case <-stopped:
+		t.Fatal("Shutdown returned with a request in progress")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	r := <-responses
+	require.NoError(t, r.err)
+	assert.Equal(t, "hello", r.body)
+	assert.NoError(t, <-stopped)
+
+	_, err = http.Get("http://" + l.Addr().String() + "/test/1")
+	assert.Error(t, err, "the listener is closed")
+}
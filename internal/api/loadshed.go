@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// concurrencyLimiter caps the number of requests handled at once.
+type concurrencyLimiter struct {
+	slots chan struct{}
+}
+
+func newConcurrencyLimiter(max int) *concurrencyLimiter {
+	return &concurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// Takes a slot without waiting, reporting false if all are taken.
+func (l *concurrencyLimiter) acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+}
+
+// Returns the concurrency limiter for a key, reusing the one from before a
+// reload if its limit has not changed, so requests in flight during a
+// reload still count.
+func (s *Server) concurrencyLimiter(key string, max int) *concurrencyLimiter {
+	s.limitersMu.Lock()
+	defer s.limitersMu.Unlock()
+
+	key = fmt.Sprintf("%s|%d", key, max)
+	if l, ok := s.concurrencyLimiters[key]; ok {
+		return l
+	}
+	if s.concurrencyLimiters == nil {
+		s.concurrencyLimiters = make(map[string]*concurrencyLimiter)
+	}
+	l := newConcurrencyLimiter(max)
+	s.concurrencyLimiters[key] = l
+	return l
+}
+
+// Returns a middleware counting a project's requests in flight. Requests
+// arriving while any of the limiters is full are answered with 503 Service
+// Unavailable right away, so an overloaded backend is not buried under a
+// growing queue.
+func (s *Server) inFlightMiddleware(project string, limiters ...*concurrencyLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for i, l := range limiters {
+			if l.acquire() {
+				continue
+			}
+			for _, taken := range limiters[:i] {
+				taken.release()
+			}
+			s.metrics.ObserveRequestShed(project)
+			c.Header("Retry-After", "1")
+			c.String(http.StatusServiceUnavailable, "Service Unavailable")
+			c.Abort()
+			return
+		}
+		defer func() {
+			for _, l := range limiters {
+				l.release()
+			}
+		}()
+
+		s.metrics.ObserveRequestStart(project)
+		defer s.metrics.ObserveRequestEnd(project)
+		c.Next()
+	}
+}
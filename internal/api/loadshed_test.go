@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFlightLimit(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/items/slow" {
+			close(started)
+			<-release
+		}
+		w.Write([]byte("data"))
+	}, func(p *config.Project) {
+		p.MaxInFlight = 1
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve("/test/slow") }()
+	<-started
+	assert.Equal(t, int64(1), s.metrics.Snapshot()["test_project"].InFlight)
+
+	w := serve("/test/1")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, uint64(1), s.metrics.Snapshot()["test_project"].RequestsShed)
+
+	close(release)
+	select {
+	case w := <-done:
+		assert.Equal(t, http.StatusOK, w.Code)
+	case <-time.After(time.Second):
+		t.Fatal("the slow request did not finish")
+	}
+	assert.Equal(t, http.StatusOK, serve("/test/1").Code, "the slot is released")
+	assert.Zero(t, s.metrics.Snapshot()["test_project"].InFlight)
+
+	t.Run("Global", func(t *testing.T) {
+		cfg := *s.Config()
+		project := cfg.Projects[0]
+		project.MaxInFlight = 0
+		cfg.Projects = []config.Project{project}
+		cfg.MaxInFlight = 1
+		require.NoError(t, s.Reload(&cfg))
+
+		global := s.concurrencyLimiter("global", 1)
+		require.True(t, global.acquire())
+		assert.Equal(t, http.StatusServiceUnavailable, serve("/test/2").Code)
+		global.release()
+		assert.Equal(t, http.StatusOK, serve("/test/2").Code)
+	})
+}
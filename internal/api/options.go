@@ -0,0 +1,33 @@
+package api
+
+import (
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/gin-gonic/gin"
+)
+
+// Option customizes a server created with NewServer or NewDevServer, so
+// programs building Stratum can extend it without changing its packages.
+type Option func(*Server)
+
+// WithMiddleware adds gin middleware run for every request, after request
+// IDs, access logging and panic recovery and before the endpoint's own
+// handlers, e.g. for authentication or resolving tenants.
+func WithMiddleware(middleware ...gin.HandlerFunc) Option {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// WithDataSource creates the sources of projects with the given SOURCE_TYPE
+// with factory. The type is registered with config.RegisterSourceType when
+// the option is created, so create it before loading the configuration.
+func WithDataSource(sourceType string, factory datasource.Factory) Option {
+	config.RegisterSourceType(sourceType)
+	return func(s *Server) {
+		if s.sourceFactories == nil {
+			s.sourceFactories = make(map[string]datasource.Factory)
+		}
+		s.sourceFactories[sourceType] = factory
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// A source answering every ID with a greeting from its settings.
+type greetingSource struct{ greeting string }
+
+func (g greetingSource) Fetch(ctx context.Context, idValue string, params datasource.Params) ([]byte, error) {
+	return []byte(g.greeting + ", " + idValue), nil
+}
+
+func TestOptions(t *testing.T) {
+	withGreeting := WithDataSource("greeting", func(p config.Project, cfg *config.AppConfig) (datasource.DataSource, error) {
+		return greetingSource{p.SourceSettings["TEXT"]}, nil
+	})
+	values := map[string]string{
+		"PROJECT_1_ROUTE":         "/hello/{id}",
+		"PROJECT_1_ID_COLUMN":     "id",
+		"PROJECT_1_SOURCE_TYPE":   "greeting",
+		"PROJECT_1_GREETING_TEXT": "Hello",
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	cfg, err := config.LoadFrom(func(key string) string { return values[key] }, keys...)
+	assert.NoError(t, err)
+
+	tenant := WithMiddleware(func(c *gin.Context) {
+		if c.GetHeader("X-Tenant") == "" {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Header("X-Tenant", c.GetHeader("X-Tenant"))
+	})
+	s := NewServer(cfg, nil, &mockCache{}, withGreeting, tenant)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hello/world", nil)
+	s.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req.Header.Set("X-Tenant", "acme")
+	s.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hello, world", w.Body.String())
+	assert.Equal(t, "acme", w.Header().Get("X-Tenant"))
+}
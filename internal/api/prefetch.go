@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/internal/transform"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// Matches {id}, {id+N} and {id-N} in prefetch patterns.
+var prefetchPlaceholderRegex = regexp.MustCompile(`\{id(?:([+-])(\d+))?\}`)
+
+// Warms the cache in the background with IDs related to the requested one.
+// Prefetches are dropped rather than queued when all slots are busy, so a
+// burst of misses cannot pile up goroutines. They are logged with the ID of
+// the request that triggered them.
+func (s *Server) prefetch(ctx context.Context, p config.Project, source datasource.DataSource, chain transform.Chain, idValue string, params datasource.Params) {
+	ids := expandPrefetchIDs(p.PrefetchPatterns, idValue)
+	if len(ids) == 0 {
+		return
+	}
+
+	select {
+	case <-s.stopping:
+		return
+	default:
+	}
+	select {
+	case s.prefetchSlots <- struct{}{}:
+	default:
+		utils.StratumLogContext(ctx, "DEBUG", "PREFETCH SKIP: No free slot for project '%s', ID '%s'.", p.Name, idValue)
+		return
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		defer func() { <-s.prefetchSlots }()
+
+		ctx := context.WithoutCancel(ctx)
+		for _, id := range ids {
+			cacheKey := cacheKeyFor(p, id, params)
+			if cached, err := s.loadEntry(ctx, p, cacheKey); err == nil && cached != nil {
+				continue
+			}
+			if _, err := s.fetchAndStore(ctx, p, source, chain, id, cacheKey, params); err == nil {
+				utils.StratumLogContext(ctx, "DEBUG", "PREFETCH: Warmed key '%s'.", cacheKey)
+			}
+		}
+	}()
+}
+
+// Expands prefetch patterns for an ID. Patterns using arithmetic ({id+1})
+// are skipped for non-numeric IDs, and the requested ID itself is never
+// returned.
+func expandPrefetchIDs(patterns []string, idValue string) []string {
+	numericID, numErr := strconv.ParseInt(idValue, 10, 64)
+
+	var ids []string
+	seen := map[string]bool{idValue: true}
+	for _, pattern := range patterns {
+		valid := true
+		id := prefetchPlaceholderRegex.ReplaceAllStringFunc(pattern, func(m string) string {
+			parts := prefetchPlaceholderRegex.FindStringSubmatch(m)
+			if parts[1] == "" {
+				return idValue
+			}
+			if numErr != nil {
+				valid = false
+				return m
+			}
+			offset, _ := strconv.ParseInt(parts[2], 10, 64)
+			if parts[1] == "-" {
+				offset = -offset
+			}
+			if numericID+offset < 0 {
+				valid = false
+			}
+			return strconv.FormatInt(numericID+offset, 10)
+		})
+
+		if valid && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Response headers copied from an upstream's answer to a ranged request.
+var rangeResponseHeaders = []string{"Content-Range", "Content-Length", "Accept-Ranges", "ETag", "Last-Modified"}
+
+// Passes a client's Range request through to the upstream and streams the
+// partial content back. It reports false without writing a response if the
+// source cannot serve ranges for the ID, so the request is handled normally.
+func (s *Server) serveRange(c *gin.Context, p config.Project, source datasource.DataSource, idValue string, params datasource.Params) bool {
+	rangeSource, ok := source.(datasource.RangeSource)
+	if !ok {
+		return false
+	}
+
+	resp, err := rangeSource.FetchRange(c.Request.Context(), idValue, params, c.Request.Header)
+	if err != nil {
+		s.metrics.ObserveUpstreamFetch(p.Name, 0, true)
+		utils.StratumLogContext(c.Request.Context(), "ERROR", "Range request failed for project '%s': %v", p.Name, err)
+		s.writeFetchError(c, p, err)
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	for _, name := range rangeResponseHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			c.Header(name, value)
+		}
+	}
+	contentType := p.ContentType
+	if contentType == "" {
+		contentType = resp.Header.Get("Content-Type")
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("X-Cache-Status", "PASS")
+	c.Status(resp.StatusCode)
+
+	n, err := io.Copy(c.Writer, resp.Body)
+	s.metrics.ObserveUpstreamFetch(p.Name, int(n), err != nil)
+	if err != nil {
+		utils.StratumLogContext(c.Request.Context(), "ERROR", "Streaming range for project '%s' failed: %v", p.Name, err)
+	}
+	return true
+}
+
+// Reports whether a request should be passed through to the upstream as a
+// range request.
+func wantsRangePassthrough(c *gin.Context, p config.Project, chainLength int) bool {
+	return p.RangePassthrough && chainLength == 0 && c.GetHeader("Range") != "" && c.Request.Method == http.MethodGet
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangePassthrough(t *testing.T) {
+	video := []byte("0123456789abcdefghij")
+	var upstreamRanges []string
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		upstreamRanges = append(upstreamRanges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(video))
+	}
+
+	s := newAPIProjectServer(t, upstream, func(p *config.Project) {
+		p.ContentType = "video/mp4"
+		p.RangePassthrough = true
+	})
+
+	t.Run("Partial Content", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		req.Header.Set("Range", "bytes=5-9")
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "56789", w.Body.String())
+		assert.Equal(t, "bytes 5-9/20", w.Header().Get("Content-Range"))
+		assert.Equal(t, "5", w.Header().Get("Content-Length"))
+		assert.Equal(t, "video/mp4", w.Header().Get("Content-Type"))
+		assert.Equal(t, "PASS", w.Header().Get("X-Cache-Status"))
+	})
+
+	t.Run("Unsatisfiable Range", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		req.Header.Set("Range", "bytes=100-")
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+		assert.Equal(t, "bytes */20", w.Header().Get("Content-Range"))
+	})
+
+	t.Run("Without Range", func(t *testing.T) {
+		upstreamRanges = nil
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, string(video), w.Body.String())
+		assert.Equal(t, []string{""}, upstreamRanges)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		s := newAPIProjectServer(t, upstream, nil)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		req.Header.Set("Range", "bytes=5-9")
+		s.router.ServeHTTP(w, req)
+
+		// The range is served from the fetched payload instead.
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "56789", w.Body.String())
+		assert.Equal(t, "MISS", w.Header().Get("X-Cache-Status"))
+	})
+}
+
+func TestServeCachedRanges(t *testing.T) {
+	video := []byte("0123456789abcdefghij")
+	fetches := 0
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(video)
+	}, func(p *config.Project) {
+		p.ContentType = "video/mp4"
+		p.CacheBackend = "memory"
+		p.CacheMemoryMaxEntries = 10
+	})
+	defer s.Close()
+	serve := func(method string, header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/test/1", nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	full := serve("GET", nil)
+	assert.Equal(t, http.StatusOK, full.Code)
+	assert.Equal(t, "bytes", full.Header().Get("Accept-Ranges"))
+	etag := full.Header().Get("ETag")
+
+	w := serve("GET", map[string]string{"Range": "bytes=10-"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "abcdefghij", w.Body.String())
+	assert.Equal(t, "bytes 10-19/20", w.Header().Get("Content-Range"))
+	assert.Equal(t, "10", w.Header().Get("Content-Length"))
+	assert.Equal(t, "video/mp4", w.Header().Get("Content-Type"))
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache-Status"))
+
+	w = serve("GET", map[string]string{"Range": "bytes=-3", "If-Range": etag})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "hij", w.Body.String())
+
+	w = serve("GET", map[string]string{"Range": "bytes=-3", "If-Range": `"stale"`})
+	assert.Equal(t, http.StatusOK, w.Code, "a changed payload is sent in full")
+	assert.Equal(t, string(video), w.Body.String())
+
+	w = serve("GET", map[string]string{"Range": "bytes=100-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */20", w.Header().Get("Content-Range"))
+
+	w = serve("HEAD", map[string]string{"Range": "bytes=0-4"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, "5", w.Header().Get("Content-Length"))
+
+	assert.Equal(t, 1, fetches)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// How often buckets that have filled up again are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// rateLimiter is a token bucket per client IP: each holds up to burst
+// tokens, refilled at rate per second, and every request takes one.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Takes a token from the client's bucket. If it is empty, returns false
+// along with how long until a token is available.
+func (l *rateLimiter) take(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		for key, b := range l.buckets {
+			if l.refill(b, now) >= l.burst {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens, b.last = l.refill(b, now), now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Gives back a token taken from the client's bucket, for requests another
+// limiter turned down.
+func (l *rateLimiter) refund(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[client]; ok {
+		b.tokens = math.Min(l.burst, b.tokens+1)
+	}
+}
+
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+}
+
+// Returns the limiter for a key, reusing the one from before a reload if
+// its limits have not changed, so clients' buckets survive reloads.
+func (s *Server) rateLimiter(key string, rate float64, burst int) *rateLimiter {
+	s.limitersMu.Lock()
+	defer s.limitersMu.Unlock()
+
+	key = fmt.Sprintf("%s|%g|%d", key, rate, burst)
+	if l, ok := s.limiters[key]; ok {
+		return l
+	}
+	if s.limiters == nil {
+		s.limiters = make(map[string]*rateLimiter)
+	}
+	l := newRateLimiter(rate, burst)
+	s.limiters[key] = l
+	return l
+}
+
+// Reports whether the configuration limits the request rate globally or
+// for any project.
+func rateLimited(cfg *config.AppConfig) bool {
+	if cfg.RateLimit > 0 {
+		return true
+	}
+	for _, p := range cfg.Projects {
+		if p.RateLimit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a middleware answering clients that exceed any of the limiters
+// with 429 Too Many Requests and a Retry-After header. It returns nil if
+// there are no limiters.
+func rateLimitMiddleware(limiters ...*rateLimiter) gin.HandlerFunc {
+	if len(limiters) == 0 {
+		return nil
+	}
+
+	return func(c *gin.Context) {
+		client := c.ClientIP()
+		for i, l := range limiters {
+			ok, wait := l.take(client)
+			if ok {
+				continue
+			}
+			for _, taken := range limiters[:i] {
+				taken.refund(client)
+			}
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.String(http.StatusTooManyRequests, "Too Many Requests")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
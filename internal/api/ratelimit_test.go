@@ -0,0 +1,121 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+	l := newRateLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.take("10.0.0.1")
+		assert.True(t, ok, "request %d is within the burst", i+1)
+	}
+	ok, wait := l.take("10.0.0.1")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	ok, _ = l.take("10.0.0.2")
+	assert.True(t, ok, "clients have buckets of their own")
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.take("10.0.0.1")
+	assert.True(t, ok, "a token is added every half second")
+
+	l.refund("10.0.0.1")
+	ok, _ = l.take("10.0.0.1")
+	assert.True(t, ok)
+
+	// Full buckets are dropped.
+	now = now.Add(rateLimitSweepInterval)
+	l.take("10.0.0.3")
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}, func(p *config.Project) {
+		p.RateLimit = 0.5
+		p.RateLimitBurst = 2
+	})
+	serve := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/test/1", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("192.0.2.1:1234", "").Code)
+	assert.Equal(t, http.StatusOK, serve("192.0.2.1:1234", "").Code)
+	w := serve("192.0.2.1:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, serve("192.0.2.2:1234", "").Code)
+
+	t.Run("Spoofed X-Forwarded-For", func(t *testing.T) {
+		// Without TRUSTED_PROXIES, the header is ignored when rate limits
+		// are set, so it neither dodges the limit nor adds buckets.
+		limiter := s.rateLimiter("project|test_project", 0.5, 2)
+		buckets := len(limiter.buckets)
+		for i := 0; i < 5; i++ {
+			w := serve("192.0.2.1:1234", fmt.Sprintf("198.51.100.%d", i))
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		}
+		assert.Len(t, limiter.buckets, buckets)
+	})
+
+	t.Run("Trusted Proxies", func(t *testing.T) {
+		cfg := *s.Config()
+		cfg.TrustedProxies = []string{"10.0.0.0/8"}
+		assert.NoError(t, s.Reload(&cfg))
+
+		// Clients behind a trusted proxy are told apart by X-Forwarded-For.
+		assert.Equal(t, http.StatusOK, serve("10.0.0.1:1234", "198.51.100.1").Code)
+		assert.Equal(t, http.StatusOK, serve("10.0.0.1:1234", "198.51.100.2").Code)
+		// Others cannot dodge their limit with it.
+		assert.Equal(t, http.StatusTooManyRequests, serve("192.0.2.1:1234", "198.51.100.3").Code, "the bucket survives the reload")
+	})
+
+	t.Run("Client IP Headers", func(t *testing.T) {
+		cfg := *s.Config()
+		cfg.ClientIPHeaders = []string{"CF-Connecting-IP"}
+		assert.NoError(t, s.Reload(&cfg))
+
+		// X-Forwarded-For is no longer believed, even from a trusted proxy.
+		assert.Equal(t, http.StatusOK, serve("10.0.0.1:1234", "198.51.100.1").Code)
+		assert.Equal(t, http.StatusOK, serve("10.0.0.1:1234", "198.51.100.2").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve("10.0.0.1:1234", "198.51.100.4").Code)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/test/1", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("CF-Connecting-IP", "198.51.100.5")
+		s.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Global", func(t *testing.T) {
+		cfg := *s.Config()
+		cfg.RateLimit, cfg.RateLimitBurst = 1, 1
+		assert.NoError(t, s.Reload(&cfg))
+
+		assert.Equal(t, http.StatusOK, serve("192.0.2.9:1234", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve("192.0.2.9:1234", "").Code)
+		// The project's own bucket got its token back.
+		assert.InDelta(t, 1.0, s.rateLimiter("project|test_project", 0.5, 2).buckets["192.0.2.9"].tokens, 0.01)
+	})
+}
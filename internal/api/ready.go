@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/cache"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// How long each readiness check may take.
+	readyTimeout = 5 * time.Second
+	// How long the outcome of the checks is reused, so frequent probes
+	// from several orchestrators do not each hit every backend.
+	readyCheckInterval = 5 * time.Second
+)
+
+// The outcome of the readiness checks, by check name.
+type readiness struct {
+	checked time.Time
+	ready   bool
+	checks  map[string]string
+}
+
+// Serves /ready: unlike /health, it answers 503 Service Unavailable unless
+// Redis and every project's database or upstream can be reached, so an
+// instance with broken credentials receives no traffic. Errors are logged
+// rather than returned, since the endpoint is public.
+func (s *Server) handleReady(c *gin.Context) {
+	r := s.checkReadiness()
+	status, state := http.StatusOK, "ready"
+	if !r.ready {
+		status, state = http.StatusServiceUnavailable, "unavailable"
+	}
+	c.JSON(status, gin.H{"status": state, "checks": r.checks})
+}
+
+func (s *Server) checkReadiness() *readiness {
+	s.readyMu.Lock()
+	defer s.readyMu.Unlock()
+	if s.ready != nil && time.Since(s.ready.checked) < readyCheckInterval {
+		return s.ready
+	}
+
+	checks := make(map[string]func(context.Context) error)
+	if pinger, ok := s.cache.(cache.Pinger); ok {
+		checks["cache"] = pinger.Ping
+	}
+	s.mu.RLock()
+	for name, rt := range s.runtimes {
+		if pinger, ok := rt.source.(datasource.Pinger); ok {
+			checks["project:"+name] = pinger.Ping
+		}
+		if pinger, ok := rt.cache.(cache.Pinger); ok {
+			checks["project:"+name+":cache"] = pinger.Ping
+		}
+	}
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+	r := &readiness{ready: true, checks: make(map[string]string, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+			err := check(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				utils.StratumLog("WARN", "Readiness check '%s' failed: %v", name, err)
+				r.ready = false
+				r.checks[name] = "failed"
+				return
+			}
+			r.checks[name] = "ok"
+		}(name, check)
+	}
+	wg.Wait()
+
+	r.checked = time.Now()
+	s.ready = r
+	return r
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}, nil)
+	ready := func() (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	status, body := ready()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ready", body["status"])
+	assert.Equal(t, map[string]interface{}{"project:test_project": "ok"}, body["checks"], "any answer from the upstream will do")
+
+	cfg := *s.Config()
+	broken := cfg.Projects[0]
+	broken.APIEndpoint = "http://127.0.0.1:1/items/{id}"
+	cfg.Projects = []config.Project{broken}
+	require.NoError(t, s.Reload(&cfg))
+
+	status, _ = ready()
+	assert.Equal(t, http.StatusOK, status, "the outcome is reused for a while")
+
+	s.ready = nil
+	status, body = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable", body["status"])
+	assert.Equal(t, map[string]interface{}{"project:test_project": "failed"}, body["checks"])
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "the instance is still alive")
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// X-Request-ID values accepted from clients. Anything else is replaced, so
+// clients cannot write arbitrary text into logs or upstream requests.
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:+/=-]{1,128}$`)
+
+// Gives every request an ID, the client's X-Request-ID if it sent one, and
+// returns it in the response. The ID is kept in the request's context for
+// log lines and upstream requests.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !requestIDRegex.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), id))
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	var forwarded string
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("X-Request-ID")
+		w.Write([]byte("data"))
+	}, nil)
+	serve := func(path, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if id != "" {
+			req.Header.Set("X-Request-ID", id)
+		}
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/test/1", "")
+	assert.Regexp(t, `^[0-9a-f]{32}$`, w.Header().Get("X-Request-ID"))
+	assert.Equal(t, w.Header().Get("X-Request-ID"), forwarded)
+
+	w = serve("/test/2", "edge-7f3a:1")
+	assert.Equal(t, "edge-7f3a:1", w.Header().Get("X-Request-ID"), "the client's ID is kept")
+	assert.Equal(t, "edge-7f3a:1", forwarded)
+
+	w = serve("/health", "not an id")
+	assert.Regexp(t, `^[0-9a-f]{32}$`, w.Header().Get("X-Request-ID"), "invalid IDs are replaced")
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// How often the revalidation loop looks for entries that are due.
+const revalidateTick = time.Second
+
+// trackedOrigin is a cached payload whose origin is revalidated with HEAD
+// requests until the cache entry expires or the runtime it was fetched
+// through is replaced.
+type trackedOrigin struct {
+	ctx      context.Context // of the runtime owning source
+	project  string
+	source   datasource.OriginSource
+	origin   *datasource.Origin
+	interval time.Duration
+	next     time.Time
+	expires  time.Time
+
+	// Purged from the CDN along with the cache entry
+	surrogateKeys []string
+}
+
+// Starts revalidating the origin of a cache entry. The revalidation loop is
+// started on first use, so servers without URL-backed projects never run it,
+// and ends once no origins are left to track. Origins fetched through a
+// runtime that was already replaced, by a request that outlived a reload,
+// are not tracked.
+func (s *Server) trackOrigin(p config.Project, source datasource.OriginSource, cacheKey string, origin *datasource.Origin, surrogateKeys []string) {
+	ctx := s.runtimeContext(source)
+	if ctx == nil || ctx.Err() != nil {
+		return
+	}
+	now := time.Now()
+
+	s.originsMu.Lock()
+	defer s.originsMu.Unlock()
+	if s.origins == nil {
+		s.origins = make(map[string]*trackedOrigin)
+	}
+	s.origins[cacheKey] = &trackedOrigin{
+		ctx:      ctx,
+		project:  p.Name,
+		source:   source,
+		origin:   origin,
+		interval: p.RevalidateInterval,
+		next:     now.Add(p.RevalidateInterval),
+		expires:  now.Add(p.CacheTTL),
+
+		surrogateKeys: surrogateKeys,
+	}
+
+	if s.revalidating {
+		return
+	}
+	s.revalidating = true
+	go func() {
+		ticker := time.NewTicker(revalidateTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.revalidate(context.Background(), now)
+				if !s.keepRevalidating() {
+					return
+				}
+			case <-s.stopping:
+				return
+			}
+		}
+	}()
+}
+
+// Returns the context of the current runtime whose data source is source,
+// or nil if no current runtime uses it.
+func (s *Server) runtimeContext(source datasource.OriginSource) context.Context {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, rt := range s.runtimes {
+		if rt.source == source {
+			return rt.ctx
+		}
+	}
+	return nil
+}
+
+// Reports whether origins are left to revalidate, marking the loop stopped
+// if not.
+func (s *Server) keepRevalidating() bool {
+	s.originsMu.Lock()
+	defer s.originsMu.Unlock()
+	s.revalidating = len(s.origins) > 0
+	return s.revalidating
+}
+
+// Checks every tracked origin that is due and invalidates the cache entries
+// whose origin changed. Entries are forgotten once their cache TTL passes or
+// their runtime is replaced, and checks in progress end with the runtime.
+func (s *Server) revalidate(ctx context.Context, now time.Time) {
+	due := make(map[string]*trackedOrigin)
+	s.originsMu.Lock()
+	for key, t := range s.origins {
+		switch {
+		case !now.Before(t.expires) || t.ctx.Err() != nil:
+			delete(s.origins, key)
+		case !now.Before(t.next):
+			t.next = now.Add(t.interval)
+			due[key] = t
+		}
+	}
+	s.originsMu.Unlock()
+
+	for key, t := range due {
+		checkCtx, cancel := context.WithCancel(ctx)
+		stop := context.AfterFunc(t.ctx, cancel)
+		changed, err := t.source.CheckOrigin(checkCtx, t.origin)
+		stop()
+		cancel()
+		if t.ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			utils.StratumLog("WARN", "Revalidation failed for key '%s': %v", key, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		if err := s.cacheFor(t.project).Delete(ctx, key); err != nil {
+			utils.StratumLog("ERROR", "Failed to invalidate key '%s': %v", key, err)
+			continue
+		}
+		utils.StratumLog("INFO", "REVALIDATE: Origin of '%s' changed, invalidated cache entry.", key)
+		s.purgeCDN(ctx, t.surrogateKeys)
+
+		s.originsMu.Lock()
+		if s.origins[key] == t {
+			delete(s.origins, key)
+		}
+		s.originsMu.Unlock()
+	}
+}
@@ -1,100 +1,588 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/PythonicVarun/Stratum/internal/advisor"
 	"github.com/PythonicVarun/Stratum/internal/cache"
 	"github.com/PythonicVarun/Stratum/internal/config"
 	"github.com/PythonicVarun/Stratum/internal/database"
 	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/internal/hooks"
+	"github.com/PythonicVarun/Stratum/internal/idcodec"
+	"github.com/PythonicVarun/Stratum/internal/imaging"
+	"github.com/PythonicVarun/Stratum/internal/metrics"
+	"github.com/PythonicVarun/Stratum/internal/transform"
 	"github.com/PythonicVarun/Stratum/pkg/utils"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
 )
 
+// errContentTypeMismatch is returned when a payload is rejected by a
+// project's content type policy.
+var errContentTypeMismatch = errors.New("content type mismatch")
+
 type Server struct {
 	config    *config.AppConfig
 	dbManager *database.ConnectionManager
 	cache     cache.Cache
+	metrics   *metrics.Registry
+	advisor   *advisor.Advisor
 	router    *gin.Engine
+
+	// When the server was created, for projects' warm-up periods.
+	started time.Time
+
+	// Guards config, router, runtimes and devEnv, which are swapped on
+	// reload.
+	mu       sync.RWMutex
+	runtimes map[string]*projectRuntime
+
+	// Limits the number of background prefetches running at once.
+	prefetchSlots chan struct{}
+
+	// HTTP servers started by Start, stopped by Shutdown.
+	serversMu   sync.Mutex
+	httpServers []*http.Server
+
+	// Background work outliving requests, such as prefetches, which
+	// Shutdown waits for; stopping is closed by Shutdown to end the
+	// revalidation loop.
+	background   sync.WaitGroup
+	stopping     chan struct{}
+	shutdownOnce sync.Once
+
+	// Cache backends opened for projects not using the shared cache, by
+	// cacheBackendKey.
+	cachesMu sync.Mutex
+	caches   map[string]cache.Cache
+
+	// Rate and concurrency limiters of the global and per-project limits,
+	// kept across reloads; see rateLimiter and concurrencyLimiter.
+	limitersMu          sync.Mutex
+	limiters            map[string]*rateLimiter
+	concurrencyLimiters map[string]*concurrencyLimiter
+
+	// Access log files, by path, kept open across reloads.
+	accessLogsMu sync.Mutex
+	accessLogs   map[string]*os.File
+
+	// Outcome of the last readiness checks.
+	readyMu sync.Mutex
+	ready   *readiness
+
+	// Persists projects changed through the admin API, if set; changes are
+	// made one at a time.
+	projectStore ProjectStore
+	projectsMu   sync.Mutex
+
+	// Set by options: middleware run for every request, and the factories
+	// of source types implemented outside Stratum, by SOURCE_TYPE.
+	middleware      []gin.HandlerFunc
+	sourceFactories map[string]datasource.Factory
+
+	// Coalesces concurrent fetches of the same cache key.
+	fetches singleflight.Group
+
+	// Cache entries whose URL origins are revalidated, by cache key, and
+	// whether the revalidation loop is running.
+	originsMu    sync.Mutex
+	origins      map[string]*trackedOrigin
+	revalidating bool
+
+	// Serves the local development UI under /_dev, along with the project
+	// definitions last applied through it.
+	devMode bool
+	devEnv  string
 }
 
 // Creates and configures a new server instance.
-func NewServer(cfg *config.AppConfig, dbManager *database.ConnectionManager, cache cache.Cache) *Server {
-	gin.SetMode(gin.ReleaseMode)
+func NewServer(cfg *config.AppConfig, dbManager *database.ConnectionManager, cache cache.Cache, opts ...Option) *Server {
+	return newServer(cfg, dbManager, cache, false, opts)
+}
 
-	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+func newServer(cfg *config.AppConfig, dbManager *database.ConnectionManager, cache cache.Cache, devMode bool, opts []Option) *Server {
+	gin.SetMode(gin.ReleaseMode)
 
 	s := &Server{
 		config:    cfg,
 		dbManager: dbManager,
 		cache:     cache,
-		router:    router,
+		metrics:   newMetricsRegistry(cfg),
+		advisor:   advisor.New(),
+		started:   time.Now(),
+		devMode:   devMode,
+
+		prefetchSlots: make(chan struct{}, cfg.PrefetchConcurrency),
+		stopping:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 
-	s.setupRoutes()
+	s.setDatabaseLimits(cfg)
+	router, runtimes, err := s.buildRouter(cfg)
+	if err != nil {
+		utils.StratumLog("FATAL", "%v", err)
+		os.Exit(1)
+	}
+	s.router, s.runtimes = router, runtimes
+	datasource.PruneTransports()
 	return s
 }
 
-// Configures the router with all the dynamic project routes.
-func (s *Server) setupRoutes() {
+// Reload builds a router for a new configuration and atomically swaps it in.
+// The running configuration is kept if any project fails to initialize.
+func (s *Server) Reload(cfg *config.AppConfig) error {
+	s.setDatabaseLimits(cfg)
+	router, runtimes, err := s.buildRouter(cfg)
+	if err != nil {
+		s.mu.RLock()
+		s.setDatabaseLimits(s.config)
+		s.retainDatabases(s.runtimes)
+		s.mu.RUnlock()
+		return err
+	}
+
+	s.mu.Lock()
+	s.config = cfg
+	previous := s.runtimes
+	s.router, s.runtimes = router, runtimes
+	s.mu.Unlock()
+	s.retainDatabases(runtimes)
+	s.stopRuntimes(previous)
+	datasource.PruneTransports()
+	return nil
+}
+
+// Ends the contexts of runtimes no longer served and forgets the origins
+// tracked through them, so their sources and chains can be released.
+func (s *Server) stopRuntimes(runtimes map[string]*projectRuntime) {
+	for _, rt := range runtimes {
+		if rt.cancel != nil {
+			rt.cancel()
+		}
+	}
+	s.originsMu.Lock()
+	for key, t := range s.origins {
+		if t.ctx.Err() != nil {
+			delete(s.origins, key)
+		}
+	}
+	s.originsMu.Unlock()
+}
+
+func (s *Server) setDatabaseLimits(cfg *config.AppConfig) {
+	if s.dbManager != nil {
+		s.dbManager.SetLimits(database.Limits{MaxPools: cfg.DBMaxPools, IdleTimeout: cfg.DBIdleTimeout})
+	}
+}
+
+// Tells the connection manager which databases the projects of runtimes
+// read from, so it can close the pools of projects that went away,
+// including those opened for a configuration that failed to load.
+func (s *Server) retainDatabases(runtimes map[string]*projectRuntime) {
+	if s.dbManager == nil {
+		return
+	}
+	var conns []database.DBLoader
+	for _, rt := range runtimes {
+		if db, ok := rt.source.(*datasource.DatabaseSource); ok {
+			conns = append(conns, db.DB())
+		}
+	}
+	s.dbManager.Retain(conns)
+}
+
+// Config returns the configuration the server is currently running with.
+func (s *Server) Config() *config.AppConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// ServeHTTP dispatches a request to the current router.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
+	router := s.router
+	s.mu.RUnlock()
+	router.ServeHTTP(w, req)
+}
+
+// Creates a router with all the static and dynamic project routes for cfg,
+// along with the runtimes of its projects.
+func (s *Server) buildRouter(cfg *config.AppConfig) (*gin.Engine, map[string]*projectRuntime, error) {
+	router := gin.New()
+	// Other methods on known paths get 405 with an Allow header, not 404.
+	router.HandleMethodNotAllowed = true
+	router.Use(requestIDMiddleware())
+	accessLog, err := s.accessLogOutput(cfg.AccessLogOutput)
+	if err != nil {
+		return nil, nil, err
+	}
+	if logger := accessLogMiddleware(cfg.AccessLogFormat, accessLog); logger != nil {
+		router.Use(logger)
+	}
+	router.Use(gin.Recovery())
+	if limits := requestLimitsMiddleware(cfg); limits != nil {
+		router.Use(limits)
+	}
+	router.Use(s.middleware...)
+	trustedProxies := cfg.TrustedProxies
+	if trustedProxies == nil && rateLimited(cfg) {
+		// Client IPs key the rate limits, so they must not be taken from
+		// headers any client can send.
+		utils.StratumLog("WARN", "Rate limits are set without TRUSTED_PROXIES; X-Forwarded-For is ignored and clients are told apart by their connection's address.")
+		trustedProxies = []string{}
+	}
+	if trustedProxies != nil {
+		if err := router.SetTrustedProxies(trustedProxies); err != nil {
+			return nil, nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+		}
+	}
+	if cfg.ClientIPHeaders != nil {
+		router.RemoteIPHeaders = cfg.ClientIPHeaders
+	}
+
+	// Every endpoint is mounted under BASE_PATH, if set.
+	base := router.Group(cfg.BasePath)
+
 	// Root endpoint
-	s.router.GET("/", func(c *gin.Context) {
+	base.GET("/", func(c *gin.Context) {
 		c.String(http.StatusOK, "Welcome to Stratum!")
 	})
 
 	// Health check endpoint
-	s.router.GET("/health", func(c *gin.Context) {
+	base.GET("/health", func(c *gin.Context) {
 		c.String(http.StatusOK, "OK")
 	})
 
+	// Readiness check endpoint, which also checks Redis and the projects'
+	// sources
+	base.GET("/ready", s.handleReady)
+
+	// Prometheus metrics endpoint
+	base.GET("/metrics", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; version=0.0.4")
+		c.Status(http.StatusOK)
+		s.metrics.WritePrometheus(c.Writer)
+	})
+
+	s.setupAdminRoutes(base, cfg)
+	if s.devMode {
+		s.setupDevRoutes(base, cfg)
+	}
+
+	// Build every project first so hooks can route requests to another
+	// project's source.
+	runtimes := make(map[string]*projectRuntime, len(cfg.Projects))
+	for _, p := range cfg.Projects {
+		rt, err := s.newProjectRuntime(cfg, p)
+		if err != nil {
+			return nil, nil, err
+		}
+		runtimes[p.Name] = rt
+	}
+
+	var globalLimiter *rateLimiter
+	if cfg.RateLimit > 0 {
+		globalLimiter = s.rateLimiter("global", cfg.RateLimit, cfg.RateLimitBurst)
+	}
+	var globalSlots *concurrencyLimiter
+	if cfg.MaxInFlight > 0 {
+		globalSlots = s.concurrencyLimiter("global", cfg.MaxInFlight)
+	}
+
 	// Dynamically register routes from config
-	for _, p := range s.config.Projects {
-		project := p
+	for _, p := range cfg.Projects {
+		var limiters []*rateLimiter
+		if p.RateLimit > 0 {
+			limiters = append(limiters, s.rateLimiter("project|"+p.Name, p.RateLimit, p.RateLimitBurst))
+		}
+		if globalLimiter != nil {
+			limiters = append(limiters, globalLimiter)
+		}
+		limit := rateLimitMiddleware(limiters...)
+
+		var slots []*concurrencyLimiter
+		if p.MaxInFlight > 0 {
+			slots = append(slots, s.concurrencyLimiter("project|"+p.Name, p.MaxInFlight))
+		}
+		if globalSlots != nil {
+			slots = append(slots, globalSlots)
+		}
+		inFlight := s.inFlightMiddleware(p.Name, slots...)
+
+		var shaping gin.HandlerFunc
+		if cfg.SyntheticShaping {
+			if shaping = shapingMiddleware(p); shaping != nil {
+				utils.StratumLog("WARN", "Synthetic shaping enabled for project '%s': delay %s, bandwidth %d B/s.", p.Name, p.SyntheticDelay, p.SyntheticBandwidth)
+			}
+		}
 
-		utils.StratumLog("INFO", "Registering route for project '%s': %s", project.Name, project.Route)
+		timeout := requestTimeoutMiddleware(p.RequestTimeout)
+		slow := s.slowRequestMiddleware(p)
 
-		// Convert placeholders {id} to gin-style :id
-		ginRoute := convertToGinRoute(project.Route)
-		s.router.GET(ginRoute, s.createHandler(project))
+		for _, route := range projectRoutes(p) {
+			utils.StratumLog("DEBUG", "Registering route for project '%s': %s", p.Name, route)
+
+			name := p.Name
+			setProject := func(c *gin.Context) { c.Set(accessLogProjectKey, name) }
+			handlers := []gin.HandlerFunc{setProject}
+			if slow != nil {
+				handlers = append(handlers, slow)
+			}
+			if limit != nil {
+				handlers = append(handlers, limit)
+			}
+			handlers = append(handlers, inFlight)
+			if shaping != nil {
+				handlers = append(handlers, shaping)
+			}
+			if timeout != nil {
+				handlers = append(handlers, timeout)
+			}
+			handlers = append(handlers, s.createHandler(runtimes[p.Name], runtimes, route))
+
+			// Convert placeholders {id} to gin-style :id
+			ginRoute := convertToGinRoute(route)
+			base.Match([]string{http.MethodGet, http.MethodHead}, ginRoute, handlers...)
+			base.OPTIONS(ginRoute, setProject, handleProjectOptions)
+		}
 	}
+	return router, runtimes, nil
 }
 
-// Returns a new gin.HandlerFunc for a given project configuration.
-func (s *Server) createHandler(p config.Project) gin.HandlerFunc {
-	source, err := datasource.NewDataSource(p, s.dbManager, s.config)
+// projectRuntime bundles a project with the components built for it when
+// the router is set up.
+type projectRuntime struct {
+	project config.Project
+	source  datasource.DataSource
+	chain   transform.Chain
+	hooks   *hooks.Hooks
+	codec   idcodec.Codec   // nil if public IDs are used as-is
+	idChain transform.Chain // normalizes IDs from URLs
+	idRegex *regexp.Regexp  // nil if IDs are not checked against a pattern
+	cache   cache.Cache     // nil for the shared cache
+
+	// Header sending surrogate keys to a CDN, if any
+	surrogateKeyHeader string
+
+	// Ended when the runtime is replaced by a reload or the server shuts
+	// down, which stops revalidating the origins fetched through it.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Creates the data source, transform chain, hooks, ID codec and cache of a
+// project.
+func (s *Server) newProjectRuntime(cfg *config.AppConfig, p config.Project) (*projectRuntime, error) {
+	rt, err := compileProject(p)
 	if err != nil {
-		utils.StratumLog("FATAL", "Could not create data source for project '%s': %v", p.Name, err)
-		os.Exit(1)
+		return nil, err
 	}
 
+	if factory, ok := s.sourceFactories[p.SourceType]; ok {
+		rt.source, err = factory(p, cfg)
+	} else {
+		rt.source, err = datasource.NewDataSource(p, s.dbManager, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create data source for project '%s': %w", p.Name, err)
+	}
+	if db, ok := rt.source.(*datasource.DatabaseSource); ok {
+		db.OnQuery = s.queryObserver(p)
+	}
+
+	rt.cache, err = s.projectCache(cfg, p)
+	if err != nil {
+		return nil, fmt.Errorf("could not open cache for project '%s': %w", p.Name, err)
+	}
+	rt.surrogateKeyHeader = cfg.SurrogateKeyHeader
+	rt.ctx, rt.cancel = context.WithCancel(context.Background())
+	return rt, nil
+}
+
+// Builds the parts of a project's runtime that need no connections: the
+// transform chain, hooks and ID codec.
+func compileProject(p config.Project) (*projectRuntime, error) {
+	spec := p.Transform
+	if p.SourceCharset != "" {
+		spec = "to-utf8:" + p.SourceCharset + " | " + spec
+	}
+	chain, err := transform.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transform chain for project '%s': %w", p.Name, err)
+	}
+	// Applied by the data source, parsed here so it is validated with the
+	// rest of the project.
+	if _, err := transform.Parse(p.QueryTransform); err != nil {
+		return nil, fmt.Errorf("invalid query transform for project '%s': %w", p.Name, err)
+	}
+
+	h, err := hooks.Compile(p.Hooks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hooks for project '%s': %w", p.Name, err)
+	}
+
+	rt := &projectRuntime{project: p, chain: chain, hooks: h}
+	rt.idChain, err = transform.Parse(p.IDTransform)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID transform for project '%s': %w", p.Name, err)
+	}
+	if p.IDPattern != "" {
+		// The pattern must match the whole ID, not just part of it.
+		rt.idRegex, err = regexp.Compile(`^(?:` + p.IDPattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID pattern for project '%s': %w", p.Name, err)
+		}
+	}
+	if p.IDCodec != "" {
+		rt.codec, err = idcodec.New(p.IDCodec, idcodec.Options{Salt: p.IDCodecSalt, MinLength: p.IDCodecMinLength})
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID codec for project '%s': %w", p.Name, err)
+		}
+	}
+	return rt, nil
+}
+
+// Reports whether an ID from a URL passes the project's ID_PATTERN and
+// ID_MAX_LENGTH checks.
+func (rt *projectRuntime) validID(id string) bool {
+	if limit := rt.project.IDMaxLength; limit > 0 && len(id) > limit {
+		return false
+	}
+	return rt.idRegex == nil || rt.idRegex.MatchString(id)
+}
+
+// Returns the route patterns a project is served on.
+func projectRoutes(p config.Project) []string {
+	if len(p.Routes) == 0 {
+		return []string{p.Route}
+	}
+	return p.Routes
+}
+
+// Returns a new gin.HandlerFunc for one of a project's routes. Other
+// projects are passed in so a SOURCE hook can delegate to their sources.
+func (s *Server) createHandler(rt *projectRuntime, runtimes map[string]*projectRuntime, route string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		p, source, chain := rt.project, rt.source, rt.chain
+
 		var idValue string
 		var cacheKey string
+		params := requestParams(c, p)
 
 		// If the project has no placeholder (allowed for API source types),
 		// treat the route as a direct endpoint and do not require an ID in the URL.
 		if p.IdPlaceholder == "" {
 			idValue = ""
-			cacheKey = fmt.Sprintf("%s:direct", p.Name)
+			cacheKey = cacheKeyFor(p, "direct", params)
 		} else {
 			idValue = strings.TrimPrefix(c.Param(p.IdPlaceholder), "/")
 
-			endPlaceholder := strings.Index(p.Route, "}")
-			if endPlaceholder != -1 && endPlaceholder < len(p.Route)-1 {
-				suffix := p.Route[endPlaceholder+1:]
+			endPlaceholder := strings.LastIndex(route, "}")
+			if endPlaceholder != -1 && endPlaceholder < len(route)-1 {
+				suffix := route[endPlaceholder+1:]
 				idValue = strings.TrimSuffix(idValue, suffix)
 			}
+			if idValue != "" && len(p.IdColumns) > 1 {
+				idValue = compositeID(c, p, idValue)
+			}
+
+			if len(rt.idChain) > 0 {
+				normalized, err := rt.idChain.Transform([]byte(idValue))
+				if err != nil {
+					c.String(http.StatusBadRequest, "Invalid ID")
+					return
+				}
+				idValue = string(normalized)
+			}
 
 			if idValue == "" {
 				c.String(http.StatusBadRequest, "ID not found in URL")
 				return
 			}
+			if !rt.validID(idValue) {
+				c.String(http.StatusBadRequest, "Invalid ID")
+				return
+			}
+
+			// Public IDs that do not decode are reported as unknown, not
+			// malformed, so they reveal nothing about valid IDs.
+			if rt.codec != nil {
+				decoded, err := rt.codec.Decode(idValue)
+				if err != nil {
+					c.String(http.StatusNotFound, "Not Found")
+					return
+				}
+				idValue = decoded
+			}
+
+			cacheKey = cacheKeyFor(p, idValue, params)
+		}
+
+		if rt.hooks != nil {
+			env := hookEnv(c, p, idValue)
+
+			reject, err := rt.hooks.Reject(env)
+			if err == nil && reject {
+				c.String(http.StatusForbidden, "Forbidden")
+				return
+			}
+
+			var sourceName string
+			if err == nil {
+				sourceName, err = rt.hooks.Source(env)
+			}
+			if err == nil && sourceName != "" && sourceName != p.Name {
+				other, ok := runtimes[sourceName]
+				if !ok {
+					err = fmt.Errorf("SOURCE hook selected unknown project '%s'", sourceName)
+				} else if cacheBackendKey(other.project) != cacheBackendKey(p) {
+					// Its payloads must not end up in another backend.
+					err = fmt.Errorf("SOURCE hook selected project '%s', which uses another cache backend", sourceName)
+				} else {
+					source, chain = other.source, other.chain
+					cacheKey += "@" + sourceName
+				}
+			}
+
+			if err == nil {
+				p.CacheTTL, err = rt.hooks.CacheTTL(env, p.CacheTTL)
+			}
+
+			var headers map[string]string
+			if err == nil {
+				headers, err = rt.hooks.Headers(env)
+			}
+			for name, value := range headers {
+				c.Header(name, value)
+			}
+
+			if err != nil {
+				utils.StratumLogContext(c.Request.Context(), "ERROR", "Hook evaluation failed for project '%s': %v", p.Name, err)
+				c.String(http.StatusInternalServerError, "Internal Server Error!")
+				return
+			}
+		}
 
-			cacheKey = fmt.Sprintf("%s:%s", p.Name, idValue)
+		if rt.surrogateKeyHeader != "" {
+			setSurrogateKeys(c, rt.surrogateKeyHeader, surrogateKeysFor(p, idValue, params))
 		}
 
 		ctx := c.Request.Context()
@@ -104,79 +592,560 @@ func (s *Server) createHandler(p config.Project) gin.HandlerFunc {
 		cacheControlHeader := c.GetHeader("Cache-Control")
 		bypassCache := pragmaHeader == "no-cache" || strings.Contains(cacheControlHeader, "no-cache")
 
+		if p.ImageResize {
+			opts, err := imaging.ParseOptions(c.Request.URL.Query(), p.ImageMaxDimension)
+			if err != nil {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			if !opts.IsZero() {
+				s.serveImageVariant(c, p, source, chain, idValue, cacheKey, params, opts, bypassCache)
+				return
+			}
+		}
+
+		// Partial content is never cached, so ranges go straight upstream.
+		if wantsRangePassthrough(c, p, len(chain)) && s.serveRange(c, p, source, idValue, params) {
+			return
+		}
+
 		if !bypassCache {
-			cachedData, err := s.cache.Get(ctx, cacheKey)
+			entry, err := s.loadEntry(ctx, p, cacheKey)
 			if err != nil {
-				utils.StratumLog("ERROR", "Cache lookup failed for key '%s': %v", cacheKey, err)
+				utils.StratumLogContext(ctx, "ERROR", "Cache lookup failed for key '%s': %v", cacheKey, err)
 			}
 
-			if cachedData != nil {
-				utils.StratumLog("INFO", "CACHE HIT: Serving '%s' from cache.", cacheKey)
-				c.Header("Content-Type", p.ContentType)
+			if entry != nil {
+				utils.StratumLogContext(ctx, "DEBUG", "CACHE HIT: Serving '%s' from cache.", cacheKey)
+				if entry.ContentType == "" {
+					entry.ContentType = contentTypeFor(p, entry.Data)
+				}
 				c.Header("X-Cache-Status", "HIT")
-				c.Header("Cache-Control", fmt.Sprintf("public, max-age=%.0f", p.CacheTTL.Seconds()))
-				c.Data(http.StatusOK, p.ContentType, cachedData)
+				if !entry.FetchedAt.IsZero() {
+					c.Header("Age", strconv.Itoa(int(time.Since(entry.FetchedAt).Seconds())))
+				}
+				s.flagContentType(c, p, entry)
+				writeEntry(c, p, entry)
+				s.metrics.ObservePayload(p.Name, len(entry.Data))
+				s.metrics.ObserveCacheHit(p.Name, len(entry.Data))
+				s.advisor.ObserveHit(p.Name, cacheKey)
 				return
 			}
 		}
 
 		if bypassCache {
-			utils.StratumLog("INFO", "CACHE BYPASS: Client headers triggered cache bypass for key '%s'.", cacheKey)
+			utils.StratumLogContext(ctx, "DEBUG", "CACHE BYPASS: Client headers triggered cache bypass for key '%s'.", cacheKey)
 			c.Header("X-Cache-Status", "BYPASS")
 		} else {
-			utils.StratumLog("INFO", "CACHE MISS: Key '%s' not found.", cacheKey)
+			utils.StratumLogContext(ctx, "DEBUG", "CACHE MISS: Key '%s' not found.", cacheKey)
 			c.Header("X-Cache-Status", "MISS")
+			s.metrics.ObserveCacheMiss(p.Name)
 		}
 
-		data, err := source.Fetch(idValue)
+		entry, err := s.fetchShared(ctx, p, source, chain, idValue, cacheKey, params)
 		if err != nil {
-			utils.StratumLog("ERROR", "Data source fetch failed for project '%s': %v", p.Name, err)
-			c.String(http.StatusInternalServerError, "Internal Server Error!")
+			stale := s.loadStale(ctx, p, cacheKey, err)
+			if stale == nil {
+				s.writeFetchError(c, p, err)
+				return
+			}
+			if stale.ContentType == "" {
+				stale.ContentType = contentTypeFor(p, stale.Data)
+			}
+			c.Header("X-Cache-Status", "STALE")
+			if !stale.FetchedAt.IsZero() {
+				c.Header("Age", strconv.Itoa(int(time.Since(stale.FetchedAt).Seconds())))
+			}
+			s.flagContentType(c, p, stale)
+			writeEntry(c, p, stale)
+			s.metrics.ObservePayload(p.Name, len(stale.Data))
 			return
 		}
 
-		if data == nil {
+		if entry == nil {
 			c.String(http.StatusNotFound, "Not Found")
 			return
 		}
 
-		err = s.cache.Set(ctx, cacheKey, data, p.CacheTTL)
-		if err != nil {
-			utils.StratumLog("ERROR", "Failed to set cache for key '%s': %v", cacheKey, err)
-		} else {
-			utils.StratumLog("INFO", "CACHE SET: Stored key '%s' with TTL %s.", cacheKey, p.CacheTTL)
+		s.flagContentType(c, p, entry)
+
+		if len(p.PrefetchPatterns) > 0 && idValue != "" {
+			s.prefetch(ctx, p, source, chain, idValue, params)
 		}
 
-		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%.0f", p.CacheTTL.Seconds()))
-		c.Data(http.StatusOK, p.ContentType, data)
+		writeEntry(c, p, entry)
+		s.metrics.ObservePayload(p.Name, len(entry.Data))
 	}
 }
 
-// Start runs the HTTP server.
-func (s *Server) Start() {
-	utils.StratumLog("INFO", "Server starting on port %s...", s.config.ServerPort)
-	err := s.router.Run(":" + s.config.ServerPort)
+// Writes a payload along with its validators. A request whose If-None-Match
+// matches the payload's ETag, or without one whose If-Modified-Since is not
+// before its Last-Modified, gets a 304 Not Modified, a request with a Range
+// header the ranges it asks for, and a HEAD request the headers alone.
+func writeEntry(c *gin.Context, p config.Project, e *cacheEntry) {
+	c.Header("Content-Type", e.ContentType)
+	c.Header("ETag", e.ETag)
+	if e.LastModified != "" {
+		c.Header("Last-Modified", e.LastModified)
+	}
+	c.Header("Accept-Ranges", "bytes")
+	if cacheControl := cacheControlOf(p); cacheControl != "" {
+		c.Header("Cache-Control", cacheControl)
+	}
+	if etagMatches(c.GetHeader("If-None-Match"), e.ETag) || e.notModifiedSince(c.Request.Header) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if c.GetHeader("Range") != "" {
+		// Answers 206 Partial Content, or 416 for unsatisfiable ranges, and
+		// the full payload if If-Range does not match the ETag.
+		http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(e.Data))
+		return
+	}
+	c.Header("Content-Length", strconv.Itoa(len(e.Data)))
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+	c.Data(http.StatusOK, e.ContentType, e.Data)
+}
+
+// Returns the Cache-Control header of a project's payloads, empty if it
+// sends none.
+func cacheControlOf(p config.Project) string {
+	if p.CacheControl == "off" {
+		return ""
+	}
+	if p.CacheTTL == hooks.NoCache {
+		return "no-store"
+	}
+	visibility := p.CacheControl
+	if visibility == "" {
+		visibility = "public"
+	}
+	directives := []string{visibility, fmt.Sprintf("max-age=%.0f", p.CacheTTL.Seconds())}
+	if p.CacheSMaxAge > 0 {
+		directives = append(directives, fmt.Sprintf("s-maxage=%.0f", p.CacheSMaxAge.Seconds()))
+	}
+	if p.CacheStaleWhileRevalidate > 0 {
+		directives = append(directives, fmt.Sprintf("stale-while-revalidate=%.0f", p.CacheStaleWhileRevalidate.Seconds()))
+	}
+	if p.CacheImmutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// Builds the environment hook expressions are evaluated in.
+func hookEnv(c *gin.Context, p config.Project, idValue string) hooks.Env {
+	query := make(map[string]string)
+	for name, values := range c.Request.URL.Query() {
+		query[name] = values[0]
+	}
+	header := make(map[string]string)
+	for name, values := range c.Request.Header {
+		header[name] = values[0]
+	}
+
+	return hooks.Env{
+		Project: p.Name,
+		ID:      idValue,
+		Request: hooks.Request{
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			Query:    query,
+			Header:   header,
+			ClientIP: c.ClientIP(),
+		},
+	}
+}
+
+// Fetches and caches a payload like fetchAndStore, but concurrent misses
+// of the same key share a single upstream fetch. The fetch is detached from
+// the requests waiting on it, so one client going away does not fail the
+// others; a caller whose context ends stops waiting.
+func (s *Server) fetchShared(ctx context.Context, p config.Project, source datasource.DataSource, chain transform.Chain, idValue, cacheKey string, params datasource.Params) (*cacheEntry, error) {
+	result := s.fetches.DoChan(cacheKey, func() (interface{}, error) {
+		// The fetch outlives clients that give up, but not the request's
+		// deadline.
+		fetchCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithDeadline(fetchCtx, deadline)
+			defer cancel()
+		}
+		return s.fetchAndStore(fetchCtx, p, source, chain, idValue, cacheKey, params)
+	})
+
+	select {
+	case r := <-result:
+		if r.Shared {
+			utils.StratumLogContext(ctx, "DEBUG", "CACHE MISS SHARED: Concurrent requests for '%s' used one fetch.", cacheKey)
+		}
+		entry, _ := r.Val.(*cacheEntry)
+		return entry, r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Fetches an ID from the source, applies the project's transform chain and
+// stores the result in the cache, unless a hook set its TTL to
+// hooks.NoCache. It returns a nil entry if the ID was not found.
+func (s *Server) fetchAndStore(ctx context.Context, p config.Project, source datasource.DataSource, chain transform.Chain, idValue, cacheKey string, params datasource.Params) (*cacheEntry, error) {
+	store := s.cacheFor(p.Name)
+	var data []byte
+	var origin *datasource.Origin
+	var previous *validatedEntry
+	var err error
+	originSource, hasOrigin := source.(datasource.OriginSource)
+	cacheable := p.CacheTTL != hooks.NoCache
+	conditional := hasOrigin && p.ConditionalRevalidation > 0 && cacheable
+	if conditional {
+		previous = s.loadValidators(ctx, p, cacheKey)
+	}
+	if hasOrigin && (p.RevalidateInterval > 0 || conditional || p.ModifiedColumn != "") {
+		data, origin, err = originSource.FetchWithOrigin(ctx, idValue, params, previous.origin())
+	} else {
+		data, err = source.Fetch(ctx, idValue, params)
+	}
+	s.metrics.ObserveUpstreamFetch(p.Name, len(data), err != nil && !errors.Is(err, datasource.ErrNotModified))
+	if errors.Is(err, datasource.ErrNotModified) {
+		// The stored payload was transformed and checked when it was first
+		// fetched, so it goes straight back into the cache.
+		data = previous.Data
+		entry := newCacheEntry(data, contentTypeFor(p, data))
+		entry.LastModified = rowLastModified(origin)
+		if err := s.checkEntryContentType(ctx, p, cacheKey, entry); err != nil {
+			return nil, err
+		}
+		if err := store.Set(ctx, cacheKey, entry.encode(), entryTTL(p, entry, conditional, origin)); err != nil {
+			utils.StratumLogContext(ctx, "ERROR", "Failed to set cache for key '%s': %v", cacheKey, err)
+			return entry, nil
+		}
+		utils.StratumLogContext(ctx, "DEBUG", "CACHE REVALIDATED: Origin of '%s' unchanged, stored again with TTL %s.", cacheKey, p.CacheTTL)
+		s.advisor.ObserveStore(p.Name, cacheKey, len(data), p.CacheTTL)
+		s.storeValidators(ctx, p, cacheKey, origin)
+		s.storeStale(ctx, p, cacheKey, entry)
+		if origin.URL != "" && p.RevalidateInterval > 0 {
+			s.trackOrigin(p, originSource, cacheKey, origin, entrySurrogateKeys(p, idValue, params))
+		}
+		return entry, nil
+	}
 	if err != nil {
-		utils.StratumLog("FATAL", "Failed to start server: %v", err)
-		os.Exit(1)
+		utils.StratumLogContext(ctx, "ERROR", "Data source fetch failed for project '%s': %v", p.Name, err)
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	data, err = chain.Transform(data)
+	if err != nil {
+		utils.StratumLogContext(ctx, "ERROR", "Transform failed for project '%s': %v", p.Name, err)
+		return nil, err
+	}
+
+	entry := newCacheEntry(data, contentTypeFor(p, data))
+	entry.LastModified = rowLastModified(origin)
+	if err := s.checkEntryContentType(ctx, p, cacheKey, entry); err != nil {
+		return nil, err
+	}
+	if !cacheable {
+		return entry, nil
+	}
+	err = store.Set(ctx, cacheKey, entry.encode(), entryTTL(p, entry, conditional, origin))
+	if err != nil {
+		utils.StratumLogContext(ctx, "ERROR", "Failed to set cache for key '%s': %v", cacheKey, err)
+	} else {
+		utils.StratumLogContext(ctx, "DEBUG", "CACHE SET: Stored key '%s' with TTL %s.", cacheKey, p.CacheTTL)
+		s.advisor.ObserveStore(p.Name, cacheKey, len(data), p.CacheTTL)
+		if conditional {
+			s.storeValidators(ctx, p, cacheKey, origin)
+		}
+		s.storeStale(ctx, p, cacheKey, entry)
+		if origin != nil && origin.URL != "" && p.RevalidateInterval > 0 {
+			s.trackOrigin(p, originSource, cacheKey, origin, entrySurrogateKeys(p, idValue, params))
+		}
+	}
+
+	return entry, nil
+}
+
+// Responds to a failed fetch. Requests past their deadline get 504, upstream
+// error statuses are answered as the project's UPSTREAM_STATUS_MAP says, and
+// failures during a project's warm-up period are reported as 503 with a
+// Retry-After for the rest of the period, so clients and load balancers back
+// off instead of seeing 500s.
+func (s *Server) writeFetchError(c *gin.Context, p config.Project, err error) {
+	if errors.Is(err, errContentTypeMismatch) {
+		c.String(http.StatusBadGateway, "Bad Gateway")
+		return
 	}
+
+	if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		utils.StratumLogContext(c.Request.Context(), "ERROR", "Request for project '%s' timed out after %s.", p.Name, p.RequestTimeout)
+		c.String(http.StatusGatewayTimeout, "Gateway Timeout")
+		return
+	}
+
+	var statusErr *datasource.StatusError
+	if errors.As(err, &statusErr) {
+		if mapping, ok := upstreamStatusMapping(p, statusErr.StatusCode); ok {
+			retryAfter := ""
+			if mapping.Status == http.StatusTooManyRequests || mapping.Status == http.StatusServiceUnavailable {
+				retryAfter = statusErr.RetryAfter
+			}
+			if retryAfter == "" && mapping.RetryAfter > 0 {
+				retryAfter = strconv.Itoa(int(math.Ceil(mapping.RetryAfter.Seconds())))
+			}
+			if retryAfter != "" {
+				c.Header("Retry-After", retryAfter)
+			}
+			c.String(mapping.Status, http.StatusText(mapping.Status))
+			return
+		}
+	}
+
+	if remaining := p.WarmupPeriod - time.Since(s.started); remaining > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+		c.String(http.StatusServiceUnavailable, "Service Unavailable")
+		return
+	}
+
+	c.String(http.StatusInternalServerError, "Internal Server Error!")
+}
+
+// Returns the response to an upstream error status, from a rule for the
+// status itself or else for its class.
+func upstreamStatusMapping(p config.Project, status int) (config.StatusMapping, bool) {
+	if mapping, ok := p.UpstreamStatusMap[strconv.Itoa(status)]; ok {
+		return mapping, true
+	}
+	mapping, ok := p.UpstreamStatusMap[fmt.Sprintf("%dxx", status/100)]
+	return mapping, ok
+}
+
+// Start serves on every LISTEN address, over HTTPS if a TLS certificate is
+// configured, and the admin API on ADMIN_LISTEN if set.
+func (s *Server) Start() {
+	cfg := s.Config()
+	var tlsConfig *tls.Config
+	if cfg.TLSCertFile != "" {
+		var err error
+		tlsConfig, err = tlsConfigFor(cfg.TLSCertFile, cfg.TLSKeyFile, httpsPortOf(cfg.Listen), cfg.HTTPRedirectPort)
+		if err != nil {
+			utils.StratumLog("FATAL", "Failed to start server: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	maxHeaderBytes := serverMaxHeaderBytes(cfg)
+	errs := make(chan error, len(cfg.Listen)+len(cfg.AdminListen))
+	var handler http.Handler = s
+	if len(cfg.AdminListen) > 0 {
+		handler = s.publicHandler()
+		utils.StratumLog("INFO", "Serving the admin API and metrics on %s.", strings.Join(cfg.AdminListen, ", "))
+		s.trackServers(serveAll(cfg.AdminListen, s.adminHandler(), tlsConfig, maxHeaderBytes, errs))
+	}
+	if tlsConfig != nil {
+		utils.StratumLog("INFO", "Server starting with TLS on %s...", strings.Join(cfg.Listen, ", "))
+	} else {
+		utils.StratumLog("INFO", "Server starting on %s...", strings.Join(cfg.Listen, ", "))
+	}
+	s.trackServers(serveAll(cfg.Listen, handler, tlsConfig, maxHeaderBytes, errs))
+
+	utils.StratumLog("FATAL", "Failed to start server: %v", <-errs)
+	os.Exit(1)
+}
+
+func (s *Server) trackServers(servers []*http.Server) {
+	s.serversMu.Lock()
+	s.httpServers = append(s.httpServers, servers...)
+	s.serversMu.Unlock()
+}
+
+// Shutdown stops the server gracefully: the listeners are closed, requests
+// in progress are let finish, and then background prefetches. Revalidation
+// stops, including checks in progress. It returns early, with the context's
+// error, if the context ends first. Caches and databases are closed only
+// after it returns, so nothing writes to them once closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		close(s.stopping)
+		s.mu.RLock()
+		runtimes := s.runtimes
+		s.mu.RUnlock()
+		s.stopRuntimes(runtimes)
+	})
+
+	s.serversMu.Lock()
+	servers := s.httpServers
+	s.serversMu.Unlock()
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Returns the port HTTP is redirected to for HTTPS: that of the first TCP
+// address served on.
+func httpsPortOf(addresses []string) string {
+	for _, address := range addresses {
+		if _, port, err := net.SplitHostPort(address); err == nil && !strings.HasPrefix(address, "unix:") {
+			return port
+		}
+	}
+	return "443"
+}
+
+// Returns the Content-Type to serve a payload with, sniffing it from the
+// payload's leading bytes when the project's sniffing mode asks for it.
+// Text from projects normalized to UTF-8 is labelled as such.
+func contentTypeFor(p config.Project, data []byte) string {
+	contentType := p.ContentType
+	switch p.ContentTypeSniff {
+	case "fallback":
+		if p.ContentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+	case "override":
+		if sniffed := http.DetectContentType(data); sniffed != "application/octet-stream" || p.ContentType == "" {
+			contentType = sniffed
+		}
+	}
+
+	if p.SourceCharset != "" {
+		return withUTF8Charset(contentType)
+	}
+	return contentType
+}
+
+// Collects the request values a project forwards to its source.
+func requestParams(c *gin.Context, p config.Project) datasource.Params {
+	var params datasource.Params
+	query := c.Request.URL.Query()
+	for _, name := range p.QueryParams {
+		if values, ok := query[name]; ok {
+			if params.Query == nil {
+				params.Query = url.Values{}
+			}
+			params.Query[name] = values
+		}
+	}
+	for _, name := range p.ForwardHeaders {
+		if values := c.Request.Header.Values(name); len(values) > 0 {
+			if params.Header == nil {
+				params.Header = http.Header{}
+			}
+			params.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	return params
+}
+
+// Returns the cache key under which an ID of a project is stored. Forwarded
+// query parameters and headers are part of the key since they can change
+// the content.
+func cacheKeyFor(p config.Project, idValue string, params datasource.Params) string {
+	if p.CacheKeyTemplate != "" {
+		return expandCacheKey(p, idValue, params)
+	}
+
+	key := fmt.Sprintf("%s:%s", p.Name, idValue)
+	if len(params.Query) > 0 {
+		key += "?" + params.Query.Encode()
+	}
+	if len(params.Header) > 0 {
+		key += "#" + url.Values(params.Header).Encode()
+	}
+	return key
+}
+
+// Fills in a project's cache key template.
+func expandCacheKey(p config.Project, idValue string, params datasource.Params) string {
+	return expandKeyTemplate(p.CacheKeyTemplate, p, idValue, params)
+}
+
+// Fills in a cache or surrogate key template. Placeholders were validated
+// when the configuration was loaded.
+func expandKeyTemplate(template string, p config.Project, idValue string, params datasource.Params) string {
+	return config.CacheKeyPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		m := config.CacheKeyPlaceholder.FindStringSubmatch(placeholder)
+		switch m[1] {
+		case "project":
+			return p.Name
+		case "route":
+			return p.Route
+		case "id":
+			return idValue
+		case "query":
+			if m[2] != "" {
+				return params.Query.Get(m[2])
+			}
+			return params.Query.Encode()
+		case "headers":
+			return url.Values(params.Header).Encode()
+		case "header":
+			return params.Header.Get(m[2])
+		}
+		return placeholder
+	})
+}
+
+// Answers OPTIONS requests to project routes, which clients send to probe
+// the methods they support.
+func handleProjectOptions(c *gin.Context) {
+	c.Header("Allow", "GET, HEAD, OPTIONS")
+	c.Status(http.StatusNoContent)
 }
 
 // Converts a placeholders route (/path/{id}) to a gin-style route (/path/:id).
+// Placeholders before the last, which make up a composite key, span whole
+// path segments and become plain parameters.
 func convertToGinRoute(route string) string {
-	start := strings.Index(route, "{")
+	start := strings.LastIndex(route, "{")
 	if start == -1 {
 		return route
 	}
-	end := strings.Index(route, "}")
+	end := strings.Index(route[start:], "}")
 	if end == -1 {
 		return route
 	}
+	end += start
 
+	head := keyPlaceholder.ReplaceAllString(route[:start], ":$1")
 	placeholder := route[start+1 : end]
 	if end < len(route)-1 {
-		return route[:start] + "*" + placeholder
+		return head + "*" + placeholder
 	}
 
-	return route[:start] + ":" + placeholder
+	return head + ":" + placeholder
+}
+
+// Matches the leading placeholders of a composite key route.
+var keyPlaceholder = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// Prefixes the ID taken from the last placeholder of a composite key route
+// with the values of the others, separated by "/".
+func compositeID(c *gin.Context, p config.Project, idValue string) string {
+	parts := make([]string, 0, len(p.IdColumns))
+	for _, name := range p.IdColumns[:len(p.IdColumns)-1] {
+		parts = append(parts, c.Param(name))
+	}
+	return strings.Join(append(parts, idValue), "/")
 }
@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/internal/hooks"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -19,7 +24,7 @@ type mockDataSource struct {
 	FetchFunc func(id string) ([]byte, error)
 }
 
-func (m *mockDataSource) Fetch(id string) ([]byte, error) {
+func (m *mockDataSource) Fetch(ctx context.Context, id string, params datasource.Params) ([]byte, error) {
 	if m.FetchFunc != nil {
 		return m.FetchFunc(id)
 	}
@@ -28,8 +33,9 @@ func (m *mockDataSource) Fetch(id string) ([]byte, error) {
 
 // mockCache allows faking the behavior of a cache for tests.
 type mockCache struct {
-	GetFunc func(ctx context.Context, key string) ([]byte, error)
-	SetFunc func(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	GetFunc    func(ctx context.Context, key string) ([]byte, error)
+	SetFunc    func(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	DeleteFunc func(ctx context.Context, key string) error
 }
 
 func (m *mockCache) Get(ctx context.Context, key string) ([]byte, error) {
@@ -46,6 +52,13 @@ func (m *mockCache) Set(ctx context.Context, key string, value []byte, ttl time.
 	return nil
 }
 
+func (m *mockCache) Delete(ctx context.Context, key string) error {
+	if m.DeleteFunc != nil {
+		return m.DeleteFunc(ctx, key)
+	}
+	return nil
+}
+
 func (m *mockCache) Close() error { return nil }
 
 func TestConvertToGinRoute(t *testing.T) {
@@ -58,6 +71,8 @@ func TestConvertToGinRoute(t *testing.T) {
 		{"Standard placeholder", "/users/{id}", "/users/:id"},
 		{"Placeholder with suffix", "/users/{id}/profile", "/users/*id"},
 		{"No placeholder", "/users", "/users"},
+		{"Composite key", "/tenants/{tenant_id}/users/{user_id}", "/tenants/:tenant_id/users/:user_id"},
+		{"Composite key with suffix", "/tenants/{tenant_id}/users/{user_id}/avatar", "/tenants/:tenant_id/users/*user_id"},
 		{"Malformed placeholder start", "/users/{id", "/users/{id"},
 		{"Malformed placeholder end", "/users/id}", "/users/id}"},
 	}
@@ -248,7 +263,7 @@ func (s *Server) createTestHandler(p config.Project, source *mockDataSource) gin
 		}
 
 		// Fetch from source
-		data, err := source.Fetch(idValue)
+		data, err := source.Fetch(ctx, idValue, datasource.Params{})
 		if err != nil {
 			c.String(http.StatusInternalServerError, "Internal Server Error!")
 			return
@@ -264,3 +279,731 @@ func (s *Server) createTestHandler(p config.Project, source *mockDataSource) gin
 		c.Data(http.StatusOK, p.ContentType, data)
 	}
 }
+
+// newAPIProjectServer starts a fake upstream and a Server with a single API
+// project pointing at it, so the real createHandler can be exercised.
+func newAPIProjectServer(t *testing.T, upstream http.HandlerFunc, configure func(p *config.Project)) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	ts := httptest.NewServer(upstream)
+	t.Cleanup(ts.Close)
+
+	p := config.Project{
+		Name:          "test_project",
+		Route:         "/test/{id}",
+		IdColumn:      "id",
+		IdPlaceholder: "id",
+		ContentType:   "text/plain",
+		CacheTTL:      1 * time.Minute,
+		SourceType:    "api",
+		APIEndpoint:   ts.URL + "/items/{id}",
+		APIAuthType:   "none",
+	}
+	if configure != nil {
+		configure(&p)
+	}
+
+	return NewServer(&config.AppConfig{Projects: []config.Project{p}, PrefetchConcurrency: 1}, nil, &mockCache{})
+}
+
+func TestCreateHandler_Transform(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"avatar":"aGVsbG8="}}`))
+	}, func(p *config.Project) {
+		p.Transform = "json-extract:data.avatar | base64-decode"
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+
+	upstream := s.metrics.Snapshot()["test_project"].Upstream
+	assert.Equal(t, uint64(1), upstream.Fetches)
+	assert.Equal(t, uint64(len(`{"data":{"avatar":"aGVsbG8="}}`)), upstream.Bytes)
+}
+
+func TestCreateHandler_MultipleRoutes(t *testing.T) {
+	var fetched []string
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		w.Write([]byte("avatar"))
+	}, func(p *config.Project) {
+		p.Routes = []string{"/test/{id}", "/u/{id}.png"}
+	})
+
+	for _, path := range []string{"/test/7", "/u/7.png"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "avatar", w.Body.String(), path)
+	}
+	assert.Equal(t, []string{"/items/7", "/items/7"}, fetched, "the suffix of each route is stripped from the ID")
+}
+
+func TestProjectRouteMethods(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("payload"))
+	}, nil)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodOptions, "/test/1")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+	assert.Empty(t, w.Body.String())
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		w = serve(method, "/test/1")
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code, method)
+		assert.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"), method)
+	}
+
+	w = serve(http.MethodPost, "/health")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET", w.Header().Get("Allow"))
+
+	w = serve(http.MethodPost, "/unknown/1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBasePath(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("avatar"))
+	}, nil)
+	cfg := *s.Config()
+	cfg.BasePath = "/stratum"
+	cfg.AdminToken = "secret"
+	assert.NoError(t, s.Reload(&cfg))
+
+	for path, status := range map[string]int{
+		"/stratum/test/7":      http.StatusOK,
+		"/stratum/health":      http.StatusOK,
+		"/stratum/metrics":     http.StatusOK,
+		"/stratum/admin/stats": http.StatusUnauthorized,
+		"/test/7":              http.StatusNotFound,
+		"/health":              http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		s.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+}
+
+func TestExpandPrefetchIDs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		patterns []string
+		id       string
+		expected []string
+	}{
+		{"Sizes", []string{"{id}_small", "{id}_large"}, "42", []string{"42_small", "42_large"}},
+		{"Adjacent pages", []string{"{id-1}", "{id+1}"}, "7", []string{"6", "8"}},
+		{"No negative IDs", []string{"{id-1}", "{id+1}"}, "0", []string{"1"}},
+		{"Arithmetic on non-numeric ID", []string{"{id+1}", "{id}.webp"}, "abc", []string{"abc.webp"}},
+		{"Requested ID skipped", []string{"{id}", "{id+0}"}, "5", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, expandPrefetchIDs(tc.patterns, tc.id))
+		})
+	}
+}
+
+func TestCreateHandler_Prefetch(t *testing.T) {
+	fetched := make(chan string, 4)
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fetched <- r.URL.Path
+		w.Write([]byte("page"))
+	}, func(p *config.Project) {
+		p.PrefetchPatterns = []string{"{id+1}"}
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, "/items/1", <-fetched)
+	select {
+	case path := <-fetched:
+		assert.Equal(t, "/items/2", path)
+	case <-time.After(2 * time.Second):
+		t.Fatal("related ID was not prefetched")
+	}
+}
+
+func TestContentTypeFor(t *testing.T) {
+	pngHeader := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	testCases := []struct {
+		name        string
+		sniff       string
+		contentType string
+		data        []byte
+		expected    string
+	}{
+		{"Off keeps empty type", "off", "", pngHeader, ""},
+		{"Fallback sniffs when unset", "fallback", "", pngHeader, "image/png"},
+		{"Fallback keeps configured type", "fallback", "image/webp", pngHeader, "image/webp"},
+		{"Override prefers sniffed type", "override", "application/json", pngHeader, "image/png"},
+		{"Override keeps configured type for unknown data", "override", "application/cbor", []byte{0xa1, 0x01, 0x02}, "application/cbor"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := config.Project{ContentType: tc.contentType, ContentTypeSniff: tc.sniff}
+			assert.Equal(t, tc.expected, contentTypeFor(p, tc.data))
+		})
+	}
+}
+
+func TestCreateHandler_QueryParams(t *testing.T) {
+	var cacheKeys []string
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}, func(p *config.Project) {
+		p.QueryParams = []string{"size", "theme"}
+	})
+	s.cache = &mockCache{SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+		cacheKeys = append(cacheKeys, key)
+		return nil
+	}}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1?theme=dark&size=64&tracking=abc", nil)
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "size=64&theme=dark", w.Body.String())
+	assert.Equal(t, []string{"test_project:1?size=64&theme=dark"}, cacheKeys)
+}
+
+func TestCreateHandler_ForwardHeaders(t *testing.T) {
+	var cacheKeys []string
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Cookie"))
+		w.Write([]byte(r.Header.Get("Accept-Language") + "/" + r.Header.Get("X-Tenant")))
+	}, func(p *config.Project) {
+		p.ForwardHeaders = []string{"accept-language", "X-Tenant"}
+	})
+	s.cache = &mockCache{SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+		cacheKeys = append(cacheKeys, key)
+		return nil
+	}}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	req.Header.Set("Accept-Language", "de")
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Cookie", "session=secret")
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "de/acme", w.Body.String())
+	assert.Equal(t, []string{"test_project:1#Accept-Language=de&X-Tenant=acme"}, cacheKeys)
+}
+
+func TestCreateHandler_ForwardHeadersKeepCredentials(t *testing.T) {
+	var received http.Header
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte("payload"))
+	}, func(p *config.Project) {
+		p.APIAuthType = "header"
+		p.APIAuthHeaderName = "X-Api-Key"
+		p.APIAuthSecret = "server-key"
+		p.UpstreamHeaders = map[string]string{"X-Client": "stratum"}
+		p.ForwardHeaders = []string{"X-Tenant", "X-Client"}
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Client", "spoofed")
+	req.Header.Set("X-Api-Key", "client-key")
+	req.Header.Set("Authorization", "Bearer client")
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"acme"}, received.Values("X-Tenant"))
+	assert.Equal(t, []string{"stratum"}, received.Values("X-Client"), "static upstream headers win")
+	assert.Equal(t, []string{"server-key"}, received.Values("X-Api-Key"))
+	assert.Empty(t, received.Values("Authorization"))
+}
+
+func TestCacheKeyFor(t *testing.T) {
+	params := datasource.Params{
+		Query:  url.Values{"size": {"64"}, "theme": {"dark"}},
+		Header: http.Header{"X-Tenant": {"acme"}},
+	}
+	p := config.Project{Name: "project_3", Route: "/avatars/{id}"}
+	assert.Equal(t, "project_3:7?size=64&theme=dark#X-Tenant=acme", cacheKeyFor(p, "7", params))
+
+	p.CacheKeyTemplate = "avatars:{id}:{query.size}:{header.x-tenant}"
+	assert.Equal(t, "avatars:7:64:acme", cacheKeyFor(p, "7", params))
+
+	p.CacheKeyTemplate = "{route}|{id}|{query}|{headers}"
+	assert.Equal(t, "/avatars/{id}|7|size=64&theme=dark|X-Tenant=acme", cacheKeyFor(p, "7", params))
+}
+
+func TestCacheControlOf(t *testing.T) {
+	p := config.Project{CacheControl: "public", CacheTTL: time.Hour}
+	assert.Equal(t, "public, max-age=3600", cacheControlOf(p))
+
+	p.CacheSMaxAge = 24 * time.Hour
+	p.CacheStaleWhileRevalidate = time.Minute
+	p.CacheImmutable = true
+	assert.Equal(t, "public, max-age=3600, s-maxage=86400, stale-while-revalidate=60, immutable", cacheControlOf(p))
+
+	p = config.Project{CacheControl: "private", CacheTTL: time.Minute}
+	assert.Equal(t, "private, max-age=60", cacheControlOf(p))
+
+	p.CacheControl = "off"
+	assert.Empty(t, cacheControlOf(p))
+}
+
+func TestCreateHandler_Hooks(t *testing.T) {
+	var cachedTTL time.Duration
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}, func(p *config.Project) {
+		p.Hooks = hooks.Spec{
+			Reject:   `id startsWith "private-"`,
+			CacheTTL: `request.query["draft"] == "1" ? -1 : request.query["preview"] == "1" ? 5 : 3600`,
+			Headers:  `{"X-Tenant": request.header["X-Tenant"] ?? "none"}`,
+		}
+	})
+	var writes int
+	s.cache = &mockCache{SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+		cachedTTL = ttl
+		writes++
+		return nil
+	}}
+
+	t.Run("Reject", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/private-1", nil)
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("TTL And Headers", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1?preview=1", nil)
+		req.Header.Set("X-Tenant", "acme")
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "acme", w.Header().Get("X-Tenant"))
+		assert.Equal(t, "public, max-age=5", w.Header().Get("Cache-Control"))
+		assert.Equal(t, 5*time.Second, cachedTTL)
+	})
+
+	t.Run("Negative TTL Skips The Cache", func(t *testing.T) {
+		writes = 0
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/2?draft=1", nil)
+		s.router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "data", w.Body.String())
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Zero(t, writes)
+	})
+}
+
+func TestCreateHandler_SourceHook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newUpstream := func(body string) *httptest.Server {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(ts.Close)
+		return ts
+	}
+	stable, beta := newUpstream("stable"), newUpstream("beta")
+
+	project := func(name, route, endpoint string) config.Project {
+		return config.Project{
+			Name: name, Route: route, IdColumn: "id", IdPlaceholder: "id",
+			SourceType: "api", APIEndpoint: endpoint + "/{id}", APIAuthType: "none",
+		}
+	}
+	primary := project("main", "/main/{id}", stable.URL)
+	primary.Hooks = hooks.Spec{Source: `request.header["X-Beta"] == "1" ? "beta" : ""`}
+	cfg := &config.AppConfig{Projects: []config.Project{primary, project("beta", "/beta/{id}", beta.URL)}}
+	s := NewServer(cfg, nil, &mockCache{})
+
+	for header, expected := range map[string]string{"": "stable", "1": "beta"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/main/1", nil)
+		req.Header.Set("X-Beta", header)
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Body.String())
+	}
+}
+
+func TestRevalidate(t *testing.T) {
+	etag := `"v1"`
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Write([]byte("payload"))
+	}, func(p *config.Project) {
+		p.RevalidateInterval = time.Minute
+		p.CacheTTL = time.Hour
+	})
+
+	var deleted []string
+	s.cache = &mockCache{
+		DeleteFunc: func(ctx context.Context, key string) error {
+			deleted = append(deleted, key)
+			return nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	now := time.Now()
+	s.revalidate(context.Background(), now.Add(30*time.Second))
+	assert.Empty(t, deleted, "not due yet")
+
+	s.revalidate(context.Background(), now.Add(2*time.Minute))
+	assert.Empty(t, deleted, "origin unchanged")
+
+	etag = `"v2"`
+	s.revalidate(context.Background(), now.Add(4*time.Minute))
+	assert.Equal(t, []string{"test_project:1"}, deleted)
+	assert.Empty(t, s.origins, "invalidated entries are no longer tracked")
+}
+
+func TestRevalidate_StopsOnReload(t *testing.T) {
+	var checks int
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			checks++
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("payload"))
+	}, func(p *config.Project) {
+		p.RevalidateInterval = time.Minute
+		p.CacheTTL = time.Hour
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	s.originsMu.Lock()
+	assert.Len(t, s.origins, 1)
+	s.originsMu.Unlock()
+
+	previous := s.runtimes["test_project"]
+	cfg := *s.Config()
+	assert.NoError(t, s.Reload(&cfg))
+	assert.Error(t, previous.ctx.Err(), "replaced runtimes are stopped")
+	s.originsMu.Lock()
+	assert.Empty(t, s.origins, "origins of replaced runtimes are forgotten")
+	s.originsMu.Unlock()
+
+	s.revalidate(context.Background(), time.Now().Add(2*time.Minute))
+	assert.Zero(t, checks)
+
+	// Requests that outlived the reload do not track the old source.
+	s.trackOrigin(previous.project, previous.source.(datasource.OriginSource), "test_project:1", &datasource.Origin{URL: "http://example.com"}, nil)
+	assert.Empty(t, s.origins)
+}
+
+func TestCreateHandler_ConditionalRevalidation(t *testing.T) {
+	var full, notModified int
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("payload"))
+	}, func(p *config.Project) {
+		p.ConditionalRevalidation = time.Hour
+	})
+
+	store := make(map[string][]byte)
+	ttls := make(map[string]time.Duration)
+	s.cache = &mockCache{
+		GetFunc: func(ctx context.Context, key string) ([]byte, error) {
+			return store[key], nil
+		},
+		SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+			store[key] = value
+			ttls[key] = ttl
+			return nil
+		},
+	}
+
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, full)
+	assert.Equal(t, time.Minute+time.Hour, ttls["test_project:1|validators"])
+	assert.NotContains(t, string(store["test_project:1|validators"]), "payload", "the payload is not stored twice")
+	assert.Equal(t, time.Minute+time.Hour, ttls["test_project:1"], "the entry is kept past its expiry")
+
+	expire := func() {
+		entry := decodeCacheEntry(store["test_project:1"])
+		entry.ExpiresAt = time.Now().Add(-time.Second)
+		store["test_project:1"] = entry.encode()
+	}
+
+	// The cache entry expires, but it and its validators are still around.
+	expire()
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache-Status"))
+	assert.Equal(t, "payload", w.Body.String())
+	assert.Equal(t, 1, full)
+	assert.Equal(t, 1, notModified)
+	refreshed := decodeCacheEntry(store["test_project:1"])
+	assert.Equal(t, []byte("payload"), refreshed.Data)
+	assert.False(t, refreshed.expired())
+
+	// An entry evicted before its validators is fetched in full.
+	delete(store, "test_project:1")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, "payload", w.Body.String())
+	assert.Equal(t, 2, full)
+	assert.Equal(t, 1, notModified)
+}
+
+func TestCreateHandler_StaleIfError(t *testing.T) {
+	status := http.StatusOK
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("payload"))
+	}, func(p *config.Project) {
+		p.StaleIfError = time.Hour
+	})
+
+	store := make(map[string][]byte)
+	ttls := make(map[string]time.Duration)
+	s.cache = &mockCache{
+		GetFunc: func(ctx context.Context, key string) ([]byte, error) {
+			return store[key], nil
+		},
+		SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+			store[key] = value
+			ttls[key] = ttl
+			return nil
+		},
+	}
+
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, time.Minute+time.Hour, ttls["test_project:1|stale"])
+
+	// The entry expires while the upstream is down.
+	delete(store, "test_project:1")
+	status = http.StatusServiceUnavailable
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "STALE", w.Header().Get("X-Cache-Status"))
+	assert.Equal(t, "payload", w.Body.String())
+	assert.NotContains(t, store, "test_project:1")
+
+	// An upstream refusing the ID is an answer, not an outage.
+	status = http.StatusGone
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	delete(store, "test_project:1|stale")
+	status = http.StatusServiceUnavailable
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestCreateHandler_CoalescesConcurrentMisses(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		w.Write([]byte("payload"))
+	}, nil)
+
+	const clients = 5
+	var wg sync.WaitGroup
+	codes := make([]int, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test/1", nil)
+			s.router.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+
+	// Let every request reach the in-flight fetch before it completes.
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 1 }, time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	for _, code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+}
+
+func TestCreateHandler_Warmup(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}, func(p *config.Project) {
+		p.WarmupPeriod = 30 * time.Second
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/1", nil)
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	s.started = time.Now().Add(-time.Minute)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
+
+func TestCreateHandler_UpstreamStatusMap(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/items/busy":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/items/limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/items/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}, func(p *config.Project) {
+		p.UpstreamStatusMap = map[string]config.StatusMapping{
+			"410": {Status: http.StatusNotFound},
+			"429": {Status: http.StatusServiceUnavailable, RetryAfter: 30 * time.Second},
+			"5xx": {Status: http.StatusBadGateway},
+		}
+	})
+
+	for _, tt := range []struct {
+		id         string
+		status     int
+		retryAfter string
+	}{
+		{"gone", http.StatusNotFound, ""},
+		{"busy", http.StatusServiceUnavailable, "120"},
+		{"limited", http.StatusServiceUnavailable, "30"},
+		{"broken", http.StatusBadGateway, ""},
+		{"teapot", http.StatusInternalServerError, ""},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/"+tt.id, nil)
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.id)
+		assert.Equal(t, tt.retryAfter, w.Header().Get("Retry-After"), tt.id)
+	}
+}
+
+func TestCreateHandler_IDCodec(t *testing.T) {
+	var fetched []string
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		w.Write([]byte("order"))
+	}, func(p *config.Project) {
+		p.IDCodec = "hashids"
+		p.IDCodecSalt = "this is my salt"
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/NkK9", nil)
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"/items/12345"}, fetched)
+
+	for _, id := range []string{"12345", "NkK9x"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/test/"+id, nil)
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, id)
+	}
+	assert.Len(t, fetched, 1, "undecodable IDs never reach the source")
+}
+
+func TestCreateHandler_IDPattern(t *testing.T) {
+	var fetched []string
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		w.Write([]byte("user"))
+	}, func(p *config.Project) {
+		p.IDPattern = `[0-9]+`
+		p.IDMaxLength = 6
+	})
+
+	for id, code := range map[string]int{
+		"123456":  http.StatusOK,
+		"12a":     http.StatusBadRequest,
+		"a12":     http.StatusBadRequest,
+		"1234567": http.StatusBadRequest,
+		"12%0A":   http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/"+id, nil)
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, id)
+	}
+	assert.Equal(t, []string{"/items/123456"}, fetched, "invalid IDs never reach the source")
+}
+
+func TestCreateHandler_IDTransform(t *testing.T) {
+	var fetched []string
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		w.Write([]byte("avatar"))
+	}, func(p *config.Project) {
+		p.IDTransform = "strip-extension:png | lowercase"
+	})
+
+	for _, path := range []string{"/test/ABC.png", "/test/abc"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+	assert.Equal(t, []string{"/items/abc", "/items/abc"}, fetched, "both IDs fetch the same record")
+}
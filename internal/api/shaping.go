@@ -0,0 +1,76 @@
+package api
+
+import (
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Size of the chunks a bandwidth-limited response is written in.
+const shapingChunkSize = 4096
+
+// Returns a middleware delaying a project's responses and limiting their
+// bandwidth, so clients can be tested against slow content. It returns nil
+// if the project configures neither.
+func shapingMiddleware(p config.Project) gin.HandlerFunc {
+	if p.SyntheticDelay == 0 && p.SyntheticBandwidth == 0 {
+		return nil
+	}
+
+	return func(c *gin.Context) {
+		if p.SyntheticDelay > 0 {
+			timer := time.NewTimer(p.SyntheticDelay)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+
+		if p.SyntheticBandwidth > 0 {
+			c.Writer = &throttledWriter{ResponseWriter: c.Writer, c: c, bytesPerSecond: p.SyntheticBandwidth}
+		}
+		c.Next()
+	}
+}
+
+// throttledWriter writes a response in chunks, pausing between them to keep
+// to a bandwidth limit.
+type throttledWriter struct {
+	gin.ResponseWriter
+	c              *gin.Context
+	bytesPerSecond int
+}
+
+func (w *throttledWriter) Write(data []byte) (int, error) {
+	written := 0
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > shapingChunkSize {
+			chunk = chunk[:shapingChunkSize]
+		}
+
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		w.ResponseWriter.Flush()
+		data = data[n:]
+
+		pause := time.Duration(n) * time.Second / time.Duration(w.bytesPerSecond)
+		select {
+		case <-time.After(pause):
+		case <-w.c.Request.Context().Done():
+			return written, w.c.Request.Context().Err()
+		}
+	}
+	return written, nil
+}
+
+func (w *throttledWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyntheticShaping(t *testing.T) {
+	payload := strings.Repeat("x", 8192)
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(payload))
+	}, func(p *config.Project) {
+		p.SyntheticDelay = 100 * time.Millisecond
+		p.SyntheticBandwidth = 40960
+	})
+
+	serve := func() (time.Duration, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/1", nil)
+		start := time.Now()
+		s.ServeHTTP(w, req)
+		return time.Since(start), w
+	}
+
+	t.Run("Disabled By Default", func(t *testing.T) {
+		elapsed, w := serve()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Less(t, elapsed, 100*time.Millisecond)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		cfg := *s.Config()
+		cfg.SyntheticShaping = true
+		assert.NoError(t, s.Reload(&cfg))
+
+		// 100ms of delay plus 8 KiB at 40 KiB/s.
+		elapsed, w := serve()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, payload, w.Body.String())
+		assert.GreaterOrEqual(t, elapsed, 300*time.Millisecond)
+	})
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Returns a middleware logging a warning, and counting it in the metrics,
+// for each request to a project taking longer than its SLOW_REQUEST_MS or
+// answered with more than its LARGE_RESPONSE_BYTES. It returns nil if the
+// project sets neither.
+func (s *Server) slowRequestMiddleware(p config.Project) gin.HandlerFunc {
+	if p.SlowRequestThreshold <= 0 && p.LargeResponseThreshold <= 0 {
+		return nil
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+		size := max(c.Writer.Size(), 0)
+
+		slow := p.SlowRequestThreshold > 0 && latency > p.SlowRequestThreshold
+		large := p.LargeResponseThreshold > 0 && size > p.LargeResponseThreshold
+		if slow {
+			s.metrics.ObserveSlowRequest(p.Name)
+		}
+		if large {
+			s.metrics.ObserveLargeResponse(p.Name)
+		}
+
+		var kind string
+		switch {
+		case slow && large:
+			kind = "SLOW REQUEST, LARGE RESPONSE"
+		case slow:
+			kind = "SLOW REQUEST"
+		case large:
+			kind = "LARGE RESPONSE"
+		default:
+			return
+		}
+		utils.StratumLogContext(c.Request.Context(), "WARN", "%s: project=%s id=%q path=%q status=%d latency_ms=%.1f bytes=%d cache=%s",
+			kind, p.Name, c.Param(p.IdPlaceholder), c.Request.URL.Path, c.Writer.Status(),
+			float64(latency.Microseconds())/1000, size, c.Writer.Header().Get("X-Cache-Status"))
+	}
+}
+
+// Returns a callback for a project's database queries that records them in
+// the metrics, and logs a warning for each query taking longer than the
+// project's SLOW_QUERY_MS.
+func (s *Server) queryObserver(p config.Project) func(ctx context.Context, idValue string, elapsed time.Duration, err error) {
+	return func(ctx context.Context, idValue string, elapsed time.Duration, err error) {
+		s.metrics.ObserveQuery(p.Name, elapsed, err != nil)
+		if p.SlowQueryThreshold <= 0 || elapsed <= p.SlowQueryThreshold {
+			return
+		}
+		s.metrics.ObserveSlowQuery(p.Name)
+		utils.StratumLogContext(ctx, "WARN", "SLOW QUERY: project=%s id=%q table=%s latency_ms=%.1f failed=%t",
+			p.Name, idValue, p.Table, float64(elapsed.Microseconds())/1000, err != nil)
+	}
+}
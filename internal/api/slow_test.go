@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowRequestsAndLargeResponses(t *testing.T) {
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/items/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if r.URL.Path == "/items/large" {
+			w.Write(bytes.Repeat([]byte("x"), 2048))
+			return
+		}
+		w.Write([]byte("small"))
+	}, func(p *config.Project) {
+		p.SlowRequestThreshold = 20 * time.Millisecond
+		p.LargeResponseThreshold = 1024
+	})
+
+	var logs bytes.Buffer
+	writer := gin.DefaultWriter
+	gin.DefaultWriter = &logs
+	t.Cleanup(func() { gin.DefaultWriter = writer })
+
+	for _, id := range []string{"fast", "slow", "large"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test/"+id, nil)
+		s.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	stats := s.metrics.Snapshot()["test_project"]
+	assert.Equal(t, uint64(1), stats.SlowRequests)
+	assert.Equal(t, uint64(1), stats.LargeResponses)
+	assert.Contains(t, logs.String(), `SLOW REQUEST: project=test_project id="slow" path="/test/slow" status=200`)
+	assert.Contains(t, logs.String(), `LARGE RESPONSE: project=test_project id="large" path="/test/large" status=200`)
+	assert.Contains(t, logs.String(), "bytes=2048 cache=MISS")
+	assert.NotContains(t, logs.String(), `id="fast"`)
+}
+
+func TestSlowQueries(t *testing.T) {
+	s := NewServer(&config.AppConfig{}, nil, &mockCache{})
+	observe := s.queryObserver(config.Project{Name: "receipts", Table: "orders", SlowQueryThreshold: 100 * time.Millisecond})
+
+	var logs bytes.Buffer
+	writer := gin.DefaultWriter
+	gin.DefaultWriter = &logs
+	t.Cleanup(func() { gin.DefaultWriter = writer })
+
+	observe(context.Background(), "1", 5*time.Millisecond, nil)
+	observe(context.Background(), "2", 250*time.Millisecond, nil)
+	observe(context.Background(), "3", time.Millisecond, errors.New("connection refused"))
+
+	stats := s.metrics.Snapshot()["receipts"]
+	assert.Equal(t, uint64(3), stats.Queries)
+	assert.Equal(t, uint64(1), stats.QueryErrors)
+	assert.Equal(t, uint64(1), stats.SlowQueries)
+	assert.Contains(t, logs.String(), `SLOW QUERY: project=receipts id="2" table=orders latency_ms=250.0 failed=false`)
+	assert.NotContains(t, logs.String(), `id="1"`)
+}
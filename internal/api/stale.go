@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// A copy of a cache entry that outlives it by the project's stale-if-error
+// window, served when fetching the payload again fails.
+func staleKey(cacheKey string) string {
+	return cacheKey + "|stale"
+}
+
+// Stores the stale copy of a freshly cached entry.
+func (s *Server) storeStale(ctx context.Context, p config.Project, cacheKey string, entry *cacheEntry) {
+	if p.StaleIfError <= 0 {
+		return
+	}
+	if err := s.cacheFor(p.Name).Set(ctx, staleKey(cacheKey), entry.encode(), p.CacheTTL+p.StaleIfError); err != nil {
+		utils.StratumLogContext(ctx, "ERROR", "Failed to store stale copy of key '%s': %v", cacheKey, err)
+	}
+}
+
+// Loads the stale copy of a cache key after a failed fetch, or nil if there
+// is none or the failure is the upstream's answer rather than an outage,
+// such as a 403 or 410.
+func (s *Server) loadStale(ctx context.Context, p config.Project, cacheKey string, fetchErr error) *cacheEntry {
+	if p.StaleIfError <= 0 {
+		return nil
+	}
+	var statusErr *datasource.StatusError
+	if errors.As(fetchErr, &statusErr) && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != http.StatusRequestTimeout && statusErr.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	entry, err := s.loadKeptEntry(ctx, p, staleKey(cacheKey))
+	if err != nil {
+		utils.StratumLogContext(ctx, "ERROR", "Stale copy lookup failed for key '%s': %v", cacheKey, err)
+		return nil
+	}
+	if entry != nil {
+		utils.StratumLogContext(ctx, "WARN", "CACHE STALE: Serving expired copy of '%s' after the fetch failed.", cacheKey)
+	}
+	return entry
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Returns a middleware giving requests a deadline, which the cache, database
+// and upstream calls made for them inherit through the request's context.
+// Fetches cut short by it are answered with 504 by writeFetchError. It
+// returns nil if timeout is zero.
+func requestTimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	if timeout <= 0 {
+		return nil
+	}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
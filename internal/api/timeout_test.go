@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	var upstreamErr error
+	upstreamDone := make(chan struct{})
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		defer close(upstreamDone)
+		select {
+		case <-release:
+			w.Write([]byte("data"))
+		case <-r.Context().Done():
+			upstreamErr = r.Context().Err()
+		}
+	}, func(p *config.Project) {
+		p.RequestTimeout = 50 * time.Millisecond
+	})
+	defer close(release)
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/test/1", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Less(t, time.Since(start), time.Second)
+
+	select {
+	case <-upstreamDone:
+		assert.Error(t, upstreamErr, "the upstream request is cancelled at the deadline")
+	case <-time.After(time.Second):
+		t.Fatal("the upstream request outlived the deadline")
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// Returns the TLS configuration serving the certificate of certFile and
+// keyFile, with an optional plain HTTP server on redirectPort redirecting to
+// HTTPS on httpsPort.
+func tlsConfigFor(certFile, keyFile, httpsPort, redirectPort string) (*tls.Config, error) {
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if redirectPort != "" {
+		go func() {
+			utils.StratumLog("INFO", "Redirecting HTTP on port %s to HTTPS.", redirectPort)
+			if err := http.ListenAndServe(":"+redirectPort, httpsRedirect(httpsPort)); err != nil {
+				utils.StratumLog("ERROR", "HTTP redirect server stopped: %v", err)
+			}
+		}()
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}, nil
+}
+
+// Serves the certificate of TLS_CERT_FILE and TLS_KEY_FILE, loading it again
+// when either file changes, so renewed certificates are picked up without a
+// restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time // latest modification time of the two files
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. A certificate that
+// fails to load is logged and the previous one kept.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var modified time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			if r.cert != nil {
+				return r.cert, nil
+			}
+			return nil, fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	if r.cert != nil && modified.Equal(r.modified) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			utils.StratumLog("ERROR", "Failed to load the renewed TLS certificate, keeping the previous one: %v", err)
+			r.modified = modified
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if r.cert != nil {
+		utils.StratumLog("INFO", "Loaded the renewed TLS certificate.")
+	}
+	r.cert, r.modified = &cert, modified
+	return r.cert, nil
+}
+
+// Redirects plain HTTP requests to the same URL over HTTPS on httpsPort.
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+
+		// 308 keeps the method and body of other requests.
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, target, status)
+	})
+}
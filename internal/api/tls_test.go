@@ -0,0 +1,95 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Writes a self-signed certificate for commonName and its key.
+func writeTestCert(t *testing.T, certFile, keyFile, commonName string, modified time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certFile, modified, modified))
+	require.NoError(t, os.Chtimes(keyFile, modified, modified))
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	commonName := func(cert *tls.Certificate) string {
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return parsed.Subject.CommonName
+	}
+
+	_, err := newCertReloader(certFile, keyFile)
+	assert.ErrorContains(t, err, "failed to read TLS certificate")
+
+	start := time.Now().Add(-time.Hour)
+	writeTestCert(t, certFile, keyFile, "first", start)
+	certs, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	cert, err := certs.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "first", commonName(cert))
+
+	t.Run("Renewed", func(t *testing.T) {
+		writeTestCert(t, certFile, keyFile, "renewed", start.Add(time.Minute))
+		cert, err := certs.GetCertificate(nil)
+		require.NoError(t, err)
+		assert.Equal(t, "renewed", commonName(cert))
+	})
+
+	t.Run("Broken Renewal", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+		cert, err := certs.GetCertificate(nil)
+		require.NoError(t, err)
+		assert.Equal(t, "renewed", commonName(cert), "the previous certificate is kept")
+	})
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		method, target, port, location string
+		status                         int
+	}{
+		{"GET", "http://example.com:8080/avatars/1?size=64", "8443", "https://example.com:8443/avatars/1?size=64", http.StatusMovedPermanently},
+		{"GET", "http://example.com/avatars/1", "443", "https://example.com/avatars/1", http.StatusMovedPermanently},
+		{"POST", "http://example.com/admin/purge", "443", "https://example.com/admin/purge", http.StatusPermanentRedirect},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		httpsRedirect(tt.port).ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		assert.Equal(t, tt.status, w.Code, tt.target)
+		assert.Equal(t, tt.location, w.Header().Get("Location"), tt.target)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateProject checks the parts of a project that are only parsed when
+// its route is set up: the transform chain, hooks, ID codec and upstream and
+// database TLS files. Nothing is connected to.
+func ValidateProject(p config.Project) error {
+	if _, err := compileProject(p); err != nil {
+		return err
+	}
+	if err := datasource.CheckUpstreamTLS(p); err != nil {
+		return fmt.Errorf("invalid upstream TLS settings for project '%s': %w", p.Name, err)
+	}
+	if err := datasource.DBOptions(p).TLS.Check(); err != nil {
+		return fmt.Errorf("invalid database TLS settings for project '%s': %w", p.Name, err)
+	}
+	return nil
+}
+
+// ValidateRoutes reports the first project route that conflicts with an
+// earlier route or with Stratum's own endpoints, which would otherwise
+// stop the server when its routes are set up.
+func ValidateRoutes(cfg *config.AppConfig) (err error) {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	for _, path := range []string{"/", "/health", "/ready", "/metrics"} {
+		router.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+
+	var current config.Project
+	var currentRoute string
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("route %s of project '%s' conflicts with another route: %v", currentRoute, current.Name, r)
+		}
+	}()
+	for _, p := range cfg.Projects {
+		current = p
+		for _, route := range projectRoutes(p) {
+			currentRoute = route
+			router.GET(convertToGinRoute(route), func(c *gin.Context) {})
+		}
+	}
+	return nil
+}
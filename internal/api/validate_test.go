@@ -0,0 +1,38 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateProject(t *testing.T) {
+	p := config.Project{Name: "project_1", Route: "/a/{id}", SourceType: "api", Transform: "base64-decode"}
+	assert.NoError(t, ValidateProject(p))
+
+	p.Transform = "unknown-step"
+	assert.ErrorContains(t, ValidateProject(p), "invalid transform chain for project 'project_1'")
+
+	p.Transform = ""
+	p.QueryTransform = "gunzip"
+	assert.ErrorContains(t, ValidateProject(p), "invalid query transform for project 'project_1'")
+
+	p.QueryTransform = ""
+	p.UpstreamCAFile = "/nonexistent/ca.pem"
+	assert.ErrorContains(t, ValidateProject(p), "invalid upstream TLS settings for project 'project_1'")
+}
+
+func TestValidateRoutes(t *testing.T) {
+	cfg := &config.AppConfig{Projects: []config.Project{
+		{Name: "project_1", Route: "/users/{id}"},
+		{Name: "project_2", Route: "/docs/{id}"},
+	}}
+	assert.NoError(t, ValidateRoutes(cfg))
+
+	cfg.Projects = append(cfg.Projects, config.Project{Name: "project_3", Route: "/users/{id}"})
+	assert.ErrorContains(t, ValidateRoutes(cfg), "route /users/{id} of project 'project_3' conflicts")
+
+	cfg.Projects = []config.Project{{Name: "project_1", Route: "/health"}}
+	assert.Error(t, ValidateRoutes(cfg))
+}
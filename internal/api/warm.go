@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/PythonicVarun/Stratum/internal/datasource"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// Warm fetches the IDs listed in projects' WARM_IDS, and those returned by
+// their WARM_QUERY, into the cache. It is meant to run before the server
+// accepts traffic, so the first requests after a deploy do not all reach
+// the upstreams at once. IDs that are already cached are skipped, and
+// failures are logged without stopping the warm-up. It stops after
+// WARM_TIMEOUT_SECONDS, so a slow upstream cannot keep the server from
+// listening, and warms at most WARM_MAX_IDS IDs per project.
+func (s *Server) Warm(ctx context.Context) {
+	s.mu.RLock()
+	cfg, runtimes := s.config, s.runtimes
+	s.mu.RUnlock()
+
+	if cfg.WarmTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.WarmTimeout)
+		defer cancel()
+	}
+	for _, p := range cfg.Projects {
+		if ctx.Err() != nil {
+			utils.StratumLog("WARN", "WARM: Stopped after %s (WARM_TIMEOUT_SECONDS).", cfg.WarmTimeout)
+			return
+		}
+		rt := runtimes[p.Name]
+		ids := p.WarmIDs
+		if p.WarmQuery != "" {
+			lister, ok := rt.source.(datasource.IDLister)
+			if !ok {
+				utils.StratumLog("WARN", "WARM: Source of project '%s' cannot list IDs.", p.Name)
+			} else if listed, err := lister.ListIDs(ctx, p.WarmQuery); err != nil {
+				utils.StratumLog("ERROR", "WARM: Query for project '%s' failed: %v", p.Name, err)
+			} else {
+				ids = append(append([]string(nil), ids...), listed...)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if cfg.WarmMaxIDs > 0 && len(ids) > cfg.WarmMaxIDs {
+			utils.StratumLog("WARN", "WARM: Project '%s' has %d IDs to warm, only the first %d are (WARM_MAX_IDS).", p.Name, len(ids), cfg.WarmMaxIDs)
+			ids = ids[:cfg.WarmMaxIDs]
+		}
+
+		warmed := s.warmProject(ctx, rt, ids, cfg.WarmConcurrency)
+		utils.StratumLog("INFO", "WARM: Cached %d of %d IDs for project '%s'.", warmed, len(ids), p.Name)
+	}
+}
+
+// Fetches IDs of a project into the cache, at most concurrency at a time,
+// and returns how many are cached afterwards.
+func (s *Server) warmProject(ctx context.Context, rt *projectRuntime, ids []string, concurrency int) int {
+	p := rt.project
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var warmed int64
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(id string) {
+			defer func() { <-slots; wg.Done() }()
+
+			cacheKey := cacheKeyFor(p, id, datasource.Params{})
+			if cached, err := s.runtimeCache(rt).Get(ctx, cacheKey); err == nil && cached != nil && !decodeCacheEntry(cached).expired() {
+				atomic.AddInt64(&warmed, 1)
+				return
+			}
+			entry, err := s.fetchShared(ctx, p, rt.source, rt.chain, id, cacheKey, datasource.Params{})
+			if err != nil {
+				utils.StratumLog("WARN", "WARM: Failed to fetch '%s': %v", cacheKey, err)
+				return
+			}
+			if entry != nil {
+				atomic.AddInt64(&warmed, 1)
+			}
+		}(id)
+	}
+	wg.Wait()
+	return int(warmed)
+}
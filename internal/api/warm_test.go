@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarm(t *testing.T) {
+	var mu sync.Mutex
+	var fetched []string
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched = append(fetched, r.URL.Path)
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("payload"))
+	}, func(p *config.Project) {
+		p.WarmIDs = []string{"1", "2", "3", "missing"}
+	})
+
+	stored := map[string][]byte{"test_project:3": []byte("cached")}
+	s.cache = &mockCache{
+		GetFunc: func(ctx context.Context, key string) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return stored[key], nil
+		},
+		SetFunc: func(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+			mu.Lock()
+			defer mu.Unlock()
+			stored[key] = value
+			return nil
+		},
+	}
+
+	s.Warm(context.Background())
+
+	assert.ElementsMatch(t, []string{"/items/1", "/items/2", "/items/missing"}, fetched, "cached IDs are skipped")
+	assert.Equal(t, []byte("payload"), decodeCacheEntry(stored["test_project:1"]).Data)
+	assert.Equal(t, []byte("payload"), decodeCacheEntry(stored["test_project:2"]).Data)
+	assert.NotContains(t, stored, "test_project:missing")
+}
+
+func TestWarm_Limits(t *testing.T) {
+	var mu sync.Mutex
+	var fetched []string
+	release := make(chan struct{})
+	defer close(release)
+	s := newAPIProjectServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched = append(fetched, r.URL.Path)
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/slow") {
+			<-release
+		}
+		w.Write([]byte("payload"))
+	}, func(p *config.Project) {
+		p.WarmIDs = []string{"1", "2", "3"}
+	})
+
+	cfg := *s.Config()
+	cfg.WarmMaxIDs = 2
+	assert.NoError(t, s.Reload(&cfg))
+	s.Warm(context.Background())
+	assert.ElementsMatch(t, []string{"/items/1", "/items/2"}, fetched, "IDs beyond WARM_MAX_IDS are left out")
+
+	cfg.Projects = append([]config.Project(nil), cfg.Projects...)
+	cfg.Projects[0].WarmIDs = []string{"slow"}
+	cfg.WarmTimeout = 20 * time.Millisecond
+	assert.NoError(t, s.Reload(&cfg))
+	started := time.Now()
+	s.Warm(context.Background())
+	assert.Less(t, time.Since(started), time.Second, "a slow upstream does not hold up startup")
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// AsyncCache writes values in the background on a bounded pool of workers,
+// so callers do not wait for the wrapped cache. Values waiting to be written
+// are served by Get, and a Delete cancels them. When the queue is full,
+// callers wait for room rather than writes being dropped or made around
+// the queue, so the writes of a key always land in order.
+type AsyncCache struct {
+	next Cache
+	// One queue per worker. Writes of a key always go to the same worker,
+	// so they land in order.
+	queues []chan *asyncWrite
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[string]*asyncWrite // latest queued write per key
+
+	// Held for reading while writes are queued, so Close does not close the
+	// queues under them
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// ErrClosed is returned by writes to an AsyncCache that has been closed.
+var ErrClosed = errors.New("cache is closed")
+
+type asyncWrite struct {
+	key       string
+	value     []byte
+	ttl       time.Duration
+	cancelled bool // deleted while queued or being written
+}
+
+// Wraps a cache so writes are made by a number of worker goroutines, with
+// up to queueSize writes waiting.
+func NewAsyncCache(next Cache, workers, queueSize int) *AsyncCache {
+	if workers < 1 {
+		workers = 1
+	}
+	a := &AsyncCache{
+		next:    next,
+		queues:  make([]chan *asyncWrite, workers),
+		pending: make(map[string]*asyncWrite),
+	}
+	for i := range a.queues {
+		a.queues[i] = make(chan *asyncWrite, (queueSize+workers-1)/workers)
+		a.wg.Add(1)
+		go a.work(a.queues[i])
+	}
+	return a
+}
+
+func (a *AsyncCache) Get(ctx context.Context, key string) ([]byte, error) {
+	a.mu.Lock()
+	w := a.pending[key]
+	a.mu.Unlock()
+	if w != nil {
+		return w.value, nil
+	}
+	return a.next.Get(ctx, key)
+}
+
+func (a *AsyncCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	a.closeMu.RLock()
+	defer a.closeMu.RUnlock()
+	if a.closed {
+		return ErrClosed
+	}
+
+	// An older write of the key still queued is dropped, so it cannot
+	// land after this one.
+	w := &asyncWrite{key: key, value: value, ttl: ttl}
+	a.mu.Lock()
+	if previous := a.pending[key]; previous != nil {
+		previous.cancelled = true
+	}
+	a.pending[key] = w
+	a.mu.Unlock()
+
+	select {
+	case a.queueOf(key) <- w:
+		return nil
+	case <-ctx.Done():
+		a.mu.Lock()
+		if a.pending[key] == w {
+			delete(a.pending, key)
+		}
+		a.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (a *AsyncCache) Delete(ctx context.Context, key string) error {
+	a.mu.Lock()
+	if w := a.pending[key]; w != nil {
+		w.cancelled = true
+		delete(a.pending, key)
+	}
+	a.mu.Unlock()
+	return a.next.Delete(ctx, key)
+}
+
+// Close waits for queued writes to finish before closing the wrapped cache.
+// Writes made after it fail with ErrClosed.
+func (a *AsyncCache) Close() error {
+	a.closeMu.Lock()
+	if a.closed {
+		a.closeMu.Unlock()
+		return nil
+	}
+	a.closed = true
+	for _, queue := range a.queues {
+		close(queue)
+	}
+	a.closeMu.Unlock()
+
+	a.wg.Wait()
+	return a.next.Close()
+}
+
+func (a *AsyncCache) queueOf(key string) chan *asyncWrite {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return a.queues[h.Sum32()%uint32(len(a.queues))]
+}
+
+func (a *AsyncCache) work(queue chan *asyncWrite) {
+	defer a.wg.Done()
+	ctx := context.Background()
+	for w := range queue {
+		a.mu.Lock()
+		cancelled := w.cancelled
+		a.mu.Unlock()
+		if cancelled {
+			continue
+		}
+
+		if err := a.next.Set(ctx, w.key, w.value, w.ttl); err != nil {
+			utils.StratumLog("ERROR", "Failed to set cache for key '%s': %v", w.key, err)
+		}
+
+		a.mu.Lock()
+		if a.pending[w.key] == w {
+			delete(a.pending, w.key)
+		}
+		// A Delete while the value was being written must still win.
+		cancelled = w.cancelled && a.pending[w.key] == nil
+		a.mu.Unlock()
+		if cancelled {
+			a.next.Delete(ctx, w.key)
+		}
+	}
+}
+
+func (a *AsyncCache) KeyStats(ctx context.Context, prefix string) (KeyStats, error) {
+	return keyStats(ctx, a.next, prefix)
+}
+
+func (a *AsyncCache) Info(ctx context.Context) (map[string]string, error) {
+	return info(ctx, a.next)
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingCache holds writes until it is released.
+type blockingCache struct {
+	*MemoryCache
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	<-b.release
+	return b.MemoryCache.Set(ctx, key, value, ttl)
+}
+
+func (b *blockingCache) unblock() {
+	b.once.Do(func() { close(b.release) })
+}
+
+func TestAsyncCache(t *testing.T) {
+	ctx := context.Background()
+	newCache := func(queueSize int) (*AsyncCache, *blockingCache) {
+		next := &blockingCache{MemoryCache: NewMemoryCache(10, 0), release: make(chan struct{})}
+		return NewAsyncCache(next, 1, queueSize), next
+	}
+
+	t.Run("Writes In The Background", func(t *testing.T) {
+		c, next := newCache(10)
+		assert.NoError(t, c.Set(ctx, "key", []byte("value"), time.Minute))
+
+		val, _ := c.Get(ctx, "key")
+		assert.Equal(t, []byte("value"), val, "queued values are served")
+		val, _ = next.Get(ctx, "key")
+		assert.Nil(t, val)
+
+		next.unblock()
+		assert.NoError(t, c.Close())
+		val, _ = next.Get(ctx, "key")
+		assert.Equal(t, []byte("value"), val, "Close waits for queued writes")
+	})
+
+	t.Run("Delete Cancels Queued Writes", func(t *testing.T) {
+		c, next := newCache(10)
+		c.Set(ctx, "key", []byte("value"), time.Minute)
+		assert.NoError(t, c.Delete(ctx, "key"))
+
+		val, _ := c.Get(ctx, "key")
+		assert.Nil(t, val)
+		next.unblock()
+		c.Close()
+		val, _ = next.Get(ctx, "key")
+		assert.Nil(t, val)
+	})
+
+	t.Run("Later Writes Win", func(t *testing.T) {
+		c, next := newCache(10)
+		c.Set(ctx, "key", []byte("old"), time.Minute)
+		c.Set(ctx, "key", []byte("new"), time.Minute)
+
+		next.unblock()
+		c.Close()
+		val, _ := next.Get(ctx, "key")
+		assert.Equal(t, []byte("new"), val)
+	})
+
+	t.Run("Full Queue Waits For Room", func(t *testing.T) {
+		next := NewMemoryCache(10, 0)
+		c := NewAsyncCache(&blockingCache{MemoryCache: next, release: make(chan struct{})}, 1, 1)
+		// Fill the worker and its queue with writes of other keys first.
+		blocked := c.next.(*blockingCache)
+		c.Set(ctx, "a", []byte("a"), time.Minute)
+		for len(c.queues[0]) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		c.Set(ctx, "b", []byte("b"), time.Minute)
+
+		done := make(chan struct{})
+		go func() {
+			c.Set(ctx, "c", []byte("c"), time.Minute)
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatal("a write did not wait with the queue full")
+		case <-time.After(20 * time.Millisecond):
+		}
+		blocked.unblock()
+		<-done
+		c.Close()
+		val, _ := next.Get(ctx, "c")
+		assert.Equal(t, []byte("c"), val)
+	})
+	t.Run("Writes After Close", func(t *testing.T) {
+		c, next := newCache(10)
+		next.unblock()
+		assert.NoError(t, c.Close())
+		assert.ErrorIs(t, c.Set(ctx, "key", []byte("value"), time.Minute), ErrClosed)
+		assert.NoError(t, c.Close(), "closing twice is harmless")
+	})
+}
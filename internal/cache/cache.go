@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/PythonicVarun/Stratum/internal/config"
 	"github.com/go-redis/redis/v8"
 )
 
 type Cache interface {
 	Get(ctx context.Context, key string) ([]byte, error)
 	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
 	Close() error
 }
 
@@ -18,8 +20,9 @@ type RedisCache struct {
 	client *redis.Client
 }
 
-// Creates a new RedisCache instance from a Redis URL.
-func NewRedisCache(redisURL string) (Cache, error) {
+// Creates a new RedisCache instance from a Redis URL, with the pool,
+// timeout and retry settings of pool applied over those in the URL.
+func NewRedisCache(redisURL string, pool config.RedisConfig) (Cache, error) {
 	if redisURL == "" {
 		return nil, fmt.Errorf("redis URL is not provided")
 	}
@@ -29,6 +32,7 @@ func NewRedisCache(redisURL string) (Cache, error) {
 		return nil, fmt.Errorf("failed to parse redis URL: %w", err)
 	}
 
+	applyRedisConfig(opt, pool)
 	client := redis.NewClient(opt)
 
 	if _, err := client.Ping(context.Background()).Result(); err != nil {
@@ -38,6 +42,33 @@ func NewRedisCache(redisURL string) (Cache, error) {
 	return &RedisCache{client: client}, nil
 }
 
+func applyRedisConfig(opt *redis.Options, pool config.RedisConfig) {
+	if pool.PoolSize > 0 {
+		opt.PoolSize = pool.PoolSize
+	}
+	if pool.MinIdleConns > 0 {
+		opt.MinIdleConns = pool.MinIdleConns
+	}
+	if pool.DialTimeout > 0 {
+		opt.DialTimeout = pool.DialTimeout
+	}
+	if pool.ReadTimeout > 0 {
+		opt.ReadTimeout = pool.ReadTimeout
+	}
+	if pool.WriteTimeout > 0 {
+		opt.WriteTimeout = pool.WriteTimeout
+	}
+	if pool.MaxRetries != 0 {
+		opt.MaxRetries = pool.MaxRetries
+	}
+	if pool.MinRetryBackoff > 0 {
+		opt.MinRetryBackoff = pool.MinRetryBackoff
+	}
+	if pool.MaxRetryBackoff > 0 {
+		opt.MaxRetryBackoff = pool.MaxRetryBackoff
+	}
+}
+
 // Retrieves a value from the cache.
 func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
 	val, err := r.client.Get(ctx, key).Bytes()
@@ -59,6 +90,15 @@ func (r *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time
 	return nil
 }
 
+// Removes a value from the cache.
+func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	err := r.client.Del(ctx, key).Err()
+	if err != nil {
+		return fmt.Errorf("failed to delete key from redis: %w", err)
+	}
+	return nil
+}
+
 // Closes the Redis client connection.
 func (r *RedisCache) Close() error {
 	if r.client != nil {
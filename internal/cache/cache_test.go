@@ -5,7 +5,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/PythonicVarun/Stratum/internal/config"
 	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,19 +24,19 @@ func TestNewRedisCache(t *testing.T) {
 	defer s.Close()
 
 	t.Run("Valid Redis URL", func(t *testing.T) {
-		cache, err := NewRedisCache("redis://" + addr)
+		cache, err := NewRedisCache("redis://"+addr, config.RedisConfig{})
 		assert.NoError(t, err)
 		assert.NotNil(t, cache)
 		defer cache.Close()
 	})
 
 	t.Run("Invalid Redis URL", func(t *testing.T) {
-		_, err := NewRedisCache("redis://invalid-url:port")
+		_, err := NewRedisCache("redis://invalid-url:port", config.RedisConfig{})
 		assert.Error(t, err)
 	})
 
 	t.Run("Empty Redis URL", func(t *testing.T) {
-		_, err := NewRedisCache("")
+		_, err := NewRedisCache("", config.RedisConfig{})
 		assert.Error(t, err)
 		assert.Equal(t, "redis URL is not provided", err.Error())
 	})
@@ -44,7 +46,7 @@ func TestRedisCache_GetSet(t *testing.T) {
 	s, addr := setupMiniredis(t)
 	defer s.Close()
 
-	cache, err := NewRedisCache("redis://" + addr)
+	cache, err := NewRedisCache("redis://"+addr, config.RedisConfig{})
 	assert.NoError(t, err)
 	defer cache.Close()
 
@@ -65,13 +67,20 @@ func TestRedisCache_GetSet(t *testing.T) {
 	retrievedValue, err = cache.Get(ctx, "non_existent_key")
 	assert.NoError(t, err)
 	assert.Nil(t, retrievedValue)
+
+	// Test Delete
+	err = cache.Delete(ctx, key)
+	assert.NoError(t, err)
+	retrievedValue, err = cache.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.Nil(t, retrievedValue)
 }
 
 func TestRedisCache_TTL(t *testing.T) {
 	s, addr := setupMiniredis(t)
 	defer s.Close()
 
-	cache, err := NewRedisCache("redis://" + addr)
+	cache, err := NewRedisCache("redis://"+addr, config.RedisConfig{})
 	assert.NoError(t, err)
 	defer cache.Close()
 
@@ -101,9 +110,27 @@ func TestRedisCache_Close(t *testing.T) {
 	s, addr := setupMiniredis(t)
 	defer s.Close()
 
-	cache, err := NewRedisCache("redis://" + addr)
+	cache, err := NewRedisCache("redis://"+addr, config.RedisConfig{})
 	assert.NoError(t, err)
 
 	err = cache.Close()
 	assert.NoError(t, err)
 }
+
+func TestApplyRedisConfig(t *testing.T) {
+	opt, err := redis.ParseURL("redis://localhost:6379/0?pool_size=20&read_timeout=2s")
+	assert.NoError(t, err)
+
+	applyRedisConfig(opt, config.RedisConfig{
+		MinIdleConns: 5,
+		DialTimeout:  time.Second,
+		WriteTimeout: 500 * time.Millisecond,
+		MaxRetries:   -1,
+	})
+	assert.Equal(t, 20, opt.PoolSize, "settings from the URL are kept")
+	assert.Equal(t, 2*time.Second, opt.ReadTimeout)
+	assert.Equal(t, 5, opt.MinIdleConns)
+	assert.Equal(t, time.Second, opt.DialTimeout)
+	assert.Equal(t, 500*time.Millisecond, opt.WriteTimeout)
+	assert.Equal(t, -1, opt.MaxRetries)
+}
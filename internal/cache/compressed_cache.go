@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionMagic starts every value written by a CompressedCache. Values
+// without it were stored uncompressed (e.g. before compression was enabled)
+// and are returned as they are.
+var compressionMagic = []byte("\x00STZ")
+
+// Format bytes following compressionMagic.
+const (
+	formatRaw  byte = 'r'
+	formatGzip byte = 'g'
+	formatZstd byte = 'z'
+)
+
+// CompressedCache compresses values of at least minSize bytes before
+// writing them to the next cache, and decompresses them on Get.
+type CompressedCache struct {
+	next    Cache
+	format  byte
+	minSize int
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// Wraps a cache so large values are stored compressed with algorithm
+// ("gzip" or "zstd"). An empty algorithm or "off" returns the cache
+// unchanged.
+func NewCompressedCache(next Cache, algorithm string, minSize int) (Cache, error) {
+	c := &CompressedCache{next: next, minSize: minSize}
+	switch algorithm {
+	case "", "off":
+		return next, nil
+	case "gzip":
+		c.format = formatGzip
+	case "zstd":
+		c.format = formatZstd
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm '%s'", algorithm)
+	}
+
+	var err error
+	c.encoder, err = zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	// Values written with either algorithm stay readable if it is changed.
+	c.decoder, err = zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *CompressedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.next.Get(ctx, key)
+	if err != nil || value == nil {
+		return value, err
+	}
+	return c.decode(value)
+}
+
+func (c *CompressedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	encoded, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	return c.next.Set(ctx, key, encoded, ttl)
+}
+
+func (c *CompressedCache) Delete(ctx context.Context, key string) error {
+	return c.next.Delete(ctx, key)
+}
+
+func (c *CompressedCache) Close() error {
+	c.decoder.Close()
+	return c.next.Close()
+}
+
+func (c *CompressedCache) encode(value []byte) ([]byte, error) {
+	if len(value) < c.minSize {
+		if !bytes.HasPrefix(value, compressionMagic) {
+			return value, nil
+		}
+		// Mark it, or Get would mistake it for an envelope.
+		return envelope(formatRaw, value), nil
+	}
+
+	var compressed []byte
+	switch c.format {
+	case formatGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(value); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		compressed = buf.Bytes()
+	case formatZstd:
+		compressed = c.encoder.EncodeAll(value, nil)
+	}
+
+	// Incompressible payloads (e.g. PNGs) are not worth decompressing.
+	if len(compressed) >= len(value) {
+		return envelope(formatRaw, value), nil
+	}
+	return envelope(c.format, compressed), nil
+}
+
+func (c *CompressedCache) decode(value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, compressionMagic) || len(value) == len(compressionMagic) {
+		return value, nil
+	}
+	body := value[len(compressionMagic)+1:]
+	switch value[len(compressionMagic)] {
+	case formatRaw:
+		return body, nil
+	case formatGzip:
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress cached value: %w", err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress cached value: %w", err)
+		}
+		return data, nil
+	case formatZstd:
+		data, err := c.decoder.DecodeAll(body, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress cached value: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown compression format '%c' in cached value", value[len(compressionMagic)])
+	}
+}
+
+func envelope(format byte, body []byte) []byte {
+	out := make([]byte, 0, len(compressionMagic)+1+len(body))
+	out = append(out, compressionMagic...)
+	out = append(out, format)
+	return append(out, body...)
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedCache(t *testing.T) {
+	ctx := context.Background()
+	large := bytes.Repeat([]byte(`{"name":"stratum","tags":["a","b"]},`), 100)
+
+	for _, algorithm := range []string{"gzip", "zstd"} {
+		t.Run(algorithm, func(t *testing.T) {
+			store := NewMemoryCache(10, 0)
+			c, err := NewCompressedCache(store, algorithm, 256)
+			assert.NoError(t, err)
+
+			assert.NoError(t, c.Set(ctx, "large", large, time.Minute))
+			stored, _ := store.Get(ctx, "large")
+			assert.Less(t, len(stored), len(large)/4, "large values are stored compressed")
+			val, err := c.Get(ctx, "large")
+			assert.NoError(t, err)
+			assert.Equal(t, large, val)
+
+			assert.NoError(t, c.Set(ctx, "small", []byte("tiny"), time.Minute))
+			stored, _ = store.Get(ctx, "small")
+			assert.Equal(t, []byte("tiny"), stored, "values below the threshold are stored as is")
+			val, _ = c.Get(ctx, "small")
+			assert.Equal(t, []byte("tiny"), val)
+		})
+	}
+
+	t.Run("Incompressible Values", func(t *testing.T) {
+		random := make([]byte, 1024)
+		rand.Read(random)
+		c, _ := NewCompressedCache(NewMemoryCache(10, 0), "zstd", 0)
+
+		assert.NoError(t, c.Set(ctx, "random", random, time.Minute))
+		val, err := c.Get(ctx, "random")
+		assert.NoError(t, err)
+		assert.Equal(t, random, val)
+	})
+
+	t.Run("Values Resembling An Envelope", func(t *testing.T) {
+		tricky := append([]byte("\x00STZg"), []byte("not gzip")...)
+		c, _ := NewCompressedCache(NewMemoryCache(10, 0), "gzip", 1024)
+
+		assert.NoError(t, c.Set(ctx, "tricky", tricky, time.Minute))
+		val, err := c.Get(ctx, "tricky")
+		assert.NoError(t, err)
+		assert.Equal(t, tricky, val)
+	})
+
+	t.Run("Uncompressed Values Written Earlier", func(t *testing.T) {
+		store := NewMemoryCache(10, 0)
+		store.Set(ctx, "legacy", large, time.Minute)
+		c, _ := NewCompressedCache(store, "gzip", 0)
+
+		val, err := c.Get(ctx, "legacy")
+		assert.NoError(t, err)
+		assert.Equal(t, large, val)
+	})
+
+	t.Run("Switching Algorithms", func(t *testing.T) {
+		store := NewMemoryCache(10, 0)
+		gz, _ := NewCompressedCache(store, "gzip", 0)
+		zs, _ := NewCompressedCache(store, "zstd", 0)
+
+		assert.NoError(t, gz.Set(ctx, "key", large, time.Minute))
+		val, err := zs.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, large, val)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		store := NewMemoryCache(10, 0)
+		c, err := NewCompressedCache(store, "off", 0)
+		assert.NoError(t, err)
+		assert.Same(t, store, c.(*MemoryCache))
+
+		_, err = NewCompressedCache(store, "brotli", 0)
+		assert.Error(t, err)
+	})
+}
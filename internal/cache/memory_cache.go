@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process LRU cache holding at most a fixed number of
+// entries, and optionally at most a number of bytes of values. Expired
+// entries are dropped when they are looked up or evicted.
+type MemoryCache struct {
+	maxEntries int
+	maxBytes   int64 // zero for no limit
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+	bytes   int64      // sum of the lengths of the values held
+
+	now func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero for no expiry
+}
+
+// Creates a MemoryCache holding at most maxEntries entries, whose values
+// add up to at most maxBytes bytes, unless maxBytes is zero. A value larger
+// than maxBytes is not kept at all.
+func NewMemoryCache(maxEntries int, maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && !m.now().Before(entry.expires) {
+		m.remove(elem)
+		return nil, nil
+	}
+	m.order.MoveToFront(elem)
+	return entry.value, nil
+}
+
+// Adds a value to the cache. A zero TTL keeps the value until it is evicted.
+func (m *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var expires time.Time
+	if ttl > 0 {
+		expires = m.now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+	if m.maxBytes > 0 && int64(len(value)) > m.maxBytes {
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	m.bytes += int64(len(value))
+	for m.order.Len() > m.maxEntries || (m.maxBytes > 0 && m.bytes > m.maxBytes) {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+func (m *MemoryCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+	return nil
+}
+
+func (m *MemoryCache) Close() error {
+	return nil
+}
+
+// Len returns the number of entries held, including expired ones that were
+// not looked up since.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// Bytes returns the total size of the values held.
+func (m *MemoryCache) Bytes() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytes
+}
+
+func (m *MemoryCache) remove(elem *list.Element) {
+	entry := elem.Value.(*memoryEntry)
+	m.order.Remove(elem)
+	delete(m.entries, entry.key)
+	m.bytes -= int64(len(entry.value))
+}
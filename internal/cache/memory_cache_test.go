@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Get And Set", func(t *testing.T) {
+		cache := NewMemoryCache(10, 0)
+		assert.NoError(t, cache.Set(ctx, "key", []byte("value"), time.Minute))
+
+		val, err := cache.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+
+		val, err = cache.Get(ctx, "missing")
+		assert.NoError(t, err)
+		assert.Nil(t, val)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		cache := NewMemoryCache(10, 0)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+		cache.Set(ctx, "short", []byte("value"), time.Second)
+		cache.Set(ctx, "forever", []byte("value"), 0)
+
+		now = now.Add(2 * time.Second)
+		val, _ := cache.Get(ctx, "short")
+		assert.Nil(t, val)
+		val, _ = cache.Get(ctx, "forever")
+		assert.Equal(t, []byte("value"), val)
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("Evicts Least Recently Used", func(t *testing.T) {
+		cache := NewMemoryCache(2, 0)
+		cache.Set(ctx, "a", []byte("1"), 0)
+		cache.Set(ctx, "b", []byte("2"), 0)
+		cache.Get(ctx, "a")
+		cache.Set(ctx, "c", []byte("3"), 0)
+
+		val, _ := cache.Get(ctx, "b")
+		assert.Nil(t, val, "b was used least recently")
+		val, _ = cache.Get(ctx, "a")
+		assert.Equal(t, []byte("1"), val)
+		assert.Equal(t, 2, cache.Len())
+	})
+
+	t.Run("Evicts Over Byte Budget", func(t *testing.T) {
+		cache := NewMemoryCache(10, 10)
+		cache.Set(ctx, "a", []byte("1234"), 0)
+		cache.Set(ctx, "b", []byte("1234"), 0)
+		cache.Set(ctx, "c", []byte("1234"), 0)
+
+		val, _ := cache.Get(ctx, "a")
+		assert.Nil(t, val, "a was evicted to stay within 10 bytes")
+		assert.Equal(t, int64(8), cache.Bytes())
+
+		cache.Set(ctx, "b", []byte("12"), 0)
+		assert.Equal(t, int64(6), cache.Bytes(), "replaced values are accounted for")
+
+		cache.Set(ctx, "huge", []byte("12345678901"), 0)
+		val, _ = cache.Get(ctx, "huge")
+		assert.Nil(t, val, "values over the budget are not kept")
+		assert.Equal(t, 2, cache.Len())
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		cache := NewMemoryCache(10, 0)
+		cache.Set(ctx, "key", []byte("value"), 0)
+		assert.NoError(t, cache.Delete(ctx, "key"))
+		val, _ := cache.Get(ctx, "key")
+		assert.Nil(t, val)
+	})
+}
@@ -18,6 +18,10 @@ func (n *NoOpCache) Set(ctx context.Context, key string, value []byte, ttl time.
 	return nil
 }
 
+func (n *NoOpCache) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
 func (n *NoOpCache) Close() error {
 	return nil
 }
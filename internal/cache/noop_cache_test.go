@@ -23,6 +23,11 @@ func TestNoOpCache(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("Delete", func(t *testing.T) {
+		err := cache.Delete(ctx, "any_key")
+		assert.NoError(t, err)
+	})
+
 	t.Run("Close", func(t *testing.T) {
 		err := cache.Close()
 		assert.NoError(t, err)
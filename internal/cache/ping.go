@@ -0,0 +1,38 @@
+package cache
+
+import "context"
+
+// Pinger is implemented by caches that depend on a server, to check that it
+// can be reached. Caches that do not implement it are always reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func (r *RedisCache) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Wrappers ping the cache they wrap.
+
+func (p *PrefixedCache) Ping(ctx context.Context) error {
+	return ping(ctx, p.next)
+}
+
+func (c *CompressedCache) Ping(ctx context.Context) error {
+	return ping(ctx, c.next)
+}
+
+func (a *AsyncCache) Ping(ctx context.Context) error {
+	return ping(ctx, a.next)
+}
+
+func (t *TieredCache) Ping(ctx context.Context) error {
+	return ping(ctx, t.l2)
+}
+
+func ping(ctx context.Context, c Cache) error {
+	if pinger, ok := c.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
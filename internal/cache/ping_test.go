@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	s, addr := setupMiniredis(t)
+	defer s.Close()
+
+	ctx := context.Background()
+	redisCache, err := NewRedisCache("redis://"+addr, config.RedisConfig{})
+	require.NoError(t, err)
+	defer redisCache.Close()
+	compressed, err := NewCompressedCache(NewPrefixedCache(redisCache, "eu:"), "gzip", 0)
+	require.NoError(t, err)
+	c := NewTieredCache(NewMemoryCache(10, 0), compressed, 0)
+
+	assert.NoError(t, c.Ping(ctx))
+	s.Close()
+	assert.Error(t, c.Ping(ctx), "wrappers ping the Redis server they wrap")
+
+	_, ok := Cache(NewMemoryCache(10, 0)).(Pinger)
+	assert.False(t, ok)
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// PrefixedCache prepends a prefix to every key, so several Stratum
+// deployments can share one Redis server without their keys colliding.
+type PrefixedCache struct {
+	prefix string
+	next   Cache
+}
+
+// Wraps a cache so all keys are prefixed. An empty prefix returns the cache
+// unchanged.
+func NewPrefixedCache(next Cache, prefix string) Cache {
+	if prefix == "" {
+		return next
+	}
+	return &PrefixedCache{prefix: prefix, next: next}
+}
+
+func (p *PrefixedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return p.next.Get(ctx, p.prefix+key)
+}
+
+func (p *PrefixedCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return p.next.Set(ctx, p.prefix+key, value, ttl)
+}
+
+func (p *PrefixedCache) Delete(ctx context.Context, key string) error {
+	return p.next.Delete(ctx, p.prefix+key)
+}
+
+func (p *PrefixedCache) Close() error {
+	return p.next.Close()
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixedCache(t *testing.T) {
+	ctx := context.Background()
+	shared := NewMemoryCache(10, 0)
+	eu := NewPrefixedCache(shared, "eu:")
+	us := NewPrefixedCache(shared, "us:")
+
+	assert.NoError(t, eu.Set(ctx, "project_1:7", []byte("eu"), time.Minute))
+	assert.NoError(t, us.Set(ctx, "project_1:7", []byte("us"), time.Minute))
+
+	val, _ := eu.Get(ctx, "project_1:7")
+	assert.Equal(t, []byte("eu"), val)
+	val, _ = shared.Get(ctx, "us:project_1:7")
+	assert.Equal(t, []byte("us"), val)
+
+	assert.NoError(t, eu.Delete(ctx, "project_1:7"))
+	val, _ = us.Get(ctx, "project_1:7")
+	assert.Equal(t, []byte("us"), val)
+
+	assert.Same(t, shared, NewPrefixedCache(shared, "").(*MemoryCache))
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyStats counts the keys a cache holds under a prefix, with an estimate
+// of the memory they use.
+type KeyStats struct {
+	Keys        int64 `json:"keys"`
+	MemoryBytes int64 `json:"memory_bytes"`
+}
+
+// Inspector is implemented by caches that can report on their contents.
+// Both methods may be slow, and are meant for the admin API only.
+type Inspector interface {
+	// Counts the keys starting with prefix.
+	KeyStats(ctx context.Context, prefix string) (KeyStats, error)
+	// Returns highlights of the backend's own statistics.
+	Info(ctx context.Context) (map[string]string, error)
+}
+
+// ErrNotInspectable is returned by wrappers around caches that cannot
+// report on their contents.
+var ErrNotInspectable = errors.New("cache does not support inspection")
+
+// How many keys of a prefix are measured with MEMORY USAGE. The memory of
+// the remaining keys is extrapolated from them.
+const memorySampleSize = 50
+
+// INFO fields reported by RedisCache.Info.
+var redisInfoHighlights = map[string]bool{
+	"redis_version":           true,
+	"uptime_in_seconds":       true,
+	"connected_clients":       true,
+	"used_memory":             true,
+	"used_memory_human":       true,
+	"used_memory_peak_human":  true,
+	"maxmemory":               true,
+	"maxmemory_human":         true,
+	"maxmemory_policy":        true,
+	"mem_fragmentation_ratio": true,
+	"keyspace_hits":           true,
+	"keyspace_misses":         true,
+	"evicted_keys":            true,
+	"expired_keys":            true,
+}
+
+// Counts the keys under a prefix with SCAN, so Redis is never blocked.
+func (r *RedisCache) KeyStats(ctx context.Context, prefix string) (KeyStats, error) {
+	var stats KeyStats
+	var sampled, sampledBytes int64
+	iter := r.client.Scan(ctx, 0, escapeGlob(prefix)+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		stats.Keys++
+		if sampled < memorySampleSize {
+			usage, err := r.client.MemoryUsage(ctx, iter.Val()).Result()
+			if err == nil {
+				sampled++
+				sampledBytes += usage
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return KeyStats{}, fmt.Errorf("failed to scan redis keys: %w", err)
+	}
+	if sampled > 0 {
+		stats.MemoryBytes = sampledBytes * stats.Keys / sampled
+	}
+	return stats, nil
+}
+
+// Returns the memory, eviction and keyspace figures of the Redis server.
+func (r *RedisCache) Info(ctx context.Context) (map[string]string, error) {
+	raw, err := r.client.Info(ctx).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redis info: %w", err)
+	}
+	return parseRedisInfo(raw), nil
+}
+
+// Picks the highlights and keyspace lines (db0, db1, ...) out of an INFO
+// reply.
+func parseRedisInfo(raw string) map[string]string {
+	info := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if redisInfoHighlights[name] || (strings.HasPrefix(name, "db") && strings.Contains(value, "keys=")) {
+			info[name] = value
+		}
+	}
+	return info
+}
+
+// Escapes the characters SCAN MATCH treats as patterns.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Counts the unexpired keys under a prefix. Memory counts keys and values
+// only, not the LRU's own overhead.
+func (m *MemoryCache) KeyStats(ctx context.Context, prefix string) (KeyStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var stats KeyStats
+	now := m.now()
+	for key, elem := range m.entries {
+		entry := elem.Value.(*memoryEntry)
+		if !strings.HasPrefix(key, prefix) || (!entry.expires.IsZero() && !now.Before(entry.expires)) {
+			continue
+		}
+		stats.Keys++
+		stats.MemoryBytes += int64(len(key) + len(entry.value))
+	}
+	return stats, nil
+}
+
+func (m *MemoryCache) Info(ctx context.Context) (map[string]string, error) {
+	return map[string]string{
+		"entries":     fmt.Sprint(m.Len()),
+		"max_entries": fmt.Sprint(m.maxEntries),
+		"bytes":       fmt.Sprint(m.Bytes()),
+		"max_bytes":   fmt.Sprint(m.maxBytes),
+	}, nil
+}
+
+func (n *NoOpCache) KeyStats(ctx context.Context, prefix string) (KeyStats, error) {
+	return KeyStats{}, nil
+}
+
+func (n *NoOpCache) Info(ctx context.Context) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// Wrappers inspect the cache they wrap.
+
+func (p *PrefixedCache) KeyStats(ctx context.Context, prefix string) (KeyStats, error) {
+	return keyStats(ctx, p.next, p.prefix+prefix)
+}
+
+func (p *PrefixedCache) Info(ctx context.Context) (map[string]string, error) {
+	return info(ctx, p.next)
+}
+
+func (c *CompressedCache) KeyStats(ctx context.Context, prefix string) (KeyStats, error) {
+	return keyStats(ctx, c.next, prefix)
+}
+
+func (c *CompressedCache) Info(ctx context.Context) (map[string]string, error) {
+	return info(ctx, c.next)
+}
+
+// Keys are reported from the second tier, which holds all of them.
+func (t *TieredCache) KeyStats(ctx context.Context, prefix string) (KeyStats, error) {
+	return keyStats(ctx, t.l2, prefix)
+}
+
+func (t *TieredCache) Info(ctx context.Context) (map[string]string, error) {
+	return info(ctx, t.l2)
+}
+
+func keyStats(ctx context.Context, c Cache, prefix string) (KeyStats, error) {
+	inspector, ok := c.(Inspector)
+	if !ok {
+		return KeyStats{}, ErrNotInspectable
+	}
+	return inspector.KeyStats(ctx, prefix)
+}
+
+func info(ctx context.Context, c Cache) (map[string]string, error) {
+	inspector, ok := c.(Inspector)
+	if !ok {
+		return nil, ErrNotInspectable
+	}
+	return inspector.Info(ctx)
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisCache_KeyStats(t *testing.T) {
+	s, addr := setupMiniredis(t)
+	defer s.Close()
+
+	ctx := context.Background()
+	redisCache, err := NewRedisCache("redis://"+addr, config.RedisConfig{})
+	assert.NoError(t, err)
+	defer redisCache.Close()
+	c := NewPrefixedCache(redisCache, "eu:")
+
+	c.Set(ctx, "avatars:1", []byte("one"), time.Minute)
+	c.Set(ctx, "avatars:2", []byte("two"), time.Minute)
+	c.Set(ctx, "avatars*:3", []byte("glob"), time.Minute)
+	c.Set(ctx, "products:1", []byte("other"), time.Minute)
+	redisCache.Set(ctx, "avatars:4", []byte("unprefixed"), time.Minute)
+
+	stats, err := c.(Inspector).KeyStats(ctx, "avatars:")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Keys)
+	assert.Greater(t, stats.MemoryBytes, int64(0))
+
+	stats, err = c.(Inspector).KeyStats(ctx, "avatars*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Keys, "glob characters are matched literally")
+}
+
+func TestParseRedisInfo(t *testing.T) {
+	raw := "# Server\r\nredis_version:7.2.4\r\nos:Linux\r\n\r\n# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\n" +
+		"maxmemory_policy:allkeys-lru\r\n# Stats\r\nevicted_keys:12\r\nkeyspace_hits:90\r\n# Keyspace\r\ndb0:keys=42,expires=40,avg_ttl=1000\r\n"
+
+	assert.Equal(t, map[string]string{
+		"redis_version":     "7.2.4",
+		"used_memory":       "1048576",
+		"used_memory_human": "1.00M",
+		"maxmemory_policy":  "allkeys-lru",
+		"evicted_keys":      "12",
+		"keyspace_hits":     "90",
+		"db0":               "keys=42,expires=40,avg_ttl=1000",
+	}, parseRedisInfo(raw))
+}
+
+func TestMemoryCache_KeyStats(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache(10, 0)
+	m.Set(ctx, "avatars:1", []byte("12345"), time.Minute)
+	m.Set(ctx, "products:1", []byte("1"), time.Minute)
+
+	stats, err := m.KeyStats(ctx, "avatars:")
+	assert.NoError(t, err)
+	assert.Equal(t, KeyStats{Keys: 1, MemoryBytes: int64(len("avatars:1") + 5)}, stats)
+}
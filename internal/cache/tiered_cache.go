@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// TieredCache serves hot keys from an in-process cache (L1) in front of a
+// shared cache such as Redis (L2). L1 entries live for at most l1TTL, which
+// bounds how long an instance can serve a value deleted or replaced through
+// another instance.
+type TieredCache struct {
+	l1    *MemoryCache
+	l2    Cache
+	l1TTL time.Duration
+}
+
+// Creates a TieredCache with l1 in front of l2.
+func NewTieredCache(l1 *MemoryCache, l2 Cache, l1TTL time.Duration) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2, l1TTL: l1TTL}
+}
+
+func (t *TieredCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, _ := t.l1.Get(ctx, key); value != nil {
+		return value, nil
+	}
+
+	value, err := t.l2.Get(ctx, key)
+	if err != nil || value == nil {
+		return value, err
+	}
+	t.l1.Set(ctx, key, value, t.l1TTL)
+	return value, nil
+}
+
+func (t *TieredCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	l1TTL := t.l1TTL
+	if ttl > 0 && ttl < l1TTL {
+		l1TTL = ttl
+	}
+	t.l1.Set(ctx, key, value, l1TTL)
+	return t.l2.Set(ctx, key, value, ttl)
+}
+
+func (t *TieredCache) Delete(ctx context.Context, key string) error {
+	t.l1.Delete(ctx, key)
+	return t.l2.Delete(ctx, key)
+}
+
+func (t *TieredCache) Close() error {
+	return t.l2.Close()
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredCache(t *testing.T) {
+	s, addr := setupMiniredis(t)
+	defer s.Close()
+
+	redisCache, err := NewRedisCache("redis://"+addr, config.RedisConfig{})
+	assert.NoError(t, err)
+	l1 := NewMemoryCache(10, 0)
+	cache := NewTieredCache(l1, redisCache, 5*time.Second)
+	defer cache.Close()
+	ctx := context.Background()
+
+	t.Run("Set Writes Both Tiers", func(t *testing.T) {
+		assert.NoError(t, cache.Set(ctx, "avatar", []byte("png"), time.Hour))
+		assert.True(t, s.Exists("avatar"))
+		assert.Equal(t, time.Hour, s.TTL("avatar"))
+		val, _ := l1.Get(ctx, "avatar")
+		assert.Equal(t, []byte("png"), val)
+	})
+
+	t.Run("Hot Keys Are Served From Memory", func(t *testing.T) {
+		s.Del("avatar")
+		val, err := cache.Get(ctx, "avatar")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("png"), val)
+	})
+
+	t.Run("Misses Fall Back To Redis", func(t *testing.T) {
+		s.Set("banner", "jpg")
+		val, err := cache.Get(ctx, "banner")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("jpg"), val)
+
+		val, _ = l1.Get(ctx, "banner")
+		assert.Equal(t, []byte("jpg"), val, "promoted to memory")
+	})
+
+	t.Run("Delete Removes Both Tiers", func(t *testing.T) {
+		assert.NoError(t, cache.Delete(ctx, "banner"))
+		assert.False(t, s.Exists("banner"))
+		val, _ := cache.Get(ctx, "banner")
+		assert.Nil(t, val)
+	})
+}
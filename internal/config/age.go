@@ -0,0 +1,46 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// Decrypts files encrypted with age (https://age-encryption.org/v1) to
+// X25519 identities, the AGE-SECRET-KEY-1... keys of age-keygen. This is
+// how SOPS encrypts the data key of files encrypted with age keys.
+
+// Parses the age identities of a key file: one AGE-SECRET-KEY-1... per
+// line, with blank lines and # comments ignored.
+func parseAgeIdentities(text string) ([]age.Identity, error) {
+	identities, err := age.ParseIdentities(strings.NewReader(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity: %w", err)
+	}
+	return identities, nil
+}
+
+// Decrypts an age file, armored or not, with the first identity it was
+// encrypted to.
+func ageDecrypt(file []byte, identities []age.Identity) ([]byte, error) {
+	buffered := bufio.NewReader(bytes.NewReader(bytes.TrimLeft(file, " \t\r\n")))
+	var in io.Reader = buffered
+	if start, _ := buffered.Peek(len(armor.Header)); string(start) == armor.Header {
+		in = armor.NewReader(buffered)
+	}
+	r, err := age.Decrypt(in, identities...)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, fmt.Errorf("no age identity matches the file's recipients")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
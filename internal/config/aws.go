@@ -0,0 +1,261 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reads secrets from AWS Secrets Manager. References are the name or ARN of
+// a secret and, for secrets holding a JSON object, the field to use:
+// "aws-sm://stratum/db#dsn". Credentials are taken from the usual
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables,
+// or from the ECS task role; the region from AWS_REGION unless the
+// reference is an ARN.
+type awsSecretsStore struct {
+	getenv func(string) string
+	client *http.Client
+	now    func() time.Time
+}
+
+type awsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"Token"`
+}
+
+// Where ECS serves the credentials of a task's role.
+const ecsCredentialsHost = "http://169.254.170.2"
+
+func newAWSSecretsStore(getenv func(string) string) secretStore {
+	return &awsSecretsStore{getenv: getenv, client: &http.Client{}, now: time.Now}
+}
+
+func (a *awsSecretsStore) fetch(ctx context.Context, ref string) (string, time.Duration, error) {
+	secretID, field, _ := strings.Cut(ref, "#")
+	region := a.region(secretID)
+	if region == "" {
+		return "", 0, fmt.Errorf("AWS_REGION is not set")
+	}
+	creds, err := a.credentials(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	endpoint := a.getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+	body, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(req, body, creds, region, "secretsmanager", a.now())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("secrets manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var secret struct {
+		SecretString *string `json:"SecretString"`
+		SecretBinary []byte  `json:"SecretBinary"`
+		Message      string  `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil && resp.StatusCode == http.StatusOK {
+		return "", 0, fmt.Errorf("invalid secrets manager response for '%s': %w", secretID, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("secrets manager returned %s for '%s': %s", resp.Status, secretID, secret.Message)
+	}
+
+	value := string(secret.SecretBinary)
+	if secret.SecretString != nil {
+		value = *secret.SecretString
+	}
+	value, err = jsonSecretField(value, field)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w in secret '%s'", err, secretID)
+	}
+	return value, 0, nil
+}
+
+// ARNs name their region, e.g. arn:aws:secretsmanager:eu-west-1:...
+func (a *awsSecretsStore) region(secretID string) string {
+	if parts := strings.Split(secretID, ":"); len(parts) > 3 && parts[0] == "arn" {
+		return parts[3]
+	}
+	if region := a.getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return a.getenv("AWS_DEFAULT_REGION")
+}
+
+func (a *awsSecretsStore) credentials(ctx context.Context) (awsCredentials, error) {
+	return loadAWSCredentials(ctx, a.client, a.getenv)
+}
+
+// Reads AWS credentials from the environment, or from the ECS task role.
+func loadAWSCredentials(ctx context.Context, client *http.Client, getenv func(string) string) (awsCredentials, error) {
+	if id := getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	uri := getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		uri = ecsCredentialsHost + relative
+	}
+	if uri == "" {
+		return awsCredentials{}, fmt.Errorf("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if token := getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to get container credentials: %w", err)
+	}
+	defer resp.Body.Close()
+	var creds awsCredentials
+	if resp.StatusCode != http.StatusOK {
+		return creds, fmt.Errorf("failed to get container credentials: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+		return creds, fmt.Errorf("invalid container credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// Signs a request with AWS Signature Version 4, covering its body and all of
+// the headers set so far.
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSHA256(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	signature := signatureV4(creds, amzDate, scope, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// Presigns a URL with AWS Signature Version 4 query parameters, valid for
+// expires. Only the host header is signed.
+func presignV4(u *url.URL, payloadHash string, creds awsCredentials, region, service string, now time.Time, expires time.Duration) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + region + "/" + service + "/aws4_request"
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if creds.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		path,
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		payloadHash,
+	}, "\n")
+
+	u.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signatureV4(creds, amzDate, scope, canonicalRequest)
+}
+
+// Signs a canonical request for a credential scope.
+func signatureV4(creds awsCredentials, amzDate, scope, canonicalRequest string) string {
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, data)
+	return mac.Sum(nil)
+}
+
+// Picks a field of a secret holding a JSON object, or returns the whole
+// secret if no field is selected.
+func jsonSecretField(value, field string) (string, error) {
+	if field == "" {
+		return value, nil
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return "", fmt.Errorf("field '%s' selected, but the secret is not a JSON object", field)
+	}
+	return secretField(data, field)
+}
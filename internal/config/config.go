@@ -1,32 +1,273 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/hooks"
 )
 
 type Project struct {
 	Name          string
 	Route         string
+	Routes        []string // every route pattern served, Route first
 	IdColumn      string
 	ContentType   string
 	CacheTTL      time.Duration
 	IdPlaceholder string
 
+	// For routes with several placeholders, such as
+	// /tenants/{tenant_id}/users/{user_id}, the columns of the composite key
+	// they name, in route order. IdPlaceholder is the last one, and IDs are
+	// the values of each, separated by "/".
+	IdColumns []string
+
+	// Cache-Control sent with payloads, whose max-age is CacheTTL: "public"
+	// (default), "private" (browsers only) or "off" to send none, e.g. behind
+	// a CDN with its own rules. s-maxage and stale-while-revalidate are added
+	// when set, immutable when CacheImmutable is.
+	CacheControl              string
+	CacheSMaxAge              time.Duration
+	CacheStaleWhileRevalidate time.Duration
+	CacheImmutable            bool
+
+	// Codec decoding public IDs (e.g. "hashids") into source keys
+	IDCodec          string
+	IDCodecSalt      string
+	IDCodecMinLength int
+
+	// Transformation chain normalizing IDs from URLs before the lookup,
+	// e.g. "url-decode | strip-extension:png | lowercase"
+	IDTransform string
+
+	// Requests whose ID (as it appears in the URL) does not fully match
+	// IDPattern or is longer than IDMaxLength are rejected with 400
+	IDPattern   string
+	IDMaxLength int
+
 	// Source-specific fields
-	SourceType  string // "database" or "api"
+	SourceType  string // "database", "api" or a type added with RegisterSourceType
 	DB_DSN      string // For database source
 	Table       string // For database source
 	ServeColumn string // For database source
 	APIEndpoint string // For api source
 
+	// Read replicas of DB_DSN, read from in turn while they are healthy
+	DBReplicaDSNs []string
+
+	// Client certificate, key and CA bundle (PEM files) for TLS connections
+	// to the database and its replicas, or whether to encrypt them without
+	// verifying the server's certificate
+	DBTLSCertFile   string
+	DBTLSKeyFile    string
+	DBCAFile        string
+	DBTLSSkipVerify bool
+
+	// Dialect of DB_DSN and the replica DSNs: "postgres", "mysql" or
+	// "cockroachdb", or empty to tell it from the DSN
+	DBDriver string
+
+	// How connections to the database and its replicas authenticate: with
+	// the DSN's password ("password", the default), or with a short-lived
+	// IAM token fetched for each connection ("rds-iam" or "cloudsql-iam")
+	DBAuth string
+
+	// When the database is connected to: "lazy" (the default) starts the
+	// project even if it cannot be reached, failing its requests until it
+	// can; "eager" fails startup and reloads instead
+	DBConnect string
+
+	// A condition ANDed to the ID lookup of database sources, such as
+	// "deleted_at IS NULL"
+	WhereExtra string
+
+	// How database sources compare IDs to the ID column: "exact" (the
+	// default), "case-insensitive", or "prefix"
+	IDMatch string
+
+	// A column holding when rows of database sources were last modified,
+	// sent as Last-Modified and used to revalidate them
+	ModifiedColumn string
+
+	// For source types added with RegisterSourceType, the variables
+	// PROJECT_<ID>_<TYPE>_*, keyed by the rest of their name
+	SourceSettings map[string]string
+
+	// How values in SERVE_COLUMN are stored: "raw" (default), "base64",
+	// "data-uri", "url" (fetched over HTTP) or "auto" to guess per value
+	ValueEncoding string
+	// VALUE_ENCODING is not set. Versions before it guessed every value's
+	// encoding, as "auto" does, so such projects are warned about.
+	ValueEncodingDefaulted bool
+
+	// SERVE_COLUMN holds binary data (bytea/BLOB), served byte for byte
+	// without interpreting it (database sources only)
+	ServeColumnBinary bool
+
+	// Transformation chain applied to the SERVE_COLUMN value as read, before
+	// VALUE_ENCODING interprets it, e.g. "gzip-decode" (database sources
+	// only)
+	QueryTransform string
+
+	// API request method and body template, e.g. {"id": "{user_id}"}
+	APIMethod          string
+	APIBody            string
+	APIBodyContentType string
+
+	// Static headers added to every upstream request, e.g. {"Accept": "image/webp"}
+	UpstreamHeaders map[string]string
+
+	// Timeout for requests to the upstream API or to URLs stored in the database
+	UpstreamTimeout time.Duration
+
+	// Client certificate, key and CA bundle (PEM files) for mTLS upstreams
+	UpstreamTLSCertFile string
+	UpstreamTLSKeyFile  string
+	UpstreamCAFile      string
+
+	// Redirects followed per upstream request (zero returns the redirect as
+	// an error), and whether credentials and UpstreamHeaders are kept
+	// ("keep") or stripped ("strip") when a redirect leaves the original
+	// origin
+	UpstreamMaxRedirects int
+	UpstreamRedirectAuth string
+
+	// How upstream error statuses are answered, keyed by status ("410") or
+	// class ("5xx"); unmapped errors are answered with 500
+	UpstreamStatusMap map[string]StatusMapping
+
+	// Proxy for upstream requests (http, https or socks5 URL), overriding
+	// HTTP_PROXY/HTTPS_PROXY; "none" connects directly
+	UpstreamProxy string
+
+	// Hosts URLs stored in the database may (or may not) point at, e.g.
+	// "cdn.example.com" or "*.example.com", and whether addresses in
+	// private, loopback and link-local ranges are refused
+	URLAllowedHosts []string
+	URLDeniedHosts  []string
+	URLBlockPrivate bool
+
 	// API Source Auth
 	APIAuthType       string
 	APIAuthSecret     string
 	APIAuthHeaderName string
+	APIAuthUsername   string // For basic auth
+	APIAuthPassword   string // For basic auth
+
+	// For hmac auth: the signature is sent in APIAuthHeaderName
+	APIAuthAlgorithm       string
+	APIAuthTimestampHeader string
+
+	// Transformation chain applied to fetched bytes, e.g. "base64-decode | json-extract:data"
+	Transform string
+
+	// Charset text payloads are stored in ("auto" to detect), transcoded to
+	// UTF-8 before transforms run and served with charset=utf-8
+	SourceCharset string
+
+	// Query parameters forwarded to the upstream API and included in the cache key
+	QueryParams []string
+
+	// Request headers forwarded to the upstream API and included in the cache key
+	ForwardHeaders []string
+
+	// Cache key template, e.g. "{route}:{id}:{query.size}"; empty for
+	// "<project>:<id>" followed by the forwarded query and headers
+	CacheKeyTemplate string
+
+	// Surrogate key templates, with the placeholders of cache keys, sent
+	// in SurrogateKeyHeader so a CDN can purge payloads by key
+	SurrogateKeys []string
+
+	// Where the project's payloads are cached: "shared" (the global cache),
+	// "redis" (its own Redis server or DB), "memory" (in-process only) or
+	// "none". CacheNamespace is prepended to its keys.
+	CacheBackend          string
+	CacheRedisURL         string // REDIS_URL with CACHE_REDIS_DB applied
+	CacheMemoryMaxEntries int
+	CacheMemoryMaxBytes   int64
+	CacheNamespace        string
+
+	// Expression hooks evaluated per request
+	Hooks hooks.Spec
+
+	// How often cached URL-backed payloads are checked against their origin
+	// with HEAD requests; zero disables revalidation
+	RevalidateInterval time.Duration
+
+	// How long the validators (ETag/Last-Modified) and payload of an expired
+	// entry are kept, so the next miss can revalidate it with a conditional
+	// request instead of a full download; zero disables
+	ConditionalRevalidation time.Duration
+
+	// How long a copy of an entry is kept after it expires, to be served
+	// when fetching it again fails; zero disables
+	StaleIfError time.Duration
+
+	// Seconds after boot during which fetch failures are answered with 503
+	// and a Retry-After header instead of 500, while upstreams warm up
+	WarmupPeriod time.Duration
+
+	// Deadline for answering a request, after which it fails with 504;
+	// zero for none
+	RequestTimeout time.Duration
+
+	// Synthetic latency and bandwidth limit applied to responses when
+	// SYNTHETIC_SHAPING is enabled
+	SyntheticDelay     time.Duration
+	SyntheticBandwidth int // bytes per second, zero for unlimited
+
+	// Requests per second each client IP may make to the project, with
+	// bursts of up to RateLimitBurst; zero for unlimited
+	RateLimit      float64
+	RateLimitBurst int
+
+	// Requests to the project handled at once, beyond which requests are
+	// answered with 503; zero for unlimited
+	MaxInFlight int
+
+	// Requests taking longer, or responses larger, are logged as warnings
+	// and counted; zero disables either check
+	SlowRequestThreshold   time.Duration
+	LargeResponseThreshold int
+
+	// Database queries taking longer are logged as warnings and counted;
+	// zero disables the check
+	SlowQueryThreshold time.Duration
+
+	// IDs fetched into the cache on startup, listed directly and/or
+	// returned by a SQL query (database sources only)
+	WarmIDs   []string
+	WarmQuery string
+
+	// Related IDs fetched in the background after a cache miss, e.g. "{id}_small", "{id+1}"
+	PrefetchPatterns []string
+
+	// Forward client Range requests to URL-backed upstreams instead of
+	// serving the full (cached) payload
+	RangePassthrough bool
+
+	// On-the-fly image resizing via ?w=&h=&format= query parameters
+	ImageResize       bool
+	ImageMaxDimension int
+	// Largest image, in pixels, decoded for resizing
+	ImageMaxPixels int
+
+	// Content-Type sniffing mode: "off", "fallback" (sniff when CONTENT_TYPE
+	// is unset) or "override" (prefer the sniffed type)
+	ContentTypeSniff string
+
+	// What to do with payloads that don't match CONTENT_TYPE: "off", "reject" or "flag"
+	ContentTypePolicy string
 }
 
 // AppConfig holds the global application configuration.
@@ -35,98 +276,1168 @@ type AppConfig struct {
 	ServerPort         string
 	RedisURL           string
 	ApiClientUserAgent string
+
+	// Addresses served on, ":8080" or "unix:/run/stratum.sock"; defaults to
+	// ServerPort. When AdminListen is set, the admin API and metrics are
+	// only served on its addresses.
+	Listen      []string
+	AdminListen []string
+
+	// Serves HTTPS with this certificate and key (PEM files) instead of
+	// plain HTTP. HTTPRedirectPort, if set, is a plain HTTP port redirecting
+	// to it.
+	TLSCertFile      string
+	TLSKeyFile       string
+	HTTPRedirectPort string
+
+	// Path prefix every endpoint is mounted under, e.g. "/stratum", or ""
+	// for none
+	BasePath string
+
+	// Header telling a CDN the surrogate keys of a payload, e.g.
+	// "Surrogate-Key" or "Cache-Tag"; empty to send none
+	SurrogateKeyHeader string
+
+	// CDN whose cache is purged along with Stratum's
+	CDNPurge CDNPurgeConfig
+
+	// Minimum level of log messages ("debug", "info", "warn" or "error"),
+	// overridden for modules by LogLevels, and their format, "console" or
+	// "json"
+	LogLevel  string
+	LogLevels map[string]string
+	LogFormat string
+
+	// Access log format, "json" or "text", and where it is written:
+	// "stdout", "stderr", "off" or the path of a file to append to
+	AccessLogFormat string
+	AccessLogOutput string
+
+	// Admin API
+	AdminToken string
+
+	// Ratio by which a payload has to differ from a project's average size to
+	// be reported as a size shift. Zero disables the detection.
+	PayloadSizeAlertRatio float64
+
+	// Maximum number of background prefetches running at once.
+	PrefetchConcurrency int
+
+	// Maximum number of IDs fetched at once while warming the cache.
+	WarmConcurrency int
+
+	// Bounds on warming the cache: how long it may take before the server
+	// starts listening regardless, and how many IDs are warmed per project;
+	// zero for no bound
+	WarmTimeout time.Duration
+	WarmMaxIDs  int
+
+	// Applies projects' synthetic delay and bandwidth limits. Meant to be
+	// enabled in staging only, so the same project config can ship to
+	// production.
+	SyntheticShaping bool
+
+	// Prepended to every cache key, so instances sharing a Redis server
+	// can keep their keys apart
+	CacheKeyPrefix string
+
+	// Connection pooling for upstream requests, shared by all projects
+	UpstreamTransport TransportConfig
+
+	// Connection pool, timeouts and retries of Redis clients
+	Redis RedisConfig
+
+	// Number of database pools, one per DSN, that may be open at once (zero
+	// for unlimited), and how long idle connections, and pools no project
+	// uses anymore, are kept open (zero for forever)
+	DBMaxPools    int
+	DBIdleTimeout time.Duration
+
+	// In-process cache in front of Redis for hot keys. Zero entries
+	// disables it. Zero bytes does not limit its size.
+	CacheL1MaxEntries int
+	CacheL1MaxBytes   int64
+	CacheL1TTL        time.Duration
+
+	// Compression of values written to Redis ("off", "gzip" or "zstd"),
+	// applied to values of at least CacheCompressionMinBytes
+	CacheCompression         string
+	CacheCompressionMinBytes int
+
+	// Writes cache entries in the background instead of on the request
+	// path, on a pool of workers with a bounded queue
+	CacheAsyncWrites    bool
+	CacheWriteWorkers   int
+	CacheWriteQueueSize int
+
+	// Reloads the configuration when the configuration or .env file changes,
+	// checking a remote configuration every ConfigPollInterval
+	ConfigWatch        bool
+	ConfigPollInterval time.Duration
+
+	// Requests per second each client IP may make to all projects together,
+	// with bursts of up to RateLimitBurst; zero for unlimited
+	RateLimit      float64
+	RateLimitBurst int
+
+	// Requests to all projects handled at once, beyond which requests are
+	// answered with 503; zero for unlimited
+	MaxInFlight int
+
+	// Limits on the request target, the headers and the body of requests,
+	// beyond which they are answered with 414, 431 and 413; zero for none
+	MaxURLLength   int
+	MaxHeaderBytes int
+	MaxBodyBytes   int64
+
+	// Proxies (IPs or CIDR ranges) whose X-Forwarded-For and X-Real-IP
+	// headers are trusted to tell the client IP; nil trusts every proxy,
+	// or none if rate limits are set, an empty list none
+	TrustedProxies []string
+
+	// Headers telling the client IP in requests from trusted proxies, in
+	// order of preference; nil for X-Forwarded-For, then X-Real-IP
+	ClientIPHeaders []string
+
+	// Refuses to start when Redis or any project's database or upstream
+	// cannot be reached, instead of finding out on the first request
+	StrictStartup bool
+
+	// When secret references should be resolved again: before the
+	// shortest of their leases runs out, or every SECRETS_REFRESH_SECONDS;
+	// zero if never
+	SecretsRefresh time.Duration
+}
+
+// TransportConfig tunes the connection pool used for upstream requests.
+type TransportConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // zero for unlimited
+	IdleConnTimeout     time.Duration
+	DisableKeepAlives   bool
+}
+
+// CDNPurgeConfig names the CDN service whose cached payloads are purged by
+// surrogate key when Stratum's own cache entries are.
+type CDNPurgeConfig struct {
+	Provider string // "fastly" or "cloudflare"; empty for none
+	Service  string // Fastly service ID or Cloudflare zone ID
+	Token    string // API token
+}
+
+// StatusMapping is the response to an upstream error status.
+type StatusMapping struct {
+	Status int
+
+	// Retry-After sent when the upstream sends none; zero for none
+	RetryAfter time.Duration
+}
+
+// RedisConfig tunes the Redis clients. Zero values keep the client's
+// defaults (or those set in the Redis URL).
+type RedisConfig struct {
+	PoolSize        int
+	MinIdleConns    int
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	MaxRetries      int // -1 disables retries
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
 }
 
 // Load scans the environment variables and builds the application configuration.
 func Load() (*AppConfig, error) {
+	return LoadFrom(os.Getenv, EnvKeys()...)
+}
+
+// LoadFrom builds the application configuration from variables returned by
+// getenv, which is called with the same keys Load reads from the environment.
+// keys lists the variables that are set, which is how named projects
+// (PROJECT_{NAME}_*) are found; numbered projects are found without it.
+// Values referencing a secret store (see secrets.go) are replaced by the
+// secrets.
+func LoadFrom(getenv func(string) string, keys ...string) (*AppConfig, error) {
+	secrets := newSecretResolver(getenv)
+	appConfig, err := loadFrom(secrets.getenv, keys)
+	// Settings may only be invalid because a secret could not be resolved,
+	// so that is reported first.
+	if secrets.err != nil {
+		return nil, secrets.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	appConfig.SecretsRefresh = secrets.refresh
+	return appConfig, nil
+}
+
+func loadFrom(getenv func(string) string, keys []string) (*AppConfig, error) {
 	// Google Cloud Run sets the PORT environment variable.
-	port := os.Getenv("PORT")
+	port := getenv("PORT")
 	if port == "" {
-		port = os.Getenv("SERVER_PORT")
+		port = getenv("SERVER_PORT")
 	}
 
 	appConfig := &AppConfig{
 		ServerPort:         port,
-		RedisURL:           os.Getenv("REDIS_URL"),
-		ApiClientUserAgent: os.Getenv("API_CLIENT_USER_AGENT"),
+		RedisURL:           getenv("REDIS_URL"),
+		ApiClientUserAgent: getenv("API_CLIENT_USER_AGENT"),
+		AdminToken:         getenv("ADMIN_TOKEN"),
+		CacheKeyPrefix:     getenv("CACHE_KEY_PREFIX"),
 	}
 
 	if appConfig.ServerPort == "" {
 		appConfig.ServerPort = "8080" // Default port
 	}
 
+	appConfig.Listen = []string{":" + appConfig.ServerPort}
+	if listen := getenv("LISTEN"); listen != "" {
+		appConfig.Listen = splitList(listen)
+	}
+	appConfig.AdminListen = splitList(getenv("ADMIN_LISTEN"))
+	for _, address := range appConfig.Listen {
+		if !isListenAddress(address) {
+			return nil, fmt.Errorf("invalid LISTEN address '%s'", address)
+		}
+	}
+	for _, address := range appConfig.AdminListen {
+		if !isListenAddress(address) {
+			return nil, fmt.Errorf("invalid ADMIN_LISTEN address '%s'", address)
+		}
+	}
+
+	appConfig.TLSCertFile = getenv("TLS_CERT_FILE")
+	appConfig.TLSKeyFile = getenv("TLS_KEY_FILE")
+	if (appConfig.TLSCertFile == "") != (appConfig.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	appConfig.HTTPRedirectPort = getenv("HTTP_REDIRECT_PORT")
+	if appConfig.HTTPRedirectPort != "" {
+		if appConfig.TLSCertFile == "" {
+			return nil, fmt.Errorf("HTTP_REDIRECT_PORT requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		if port, err := strconv.Atoi(appConfig.HTTPRedirectPort); err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid HTTP_REDIRECT_PORT '%s'", appConfig.HTTPRedirectPort)
+		}
+		if appConfig.HTTPRedirectPort == appConfig.ServerPort {
+			return nil, fmt.Errorf("HTTP_REDIRECT_PORT must differ from SERVER_PORT")
+		}
+	}
+
+	appConfig.BasePath = strings.TrimRight(getenv("BASE_PATH"), "/")
+	if appConfig.BasePath != "" {
+		if !strings.HasPrefix(appConfig.BasePath, "/") || strings.ContainsAny(appConfig.BasePath, ":*{}?#") {
+			return nil, fmt.Errorf("invalid BASE_PATH '%s', it must be a path starting with '/' without placeholders", getenv("BASE_PATH"))
+		}
+	}
+
+	appConfig.SurrogateKeyHeader = getenv("SURROGATE_KEY_HEADER")
+	if purge := getenv("CDN_PURGE"); purge != "" {
+		provider, service, _ := strings.Cut(purge, ":")
+		if (provider != "fastly" && provider != "cloudflare") || service == "" {
+			return nil, fmt.Errorf("invalid CDN_PURGE '%s', expected fastly:<service ID> or cloudflare:<zone ID>", purge)
+		}
+		appConfig.CDNPurge = CDNPurgeConfig{Provider: provider, Service: service, Token: getenv("CDN_PURGE_TOKEN")}
+		if appConfig.CDNPurge.Token == "" {
+			return nil, fmt.Errorf("CDN_PURGE requires CDN_PURGE_TOKEN")
+		}
+	}
+
+	appConfig.LogLevel = strings.ToLower(getenv("LOG_LEVEL"))
+	if appConfig.LogLevel == "" {
+		appConfig.LogLevel = "info"
+	}
+	if !isLogLevel(appConfig.LogLevel) {
+		return nil, fmt.Errorf("unknown LOG_LEVEL '%s'", appConfig.LogLevel)
+	}
+	for _, item := range splitList(getenv("LOG_LEVELS")) {
+		module, level, ok := strings.Cut(item, "=")
+		module, level = strings.TrimSpace(module), strings.ToLower(strings.TrimSpace(level))
+		if !ok || module == "" || !isLogLevel(level) {
+			return nil, fmt.Errorf("invalid LOG_LEVELS entry '%s', expected <module>=<level>", item)
+		}
+		if appConfig.LogLevels == nil {
+			appConfig.LogLevels = make(map[string]string)
+		}
+		appConfig.LogLevels[module] = level
+	}
+	appConfig.LogFormat = getenv("LOG_FORMAT")
+	switch appConfig.LogFormat {
+	case "":
+		appConfig.LogFormat = "console"
+	case "console", "json":
+	default:
+		return nil, fmt.Errorf("unknown LOG_FORMAT '%s'", appConfig.LogFormat)
+	}
+
+	appConfig.AccessLogFormat = getenv("ACCESS_LOG_FORMAT")
+	switch appConfig.AccessLogFormat {
+	case "":
+		appConfig.AccessLogFormat = "json"
+	case "json", "text":
+	default:
+		return nil, fmt.Errorf("unknown ACCESS_LOG_FORMAT '%s'", appConfig.AccessLogFormat)
+	}
+	appConfig.AccessLogOutput = getenv("ACCESS_LOG_OUTPUT")
+	if appConfig.AccessLogOutput == "" {
+		appConfig.AccessLogOutput = "stdout"
+	}
+
 	if appConfig.ApiClientUserAgent == "" {
 		appConfig.ApiClientUserAgent = "Stratum-Server/1.0 (github.com/PythonicVarun/Stratum)" // Default user agent
 	}
 
-	// Scan for projects by looking for PROJECT_{n}_ROUTE variables
-	for i := 1; ; i++ {
-		routeKey := fmt.Sprintf("PROJECT_%d_ROUTE", i)
-		route := os.Getenv(routeKey)
-		if route == "" {
-			break
+	appConfig.PayloadSizeAlertRatio = 10
+	if ratioStr := getenv("PAYLOAD_SIZE_ALERT_RATIO"); ratioStr != "" {
+		ratio, err := strconv.ParseFloat(ratioStr, 64)
+		if err != nil || ratio < 0 {
+			return nil, fmt.Errorf("invalid PAYLOAD_SIZE_ALERT_RATIO '%s'", ratioStr)
+		}
+		appConfig.PayloadSizeAlertRatio = ratio
+	}
+
+	appConfig.PrefetchConcurrency = 4
+	if concurrencyStr := getenv("PREFETCH_CONCURRENCY"); concurrencyStr != "" {
+		concurrency, err := strconv.Atoi(concurrencyStr)
+		if err != nil || concurrency < 0 {
+			return nil, fmt.Errorf("invalid PREFETCH_CONCURRENCY '%s'", concurrencyStr)
+		}
+		appConfig.PrefetchConcurrency = concurrency
+	}
+
+	appConfig.UpstreamTransport = TransportConfig{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	for key, target := range map[string]*int{
+		"UPSTREAM_MAX_IDLE_CONNS":          &appConfig.UpstreamTransport.MaxIdleConns,
+		"UPSTREAM_MAX_IDLE_CONNS_PER_HOST": &appConfig.UpstreamTransport.MaxIdleConnsPerHost,
+		"UPSTREAM_MAX_CONNS_PER_HOST":      &appConfig.UpstreamTransport.MaxConnsPerHost,
+	} {
+		if valueStr := getenv(key); valueStr != "" {
+			value, err := strconv.Atoi(valueStr)
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("invalid %s '%s'", key, valueStr)
+			}
+			*target = value
+		}
+	}
+	if idleStr := getenv("UPSTREAM_IDLE_CONN_TIMEOUT_SECONDS"); idleStr != "" {
+		idle, err := parseDuration(idleStr, time.Second)
+		if err != nil || idle < 0 {
+			return nil, fmt.Errorf("invalid UPSTREAM_IDLE_CONN_TIMEOUT_SECONDS '%s'", idleStr)
+		}
+		appConfig.UpstreamTransport.IdleConnTimeout = idle
+	}
+	disableKeepAlives, err := parseBoolEnv(getenv, "UPSTREAM_DISABLE_KEEP_ALIVES")
+	if err != nil {
+		return nil, err
+	}
+	appConfig.UpstreamTransport.DisableKeepAlives = disableKeepAlives
+
+	for key, target := range map[string]*int{
+		"REDIS_POOL_SIZE":      &appConfig.Redis.PoolSize,
+		"REDIS_MIN_IDLE_CONNS": &appConfig.Redis.MinIdleConns,
+		"REDIS_MAX_RETRIES":    &appConfig.Redis.MaxRetries,
+	} {
+		if valueStr := getenv(key); valueStr != "" {
+			value, err := strconv.Atoi(valueStr)
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("invalid %s '%s'", key, valueStr)
+			}
+			*target = value
+		}
+	}
+	if appConfig.Redis.MaxRetries == 0 && getenv("REDIS_MAX_RETRIES") != "" {
+		appConfig.Redis.MaxRetries = -1
+	}
+	for key, target := range map[string]*time.Duration{
+		"REDIS_DIAL_TIMEOUT_SECONDS":  &appConfig.Redis.DialTimeout,
+		"REDIS_READ_TIMEOUT_SECONDS":  &appConfig.Redis.ReadTimeout,
+		"REDIS_WRITE_TIMEOUT_SECONDS": &appConfig.Redis.WriteTimeout,
+	} {
+		if valueStr := getenv(key); valueStr != "" {
+			value, err := parseDuration(valueStr, time.Second)
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("invalid %s '%s'", key, valueStr)
+			}
+			*target = value
+		}
+	}
+	for key, target := range map[string]*time.Duration{
+		"REDIS_MIN_RETRY_BACKOFF_MS": &appConfig.Redis.MinRetryBackoff,
+		"REDIS_MAX_RETRY_BACKOFF_MS": &appConfig.Redis.MaxRetryBackoff,
+	} {
+		if valueStr := getenv(key); valueStr != "" {
+			value, err := parseDuration(valueStr, time.Millisecond)
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("invalid %s '%s'", key, valueStr)
+			}
+			*target = value
+		}
+	}
+	if appConfig.Redis.MaxRetryBackoff > 0 && appConfig.Redis.MinRetryBackoff > appConfig.Redis.MaxRetryBackoff {
+		return nil, fmt.Errorf("REDIS_MIN_RETRY_BACKOFF_MS must not exceed REDIS_MAX_RETRY_BACKOFF_MS")
+	}
+
+	if maxPoolsStr := getenv("DB_MAX_POOLS"); maxPoolsStr != "" {
+		maxPools, err := strconv.Atoi(maxPoolsStr)
+		if err != nil || maxPools < 0 {
+			return nil, fmt.Errorf("invalid DB_MAX_POOLS '%s'", maxPoolsStr)
+		}
+		appConfig.DBMaxPools = maxPools
+	}
+	appConfig.DBIdleTimeout = 5 * time.Minute
+	if idleStr := getenv("DB_IDLE_TIMEOUT_SECONDS"); idleStr != "" {
+		idle, err := parseDuration(idleStr, time.Second)
+		if err != nil || idle < 0 {
+			return nil, fmt.Errorf("invalid DB_IDLE_TIMEOUT_SECONDS '%s'", idleStr)
+		}
+		appConfig.DBIdleTimeout = idle
+	}
+
+	if entriesStr := getenv("CACHE_L1_MAX_ENTRIES"); entriesStr != "" {
+		entries, err := strconv.Atoi(entriesStr)
+		if err != nil || entries < 0 {
+			return nil, fmt.Errorf("invalid CACHE_L1_MAX_ENTRIES '%s'", entriesStr)
+		}
+		appConfig.CacheL1MaxEntries = entries
+	}
+	appConfig.CacheL1MaxBytes = 64 << 20
+	if bytesStr := getenv("CACHE_L1_MAX_BYTES"); bytesStr != "" {
+		bytes, err := strconv.ParseInt(bytesStr, 10, 64)
+		if err != nil || bytes < 0 {
+			return nil, fmt.Errorf("invalid CACHE_L1_MAX_BYTES '%s'", bytesStr)
+		}
+		appConfig.CacheL1MaxBytes = bytes
+	}
+	appConfig.CacheL1TTL = 5 * time.Second
+	if l1TTLStr := getenv("CACHE_L1_TTL_SECONDS"); l1TTLStr != "" {
+		l1TTL, err := parseDuration(l1TTLStr, time.Second)
+		if err != nil || l1TTL <= 0 {
+			return nil, fmt.Errorf("invalid CACHE_L1_TTL_SECONDS '%s'", l1TTLStr)
+		}
+		appConfig.CacheL1TTL = l1TTL
+	}
+
+	appConfig.CacheCompression = getenv("CACHE_COMPRESSION")
+	switch appConfig.CacheCompression {
+	case "":
+		appConfig.CacheCompression = "off"
+	case "off", "gzip", "zstd":
+	default:
+		return nil, fmt.Errorf("unknown CACHE_COMPRESSION '%s'", appConfig.CacheCompression)
+	}
+	appConfig.CacheCompressionMinBytes = 1024
+	if minBytesStr := getenv("CACHE_COMPRESSION_MIN_BYTES"); minBytesStr != "" {
+		minBytes, err := strconv.Atoi(minBytesStr)
+		if err != nil || minBytes < 0 {
+			return nil, fmt.Errorf("invalid CACHE_COMPRESSION_MIN_BYTES '%s'", minBytesStr)
+		}
+		appConfig.CacheCompressionMinBytes = minBytes
+	}
+
+	appConfig.CacheAsyncWrites, err = parseBoolEnv(getenv, "CACHE_ASYNC_WRITES")
+	if err != nil {
+		return nil, err
+	}
+	appConfig.CacheWriteWorkers = 4
+	if workersStr := getenv("CACHE_WRITE_WORKERS"); workersStr != "" {
+		workers, err := strconv.Atoi(workersStr)
+		if err != nil || workers < 1 {
+			return nil, fmt.Errorf("invalid CACHE_WRITE_WORKERS '%s'", workersStr)
+		}
+		appConfig.CacheWriteWorkers = workers
+	}
+	appConfig.CacheWriteQueueSize = 1000
+	if queueStr := getenv("CACHE_WRITE_QUEUE_SIZE"); queueStr != "" {
+		queue, err := strconv.Atoi(queueStr)
+		if err != nil || queue < 1 {
+			return nil, fmt.Errorf("invalid CACHE_WRITE_QUEUE_SIZE '%s'", queueStr)
+		}
+		appConfig.CacheWriteQueueSize = queue
+	}
+
+	appConfig.WarmConcurrency = 4
+	if concurrencyStr := getenv("WARM_CONCURRENCY"); concurrencyStr != "" {
+		concurrency, err := strconv.Atoi(concurrencyStr)
+		if err != nil || concurrency < 1 {
+			return nil, fmt.Errorf("invalid WARM_CONCURRENCY '%s'", concurrencyStr)
+		}
+		appConfig.WarmConcurrency = concurrency
+	}
+	appConfig.WarmTimeout = time.Minute
+	if timeoutStr := getenv("WARM_TIMEOUT_SECONDS"); timeoutStr != "" {
+		timeout, err := parseDuration(timeoutStr, time.Second)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid WARM_TIMEOUT_SECONDS '%s'", timeoutStr)
+		}
+		appConfig.WarmTimeout = timeout
+	}
+	appConfig.WarmMaxIDs = 1000
+	if maxStr := getenv("WARM_MAX_IDS"); maxStr != "" {
+		maxIDs, err := strconv.Atoi(maxStr)
+		if err != nil || maxIDs < 0 {
+			return nil, fmt.Errorf("invalid WARM_MAX_IDS '%s'", maxStr)
+		}
+		appConfig.WarmMaxIDs = maxIDs
+	}
+
+	shaping, err := parseBoolEnv(getenv, "SYNTHETIC_SHAPING")
+	if err != nil {
+		return nil, err
+	}
+	appConfig.SyntheticShaping = shaping
+
+	appConfig.ConfigWatch, err = parseBoolEnv(getenv, "CONFIG_WATCH")
+	if err != nil {
+		return nil, err
+	}
+
+	appConfig.StrictStartup, err = parseBoolEnv(getenv, "STRICT_STARTUP")
+	if err != nil {
+		return nil, err
+	}
+
+	appConfig.RateLimit, appConfig.RateLimitBurst, err = parseRateLimit(getenv, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if maxStr := getenv("MAX_IN_FLIGHT"); maxStr != "" {
+		max, err := strconv.Atoi(maxStr)
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("invalid MAX_IN_FLIGHT '%s'", maxStr)
 		}
+		appConfig.MaxInFlight = max
+	}
 
-		sourceType := os.Getenv(fmt.Sprintf("PROJECT_%d_SOURCE_TYPE", i))
+	appConfig.MaxURLLength = 8 << 10
+	if lengthStr := getenv("MAX_URL_LENGTH"); lengthStr != "" {
+		length, err := strconv.Atoi(lengthStr)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid MAX_URL_LENGTH '%s'", lengthStr)
+		}
+		appConfig.MaxURLLength = length
+	}
+	appConfig.MaxHeaderBytes = 64 << 10
+	if bytesStr := getenv("MAX_HEADER_BYTES"); bytesStr != "" {
+		bytes, err := strconv.Atoi(bytesStr)
+		if err != nil || bytes < 0 {
+			return nil, fmt.Errorf("invalid MAX_HEADER_BYTES '%s'", bytesStr)
+		}
+		appConfig.MaxHeaderBytes = bytes
+	}
+	appConfig.MaxBodyBytes = 1 << 20
+	if bytesStr := getenv("MAX_BODY_BYTES"); bytesStr != "" {
+		bytes, err := strconv.ParseInt(bytesStr, 10, 64)
+		if err != nil || bytes < 0 {
+			return nil, fmt.Errorf("invalid MAX_BODY_BYTES '%s'", bytesStr)
+		}
+		appConfig.MaxBodyBytes = bytes
+	}
+
+	if proxies := getenv("TRUSTED_PROXIES"); proxies != "" {
+		appConfig.TrustedProxies = []string{}
+		if proxies != "none" {
+			for _, proxy := range splitList(proxies) {
+				if net.ParseIP(proxy) == nil {
+					if _, _, err := net.ParseCIDR(proxy); err != nil {
+						return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry '%s'", proxy)
+					}
+				}
+				appConfig.TrustedProxies = append(appConfig.TrustedProxies, proxy)
+			}
+		}
+	}
+	if headers := getenv("CLIENT_IP_HEADERS"); headers != "" {
+		for _, name := range splitList(headers) {
+			if strings.ContainsAny(name, " \t:") {
+				return nil, fmt.Errorf("invalid CLIENT_IP_HEADERS entry '%s'", name)
+			}
+			appConfig.ClientIPHeaders = append(appConfig.ClientIPHeaders, name)
+		}
+	}
+
+	appConfig.ConfigPollInterval = 30 * time.Second
+	if pollStr := getenv("CONFIG_POLL_SECONDS"); pollStr != "" {
+		poll, err := parseDuration(pollStr, time.Second)
+		if err != nil || poll < time.Second {
+			return nil, fmt.Errorf("invalid CONFIG_POLL_SECONDS '%s'", pollStr)
+		}
+		appConfig.ConfigPollInterval = poll
+	}
+
+	// Scan for projects by looking for PROJECT_{n}_ROUTE and
+	// PROJECT_{NAME}_ROUTE variables
+	for _, id := range projectIDs(getenv, keys) {
+		getenv := withProjectDefaults(getenv, id)
+		// ROUTE may list several patterns served by the same project; the
+		// first one is its main route.
+		routes := splitList(getenv(fmt.Sprintf("PROJECT_%s_ROUTE", id)))
+		if len(routes) == 0 {
+			return nil, fmt.Errorf("missing ROUTE for project %s", id)
+		}
+		route := routes[0]
+
+		sourceType := getenv(fmt.Sprintf("PROJECT_%s_SOURCE_TYPE", id))
 
 		// Extract placeholder from route only if present. For API source types the
 		// route is allowed to not contain a placeholder (it's a direct endpoint).
 		var idPlaceholder string
+		var placeholders []string
 		if strings.Contains(route, "{") {
 			var err error
 			idPlaceholder, err = extractIDPlaceholder(route)
+			if err == nil {
+				placeholders, err = routePlaceholders(route)
+			}
 			if err != nil {
-				return nil, fmt.Errorf("invalid route for project %d: %w", i, err)
+				return nil, fmt.Errorf("invalid route for project %s: %w", id, err)
 			}
+			idPlaceholder = placeholders[len(placeholders)-1]
 		} else {
 			if sourceType == "" {
 				sourceType = "database"
 			}
 			if sourceType != "api" {
-				return nil, fmt.Errorf("invalid route for project %d: no '{' found in route", i)
+				return nil, fmt.Errorf("invalid route for project %s: no '{' found in route", id)
 			}
 
 			// API endpoint to be used directly.
 			idPlaceholder = ""
 		}
+		for _, alias := range routes[1:] {
+			aliasPlaceholders, err := routePlaceholders(alias)
+			if err != nil {
+				return nil, fmt.Errorf("invalid route '%s' for project %s: %w", alias, id, err)
+			}
+			if strings.Join(aliasPlaceholders, ",") != strings.Join(placeholders, ",") {
+				return nil, fmt.Errorf("route '%s' of project %s must have the same ID placeholder as '%s'", alias, id, route)
+			}
+		}
 
-		ttlStr := os.Getenv(fmt.Sprintf("PROJECT_%d_CACHE_TTL_SECONDS", i))
-		ttl, err := strconv.Atoi(ttlStr)
-		if err != nil {
-			ttl = 3600 // Default to 1 hour
+		// CACHE_TTL reads better with units ("90m", "1d"), CACHE_TTL_SECONDS
+		// is the original name; both accept either form.
+		ttl := time.Hour
+		ttlKey := "CACHE_TTL"
+		ttlStr := getenv(fmt.Sprintf("PROJECT_%s_CACHE_TTL", id))
+		if ttlStr == "" {
+			ttlKey = "CACHE_TTL_SECONDS"
+			ttlStr = getenv(fmt.Sprintf("PROJECT_%s_CACHE_TTL_SECONDS", id))
+		}
+		if ttlStr != "" {
+			ttl, err = parseDuration(ttlStr, time.Second)
+			if err != nil || ttl < 0 {
+				return nil, fmt.Errorf("invalid %s '%s' for project %s", ttlKey, ttlStr, id)
+			}
 		}
 
 		project := Project{
-			Name:          fmt.Sprintf("project_%d", i),
+			Name:          projectName(id),
 			Route:         route,
-			IdColumn:      os.Getenv(fmt.Sprintf("PROJECT_%d_ID_COLUMN", i)),
-			ContentType:   os.Getenv(fmt.Sprintf("PROJECT_%d_CONTENT_TYPE", i)),
-			CacheTTL:      time.Duration(ttl) * time.Second,
+			Routes:        routes,
+			IdColumn:      getenv(fmt.Sprintf("PROJECT_%s_ID_COLUMN", id)),
+			ContentType:   getenv(fmt.Sprintf("PROJECT_%s_CONTENT_TYPE", id)),
+			CacheTTL:      ttl,
 			IdPlaceholder: idPlaceholder,
 			SourceType:    sourceType,
+			Transform:     getenv(fmt.Sprintf("PROJECT_%s_TRANSFORM", id)),
+			SourceCharset: getenv(fmt.Sprintf("PROJECT_%s_SOURCE_CHARSET", id)),
 		}
 
 		if project.SourceType == "" {
 			project.SourceType = "database" // Default source type
 		}
 
+		project.CacheControl = getenv(fmt.Sprintf("PROJECT_%s_CACHE_CONTROL", id))
+		switch project.CacheControl {
+		case "":
+			project.CacheControl = "public"
+		case "public", "private", "off":
+		default:
+			return nil, fmt.Errorf("unknown CACHE_CONTROL '%s' for project %s", project.CacheControl, id)
+		}
+		if maxAgeStr := getenv(fmt.Sprintf("PROJECT_%s_CACHE_S_MAXAGE", id)); maxAgeStr != "" {
+			maxAge, err := parseDuration(maxAgeStr, time.Second)
+			if err != nil || maxAge < 0 {
+				return nil, fmt.Errorf("invalid CACHE_S_MAXAGE '%s' for project %s", maxAgeStr, id)
+			}
+			project.CacheSMaxAge = maxAge
+		}
+		if staleStr := getenv(fmt.Sprintf("PROJECT_%s_CACHE_STALE_WHILE_REVALIDATE", id)); staleStr != "" {
+			stale, err := parseDuration(staleStr, time.Second)
+			if err != nil || stale < 0 {
+				return nil, fmt.Errorf("invalid CACHE_STALE_WHILE_REVALIDATE '%s' for project %s", staleStr, id)
+			}
+			project.CacheStaleWhileRevalidate = stale
+		}
+		project.CacheImmutable, err = parseBoolEnv(getenv, fmt.Sprintf("PROJECT_%s_CACHE_IMMUTABLE", id))
+		if err != nil {
+			return nil, fmt.Errorf("%w for project %s", err, id)
+		}
+		if project.CacheSMaxAge > 0 && project.CacheControl != "public" {
+			return nil, fmt.Errorf("CACHE_S_MAXAGE requires a public CACHE_CONTROL for project %s", id)
+		}
+		if project.CacheControl == "off" && (project.CacheStaleWhileRevalidate > 0 || project.CacheImmutable) {
+			return nil, fmt.Errorf("CACHE_STALE_WHILE_REVALIDATE and CACHE_IMMUTABLE require a CACHE_CONTROL other than off for project %s", id)
+		}
+
+		project.IDCodec = getenv(fmt.Sprintf("PROJECT_%s_ID_CODEC", id))
+		project.IDCodecSalt = getenv(fmt.Sprintf("PROJECT_%s_ID_CODEC_SALT", id))
+		if lengthStr := getenv(fmt.Sprintf("PROJECT_%s_ID_CODEC_MIN_LENGTH", id)); lengthStr != "" {
+			length, err := strconv.Atoi(lengthStr)
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid ID_CODEC_MIN_LENGTH '%s' for project %s", lengthStr, id)
+			}
+			project.IDCodecMinLength = length
+		}
+		if project.IDCodec != "" && project.IdPlaceholder == "" {
+			return nil, fmt.Errorf("ID_CODEC requires an ID placeholder in the route for project %s", id)
+		}
+
+		project.IDTransform = getenv(fmt.Sprintf("PROJECT_%s_ID_TRANSFORM", id))
+		if project.IDTransform != "" && project.IdPlaceholder == "" {
+			return nil, fmt.Errorf("ID_TRANSFORM requires an ID placeholder in the route for project %s", id)
+		}
+		project.IDPattern = getenv(fmt.Sprintf("PROJECT_%s_ID_PATTERN", id))
+		if project.IDPattern != "" {
+			if _, err := regexp.Compile(project.IDPattern); err != nil {
+				return nil, fmt.Errorf("invalid ID_PATTERN for project %s: %w", id, err)
+			}
+		}
+		if lengthStr := getenv(fmt.Sprintf("PROJECT_%s_ID_MAX_LENGTH", id)); lengthStr != "" {
+			length, err := strconv.Atoi(lengthStr)
+			if err != nil || length < 1 {
+				return nil, fmt.Errorf("invalid ID_MAX_LENGTH '%s' for project %s", lengthStr, id)
+			}
+			project.IDMaxLength = length
+		}
+		if (project.IDPattern != "" || project.IDMaxLength > 0) && project.IdPlaceholder == "" {
+			return nil, fmt.Errorf("ID_PATTERN and ID_MAX_LENGTH require an ID placeholder in the route for project %s", id)
+		}
+
+		project.ValueEncoding = getenv(fmt.Sprintf("PROJECT_%s_VALUE_ENCODING", id))
+		switch project.ValueEncoding {
+		case "":
+			project.ValueEncoding = "raw"
+			project.ValueEncodingDefaulted = true
+		case "raw", "base64", "data-uri", "url", "auto":
+		default:
+			return nil, fmt.Errorf("unknown VALUE_ENCODING '%s' for project %s", project.ValueEncoding, id)
+		}
+		project.ServeColumnBinary, err = parseBoolEnv(getenv, fmt.Sprintf("PROJECT_%s_SERVE_COLUMN_BINARY", id))
+		if err != nil {
+			return nil, fmt.Errorf("%w for project %s", err, id)
+		}
+		if project.ServeColumnBinary {
+			if project.SourceType != "database" {
+				return nil, fmt.Errorf("SERVE_COLUMN_BINARY requires a database source for project %s", id)
+			}
+			if project.ValueEncoding != "raw" {
+				return nil, fmt.Errorf("SERVE_COLUMN_BINARY cannot be combined with VALUE_ENCODING '%s' for project %s", project.ValueEncoding, id)
+			}
+			if project.SourceCharset != "" {
+				return nil, fmt.Errorf("SERVE_COLUMN_BINARY cannot be combined with SOURCE_CHARSET for project %s", id)
+			}
+		}
+		project.QueryTransform = getenv(fmt.Sprintf("PROJECT_%s_QUERY_TRANSFORM", id))
+		if project.QueryTransform != "" && project.SourceType != "database" {
+			return nil, fmt.Errorf("QUERY_TRANSFORM requires a database source for project %s", id)
+		}
+
+		project.ContentTypeSniff = getenv(fmt.Sprintf("PROJECT_%s_CONTENT_TYPE_SNIFF", id))
+		switch project.ContentTypeSniff {
+		case "":
+			project.ContentTypeSniff = "off"
+		case "off", "fallback", "override":
+		default:
+			return nil, fmt.Errorf("unknown CONTENT_TYPE_SNIFF '%s' for project %s", project.ContentTypeSniff, id)
+		}
+
+		project.ContentTypePolicy = getenv(fmt.Sprintf("PROJECT_%s_CONTENT_TYPE_POLICY", id))
+		switch project.ContentTypePolicy {
+		case "":
+			project.ContentTypePolicy = "off"
+		case "off", "reject", "flag":
+		default:
+			return nil, fmt.Errorf("unknown CONTENT_TYPE_POLICY '%s' for project %s", project.ContentTypePolicy, id)
+		}
+
+		project.ImageResize, err = parseBoolEnv(getenv, fmt.Sprintf("PROJECT_%s_IMAGE_RESIZE", id))
+		if err != nil {
+			return nil, fmt.Errorf("%w for project %s", err, id)
+		}
+		project.RangePassthrough, err = parseBoolEnv(getenv, fmt.Sprintf("PROJECT_%s_RANGE_PASSTHROUGH", id))
+		if err != nil {
+			return nil, fmt.Errorf("%w for project %s", err, id)
+		}
+		if project.RangePassthrough && (project.Transform != "" || project.SourceCharset != "") {
+			return nil, fmt.Errorf("RANGE_PASSTHROUGH cannot be combined with TRANSFORM or SOURCE_CHARSET for project %s", id)
+		}
+
+		project.ImageMaxDimension = 2048
+		if dimStr := getenv(fmt.Sprintf("PROJECT_%s_IMAGE_MAX_DIMENSION", id)); dimStr != "" {
+			dim, err := strconv.Atoi(dimStr)
+			if err != nil || dim <= 0 {
+				return nil, fmt.Errorf("invalid IMAGE_MAX_DIMENSION '%s' for project %s", dimStr, id)
+			}
+			project.ImageMaxDimension = dim
+		}
+		project.ImageMaxPixels = 40_000_000
+		if pixelsStr := getenv(fmt.Sprintf("PROJECT_%s_IMAGE_MAX_PIXELS", id)); pixelsStr != "" {
+			pixels, err := strconv.Atoi(pixelsStr)
+			if err != nil || pixels <= 0 {
+				return nil, fmt.Errorf("invalid IMAGE_MAX_PIXELS '%s' for project %s", pixelsStr, id)
+			}
+			project.ImageMaxPixels = pixels
+		}
+
+		project.Hooks = hooks.Spec{
+			Reject:   getenv(fmt.Sprintf("PROJECT_%s_HOOK_REJECT", id)),
+			CacheTTL: getenv(fmt.Sprintf("PROJECT_%s_HOOK_CACHE_TTL", id)),
+			Headers:  getenv(fmt.Sprintf("PROJECT_%s_HOOK_HEADERS", id)),
+			Source:   getenv(fmt.Sprintf("PROJECT_%s_HOOK_SOURCE", id)),
+		}
+
+		if intervalStr := getenv(fmt.Sprintf("PROJECT_%s_REVALIDATE_INTERVAL_SECONDS", id)); intervalStr != "" {
+			interval, err := parseDuration(intervalStr, time.Second)
+			if err != nil || interval < 0 {
+				return nil, fmt.Errorf("invalid REVALIDATE_INTERVAL_SECONDS '%s' for project %s", intervalStr, id)
+			}
+			project.RevalidateInterval = interval
+		}
+
+		if windowStr := getenv(fmt.Sprintf("PROJECT_%s_CONDITIONAL_REVALIDATION_SECONDS", id)); windowStr != "" {
+			window, err := parseDuration(windowStr, time.Second)
+			if err != nil || window < 0 {
+				return nil, fmt.Errorf("invalid CONDITIONAL_REVALIDATION_SECONDS '%s' for project %s", windowStr, id)
+			}
+			project.ConditionalRevalidation = window
+		}
+
+		if graceStr := getenv(fmt.Sprintf("PROJECT_%s_STALE_IF_ERROR_SECONDS", id)); graceStr != "" {
+			grace, err := parseDuration(graceStr, time.Second)
+			if err != nil || grace < 0 {
+				return nil, fmt.Errorf("invalid STALE_IF_ERROR_SECONDS '%s' for project %s", graceStr, id)
+			}
+			project.StaleIfError = grace
+		}
+
+		if headersStr := getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_HEADERS", id)); headersStr != "" {
+			project.UpstreamHeaders, err = parseHeaderList(headersStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UPSTREAM_HEADERS for project %s: %w", id, err)
+			}
+		}
+
+		if mapStr := getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_STATUS_MAP", id)); mapStr != "" {
+			project.UpstreamStatusMap, err = parseStatusMap(mapStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UPSTREAM_STATUS_MAP for project %s: %w", id, err)
+			}
+		}
+
+		project.UpstreamTimeout = 30 * time.Second
+		if timeoutStr := getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_TIMEOUT", id)); timeoutStr != "" {
+			timeout, err := parseDuration(timeoutStr, time.Second)
+			if err != nil || timeout < 0 {
+				return nil, fmt.Errorf("invalid UPSTREAM_TIMEOUT '%s' for project %s", timeoutStr, id)
+			}
+			project.UpstreamTimeout = timeout
+		}
+
+		project.UpstreamTLSCertFile = getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_TLS_CERT_FILE", id))
+		project.UpstreamTLSKeyFile = getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_TLS_KEY_FILE", id))
+		project.UpstreamCAFile = getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_CA_FILE", id))
+		if (project.UpstreamTLSCertFile == "") != (project.UpstreamTLSKeyFile == "") {
+			return nil, fmt.Errorf("UPSTREAM_TLS_CERT_FILE and UPSTREAM_TLS_KEY_FILE must be set together for project %s", id)
+		}
+
+		project.UpstreamMaxRedirects = 10
+		if redirectsStr := getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_MAX_REDIRECTS", id)); redirectsStr != "" {
+			redirects, err := strconv.Atoi(redirectsStr)
+			if err != nil || redirects < 0 {
+				return nil, fmt.Errorf("invalid UPSTREAM_MAX_REDIRECTS '%s' for project %s", redirectsStr, id)
+			}
+			project.UpstreamMaxRedirects = redirects
+		}
+
+		project.UpstreamRedirectAuth = getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_REDIRECT_AUTH", id))
+		switch project.UpstreamRedirectAuth {
+		case "":
+			project.UpstreamRedirectAuth = "strip"
+		case "strip", "keep":
+		default:
+			return nil, fmt.Errorf("unknown UPSTREAM_REDIRECT_AUTH '%s' for project %s", project.UpstreamRedirectAuth, id)
+		}
+
+		project.UpstreamProxy = getenv(fmt.Sprintf("PROJECT_%s_UPSTREAM_PROXY", id))
+		if project.UpstreamProxy != "" && project.UpstreamProxy != "none" {
+			proxyURL, err := url.Parse(project.UpstreamProxy)
+			if err != nil || proxyURL.Host == "" {
+				return nil, fmt.Errorf("invalid UPSTREAM_PROXY '%s' for project %s", project.UpstreamProxy, id)
+			}
+			switch proxyURL.Scheme {
+			case "http", "https", "socks5", "socks5h":
+			default:
+				return nil, fmt.Errorf("unsupported UPSTREAM_PROXY scheme '%s' for project %s", proxyURL.Scheme, id)
+			}
+		}
+
+		project.URLAllowedHosts = splitList(getenv(fmt.Sprintf("PROJECT_%s_URL_ALLOWED_HOSTS", id)))
+		project.URLDeniedHosts = splitList(getenv(fmt.Sprintf("PROJECT_%s_URL_DENIED_HOSTS", id)))
+		project.URLBlockPrivate, err = parseBoolEnv(getenv, fmt.Sprintf("PROJECT_%s_URL_BLOCK_PRIVATE", id))
+		if err != nil {
+			return nil, fmt.Errorf("%w for project %s", err, id)
+		}
+
+		if warmupStr := getenv(fmt.Sprintf("PROJECT_%s_WARMUP_SECONDS", id)); warmupStr != "" {
+			warmup, err := parseDuration(warmupStr, time.Second)
+			if err != nil || warmup < 0 {
+				return nil, fmt.Errorf("invalid WARMUP_SECONDS '%s' for project %s", warmupStr, id)
+			}
+			project.WarmupPeriod = warmup
+		}
+
+		if timeoutStr := getenv(fmt.Sprintf("PROJECT_%s_REQUEST_TIMEOUT_SECONDS", id)); timeoutStr != "" {
+			timeout, err := parseDuration(timeoutStr, time.Second)
+			if err != nil || timeout < 0 {
+				return nil, fmt.Errorf("invalid REQUEST_TIMEOUT_SECONDS '%s' for project %s", timeoutStr, id)
+			}
+			project.RequestTimeout = timeout
+		}
+
+		if delayStr := getenv(fmt.Sprintf("PROJECT_%s_SYNTHETIC_DELAY_MS", id)); delayStr != "" {
+			delay, err := parseDuration(delayStr, time.Millisecond)
+			if err != nil || delay < 0 {
+				return nil, fmt.Errorf("invalid SYNTHETIC_DELAY_MS '%s' for project %s", delayStr, id)
+			}
+			project.SyntheticDelay = delay
+		}
+		if bandwidthStr := getenv(fmt.Sprintf("PROJECT_%s_SYNTHETIC_BANDWIDTH", id)); bandwidthStr != "" {
+			bandwidth, err := strconv.Atoi(bandwidthStr)
+			if err != nil || bandwidth < 0 {
+				return nil, fmt.Errorf("invalid SYNTHETIC_BANDWIDTH '%s' for project %s", bandwidthStr, id)
+			}
+			project.SyntheticBandwidth = bandwidth
+		}
+
+		project.RateLimit, project.RateLimitBurst, err = parseRateLimit(getenv, fmt.Sprintf("PROJECT_%s_", id))
+		if err != nil {
+			return nil, fmt.Errorf("%w for project %s", err, id)
+		}
+		if maxStr := getenv(fmt.Sprintf("PROJECT_%s_MAX_IN_FLIGHT", id)); maxStr != "" {
+			max, err := strconv.Atoi(maxStr)
+			if err != nil || max < 0 {
+				return nil, fmt.Errorf("invalid MAX_IN_FLIGHT '%s' for project %s", maxStr, id)
+			}
+			project.MaxInFlight = max
+		}
+
+		if slowStr := getenv(fmt.Sprintf("PROJECT_%s_SLOW_REQUEST_MS", id)); slowStr != "" {
+			slow, err := parseDuration(slowStr, time.Millisecond)
+			if err != nil || slow < 0 {
+				return nil, fmt.Errorf("invalid SLOW_REQUEST_MS '%s' for project %s", slowStr, id)
+			}
+			project.SlowRequestThreshold = slow
+		}
+		if largeStr := getenv(fmt.Sprintf("PROJECT_%s_LARGE_RESPONSE_BYTES", id)); largeStr != "" {
+			large, err := strconv.Atoi(largeStr)
+			if err != nil || large < 0 {
+				return nil, fmt.Errorf("invalid LARGE_RESPONSE_BYTES '%s' for project %s", largeStr, id)
+			}
+			project.LargeResponseThreshold = large
+		}
+		if slowStr := getenv(fmt.Sprintf("PROJECT_%s_SLOW_QUERY_MS", id)); slowStr != "" {
+			slow, err := parseDuration(slowStr, time.Millisecond)
+			if err != nil || slow < 0 {
+				return nil, fmt.Errorf("invalid SLOW_QUERY_MS '%s' for project %s", slowStr, id)
+			}
+			project.SlowQueryThreshold = slow
+		}
+
+		project.WarmIDs = splitList(getenv(fmt.Sprintf("PROJECT_%s_WARM_IDS", id)))
+		project.WarmQuery = getenv(fmt.Sprintf("PROJECT_%s_WARM_QUERY", id))
+		if project.WarmQuery != "" && project.SourceType != "database" {
+			return nil, fmt.Errorf("WARM_QUERY requires a database source for project %s", id)
+		}
+
+		project.QueryParams = splitList(getenv(fmt.Sprintf("PROJECT_%s_QUERY_PARAMS", id)))
+		project.ForwardHeaders = splitList(getenv(fmt.Sprintf("PROJECT_%s_FORWARD_HEADERS", id)))
+
+		project.CacheKeyTemplate = getenv(fmt.Sprintf("PROJECT_%s_CACHE_KEY", id))
+		if project.CacheKeyTemplate != "" {
+			if err := validateCacheKeyTemplate(project); err != nil {
+				return nil, fmt.Errorf("invalid CACHE_KEY for project %s: %w", id, err)
+			}
+		}
+		project.SurrogateKeys = []string{"project:{project}", "id:{id}"}
+		if keys := getenv(fmt.Sprintf("PROJECT_%s_SURROGATE_KEYS", id)); keys != "" {
+			project.SurrogateKeys = splitList(keys)
+		}
+		for _, key := range project.SurrogateKeys {
+			if _, err := validateKeyTemplate(project, key); err != nil {
+				return nil, fmt.Errorf("invalid SURROGATE_KEYS entry '%s' for project %s: %w", key, id, err)
+			}
+		}
+
+		project.CacheBackend = getenv(fmt.Sprintf("PROJECT_%s_CACHE_BACKEND", id))
+		switch project.CacheBackend {
+		case "":
+			project.CacheBackend = "shared"
+		case "shared", "redis", "memory", "none":
+		default:
+			return nil, fmt.Errorf("unknown CACHE_BACKEND '%s' for project %s", project.CacheBackend, id)
+		}
+		if project.CacheBackend == "redis" {
+			project.CacheRedisURL = getenv(fmt.Sprintf("PROJECT_%s_CACHE_REDIS_URL", id))
+			if project.CacheRedisURL == "" {
+				project.CacheRedisURL = appConfig.RedisURL
+			}
+			if project.CacheRedisURL == "" {
+				return nil, fmt.Errorf("CACHE_BACKEND 'redis' requires CACHE_REDIS_URL or REDIS_URL for project %s", id)
+			}
+			redisURL, err := url.Parse(project.CacheRedisURL)
+			if err != nil || redisURL.Host == "" {
+				return nil, fmt.Errorf("invalid CACHE_REDIS_URL for project %s", id)
+			}
+			if dbStr := getenv(fmt.Sprintf("PROJECT_%s_CACHE_REDIS_DB", id)); dbStr != "" {
+				db, err := strconv.Atoi(dbStr)
+				if err != nil || db < 0 {
+					return nil, fmt.Errorf("invalid CACHE_REDIS_DB '%s' for project %s", dbStr, id)
+				}
+				redisURL.Path = "/" + strconv.Itoa(db)
+				project.CacheRedisURL = redisURL.String()
+			}
+		}
+		project.CacheMemoryMaxEntries = 1000
+		if entriesStr := getenv(fmt.Sprintf("PROJECT_%s_CACHE_MEMORY_MAX_ENTRIES", id)); entriesStr != "" {
+			entries, err := strconv.Atoi(entriesStr)
+			if err != nil || entries < 1 {
+				return nil, fmt.Errorf("invalid CACHE_MEMORY_MAX_ENTRIES '%s' for project %s", entriesStr, id)
+			}
+			project.CacheMemoryMaxEntries = entries
+		}
+		project.CacheMemoryMaxBytes = 256 << 20
+		if bytesStr := getenv(fmt.Sprintf("PROJECT_%s_CACHE_MEMORY_MAX_BYTES", id)); bytesStr != "" {
+			bytes, err := strconv.ParseInt(bytesStr, 10, 64)
+			if err != nil || bytes < 0 {
+				return nil, fmt.Errorf("invalid CACHE_MEMORY_MAX_BYTES '%s' for project %s", bytesStr, id)
+			}
+			project.CacheMemoryMaxBytes = bytes
+		}
+		project.CacheNamespace = getenv(fmt.Sprintf("PROJECT_%s_CACHE_NAMESPACE", id))
+
+		for _, pattern := range splitList(getenv(fmt.Sprintf("PROJECT_%s_PREFETCH", id))) {
+			if !strings.Contains(pattern, "{id") {
+				return nil, fmt.Errorf("prefetch pattern '%s' must contain an {id} placeholder for project %s", pattern, id)
+			}
+			project.PrefetchPatterns = append(project.PrefetchPatterns, pattern)
+		}
+
 		// Load source-specific config and validate
 		switch project.SourceType {
 		case "database":
-			project.DB_DSN = os.Getenv(fmt.Sprintf("PROJECT_%d_DB_DSN", i))
-			project.Table = os.Getenv(fmt.Sprintf("PROJECT_%d_TABLE", i))
-			project.ServeColumn = os.Getenv(fmt.Sprintf("PROJECT_%d_SERVE_COLUMN", i))
+			project.DB_DSN = getenv(fmt.Sprintf("PROJECT_%s_DB_DSN", id))
+			project.DBDriver = getenv(fmt.Sprintf("PROJECT_%s_DB_DRIVER", id))
+			switch project.DBDriver {
+			case "", "postgres", "mysql", "cockroachdb":
+			default:
+				return nil, fmt.Errorf("unknown DB_DRIVER '%s' for project %s", project.DBDriver, id)
+			}
+			assembled, err := assembleDSN(getenv, id)
+			if err != nil {
+				return nil, err
+			}
+			if assembled != "" {
+				if project.DB_DSN != "" {
+					return nil, fmt.Errorf("DB_DSN and DB_HOST cannot both be set for project %s", id)
+				}
+				project.DB_DSN = assembled
+			}
+			project.Table = getenv(fmt.Sprintf("PROJECT_%s_TABLE", id))
+			project.ServeColumn = getenv(fmt.Sprintf("PROJECT_%s_SERVE_COLUMN", id))
 			if project.DB_DSN == "" || project.Table == "" || project.ServeColumn == "" {
-				return nil, fmt.Errorf("missing required database configuration (DB_DSN, TABLE, SERVE_COLUMN) for project %d", i)
+				return nil, fmt.Errorf("missing required database configuration (DB_DSN or DB_HOST, TABLE, SERVE_COLUMN) for project %s", id)
+			}
+			project.DBReplicaDSNs = splitList(getenv(fmt.Sprintf("PROJECT_%s_DB_REPLICA_DSNS", id)))
+			project.DBTLSCertFile = getenv(fmt.Sprintf("PROJECT_%s_DB_TLS_CERT_FILE", id))
+			project.DBTLSKeyFile = getenv(fmt.Sprintf("PROJECT_%s_DB_TLS_KEY_FILE", id))
+			project.DBCAFile = getenv(fmt.Sprintf("PROJECT_%s_DB_CA_FILE", id))
+			if (project.DBTLSCertFile == "") != (project.DBTLSKeyFile == "") {
+				return nil, fmt.Errorf("DB_TLS_CERT_FILE and DB_TLS_KEY_FILE must be set together for project %s", id)
+			}
+			project.DBTLSSkipVerify, err = parseBoolEnv(getenv, fmt.Sprintf("PROJECT_%s_DB_TLS_SKIP_VERIFY", id))
+			if err != nil {
+				return nil, fmt.Errorf("%w for project %s", err, id)
+			}
+			if project.DBTLSSkipVerify && project.DBCAFile != "" {
+				return nil, fmt.Errorf("DB_TLS_SKIP_VERIFY cannot be combined with DB_CA_FILE for project %s", id)
+			}
+			project.DBAuth = getenv(fmt.Sprintf("PROJECT_%s_DB_AUTH", id))
+			switch project.DBAuth {
+			case "":
+				project.DBAuth = "password"
+			case "password", "rds-iam", "cloudsql-iam":
+			default:
+				return nil, fmt.Errorf("invalid DB_AUTH '%s' for project %s", project.DBAuth, id)
+			}
+			project.DBConnect = getenv(fmt.Sprintf("PROJECT_%s_DB_CONNECT", id))
+			switch project.DBConnect {
+			case "":
+				project.DBConnect = "lazy"
+			case "lazy", "eager":
+			default:
+				return nil, fmt.Errorf("invalid DB_CONNECT '%s' for project %s", project.DBConnect, id)
+			}
+			project.ModifiedColumn = getenv(fmt.Sprintf("PROJECT_%s_MODIFIED_COLUMN", id))
+			project.IDMatch = getenv(fmt.Sprintf("PROJECT_%s_ID_MATCH", id))
+			switch project.IDMatch {
+			case "":
+				project.IDMatch = "exact"
+			case "exact", "case-insensitive", "prefix":
+			default:
+				return nil, fmt.Errorf("invalid ID_MATCH '%s' for project %s", project.IDMatch, id)
+			}
+			project.WhereExtra = strings.TrimSpace(getenv(fmt.Sprintf("PROJECT_%s_WHERE_EXTRA", id)))
+			if err := validateWhereExtra(project); err != nil {
+				return nil, fmt.Errorf("invalid WHERE_EXTRA for project %s: %w", id, err)
 			}
 		case "api":
-			project.APIEndpoint = os.Getenv(fmt.Sprintf("PROJECT_%d_API_ENDPOINT", i))
-			project.APIAuthType = os.Getenv(fmt.Sprintf("PROJECT_%d_API_AUTH_TYPE", i))
-			project.APIAuthSecret = os.Getenv(fmt.Sprintf("PROJECT_%d_API_AUTH_SECRET", i))
-			project.APIAuthHeaderName = os.Getenv(fmt.Sprintf("PROJECT_%d_API_AUTH_HEADER_NAME", i))
+			project.APIEndpoint = getenv(fmt.Sprintf("PROJECT_%s_API_ENDPOINT", id))
+			project.APIAuthType = getenv(fmt.Sprintf("PROJECT_%s_API_AUTH_TYPE", id))
+			project.APIAuthSecret = getenv(fmt.Sprintf("PROJECT_%s_API_AUTH_SECRET", id))
+			project.APIAuthHeaderName = getenv(fmt.Sprintf("PROJECT_%s_API_AUTH_HEADER_NAME", id))
+			project.APIAuthUsername = getenv(fmt.Sprintf("PROJECT_%s_API_AUTH_USERNAME", id))
+			project.APIAuthPassword = getenv(fmt.Sprintf("PROJECT_%s_API_AUTH_PASSWORD", id))
+			project.APIAuthAlgorithm = getenv(fmt.Sprintf("PROJECT_%s_API_AUTH_ALGORITHM", id))
+			project.APIAuthTimestampHeader = getenv(fmt.Sprintf("PROJECT_%s_API_AUTH_TIMESTAMP_HEADER", id))
 
 			if project.APIEndpoint == "" {
-				return nil, fmt.Errorf("missing required API configuration (API_ENDPOINT) for project %d", i)
+				return nil, fmt.Errorf("missing required API configuration (API_ENDPOINT) for project %s", id)
+			}
+
+			project.APIMethod = strings.ToUpper(getenv(fmt.Sprintf("PROJECT_%s_API_METHOD", id)))
+			project.APIBody = getenv(fmt.Sprintf("PROJECT_%s_API_BODY", id))
+			project.APIBodyContentType = getenv(fmt.Sprintf("PROJECT_%s_API_BODY_CONTENT_TYPE", id))
+			if project.APIMethod == "" {
+				project.APIMethod = "GET"
+			}
+			if project.APIBodyContentType == "" {
+				project.APIBodyContentType = "application/json"
+			}
+			switch project.APIMethod {
+			case "GET":
+				if project.APIBody != "" {
+					return nil, fmt.Errorf("API_BODY requires API_METHOD POST or PUT for project %s", id)
+				}
+			case "POST", "PUT":
+			default:
+				return nil, fmt.Errorf("unsupported API_METHOD '%s' for project %s", project.APIMethod, id)
 			}
 			if project.APIAuthType == "" {
 				project.APIAuthType = "none"
@@ -136,29 +1447,91 @@ func Load() (*AppConfig, error) {
 			switch project.APIAuthType {
 			case "bearer", "header":
 				if project.APIAuthSecret == "" {
-					return nil, fmt.Errorf("API_AUTH_SECRET must be set for auth type '%s' on project %d", project.APIAuthType, i)
+					return nil, fmt.Errorf("API_AUTH_SECRET must be set for auth type '%s' on project %s", project.APIAuthType, id)
 				}
 				if project.APIAuthType == "header" && project.APIAuthHeaderName == "" {
-					return nil, fmt.Errorf("API_AUTH_HEADER_NAME must be set for auth type 'header' on project %d", i)
+					return nil, fmt.Errorf("API_AUTH_HEADER_NAME must be set for auth type 'header' on project %s", id)
+				}
+			case "hmac":
+				if project.APIAuthSecret == "" {
+					return nil, fmt.Errorf("API_AUTH_SECRET must be set for auth type 'hmac' on project %s", id)
+				}
+				if project.APIAuthHeaderName == "" {
+					project.APIAuthHeaderName = "X-Signature"
+				}
+				if project.APIAuthTimestampHeader == "" {
+					project.APIAuthTimestampHeader = "X-Timestamp"
+				}
+				switch project.APIAuthAlgorithm {
+				case "":
+					project.APIAuthAlgorithm = "sha256"
+				case "sha1", "sha256", "sha512":
+				default:
+					return nil, fmt.Errorf("unknown API_AUTH_ALGORITHM '%s' for project %s", project.APIAuthAlgorithm, id)
+				}
+			case "basic":
+				if project.APIAuthUsername == "" {
+					return nil, fmt.Errorf("API_AUTH_USERNAME must be set for auth type 'basic' on project %s", id)
 				}
 			case "none":
 				// No validation needed
 			default:
-				return nil, fmt.Errorf("unknown API_AUTH_TYPE '%s' for project %d", project.APIAuthType, i)
+				return nil, fmt.Errorf("unknown API_AUTH_TYPE '%s' for project %s", project.APIAuthType, id)
+			}
+
+			// Upstream credentials are always Stratum's own, never the
+			// client's.
+			var credentials []string
+			switch project.APIAuthType {
+			case "bearer", "basic":
+				credentials = []string{"Authorization"}
+			case "header", "hmac":
+				credentials = []string{project.APIAuthHeaderName, project.APIAuthTimestampHeader}
+			}
+			for _, name := range credentials {
+				if name != "" && containsFold(project.ForwardHeaders, name, true) {
+					return nil, fmt.Errorf("FORWARD_HEADERS cannot include '%s', which API_AUTH_TYPE '%s' sets, for project %s", name, project.APIAuthType, id)
+				}
 			}
 
 		default:
-			return nil, fmt.Errorf("unknown SOURCE_TYPE '%s' for project %d", project.SourceType, i)
+			if !isCustomSourceType(project.SourceType) {
+				return nil, fmt.Errorf("unknown SOURCE_TYPE '%s' for project %s", project.SourceType, id)
+			}
+			prefix := fmt.Sprintf("PROJECT_%s_%s_", id, strings.ToUpper(project.SourceType))
+			project.SourceSettings = make(map[string]string)
+			for _, key := range keys {
+				if name, ok := strings.CutPrefix(key, prefix); ok && name != "" {
+					project.SourceSettings[name] = getenv(key)
+				}
+			}
 		}
 
 		// Basic validation
-		if project.SourceType != "api" || project.IdPlaceholder != "" {
+		if project.SourceType == "database" || project.IdPlaceholder != "" {
 			if project.IdColumn == "" {
-				return nil, fmt.Errorf("missing required configuration (ID_COLUMN) for project %d", i)
+				return nil, fmt.Errorf("missing required configuration (ID_COLUMN) for project %s", id)
 			}
-			// Validate that the placeholder from the route matches the ID column
-			if project.IdPlaceholder != project.IdColumn {
-				return nil, fmt.Errorf("route placeholder {%s} must match ID_COLUMN '%s' for project %d", project.IdPlaceholder, project.IdColumn, i)
+			if len(placeholders) > 1 {
+				// Placeholders and columns of a composite key may be listed in
+				// any order.
+				columns := splitList(project.IdColumn)
+				sort.Strings(columns)
+				sorted := append([]string(nil), placeholders...)
+				sort.Strings(sorted)
+				if strings.Join(columns, ",") != strings.Join(sorted, ",") {
+					return nil, fmt.Errorf("route placeholders {%s} must match ID_COLUMN '%s' for project %s", strings.Join(placeholders, "}, {"), project.IdColumn, id)
+				}
+				if project.SourceType != "database" {
+					return nil, fmt.Errorf("routes with several placeholders require a database source for project %s", id)
+				}
+				if project.IDCodec != "" {
+					return nil, fmt.Errorf("ID_CODEC cannot be used with several route placeholders for project %s", id)
+				}
+				project.IdColumns = placeholders
+			} else if project.IdPlaceholder != project.IdColumn {
+				// Validate that the placeholder from the route matches the ID column
+				return nil, fmt.Errorf("route placeholder {%s} must match ID_COLUMN '%s' for project %s", project.IdPlaceholder, project.IdColumn, id)
 			}
 		}
 
@@ -172,6 +1545,370 @@ func Load() (*AppConfig, error) {
 	return appConfig, nil
 }
 
+// CacheKeyPlaceholder matches the placeholders of a cache key template:
+// {project}, {route}, {id}, {query}, {headers}, {query.NAME} and
+// {header.NAME}.
+var CacheKeyPlaceholder = regexp.MustCompile(`\{([a-z]+)(?:\.([^{}]+))?\}`)
+
+// Checks that a cache key template only uses known placeholders, includes
+// the ID when the route has one, and only refers to query parameters and
+// headers that are forwarded.
+func validateCacheKeyTemplate(p Project) error {
+	hasID, err := validateKeyTemplate(p, p.CacheKeyTemplate)
+	if err != nil {
+		return err
+	}
+	if p.IdPlaceholder != "" && !hasID {
+		return fmt.Errorf("template must contain {id}")
+	}
+	return nil
+}
+
+// Checks the placeholders of a cache or surrogate key template, reporting
+// whether it contains the ID.
+func validateKeyTemplate(p Project, template string) (bool, error) {
+	hasID := false
+	for _, m := range CacheKeyPlaceholder.FindAllStringSubmatch(template, -1) {
+		name, arg := m[1], m[2]
+		switch {
+		case name == "id" && arg == "":
+			hasID = true
+		case (name == "project" || name == "route" || name == "query" || name == "headers") && arg == "":
+		case name == "query" && containsFold(p.QueryParams, arg, false):
+		case name == "header" && containsFold(p.ForwardHeaders, arg, true):
+		case name == "query" || name == "header":
+			return false, fmt.Errorf("%s '%s' is not forwarded", name, arg)
+		default:
+			return false, fmt.Errorf("unknown placeholder '%s'", m[0])
+		}
+	}
+	return hasID, nil
+}
+
+// Checks that a WHERE_EXTRA condition cannot end the generated query or
+// escape the parentheses it is wrapped in: outside quoted strings and
+// identifiers, it may not hold statement separators, comments, parameter
+// placeholders or unbalanced parentheses. MySQL also lets a backslash
+// escape the next character of a string, as in 'it\'s'.
+func validateWhereExtra(p Project) error {
+	mysql := p.DBDriver == "mysql" || (p.DBDriver == "" && strings.Contains(p.DB_DSN, "@tcp("))
+	condition := p.WhereExtra
+	var quote byte
+	depth := 0
+	for i := 0; i < len(condition); i++ {
+		c := condition[i]
+		if quote != 0 {
+			if mysql && c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		rest := condition[i:]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ';':
+			return fmt.Errorf("must be a single condition")
+		case strings.HasPrefix(rest, "--") || strings.HasPrefix(rest, "/*") || c == '#':
+			return fmt.Errorf("may not contain comments")
+		case c == '?' || c == '$':
+			return fmt.Errorf("may not contain parameters")
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("has unbalanced parentheses")
+			}
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("has an unterminated quote")
+	}
+	if depth != 0 {
+		return fmt.Errorf("has unbalanced parentheses")
+	}
+	return nil
+}
+
+// Reports whether list contains value, ignoring case if fold is set.
+func containsFold(list []string, value string, fold bool) bool {
+	for _, item := range list {
+		if item == value || (fold && strings.EqualFold(item, value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether address is a host:port (the host may be empty) or
+// "unix:" followed by a socket path.
+func isListenAddress(address string) bool {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		return path != ""
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 0 && n <= 65535
+}
+
+// Splits a comma-separated list, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Parses a list of headers, given either as a JSON object or as
+// comma-separated "Name: value" pairs.
+func parseHeaderList(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		if err := json.Unmarshal([]byte(value), &headers); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, item := range splitList(value) {
+			name, val, found := strings.Cut(item, ":")
+			if !found {
+				return nil, fmt.Errorf("missing ':' in '%s'", item)
+			}
+			headers[strings.TrimSpace(name)] = strings.TrimSpace(val)
+		}
+	}
+	for name := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return nil, fmt.Errorf("invalid header name '%s'", name)
+		}
+	}
+	return headers, nil
+}
+
+// Parses comma-separated "upstream:response[:retry-after]" rules, e.g.
+// "410:404,5xx:502,429:503:30s". Upstream statuses are error statuses or
+// classes of them; 404 is always served as such.
+func parseStatusMap(value string) (map[string]StatusMapping, error) {
+	rules := make(map[string]StatusMapping)
+	for _, item := range splitList(value) {
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("'%s' is not upstream:response[:retry-after]", item)
+		}
+		from := strings.ToLower(strings.TrimSpace(parts[0]))
+		if from != "4xx" && from != "5xx" {
+			code, err := strconv.Atoi(from)
+			if err != nil || code < 400 || code > 599 || code == 404 {
+				return nil, fmt.Errorf("invalid upstream status '%s'", parts[0])
+			}
+		}
+		status, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || status < 400 || status > 599 {
+			return nil, fmt.Errorf("invalid response status '%s'", parts[1])
+		}
+		mapping := StatusMapping{Status: status}
+		if len(parts) == 3 {
+			mapping.RetryAfter, err = parseDuration(parts[2], time.Second)
+			if err != nil || mapping.RetryAfter < time.Second {
+				return nil, fmt.Errorf("invalid Retry-After '%s'", parts[2])
+			}
+		}
+		rules[from] = mapping
+	}
+	return rules, nil
+}
+
+// EnvKeys returns the names of the variables set in the environment.
+func EnvKeys() []string {
+	environ := os.Environ()
+	keys := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		if key, _, ok := strings.Cut(kv, "="); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Matches the route variable of a project, numbered or named.
+var projectRouteKeyRegex = regexp.MustCompile(`^PROJECT_([A-Z0-9_]+)_ROUTE$`)
+
+// Project numbers and names, in the form used in variables.
+var projectIDRegex = regexp.MustCompile(`^[A-Z0-9_]+$`)
+
+// Numbered projects are looked up from 1 to this number, in addition to
+// those found among the keys passed to LoadFrom.
+const maxScannedProjectNumber = 100
+
+// Returns the identifiers of the configured projects, as used in their
+// variables: the numbers in ascending order, followed by the names in
+// alphabetical order. Numbers need not be consecutive.
+func projectIDs(getenv func(string) string, keys []string) []string {
+	numbers := make(map[string]int)
+	for i := 1; i <= maxScannedProjectNumber; i++ {
+		if getenv(fmt.Sprintf("PROJECT_%d_ROUTE", i)) != "" {
+			numbers[strconv.Itoa(i)] = i
+		}
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		match := projectRouteKeyRegex.FindStringSubmatch(key)
+		if match == nil || seen[match[1]] || getenv(key) == "" {
+			continue
+		}
+		seen[match[1]] = true
+		if n, err := strconv.Atoi(match[1]); err == nil {
+			numbers[match[1]] = n
+		} else {
+			names = append(names, match[1])
+		}
+	}
+
+	ids := make([]string, 0, len(numbers)+len(names))
+	for id := range numbers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if numbers[ids[i]] != numbers[ids[j]] {
+			return numbers[ids[i]] < numbers[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > 0 && numbers[ids[len(ids)-1]] != len(ids) {
+		fmt.Printf("Warning: Project numbers are not consecutive. Loaded projects %s.\n", strings.Join(ids, ", "))
+	}
+
+	sort.Strings(names)
+	return append(ids, names...)
+}
+
+// Settings a project leaves unset fall back to the DEFAULT_* variable of the
+// same name, e.g. PROJECT_1_CACHE_TTL_SECONDS to DEFAULT_CACHE_TTL_SECONDS.
+// Routes are never inherited.
+func withProjectDefaults(getenv func(string) string, id string) func(string) string {
+	prefix := "PROJECT_" + id + "_"
+	return func(key string) string {
+		value := getenv(key)
+		setting, ok := strings.CutPrefix(key, prefix)
+		if value != "" || !ok || setting == "ROUTE" {
+			return value
+		}
+		return getenv("DEFAULT_" + setting)
+	}
+}
+
+func isProjectNumber(id string) bool {
+	_, err := strconv.Atoi(id)
+	return err == nil
+}
+
+// Names numbered projects "project_<n>" and named projects by their name in
+// lowercase, as they appear in logs, metrics, cache keys and the admin API.
+func projectName(id string) string {
+	if isProjectNumber(id) {
+		return "project_" + id
+	}
+	return strings.ToLower(id)
+}
+
+// Reports whether a lowercase name is a level LOG_LEVEL accepts.
+func isLogLevel(name string) bool {
+	switch name {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+// Reads a boolean environment variable. Unset variables are false.
+func parseBoolEnv(getenv func(string) string, key string) (bool, error) {
+	value := getenv(key)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean value '%s' for %s", value, key)
+	}
+	return b, nil
+}
+
+// Reads the RATE_LIMIT_RPS and RATE_LIMIT_BURST settings under a prefix.
+// The burst defaults to one second's worth of requests.
+func parseRateLimit(getenv func(string) string, prefix string) (float64, int, error) {
+	var rate float64
+	if rateStr := getenv(prefix + "RATE_LIMIT_RPS"); rateStr != "" {
+		var err error
+		rate, err = strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return 0, 0, fmt.Errorf("invalid RATE_LIMIT_RPS '%s'", rateStr)
+		}
+	}
+	burstStr := getenv(prefix + "RATE_LIMIT_BURST")
+	if burstStr == "" {
+		if rate == 0 {
+			return 0, 0, nil
+		}
+		return rate, int(math.Max(1, math.Ceil(rate))), nil
+	}
+	burst, err := strconv.Atoi(burstStr)
+	if err != nil || burst < 1 {
+		return 0, 0, fmt.Errorf("invalid RATE_LIMIT_BURST '%s'", burstStr)
+	}
+	if rate == 0 {
+		return 0, 0, fmt.Errorf("RATE_LIMIT_BURST requires RATE_LIMIT_RPS")
+	}
+	return rate, burst, nil
+}
+
+// Returns the names of a route's placeholders, in order. Those before the
+// last must span a whole path segment, as in
+// /tenants/{tenant_id}/users/{user_id}.png, and may not repeat.
+func routePlaceholders(route string) ([]string, error) {
+	var names []string
+	var starts, ends []int
+	for offset := 0; ; {
+		start := strings.Index(route[offset:], "{")
+		if start == -1 {
+			break
+		}
+		start += offset
+		end := strings.Index(route[start:], "}")
+		if end == -1 {
+			return nil, fmt.Errorf("no '}' found after '{' in route")
+		}
+		end += start
+		name := route[start+1 : end]
+		if name == "" || strings.ContainsAny(name, "{/") {
+			return nil, fmt.Errorf("invalid placeholder '%s' in route", route[start:end+1])
+		}
+		for _, other := range names {
+			if other == name {
+				return nil, fmt.Errorf("placeholder {%s} appears twice in route", name)
+			}
+		}
+		names = append(names, name)
+		starts, ends = append(starts, start), append(ends, end)
+		offset = end + 1
+	}
+	for i := 0; i < len(names)-1; i++ {
+		if starts[i] == 0 || route[starts[i]-1] != '/' || route[ends[i]+1] != '/' {
+			return nil, fmt.Errorf("placeholder {%s} must span a whole path segment", names[i])
+		}
+	}
+	return names, nil
+}
+
 // Finds the placeholder in a route pattern.
 // e.g., "/api/users/{user_id}/avatar" -> "user_id", nil
 func extractIDPlaceholder(route string) (string, error) {
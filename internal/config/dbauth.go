@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// How long RDS IAM authentication tokens are valid.
+const rdsTokenLifetime = 15 * time.Minute
+
+// RDSAuthToken returns an IAM authentication token to use as the password
+// of a new connection to the RDS database at address (host:port) as user.
+// Credentials are taken as for AWS Secrets Manager, the region from the
+// host name or AWS_REGION.
+func RDSAuthToken(ctx context.Context, address, user string) (string, error) {
+	creds, err := loadAWSCredentials(ctx, remoteClient, os.Getenv)
+	if err != nil {
+		return "", err
+	}
+	region := rdsRegion(address)
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return "", fmt.Errorf("AWS_REGION is not set")
+	}
+	return rdsAuthToken(creds, address, user, region, time.Now()), nil
+}
+
+// A token is a presigned connect URL without its scheme.
+func rdsAuthToken(creds awsCredentials, address, user, region string, now time.Time) string {
+	u := &url.URL{
+		Scheme:   "https",
+		Host:     address,
+		Path:     "/",
+		RawQuery: url.Values{"Action": {"connect"}, "DBUser": {user}}.Encode(),
+	}
+	presignV4(u, hexSHA256(nil), creds, region, "rds-db", now, rdsTokenLifetime)
+	return strings.TrimPrefix(u.String(), "https://")
+}
+
+// Host names of RDS endpoints name their region before "rds", e.g.
+// orders.c9akciq32.eu-west-1.rds.amazonaws.com.
+func rdsRegion(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	labels := strings.Split(host, ".")
+	for i := 1; i < len(labels); i++ {
+		if labels[i] == "rds" {
+			return labels[i-1]
+		}
+	}
+	return ""
+}
+
+// CloudSQLAuthToken returns an OAuth2 access token to use as the password of
+// a new connection to Cloud SQL with IAM database authentication. The token
+// is taken as for Google Cloud Secret Manager; address and user do not
+// change it.
+func CloudSQLAuthToken(ctx context.Context, address, user string) (string, error) {
+	store := &gcpSecretsStore{getenv: os.Getenv, client: remoteClient}
+	return store.accessToken(ctx)
+}
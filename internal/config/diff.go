@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Diff describes how next differs from prev: one line per server setting
+// that changed and per project added, removed or changed. Values are left
+// out, as many of them are secrets.
+func Diff(prev, next *AppConfig) []string {
+	var lines []string
+	for _, field := range changedFields(*prev, *next, "Projects") {
+		lines = append(lines, fmt.Sprintf("changed %s", field))
+	}
+
+	previous := make(map[string]Project, len(prev.Projects))
+	for _, p := range prev.Projects {
+		previous[p.Name] = p
+	}
+	current := make(map[string]bool, len(next.Projects))
+	for _, p := range next.Projects {
+		current[p.Name] = true
+		old, ok := previous[p.Name]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("added project %s (%s)", p.Name, p.Route))
+			continue
+		}
+		if fields := changedFields(old, p); len(fields) > 0 {
+			lines = append(lines, fmt.Sprintf("changed project %s: %s", p.Name, strings.Join(fields, ", ")))
+		}
+	}
+	for _, p := range prev.Projects {
+		if !current[p.Name] {
+			lines = append(lines, fmt.Sprintf("removed project %s (%s)", p.Name, p.Route))
+		}
+	}
+	return lines
+}
+
+// Names the fields of two structs of the same type that differ.
+func changedFields(a, b interface{}, skip ...string) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var fields []string
+outer:
+	for i := 0; i < va.NumField(); i++ {
+		name := va.Type().Field(i).Name
+		for _, s := range skip {
+			if name == s {
+				continue outer
+			}
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	prev := &AppConfig{
+		ServerPort: "8080",
+		Projects: []Project{
+			{Name: "project_1", Route: "/users/{id}", CacheTTL: time.Hour},
+			{Name: "project_2", Route: "/docs/{id}", APIAuthSecret: "old"},
+		},
+	}
+	next := &AppConfig{
+		ServerPort: "9090",
+		Projects: []Project{
+			{Name: "project_1", Route: "/users/{id}", CacheTTL: 2 * time.Hour, WarmIDs: []string{"1"}},
+			{Name: "project_3", Route: "/files/{id}"},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"changed ServerPort",
+		"changed project project_1: CacheTTL, WarmIDs",
+		"added project project_3 (/files/{id})",
+		"removed project project_2 (/docs/{id})",
+	}, Diff(prev, next))
+
+	assert.Empty(t, Diff(next, next))
+}
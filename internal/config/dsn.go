@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// Builds a project's DSN from DB_HOST, DB_PORT, DB_USER, DB_PASSWORD,
+// DB_NAME and DB_SSLMODE, the way secret managers and Helm charts often hand
+// out credentials, for a PostgreSQL, CockroachDB or MySQL DB_DRIVER.
+// Returns "" if DB_HOST is not set.
+func assembleDSN(getenv func(string) string, id string) (string, error) {
+	part := func(name string) string {
+		return getenv(fmt.Sprintf("PROJECT_%s_DB_%s", id, name))
+	}
+	host := part("HOST")
+	if host == "" {
+		return "", nil
+	}
+	if part("NAME") == "" {
+		return "", fmt.Errorf("DB_HOST requires DB_NAME for project %s", id)
+	}
+
+	driver := part("DRIVER")
+	port := part("PORT")
+	sslMode := part("SSLMODE")
+	switch driver {
+	case "", "postgres", "cockroachdb":
+		if port == "" {
+			port = "5432"
+			if driver == "cockroachdb" {
+				port = "26257"
+			}
+		}
+		dsn := &url.URL{Scheme: "postgres", Host: net.JoinHostPort(host, port), Path: "/" + part("NAME")}
+		if user, password := part("USER"), part("PASSWORD"); password != "" {
+			dsn.User = url.UserPassword(user, password)
+		} else if user != "" {
+			dsn.User = url.User(user)
+		}
+		if sslMode != "" {
+			dsn.RawQuery = url.Values{"sslmode": {sslMode}}.Encode()
+		}
+		return dsn.String(), nil
+	case "mysql":
+		if port == "" {
+			port = "3306"
+		}
+		// SSLMODE takes PostgreSQL's values, mapped to the MySQL driver's tls.
+		tls := map[string]string{
+			"":            "",
+			"disable":     "false",
+			"prefer":      "preferred",
+			"require":     "skip-verify",
+			"verify-ca":   "true",
+			"verify-full": "true",
+		}
+		mode, ok := tls[sslMode]
+		if !ok {
+			return "", fmt.Errorf("unknown DB_SSLMODE '%s' for project %s", sslMode, id)
+		}
+		credentials := part("USER")
+		if password := part("PASSWORD"); password != "" {
+			credentials += ":" + password
+		}
+		dsn := fmt.Sprintf("%s@tcp(%s)/%s", credentials, net.JoinHostPort(host, port), url.PathEscape(part("NAME")))
+		if mode != "" {
+			dsn += "?tls=" + mode
+		}
+		return dsn, nil
+	}
+	return "", fmt.Errorf("unknown DB_DRIVER '%s' for project %s", driver, id)
+}
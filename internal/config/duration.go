@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parses a duration setting. Bare numbers are in the setting's unit (the
+// seconds of a _SECONDS setting, the milliseconds of an _MS one); anything
+// else is a Go duration such as "90m" or "1h30m", which may also start with
+// a number of days: "1d", "1d12h".
+func parseDuration(value string, unit time.Duration) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+
+	if count, rest, ok := strings.Cut(value, "d"); ok {
+		n, err := strconv.ParseFloat(count, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+		days := time.Duration(n * float64(24*time.Hour))
+		if rest == "" {
+			return days, nil
+		}
+		if strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+") {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+		if n < 0 {
+			d = -d
+		}
+		return days + d, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"60":      60 * time.Second,
+		"1.5":     1500 * time.Millisecond,
+		"90m":     90 * time.Minute,
+		"6h":      6 * time.Hour,
+		"1d":      24 * time.Hour,
+		"1d12h":   36 * time.Hour,
+		"0.5d":    12 * time.Hour,
+		" 250ms ": 250 * time.Millisecond,
+	} {
+		got, err := parseDuration(value, time.Second)
+		assert.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+
+	got, err := parseDuration("250", time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, got, "bare numbers are in the setting's unit")
+
+	for _, value := range []string{"", "soon", "1w", "d", "1d-2h", "1dd", "NaN", "Inf"} {
+		_, err := parseDuration(value, time.Second)
+		assert.Error(t, err, value)
+	}
+}
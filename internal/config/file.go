@@ -0,0 +1,327 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings whose values are objects, passed on as JSON instead of being
+// flattened into one variable per field.
+var objectSettings = map[string]bool{
+	"UPSTREAM_HEADERS": true,
+}
+
+// LoadFile builds the configuration from a YAML (or JSON) file, or from a
+// remote backend if path is one of its URLs (see remote.go). Environment
+// variables take precedence over the file, so secrets can be kept out of it.
+func LoadFile(path string) (*AppConfig, error) {
+	values, err := ReadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return loadValues(values)
+}
+
+// Builds the configuration from the variables of a configuration file, with
+// environment variables taking precedence.
+func loadValues(values map[string]string) (*AppConfig, error) {
+	keys := EnvKeys()
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return LoadFrom(func(key string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return values[key]
+	}, keys...)
+}
+
+// ReadFile reads a configuration file into the variables it stands for.
+// Top-level settings map to the global variables (redis_url to REDIS_URL),
+// and the settings of the n-th entry of "projects" to PROJECT_n_*, or to
+// PROJECT_{NAME}_* if the entry has a name. "projects" may also map names to
+// settings. The settings under "defaults" map to DEFAULT_*. Nested objects
+// are joined with underscores (hook.reject to HOOK_REJECT) and lists are
+// comma-separated.
+func ReadFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return parseConfigFile(raw, path)
+}
+
+// ReadConfig reads the variables of a configuration file or remote backend.
+func ReadConfig(path string) (map[string]string, error) {
+	if IsRemote(path) {
+		return readRemote(path)
+	}
+	return ReadFile(path)
+}
+
+// Parses the contents of a configuration file, named name in errors.
+func parseConfigFile(raw []byte, name string) (map[string]string, error) {
+	// YAML is a superset of JSON, so this reads both.
+	var node yaml.Node
+	if err := yaml.Unmarshal(raw, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", name, err)
+	}
+	var doc map[string]interface{}
+	if len(node.Content) > 0 {
+		if sops, _ := sopsMetadataOf(node.Content[0]); sops != nil {
+			if err := decryptSOPS(node.Content[0], name); err != nil {
+				return nil, err
+			}
+		}
+		if err := node.Content[0].Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", name, err)
+		}
+	}
+
+	values := make(map[string]string)
+	if err := flattenDocument(values, doc); err != nil {
+		return nil, err
+	}
+
+	// The profile named by STRATUM_ENV overrides settings of the rest of the
+	// file, e.g. shorter TTLs in dev.
+	if profile := os.Getenv("STRATUM_ENV"); profile != "" && profilesOf(doc) != nil {
+		profiles := profilesOf(doc)
+		byName, ok := profiles.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'profiles' in config file must be an object")
+		}
+		overrides, ok := byName[profile]
+		if !ok {
+			names := make([]string, 0, len(byName))
+			for name := range byName {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown profile '%s' in config file %s (profiles: %s)", profile, name, strings.Join(names, ", "))
+		}
+		settings, ok := overrides.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profile '%s' in config file must be an object", profile)
+		}
+		if profilesOf(settings) != nil {
+			return nil, fmt.Errorf("profile '%s' in config file must not have profiles", profile)
+		}
+		if err := flattenDocument(values, settings); err != nil {
+			return nil, fmt.Errorf("profile '%s': %w", profile, err)
+		}
+	}
+	return values, nil
+}
+
+// Returns the profiles of a configuration document, if any.
+func profilesOf(doc map[string]interface{}) interface{} {
+	for key, value := range doc {
+		if settingName(key) == "PROFILES" {
+			return value
+		}
+	}
+	return nil
+}
+
+// Adds the settings of a configuration document (or profile) to values,
+// replacing those already there.
+func flattenDocument(values map[string]string, doc map[string]interface{}) error {
+	for key, value := range doc {
+		var err error
+		switch settingName(key) {
+		case "PROFILES":
+			continue
+		case "PROJECTS":
+			err = flattenProjects(values, value)
+		case "DEFAULTS":
+			// Settings inherited by all projects, as DEFAULT_* variables.
+			if _, ok := value.(map[string]interface{}); !ok {
+				return fmt.Errorf("'defaults' in config file must be an object")
+			}
+			err = flattenSetting(values, "DEFAULT", value)
+		default:
+			err = flattenSetting(values, settingName(key), value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func flattenProjects(values map[string]string, projects interface{}) error {
+	var entries []map[string]interface{}
+	var ids []string
+	switch projects := projects.(type) {
+	case []interface{}:
+		// Entries without a name are numbered in order.
+		number := 0
+		for i, project := range projects {
+			settings, ok := project.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("project %d in config file must be an object", i+1)
+			}
+			name, ok := settings["name"]
+			if !ok {
+				number++
+				name = number
+			} else if isProjectNumber(fmt.Sprint(name)) {
+				return fmt.Errorf("invalid project name '%v' in config file, names must not be numbers", name)
+			}
+			entries = append(entries, settings)
+			ids = append(ids, fmt.Sprint(name))
+		}
+	case map[string]interface{}:
+		for name, project := range projects {
+			settings, ok := project.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("project '%s' in config file must be an object", name)
+			}
+			if isProjectNumber(name) {
+				return fmt.Errorf("invalid project name '%s' in config file, names must not be numbers", name)
+			}
+			entries = append(entries, settings)
+			ids = append(ids, name)
+		}
+	default:
+		return fmt.Errorf("'projects' in config file must be a list or an object")
+	}
+
+	seen := make(map[string]bool)
+	for i, settings := range entries {
+		id := settingName(ids[i])
+		if !projectIDRegex.MatchString(id) {
+			return fmt.Errorf("invalid project name '%s' in config file", ids[i])
+		}
+		if seen[id] {
+			return fmt.Errorf("duplicate project name '%s' in config file", ids[i])
+		}
+		seen[id] = true
+		for key, value := range settings {
+			if settingName(key) == "NAME" {
+				continue
+			}
+			if err := flattenSetting(values, fmt.Sprintf("PROJECT_%s_%s", id, settingName(key)), value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func settingName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+func isObjectSetting(name string) bool {
+	for setting := range objectSettings {
+		if name == setting || strings.HasSuffix(name, "_"+setting) {
+			return true
+		}
+	}
+	return false
+}
+
+func flattenSetting(values map[string]string, name string, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		values[name] = ""
+	case map[string]interface{}:
+		if isObjectSetting(name) {
+			object := make(map[string]interface{}, len(v))
+			for key, value := range v {
+				if s, ok := value.(string); ok {
+					expanded, err := expandEnv(s)
+					if err != nil {
+						return fmt.Errorf("invalid value for %s in config file: %w", name, err)
+					}
+					value = expanded
+				}
+				object[key] = value
+			}
+			encoded, err := json.Marshal(object)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s in config file: %w", name, err)
+			}
+			values[name] = string(encoded)
+			return nil
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := flattenSetting(values, name+"_"+settingName(key), v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := scalarString(item)
+			if err != nil {
+				return fmt.Errorf("invalid list item for %s in config file: %w", name, err)
+			}
+			items[i] = s
+		}
+		values[name] = strings.Join(items, ",")
+	default:
+		s, err := scalarString(v)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s in config file: %w", name, err)
+		}
+		values[name] = s
+	}
+	return nil
+}
+
+func scalarString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return expandEnv(v)
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+// Matches ${VAR} and ${VAR:-default} in config file values, and $${ (an
+// escaped "${").
+var envReferenceRegex = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Replaces references to environment variables in a config file value, so
+// the file can be committed while secrets stay in the environment.
+// Referencing a variable that is not set, without a default, is an error.
+func expandEnv(value string) (string, error) {
+	var err error
+	expanded := envReferenceRegex.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		match := envReferenceRegex.FindStringSubmatch(ref)
+		if v, ok := os.LookupEnv(match[1]); ok {
+			return v
+		}
+		if match[2] == "" && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", match[1])
+		}
+		return match[3]
+	})
+	return expanded, err
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	path := writeConfigFile(t, "stratum.yaml", `
+server_port: 9090
+cache_l1_ttl_seconds: 1.5
+projects:
+  - route: /users/{id}/avatar
+    source_type: api
+    api_endpoint: https://example.com/{id}
+    warm_ids: [1, 2, 3]
+    upstream_headers:
+      Accept: image/webp
+    hook:
+      reject: request.id == "0"
+    url_block_private: true
+  - route: /docs/{id}
+    db-dsn: postgres://localhost/docs
+`)
+
+	values, err := ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"SERVER_PORT":                 "9090",
+		"CACHE_L1_TTL_SECONDS":        "1.5",
+		"PROJECT_1_ROUTE":             "/users/{id}/avatar",
+		"PROJECT_1_SOURCE_TYPE":       "api",
+		"PROJECT_1_API_ENDPOINT":      "https://example.com/{id}",
+		"PROJECT_1_WARM_IDS":          "1,2,3",
+		"PROJECT_1_UPSTREAM_HEADERS":  `{"Accept":"image/webp"}`,
+		"PROJECT_1_HOOK_REJECT":       `request.id == "0"`,
+		"PROJECT_1_URL_BLOCK_PRIVATE": "true",
+		"PROJECT_2_ROUTE":             "/docs/{id}",
+		"PROJECT_2_DB_DSN":            "postgres://localhost/docs",
+	}, values)
+
+	t.Run("JSON", func(t *testing.T) {
+		path := writeConfigFile(t, "stratum.json", `{"redis_url": "redis://localhost:6379", "projects": [{"route": "/a/{id}"}]}`)
+		values, err := ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "redis://localhost:6379", values["REDIS_URL"])
+		assert.Equal(t, "/a/{id}", values["PROJECT_1_ROUTE"])
+	})
+
+	t.Run("Invalid Files", func(t *testing.T) {
+		_, err := ReadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.Error(t, err)
+
+		_, err = ReadFile(writeConfigFile(t, "bad.yaml", "projects: [unclosed"))
+		assert.Error(t, err)
+
+		_, err = ReadFile(writeConfigFile(t, "projects.yaml", "projects: /a/{id}\n"))
+		assert.EqualError(t, err, "'projects' in config file must be a list or an object")
+
+		_, err = ReadFile(writeConfigFile(t, "numbered.yaml", "projects:\n  - name: 2\n"))
+		assert.ErrorContains(t, err, "names must not be numbers")
+
+		_, err = ReadFile(writeConfigFile(t, "duplicate.yaml", "projects:\n  - name: a\n  - name: A\n"))
+		assert.ErrorContains(t, err, "duplicate project name 'A'")
+
+		_, err = ReadFile(writeConfigFile(t, "nested.yaml", "projects:\n  - warm_ids: [[1]]\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestReadFile_NamedProjects(t *testing.T) {
+	list := writeConfigFile(t, "list.yaml", `
+projects:
+  - name: avatars
+    route: /avatars/{id}
+  - route: /docs/{id}
+  - name: user-files
+    route: /files/{id}
+`)
+	values, err := ReadFile(list)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"PROJECT_AVATARS_ROUTE":    "/avatars/{id}",
+		"PROJECT_1_ROUTE":          "/docs/{id}",
+		"PROJECT_USER_FILES_ROUTE": "/files/{id}",
+	}, values)
+
+	object := writeConfigFile(t, "object.yaml", `
+projects:
+  avatars:
+    route: /avatars/{id}
+`)
+	values, err = ReadFile(object)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"PROJECT_AVATARS_ROUTE": "/avatars/{id}"}, values)
+}
+
+func TestReadFile_Defaults(t *testing.T) {
+	values, err := ReadFile(writeConfigFile(t, "defaults.yaml", `
+defaults:
+  cache_ttl_seconds: 60
+  upstream_headers:
+    Accept: image/webp
+projects:
+  - route: /a/{id}
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DEFAULT_CACHE_TTL_SECONDS": "60",
+		"DEFAULT_UPSTREAM_HEADERS":  `{"Accept":"image/webp"}`,
+		"PROJECT_1_ROUTE":           "/a/{id}",
+	}, values)
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeConfigFile(t, "stratum.yaml", `
+server_port: 9090
+projects:
+  - route: /users/{id}/avatar
+    source_type: api
+    api_endpoint: https://example.com/{id}
+    api_auth_type: bearer
+    id_column: id
+    cache_ttl_seconds: 60
+`)
+
+	t.Setenv("PROJECT_1_API_AUTH_SECRET", "from-env")
+	t.Setenv("SERVER_PORT", "")
+
+	cfg, err := LoadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "9090", cfg.ServerPort)
+	assert.Len(t, cfg.Projects, 1)
+	assert.Equal(t, "/users/{id}/avatar", cfg.Projects[0].Route)
+	assert.Equal(t, "from-env", cfg.Projects[0].APIAuthSecret)
+
+	t.Setenv("SERVER_PORT", "7070")
+	cfg, err = LoadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "7070", cfg.ServerPort, "environment variables override the file")
+}
+
+func TestReadFile_Interpolation(t *testing.T) {
+	t.Setenv("STRATUM_TEST_DB_PASSWORD", "p@ss")
+	t.Setenv("STRATUM_TEST_TOKEN", "abc")
+	path := writeConfigFile(t, "stratum.yaml", `
+redis_url: redis://:${STRATUM_TEST_DB_PASSWORD}@redis:6379
+projects:
+  - route: /a/{id}
+    db_dsn: postgres://app:${STRATUM_TEST_DB_PASSWORD}@${STRATUM_TEST_DB_HOST:-localhost}/app
+    hook_reject: request.header["X"] == "$${literal}"
+    upstream_headers:
+      Authorization: Bearer ${STRATUM_TEST_TOKEN}
+`)
+
+	values, err := ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "redis://:p@ss@redis:6379", values["REDIS_URL"])
+	assert.Equal(t, "postgres://app:p@ss@localhost/app", values["PROJECT_1_DB_DSN"])
+	assert.Equal(t, `request.header["X"] == "${literal}"`, values["PROJECT_1_HOOK_REJECT"])
+	assert.Equal(t, `{"Authorization":"Bearer abc"}`, values["PROJECT_1_UPSTREAM_HEADERS"])
+
+	_, err = ReadFile(writeConfigFile(t, "unset.yaml", "redis_url: redis://${STRATUM_TEST_UNSET}/0\n"))
+	assert.EqualError(t, err, "invalid value for REDIS_URL in config file: environment variable STRATUM_TEST_UNSET is not set")
+}
+
+func TestReadFile_Profiles(t *testing.T) {
+	path := writeConfigFile(t, "profiles.yaml", `
+cache_l1_ttl_seconds: 5
+projects:
+  - name: avatars
+    route: /avatars/{id}
+    cache_ttl: 1d
+    hook:
+      reject: request.id == "0"
+profiles:
+  dev:
+    cache_l1_ttl_seconds: 1
+    projects:
+      avatars:
+        cache_ttl: 1m
+        hook:
+          cache_ttl: "60"
+  prod: {}
+`)
+
+	values, err := ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "5", values["CACHE_L1_TTL_SECONDS"], "profiles are ignored without STRATUM_ENV")
+	assert.Equal(t, "1d", values["PROJECT_AVATARS_CACHE_TTL"])
+
+	t.Setenv("STRATUM_ENV", "dev")
+	values, err = ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"CACHE_L1_TTL_SECONDS":           "1",
+		"PROJECT_AVATARS_ROUTE":          "/avatars/{id}",
+		"PROJECT_AVATARS_CACHE_TTL":      "1m",
+		"PROJECT_AVATARS_HOOK_REJECT":    `request.id == "0"`,
+		"PROJECT_AVATARS_HOOK_CACHE_TTL": "60",
+	}, values)
+
+	t.Setenv("STRATUM_ENV", "staging")
+	_, err = ReadFile(path)
+	assert.ErrorContains(t, err, "unknown profile 'staging'")
+	assert.ErrorContains(t, err, "(profiles: dev, prod)")
+}
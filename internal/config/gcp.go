@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Reads secrets from Google Cloud Secret Manager. References are the
+// resource name of a secret version, or of a secret for its latest version,
+// optionally followed by the JSON field to use:
+// "gcp-sm://projects/my-project/secrets/db/versions/3#dsn". The access
+// token is taken from GOOGLE_OAUTH_ACCESS_TOKEN or, on Google Cloud, from
+// the metadata server.
+type gcpSecretsStore struct {
+	getenv   func(string) string
+	client   *http.Client
+	endpoint string
+}
+
+const gcpSecretManagerEndpoint = "https://secretmanager.googleapis.com"
+
+func newGCPSecretsStore(getenv func(string) string) secretStore {
+	return &gcpSecretsStore{getenv: getenv, client: &http.Client{}, endpoint: gcpSecretManagerEndpoint}
+}
+
+func (g *gcpSecretsStore) fetch(ctx context.Context, ref string) (string, time.Duration, error) {
+	name, field, _ := strings.Cut(ref, "#")
+	parts := strings.Split(name, "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets":
+		name += "/versions/latest"
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions":
+	default:
+		return "", 0, fmt.Errorf("invalid secret name '%s', expected projects/<project>/secrets/<secret>[/versions/<version>]", name)
+	}
+
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.endpoint+"/v1/"+name+":access", nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("secret manager request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return "", 0, fmt.Errorf("invalid secret manager response for '%s': %w", name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("secret manager returned %s for '%s': %s", resp.Status, name, body.Error.Message)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(body.Payload.Data)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid payload of secret '%s': %w", name, err)
+	}
+	value, err := jsonSecretField(string(payload), field)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w in secret '%s'", err, name)
+	}
+	return value, 0, nil
+}
+
+func (g *gcpSecretsStore) accessToken(ctx context.Context) (string, error) {
+	if token := g.getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	host := g.getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("no Google Cloud credentials: set GOOGLE_OAUTH_ACCESS_TOKEN or run on Google Cloud (%v)", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get an access token from the metadata server: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid access token from the metadata server: %w", err)
+	}
+	return body.AccessToken, nil
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrReadOnly is returned when projects are changed at runtime but the
+// configuration is not kept as keys in Consul or etcd.
+var ErrReadOnly = errors.New("projects can only be changed at runtime when the configuration is kept as keys in Consul or etcd")
+
+// ErrProjectNotFound is returned when removing a project the configuration
+// store does not define.
+var ErrProjectNotFound = errors.New("project not found in the configuration store")
+
+// ErrStoreUnavailable wraps failures to read or write the configuration
+// store.
+var ErrStoreUnavailable = errors.New("configuration store unavailable")
+
+// Writes keys to a remote backend and deletes others, in one transaction.
+type remoteWriter func(ctx context.Context, endpoint string, put map[string][]byte, del []string) error
+
+var remoteWriters = map[string]remoteWriter{
+	"consul": writeConsul,
+	"etcd":   writeEtcd,
+}
+
+var projectNumberRegex = regexp.MustCompile(`^project_([0-9]+)$`)
+
+// UpdateProject replaces the settings of a project, or removes the project
+// if settings is nil, in a configuration kept as keys in Consul or etcd
+// (see remote.go). Settings are named like the project's variables without
+// their PROJECT_<ID>_ prefix, cache_ttl for PROJECT_<ID>_CACHE_TTL, and
+// numbered projects are named project_<n>.
+//
+// The new configuration is passed to apply before anything is written, and
+// is not written if apply fails. If writing fails, apply has already been
+// called and the caller should restore its previous configuration.
+func (s *Source) UpdateProject(name string, settings map[string]string, apply func(*AppConfig) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := url.Parse(s.ConfigPath())
+	if err != nil || u.Host == "" {
+		return ErrReadOnly
+	}
+	scheme, _ := strings.CutSuffix(u.Scheme, "+https")
+	writer, ok := remoteWriters[scheme]
+	if !ok {
+		return ErrReadOnly
+	}
+	backend, server, _ := remoteBackendOf(u.String())
+	id, err := projectIDOf(name)
+	if err != nil {
+		return err
+	}
+
+	prefix := remotePrefix(u)
+	ctx, cancel := context.WithTimeout(context.Background(), remoteConfigTimeout)
+	pairs, err := backend(ctx, server.String(), prefix)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("%w: failed to read configuration from %s: %v", ErrStoreUnavailable, u.Redacted(), err)
+	}
+	if raw, ok := pairs[prefix]; ok && len(raw) > 0 {
+		return ErrReadOnly // a whole configuration file
+	}
+
+	// The keys of the project are replaced by the new settings.
+	dir := prefix + "/project/" + strings.ToLower(id) + "/"
+	values := make(map[string]string)
+	stale := make(map[string]bool)
+	for key, value := range pairs {
+		if strings.HasPrefix(strings.ToLower(key), strings.ToLower(dir)) {
+			stale[key] = true
+			continue
+		}
+		if name, ok := remoteVariable(key, prefix); ok {
+			values[name] = string(value)
+		}
+	}
+	if settings == nil && len(stale) == 0 {
+		return ErrProjectNotFound
+	}
+
+	put := make(map[string][]byte, len(settings))
+	for setting, value := range settings {
+		variable := settingName(setting)
+		if variable == "NAME" {
+			continue
+		}
+		if !projectIDRegex.MatchString(variable) {
+			return fmt.Errorf("invalid setting name '%s'", setting)
+		}
+		values[fmt.Sprintf("PROJECT_%s_%s", id, variable)] = value
+		key := dir + strings.ToLower(variable)
+		put[key] = []byte(value)
+		delete(stale, key)
+	}
+	del := make([]string, 0, len(stale))
+	for key := range stale {
+		del = append(del, key)
+	}
+
+	cfg, err := loadValues(values)
+	if err != nil {
+		return err
+	}
+	if err := apply(cfg); err != nil {
+		return err
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), remoteConfigTimeout)
+	defer cancel()
+	if err := writer(ctx, server.String(), put, del); err != nil {
+		return fmt.Errorf("%w: failed to write configuration to %s: %v", ErrStoreUnavailable, u.Redacted(), err)
+	}
+	return nil
+}
+
+// Returns the ID of a project's variables from its name.
+func projectIDOf(name string) (string, error) {
+	if m := projectNumberRegex.FindStringSubmatch(strings.ToLower(name)); m != nil {
+		return m[1], nil
+	}
+	id := settingName(name)
+	if isProjectNumber(id) {
+		return "", fmt.Errorf("invalid project name '%s', names must not be numbers", name)
+	}
+	if !projectIDRegex.MatchString(id) {
+		return "", fmt.Errorf("invalid project name '%s'", name)
+	}
+	return id, nil
+}
+
+// Sets and deletes keys in Consul's KV store in one transaction. The token
+// is taken from CONSUL_HTTP_TOKEN.
+func writeConsul(ctx context.Context, endpoint string, put map[string][]byte, del []string) error {
+	type kvOp struct {
+		Verb  string
+		Key   string
+		Value []byte `json:",omitempty"` // base64 in the request
+	}
+	var ops []map[string]kvOp
+	for key, value := range put {
+		ops = append(ops, map[string]kvOp{"KV": {Verb: "set", Key: key, Value: value}})
+	}
+	for _, key := range del {
+		ops = append(ops, map[string]kvOp{"KV": {Verb: "delete", Key: key}})
+	}
+	body, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/v1/txn", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Puts and deletes keys through etcd's JSON gateway in one transaction.
+// Credentials are taken from ETCD_USERNAME and ETCD_PASSWORD, if set.
+func writeEtcd(ctx context.Context, endpoint string, put map[string][]byte, del []string) error {
+	token, err := etcdToken(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+
+	type keyValue struct {
+		Key   []byte `json:"key"`
+		Value []byte `json:"value,omitempty"`
+	}
+	var ops []map[string]keyValue
+	for key, value := range put {
+		ops = append(ops, map[string]keyValue{"request_put": {Key: []byte(key), Value: value}})
+	}
+	for _, key := range del {
+		ops = append(ops, map[string]keyValue{"request_delete_range": {Key: []byte(key)}})
+	}
+	var result struct{}
+	return etcdCall(ctx, endpoint+"/v3/kv/txn", token, map[string]interface{}{"success": ops}, &result)
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A Consul KV store serving reads and transactions.
+type fakeConsul struct {
+	mu   sync.Mutex
+	kv   map[string][]byte
+	txns int
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-Consul-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		type entry struct {
+			Key   string
+			Value []byte
+		}
+		var entries []entry
+		for key, value := range f.kv {
+			if strings.HasPrefix(key, prefix) {
+				entries = append(entries, entry{key, value})
+			}
+		}
+		if entries == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(entries)
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/txn":
+		var ops []struct {
+			KV struct {
+				Verb  string
+				Key   string
+				Value []byte
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&ops)
+		for _, op := range ops {
+			switch op.KV.Verb {
+			case "set":
+				f.kv[op.KV.Key] = op.KV.Value
+			case "delete":
+				delete(f.kv, op.KV.Key)
+			}
+		}
+		f.txns++
+		w.Write([]byte(`{"Results":[],"Errors":null}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeConsul) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.kv))
+	for key := range f.kv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestUpdateProject(t *testing.T) {
+	consul := &fakeConsul{kv: map[string][]byte{
+		"stratum/project/avatars/route":        []byte("/avatars/{id}"),
+		"stratum/project/avatars/source_type":  []byte("api"),
+		"stratum/project/avatars/api_endpoint": []byte("https://example.com/{id}"),
+		"stratum/project/avatars/id_column":    []byte("id"),
+		"stratum/project/avatars/cache_ttl":    []byte("1h"),
+	}}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+	t.Setenv("CONSUL_HTTP_TOKEN", "token")
+	source := &Source{Path: "consul://" + strings.TrimPrefix(server.URL, "http://") + "/stratum", DotenvPath: "missing.env"}
+
+	var applied *AppConfig
+	apply := func(cfg *AppConfig) error {
+		applied = cfg
+		return nil
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		err := source.UpdateProject("docs", map[string]string{
+			"route":        "/docs/{id}",
+			"source_type":  "api",
+			"id_column":    "id",
+			"api_endpoint": "https://docs.example.com/{id}",
+		}, apply)
+		assert.NoError(t, err)
+		assert.Len(t, applied.Projects, 2)
+		assert.Contains(t, consul.keys(), "stratum/project/docs/api_endpoint")
+
+		cfg, err := source.Load()
+		assert.NoError(t, err)
+		assert.Len(t, cfg.Projects, 2)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		err := source.UpdateProject("avatars", map[string]string{
+			"route":        "/v2/avatars/{id}",
+			"source_type":  "api",
+			"id_column":    "id",
+			"api_endpoint": "https://example.com/{id}",
+		}, apply)
+		assert.NoError(t, err)
+		assert.NotContains(t, consul.keys(), "stratum/project/avatars/cache_ttl", "settings left out are removed")
+
+		cfg, err := source.Load()
+		assert.NoError(t, err)
+		for _, p := range cfg.Projects {
+			if p.Name == "avatars" {
+				assert.Equal(t, []string{"/v2/avatars/{id}"}, p.Routes)
+			}
+		}
+	})
+
+	t.Run("Invalid Settings Are Not Written", func(t *testing.T) {
+		txns := consul.txns
+		err := source.UpdateProject("docs", map[string]string{"route": "/docs/{id}", "source_type": "ftp"}, apply)
+		assert.Error(t, err)
+		assert.Equal(t, txns, consul.txns)
+
+		err = source.UpdateProject("docs", map[string]string{
+			"route":        "/docs/{id}",
+			"source_type":  "api",
+			"id_column":    "id",
+			"api_endpoint": "https://docs.example.com/{id}",
+		}, func(*AppConfig) error { return errors.New("failed to initialize") })
+		assert.ErrorContains(t, err, "failed to initialize")
+		assert.Equal(t, txns, consul.txns)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		assert.NoError(t, source.UpdateProject("docs", nil, apply))
+		assert.Len(t, applied.Projects, 1)
+		assert.Equal(t, []string{
+			"stratum/project/avatars/api_endpoint",
+			"stratum/project/avatars/id_column",
+			"stratum/project/avatars/route",
+			"stratum/project/avatars/source_type",
+		}, consul.keys())
+
+		err := source.UpdateProject("docs", nil, apply)
+		assert.ErrorIs(t, err, ErrProjectNotFound)
+	})
+
+	t.Run("Invalid Names", func(t *testing.T) {
+		assert.ErrorContains(t, source.UpdateProject("3", nil, apply), "names must not be numbers")
+		assert.ErrorContains(t, source.UpdateProject("a/b", nil, apply), "invalid project name")
+	})
+
+	t.Run("Read Only", func(t *testing.T) {
+		file := &Source{Path: "stratum.yaml"}
+		assert.ErrorIs(t, file.UpdateProject("docs", nil, apply), ErrReadOnly)
+
+		consul.kv["single"] = []byte("server_port: 8081\n")
+		single := &Source{Path: source.Path[:strings.LastIndex(source.Path, "/")] + "/single"}
+		assert.ErrorIs(t, single.UpdateProject("docs", nil, apply), ErrReadOnly)
+	})
+
+	t.Run("Store Unavailable", func(t *testing.T) {
+		t.Setenv("CONSUL_HTTP_TOKEN", "wrong")
+		err := source.UpdateProject("avatars", nil, apply)
+		assert.ErrorIs(t, err, ErrStoreUnavailable)
+		assert.ErrorContains(t, err, "consul returned 403 Forbidden")
+	})
+}
+
+func TestWriteEtcd(t *testing.T) {
+	var txn struct {
+		Success []map[string]struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"success"`
+	}
+	etcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/auth/authenticate":
+			w.Write([]byte(`{"token":"tok"}`))
+		case "/v3/kv/txn":
+			assert.Equal(t, "tok", r.Header.Get("Authorization"))
+			json.NewDecoder(r.Body).Decode(&txn)
+			w.Write([]byte(`{"succeeded":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer etcd.Close()
+	t.Setenv("ETCD_USERNAME", "stratum")
+
+	err := writeEtcd(context.Background(), etcd.URL, map[string][]byte{"stratum/project/docs/route": []byte("/docs/{id}")}, []string{"stratum/project/docs/cache_ttl"})
+	assert.NoError(t, err)
+	assert.Len(t, txn.Success, 2)
+	assert.Equal(t, "stratum/project/docs/route", string(txn.Success[0]["request_put"].Key))
+	assert.Equal(t, "/docs/{id}", string(txn.Success[0]["request_put"].Value))
+	assert.Equal(t, "stratum/project/docs/cache_ttl", string(txn.Success[1]["request_delete_range"].Key))
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Replaces secrets in the redacted configuration. Empty settings are left
+// empty, so it still shows whether a secret is set.
+const redactedValue = "REDACTED"
+
+var (
+	// password=... in a key/value DSN such as PostgreSQL's
+	dsnPasswordRegex = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('[^']*'|\S+)`)
+	// Upstream headers and source settings whose values are credentials
+	secretHeaderRegex = regexp.MustCompile(`(?i)authorization|cookie|token|secret|key|password|signature`)
+	// Configuration variables whose values are credentials, by the end of
+	// their names
+	secretVariableRegex = regexp.MustCompile(`(?i)(secret|password|token|salt|credentials|api_key|access_key)$`)
+	// Configuration variables holding connection URLs or DSNs
+	dsnVariableRegex = regexp.MustCompile(`(?i)(dsn|_url|_proxy|_endpoint)$`)
+)
+
+// Redacted returns a copy of the configuration with its secrets masked:
+// tokens, passwords, salts, and the passwords in connection URLs and DSNs.
+func (c *AppConfig) Redacted() *AppConfig {
+	redacted := *c
+	redacted.AdminToken = redactSecret(c.AdminToken)
+	redacted.RedisURL = redactDSN(c.RedisURL)
+	redacted.CDNPurge.Token = redactSecret(c.CDNPurge.Token)
+
+	redacted.Projects = make([]Project, len(c.Projects))
+	for i, p := range c.Projects {
+		p.DB_DSN = redactDSN(p.DB_DSN)
+		if p.DBReplicaDSNs != nil {
+			replicas := make([]string, len(p.DBReplicaDSNs))
+			for j, dsn := range p.DBReplicaDSNs {
+				replicas[j] = redactDSN(dsn)
+			}
+			p.DBReplicaDSNs = replicas
+		}
+		p.CacheRedisURL = redactDSN(p.CacheRedisURL)
+		p.UpstreamProxy = redactDSN(p.UpstreamProxy)
+		p.APIAuthSecret = redactSecret(p.APIAuthSecret)
+		p.APIAuthPassword = redactSecret(p.APIAuthPassword)
+		p.IDCodecSalt = redactSecret(p.IDCodecSalt)
+		p.UpstreamHeaders = redactHeaders(p.UpstreamHeaders)
+		p.SourceSettings = redactHeaders(p.SourceSettings)
+		redacted.Projects[i] = p
+	}
+	return &redacted
+}
+
+// RedactedVariable returns the value of a configuration variable, such as
+// PROJECT_1_DB_DSN, with its secrets masked as Redacted masks them.
+func RedactedVariable(key, value string) string {
+	switch {
+	case value == "":
+		return ""
+	case strings.HasSuffix(strings.ToUpper(key), "UPSTREAM_HEADERS"):
+		headers, err := parseHeaderList(value)
+		if err != nil {
+			return redactSecret(value)
+		}
+		encoded, _ := json.Marshal(redactHeaders(headers))
+		return string(encoded)
+	case strings.HasSuffix(strings.ToUpper(key), "_DSNS"):
+		items := splitList(value)
+		for i, item := range items {
+			items[i] = redactDSN(item)
+		}
+		return strings.Join(items, ",")
+	case dsnVariableRegex.MatchString(key):
+		return redactDSN(value)
+	case secretVariableRegex.MatchString(key):
+		return redactSecret(value)
+	}
+	return value
+}
+
+// Masks the values of the settings whose names look like credentials, as
+// for upstream headers and source settings.
+func redactHeaders(settings map[string]string) map[string]string {
+	if settings == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(settings))
+	for name, value := range settings {
+		if secretHeaderRegex.MatchString(name) {
+			value = redactSecret(value)
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// Masks the password of a connection URL or DSN.
+func redactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return redactSecret(dsn)
+		}
+		masked := false
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedValue)
+			masked = true
+		}
+		query := u.Query()
+		for key := range query {
+			if strings.EqualFold(key, "password") {
+				query.Set(key, redactedValue)
+				u.RawQuery = query.Encode()
+				masked = true
+			}
+		}
+		if !masked {
+			// Left as written, as re-encoding it would escape templates
+			// such as {id}
+			return dsn
+		}
+		return u.String()
+	}
+
+	// MySQL's user:pass@tcp(host:3306)/db, where the password ends at the
+	// last @ before the database name, as the driver reads it.
+	if slash := strings.LastIndex(dsn, "/"); slash != -1 {
+		if at := strings.LastIndex(dsn[:slash], "@"); at != -1 {
+			if colon := strings.Index(dsn[:at], ":"); colon != -1 {
+				dsn = dsn[:colon+1] + redactedValue + dsn[at:]
+			}
+		}
+	}
+	return dsnPasswordRegex.ReplaceAllString(dsn, "${1}"+redactedValue)
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// The configuration can be kept in a key-value store shared by a fleet of
+// instances, named by a URL instead of a file path:
+//
+//	consul://consul.internal:8500/stratum
+//	etcd://etcd.internal:2379/stratum
+//
+// with "+https" added to the scheme (consul+https://) for TLS. The keys
+// under the prefix are variables, with slashes standing for underscores
+// (stratum/project/avatars/route is PROJECT_AVATARS_ROUTE). Alternatively,
+// the prefix itself may be a key holding a YAML or JSON configuration file.
+//
+// A configuration file can also be fetched from an object store or a web
+// server, e.g. s3://bucket/stratum.yaml or https://config.internal/stratum.yaml.
+// Plain http:// URLs are refused.
+
+// Reads the keys under a prefix from a remote backend.
+type remoteBackend func(ctx context.Context, endpoint, prefix string) (map[string][]byte, error)
+
+var remoteBackends = map[string]remoteBackend{
+	"consul": readConsul,
+	"etcd":   readEtcd,
+}
+
+// Fetches a configuration file from an object store or a web server.
+type objectStore func(ctx context.Context, u *url.URL) ([]byte, error)
+
+var objectStores = map[string]objectStore{
+	"s3":    readS3,
+	"http":  refusePlainHTTP,
+	"https": readHTTP,
+}
+
+// How long reading the configuration from a remote backend may take.
+const remoteConfigTimeout = 10 * time.Second
+
+var remoteClient = &http.Client{}
+
+// IsRemote reports whether path is the URL of a remote configuration
+// backend rather than a file.
+func IsRemote(path string) bool {
+	if _, _, ok := remoteBackendOf(path); ok {
+		return true
+	}
+	_, _, ok := objectStoreOf(path)
+	return ok
+}
+
+func objectStoreOf(path string) (objectStore, *url.URL, bool) {
+	u, err := url.Parse(path)
+	if err != nil || u.Host == "" {
+		return nil, nil, false
+	}
+	store, ok := objectStores[u.Scheme]
+	return store, u, ok
+}
+
+// Splits a backend URL into its backend and the HTTP URL of its server.
+func remoteBackendOf(path string) (remoteBackend, *url.URL, bool) {
+	u, err := url.Parse(path)
+	if err != nil || u.Host == "" {
+		return nil, nil, false
+	}
+	scheme, tls := strings.CutSuffix(u.Scheme, "+https")
+	backend, ok := remoteBackends[scheme]
+	if !ok {
+		return nil, nil, false
+	}
+	server := &url.URL{Scheme: "http", Host: u.Host}
+	if tls {
+		server.Scheme = "https"
+	}
+	return backend, server, true
+}
+
+func readRemote(path string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteConfigTimeout)
+	defer cancel()
+
+	if store, u, ok := objectStoreOf(path); ok {
+		raw, err := store(ctx, u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configuration from %s: %w", u.Redacted(), err)
+		}
+		return parseConfigFile(raw, u.Redacted())
+	}
+
+	backend, server, _ := remoteBackendOf(path)
+	u, _ := url.Parse(path)
+	prefix := remotePrefix(u)
+	pairs, err := backend(ctx, server.String(), prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration from %s: %w", u.Redacted(), err)
+	}
+
+	if raw, ok := pairs[prefix]; ok && len(raw) > 0 {
+		return parseConfigFile(raw, u.Redacted())
+	}
+	values := make(map[string]string)
+	for key, value := range pairs {
+		if name, ok := remoteVariable(key, prefix); ok {
+			values[name] = string(value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no configuration found under '%s' in %s", prefix, u.Redacted())
+	}
+	return values, nil
+}
+
+// Returns the prefix of the keys in a backend URL, "stratum" by default.
+func remotePrefix(u *url.URL) string {
+	if prefix := strings.Trim(u.Path, "/"); prefix != "" {
+		return prefix
+	}
+	return "stratum"
+}
+
+// Returns the variable a key under prefix stands for, if any.
+func remoteVariable(key, prefix string) (string, bool) {
+	name, ok := strings.CutPrefix(key, prefix+"/")
+	if !ok || name == "" || strings.HasSuffix(name, "/") {
+		return "", false
+	}
+	return settingName(strings.ReplaceAll(name, "/", "_")), true
+}
+
+// Reads the keys under a prefix from Consul's KV store. The token is taken
+// from CONSUL_HTTP_TOKEN.
+func readConsul(ctx context.Context, endpoint, prefix string) (map[string][]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/v1/kv/"+prefix+"?recurse=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	pairs := make(map[string][]byte)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return pairs, nil
+	default:
+		return nil, fmt.Errorf("consul returned %s", resp.Status)
+	}
+
+	var entries []struct {
+		Key   string
+		Value []byte // base64 in the response
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid consul response: %w", err)
+	}
+	for _, entry := range entries {
+		pairs[entry.Key] = entry.Value
+	}
+	return pairs, nil
+}
+
+// Reads the keys under a prefix from etcd's JSON gateway. Credentials are
+// taken from ETCD_USERNAME and ETCD_PASSWORD, if set.
+func readEtcd(ctx context.Context, endpoint, prefix string) (map[string][]byte, error) {
+	token, err := etcdToken(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// The range covers every key starting with the prefix. Keys and values
+	// are base64 in the gateway's JSON, as []byte is.
+	end := []byte(prefix)
+	end[len(end)-1]++
+	var result struct {
+		Kvs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	err = etcdCall(ctx, endpoint+"/v3/kv/range", token, map[string][]byte{"key": []byte(prefix), "range_end": end}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make(map[string][]byte, len(result.Kvs))
+	for _, kv := range result.Kvs {
+		pairs[string(kv.Key)] = kv.Value
+	}
+	return pairs, nil
+}
+
+// Authenticates with ETCD_USERNAME and ETCD_PASSWORD, returning "" if no
+// username is set.
+func etcdToken(ctx context.Context, endpoint string) (string, error) {
+	user := os.Getenv("ETCD_USERNAME")
+	if user == "" {
+		return "", nil
+	}
+	var auth struct {
+		Token string `json:"token"`
+	}
+	err := etcdCall(ctx, endpoint+"/v3/auth/authenticate", "", map[string]string{"name": user, "password": os.Getenv("ETCD_PASSWORD")}, &auth)
+	if err != nil {
+		return "", fmt.Errorf("etcd authentication failed: %w", err)
+	}
+	return auth.Token, nil
+}
+
+func etcdCall(ctx context.Context, url, token string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("etcd returned %s: %s", resp.Status, failure.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("invalid etcd response: %w", err)
+	}
+	return nil
+}
+
+// Fetches a file from a web server. Credentials in the URL are sent with
+// basic authentication.
+func readHTTP(ctx context.Context, u *url.URL) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return fetchObject(req)
+}
+
+// Configuration files carry secrets, and one fetched over plain HTTP can be
+// read or replaced on the way, so http:// URLs are refused with a clear
+// error rather than being taken for file paths.
+func refusePlainHTTP(ctx context.Context, u *url.URL) ([]byte, error) {
+	return nil, fmt.Errorf("configuration URLs must use https://, not http://")
+}
+
+// Fetches an object from S3, signed with the credentials and region used
+// for AWS Secrets Manager. AWS_ENDPOINT_URL_S3 points at an S3-compatible
+// store instead, addressing buckets by path.
+func readS3(ctx context.Context, u *url.URL) ([]byte, error) {
+	creds, err := loadAWSCredentials(ctx, remoteClient, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	object := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, key)
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_S3"); endpoint != "" {
+		object = strings.TrimRight(endpoint, "/") + "/" + bucket + "/" + key
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, object, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Amz-Content-Sha256", hexSHA256(nil))
+	signV4(req, nil, creds, region, "s3", time.Now())
+	return fetchObject(req)
+}
+
+func fetchObject(req *http.Request) ([]byte, error) {
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
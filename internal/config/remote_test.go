@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRemote(t *testing.T) {
+	assert.True(t, IsRemote("consul://localhost:8500/stratum"))
+	assert.True(t, IsRemote("etcd+https://etcd.internal:2379/stratum"))
+	assert.False(t, IsRemote("stratum.yaml"))
+	assert.False(t, IsRemote("/etc/stratum/stratum.yaml"))
+	assert.False(t, IsRemote("ftp://example.com/stratum.yaml"))
+}
+
+func TestReadConsul(t *testing.T) {
+	entries := `[
+		{"Key":"stratum/","Value":null},
+		{"Key":"stratum/server_port","Value":"OTA5MA=="},
+		{"Key":"stratum/project/avatars/route","Value":"L2F2YXRhcnMve2lkfQ=="}
+	]`
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("recurse"))
+		if r.Header.Get("X-Consul-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/stratum":
+			w.Write([]byte(entries))
+		case "/v1/kv/file":
+			// server_port: 8081
+			w.Write([]byte(`[{"Key":"file","Value":"c2VydmVyX3BvcnQ6IDgwODEK"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer consul.Close()
+	host := strings.TrimPrefix(consul.URL, "http://")
+	t.Setenv("CONSUL_HTTP_TOKEN", "token")
+
+	values, err := ReadConfig("consul://" + host + "/stratum")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"SERVER_PORT":           "9090",
+		"PROJECT_AVATARS_ROUTE": "/avatars/{id}",
+	}, values)
+
+	values, err = ReadConfig("consul://" + host)
+	assert.NoError(t, err, "the prefix defaults to stratum")
+	assert.Equal(t, "9090", values["SERVER_PORT"])
+
+	values, err = ReadConfig("consul://" + host + "/file")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"SERVER_PORT": "8081"}, values)
+
+	_, err = ReadConfig("consul://" + host + "/missing")
+	assert.ErrorContains(t, err, "no configuration found under 'missing'")
+
+	t.Setenv("CONSUL_HTTP_TOKEN", "wrong")
+	_, err = ReadConfig("consul://" + host + "/stratum")
+	assert.ErrorContains(t, err, "consul returned 403 Forbidden")
+}
+
+func TestReadEtcd(t *testing.T) {
+	etcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/auth/authenticate":
+			var auth map[string]string
+			json.NewDecoder(r.Body).Decode(&auth)
+			if auth["name"] != "stratum" || auth["password"] != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"message":"authentication failed, invalid user ID or password"}`))
+				return
+			}
+			w.Write([]byte(`{"token":"tok"}`))
+		case "/v3/kv/range":
+			assert.Equal(t, "tok", r.Header.Get("Authorization"))
+			var req map[string][]byte
+			json.NewDecoder(r.Body).Decode(&req)
+			assert.Equal(t, "stratum", string(req["key"]))
+			assert.Equal(t, "stratun", string(req["range_end"]))
+			w.Write([]byte(`{"kvs":[
+				{"key":"c3RyYXR1bS9jYWNoZV9sMV90dGxfc2Vjb25kcw==","value":"NjA="},
+				{"key":"c3RyYXR1bS9wcm9qZWN0LzEvcm91dGU=","value":"L2RvY3Mve2lkfQ=="}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer etcd.Close()
+	host := strings.TrimPrefix(etcd.URL, "http://")
+	t.Setenv("ETCD_USERNAME", "stratum")
+	t.Setenv("ETCD_PASSWORD", "pass")
+
+	values, err := ReadConfig("etcd://" + host + "/stratum")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"CACHE_L1_TTL_SECONDS": "60",
+		"PROJECT_1_ROUTE":      "/docs/{id}",
+	}, values)
+
+	t.Setenv("ETCD_PASSWORD", "wrong")
+	_, err = ReadConfig("etcd://" + host + "/stratum")
+	assert.ErrorContains(t, err, "etcd authentication failed")
+	assert.ErrorContains(t, err, "invalid user ID or password")
+}
+
+func TestReadObjectStore(t *testing.T) {
+	store := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stratum.yaml":
+			w.Write([]byte("server_port: 9090\n"))
+		case "/configs/prod/stratum.yaml":
+			assert.Contains(t, r.Header.Get("Authorization"), "Credential=AKID/")
+			assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+			assert.NotEmpty(t, r.Header.Get("X-Amz-Content-Sha256"))
+			w.Write([]byte(`{"server_port": 9091}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer store.Close()
+	defer func(client *http.Client) { remoteClient = client }(remoteClient)
+	remoteClient = store.Client()
+
+	assert.True(t, IsRemote(store.URL+"/stratum.yaml"))
+	values, err := ReadConfig(store.URL + "/stratum.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"SERVER_PORT": "9090"}, values)
+
+	_, err = ReadConfig(store.URL + "/missing.yaml")
+	assert.ErrorContains(t, err, "server returned 404 Not Found")
+
+	_, err = ReadConfig(strings.Replace(store.URL, "https://", "http://", 1) + "/stratum.yaml")
+	assert.ErrorContains(t, err, "must use https://")
+
+	t.Setenv("AWS_ENDPOINT_URL_S3", store.URL)
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	values, err = ReadConfig("s3://configs/prod/stratum.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"SERVER_PORT": "9091"}, values)
+}
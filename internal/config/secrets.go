@@ -0,0 +1,106 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Any value may instead reference a secret kept in a secret store, e.g.
+// "vault:secret/data/stratum#token" or "aws-sm://stratum/db#dsn".
+// References are resolved when the configuration is loaded.
+
+// A secretStore fetches the secret a reference (without its prefix) points
+// at, along with when it should be fetched again (zero for the
+// SECRETS_REFRESH_SECONDS interval).
+type secretStore interface {
+	fetch(ctx context.Context, ref string) (string, time.Duration, error)
+}
+
+// Secret stores by the prefix of their references. Each is created from the
+// variables of the configuration, which hold its address and credentials.
+var secretStores = map[string]func(getenv func(string) string) secretStore{
+	"vault:":    newVaultStore,
+	"aws-sm://": newAWSSecretsStore,
+	"gcp-sm://": newGCPSecretsStore,
+}
+
+// How often secrets are fetched again by default, unless their store says
+// otherwise.
+const defaultSecretsRefresh = time.Hour
+
+// How long fetching a single secret may take.
+const secretTimeout = 10 * time.Second
+
+// Resolves the secret references among the values returned by a getenv
+// function, remembering the first error.
+type secretResolver struct {
+	env      func(string) string
+	stores   map[string]secretStore
+	resolved map[string]string // by reference
+	refresh  time.Duration     // when the first secret should be fetched again
+	err      error
+}
+
+func newSecretResolver(getenv func(string) string) *secretResolver {
+	return &secretResolver{
+		env:      getenv,
+		stores:   make(map[string]secretStore),
+		resolved: make(map[string]string),
+	}
+}
+
+func (r *secretResolver) getenv(key string) string {
+	value := r.env(key)
+	for prefix, newStore := range secretStores {
+		if !strings.HasPrefix(value, prefix) {
+			continue
+		}
+		if secret, ok := r.resolved[value]; ok {
+			return secret
+		}
+
+		store := r.stores[prefix]
+		if store == nil {
+			store = newStore(r.env)
+			r.stores[prefix] = store
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+		secret, refresh, err := store.fetch(ctx, strings.TrimPrefix(value, prefix))
+		cancel()
+		if err == nil && refresh == 0 {
+			refresh, err = r.interval()
+		}
+		if err != nil {
+			r.fail(fmt.Errorf("failed to resolve secret for %s: %w", key, err))
+			return ""
+		}
+
+		r.resolved[value] = secret
+		if refresh > 0 && (r.refresh == 0 || refresh < r.refresh) {
+			r.refresh = refresh
+		}
+		return secret
+	}
+	return value
+}
+
+func (r *secretResolver) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Returns the SECRETS_REFRESH_SECONDS interval; zero disables refreshing.
+func (r *secretResolver) interval() (time.Duration, error) {
+	value := r.env("SECRETS_REFRESH_SECONDS")
+	if value == "" {
+		return defaultSecretsRefresh, nil
+	}
+	interval, err := parseDuration(value, time.Second)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("invalid SECRETS_REFRESH_SECONDS '%s'", value)
+	}
+	return interval, nil
+}
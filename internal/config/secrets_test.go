@@ -0,0 +1,182 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultSecrets(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/stratum":
+			w.Write([]byte(`{"lease_duration":0,"data":{"data":{"token":"s3cret","dsn":"postgres://u:p@db/app"},"metadata":{"version":3}}}`))
+		case "/v1/database/creds/stratum":
+			w.Write([]byte(`{"lease_duration":3600,"data":{"username":"v-stratum","password":"generated"}}`))
+		case "/v1/kv/single":
+			w.Write([]byte(`{"data":{"value":"only"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer vault.Close()
+
+	vars := map[string]string{
+		"VAULT_ADDR":                  vault.URL,
+		"VAULT_TOKEN":                 "root",
+		"ADMIN_TOKEN":                 "vault:kv/single",
+		"PROJECT_1_ROUTE":             "/users/{id}",
+		"PROJECT_1_ID_COLUMN":         "id",
+		"PROJECT_1_SOURCE_TYPE":       "api",
+		"PROJECT_1_API_ENDPOINT":      "https://example.com/{id}",
+		"PROJECT_1_API_AUTH_TYPE":     "bearer",
+		"PROJECT_1_API_AUTH_SECRET":   "vault:secret/data/stratum#token",
+		"PROJECT_1_API_AUTH_USERNAME": "vault:database/creds/stratum#username",
+	}
+	getenv := func(key string) string { return vars[key] }
+
+	cfg, err := LoadFrom(getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, "only", cfg.AdminToken)
+	assert.Equal(t, "s3cret", cfg.Projects[0].APIAuthSecret)
+	assert.Equal(t, "v-stratum", cfg.Projects[0].APIAuthUsername)
+	assert.Equal(t, 40*time.Minute, cfg.SecretsRefresh, "refreshed before the shortest lease runs out")
+
+	t.Run("Unresolvable References", func(t *testing.T) {
+		for ref, message := range map[string]string{
+			"vault:secret/data/missing#token":        "vault returned 404 Not Found",
+			"vault:secret/data/stratum#nope":         "no field 'nope'",
+			"vault:secret/data/stratum":              "no field selected among [dsn token]",
+			"vault:database/creds/stratum#password ": "no field 'password '",
+		} {
+			vars["PROJECT_1_API_AUTH_SECRET"] = ref
+			_, err := LoadFrom(getenv)
+			assert.ErrorContains(t, err, "failed to resolve secret for PROJECT_1_API_AUTH_SECRET")
+			assert.ErrorContains(t, err, message)
+		}
+
+		vars["PROJECT_1_API_AUTH_SECRET"] = "vault:secret/data/stratum#token"
+		vars["VAULT_TOKEN"] = "wrong"
+		_, err := LoadFrom(getenv)
+		assert.ErrorContains(t, err, "permission denied")
+	})
+}
+
+func TestSignV4(t *testing.T) {
+	// The example from AWS's Signature Version 4 documentation.
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7", req.Header.Get("Authorization"))
+}
+
+func TestAWSSecrets(t *testing.T) {
+	sm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=AKID/")
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/secretsmanager/aws4_request")
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+
+		var body struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&body)
+		switch body.SecretId {
+		case "stratum/db":
+			w.Write([]byte(`{"SecretString":"{\"dsn\":\"postgres://u:p@db/app\"}"}`))
+		case "stratum/token":
+			w.Write([]byte(`{"SecretString":"plain-token"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+		}
+	}))
+	defer sm.Close()
+
+	vars := map[string]string{
+		"AWS_ENDPOINT_URL_SECRETS_MANAGER": sm.URL,
+		"AWS_REGION":                       "eu-west-1",
+		"AWS_ACCESS_KEY_ID":                "AKID",
+		"AWS_SECRET_ACCESS_KEY":            "secret",
+		"AWS_SESSION_TOKEN":                "session",
+		"SECRETS_REFRESH_SECONDS":          "300",
+		"PROJECT_1_ROUTE":                  "/users/{id}",
+		"PROJECT_1_ID_COLUMN":              "id",
+		"PROJECT_1_DB_DSN":                 "aws-sm://stratum/db#dsn",
+		"PROJECT_1_TABLE":                  "users",
+		"PROJECT_1_SERVE_COLUMN":           "avatar",
+		"ADMIN_TOKEN":                      "aws-sm://stratum/token",
+	}
+	getenv := func(key string) string { return vars[key] }
+
+	cfg, err := LoadFrom(getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://u:p@db/app", cfg.Projects[0].DB_DSN)
+	assert.Equal(t, "plain-token", cfg.AdminToken)
+	assert.Equal(t, 5*time.Minute, cfg.SecretsRefresh)
+
+	vars["ADMIN_TOKEN"] = "aws-sm://stratum/missing"
+	_, err = LoadFrom(getenv)
+	assert.ErrorContains(t, err, "can't find the specified secret")
+
+	vars["ADMIN_TOKEN"] = "aws-sm://stratum/token#field"
+	_, err = LoadFrom(getenv)
+	assert.ErrorContains(t, err, "the secret is not a JSON object")
+
+	vars["ADMIN_TOKEN"] = ""
+	vars["SECRETS_REFRESH_SECONDS"] = "soon"
+	_, err = LoadFrom(getenv)
+	assert.ErrorContains(t, err, "invalid SECRETS_REFRESH_SECONDS 'soon'")
+}
+
+func TestGCPSecrets(t *testing.T) {
+	sm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			w.Write([]byte(`{"access_token":"ya29.token","expires_in":3599}`))
+		case "/v1/projects/acme/secrets/db/versions/latest:access":
+			assert.Equal(t, "Bearer ya29.token", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"payload":{"data":"` + base64.StdEncoding.EncodeToString([]byte(`{"dsn":"postgres://db/app"}`)) + `"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"message":"Secret not found"}}`))
+		}
+	}))
+	defer sm.Close()
+
+	store := newGCPSecretsStore(func(key string) string {
+		if key == "GCE_METADATA_HOST" {
+			return strings.TrimPrefix(sm.URL, "http://")
+		}
+		return ""
+	}).(*gcpSecretsStore)
+	store.endpoint = sm.URL
+	ctx := context.Background()
+
+	value, refresh, err := store.fetch(ctx, "projects/acme/secrets/db#dsn")
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://db/app", value)
+	assert.Zero(t, refresh)
+
+	_, _, err = store.fetch(ctx, "projects/acme/secrets/other/versions/2")
+	assert.ErrorContains(t, err, "Secret not found")
+
+	_, _, err = store.fetch(ctx, "acme/db")
+	assert.ErrorContains(t, err, "invalid secret name 'acme/db'")
+}
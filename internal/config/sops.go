@@ -0,0 +1,215 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+// Config files may be encrypted with SOPS (https://github.com/getsops/sops)
+// to age keys, so the whole configuration, secrets included, can be kept in
+// Git. They're decrypted when read, with the age identities of SOPS_AGE_KEY
+// or of the file SOPS_AGE_KEY_FILE names, as the sops tool does.
+
+// An encrypted value: ENC[AES256_GCM,data:...,iv:...,tag:...,type:str]
+var sopsValueRegex = regexp.MustCompile(`^ENC\[AES256_GCM,data:([^,]*),iv:([^,]+),tag:([^,]+),type:([a-z]+)\]$`)
+
+// The metadata SOPS adds to the files it encrypts, under "sops".
+type sopsMetadata struct {
+	Age []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	LastModified     string `yaml:"lastmodified"`
+	MAC              string `yaml:"mac"`
+	MACOnlyEncrypted bool   `yaml:"mac_only_encrypted"`
+}
+
+// Returns the "sops" metadata of a parsed config file, if it has any.
+func sopsMetadataOf(doc *yaml.Node) (*yaml.Node, int) {
+	if doc.Kind != yaml.MappingNode {
+		return nil, -1
+	}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "sops" {
+			return doc.Content[i+1], i
+		}
+	}
+	return nil, -1
+}
+
+// Decrypts the values of a SOPS-encrypted document in place and removes its
+// metadata, after checking the document's MAC.
+func decryptSOPS(doc *yaml.Node, name string) error {
+	node, index := sopsMetadataOf(doc)
+	var meta sopsMetadata
+	if err := node.Decode(&meta); err != nil {
+		return fmt.Errorf("invalid sops metadata in config file %s: %w", name, err)
+	}
+	if len(meta.Age) == 0 {
+		return fmt.Errorf("config file %s is not encrypted with an age key, the only kind of SOPS key supported", name)
+	}
+	identities, err := sopsAgeIdentities()
+	if err != nil {
+		return fmt.Errorf("failed to decrypt config file %s: %w", name, err)
+	}
+	var dataKey []byte
+	for _, recipient := range meta.Age {
+		if dataKey, err = ageDecrypt([]byte(recipient.Enc), identities); err == nil {
+			break
+		}
+	}
+	if dataKey == nil {
+		return fmt.Errorf("failed to decrypt config file %s: %w", name, err)
+	}
+	doc.Content = append(doc.Content[:index], doc.Content[index+2:]...)
+
+	mac := sha512.New()
+	if err := decryptSOPSNode(doc, nil, dataKey, mac, meta.MACOnlyEncrypted); err != nil {
+		return fmt.Errorf("failed to decrypt config file %s: %w", name, err)
+	}
+	if meta.MAC == "" {
+		return fmt.Errorf("config file %s has no sops MAC", name)
+	}
+	expected, _, err := decryptSOPSValue(meta.MAC, dataKey, meta.LastModified)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the sops MAC of config file %s: %w", name, err)
+	}
+	if !strings.EqualFold(expected, hex.EncodeToString(mac.Sum(nil))) {
+		return fmt.Errorf("sops MAC mismatch in config file %s, it was modified after being encrypted", name)
+	}
+	return nil
+}
+
+// Decrypts the values under a node, adding them to the MAC in document
+// order. Values are authenticated with the path of keys leading to them;
+// list items share the path of their list.
+func decryptSOPSNode(node *yaml.Node, path []string, key []byte, mac hash.Hash, onlyEncrypted bool) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := decryptSOPSNode(child, path, key, mac, onlyEncrypted); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := append(append([]string{}, path...), node.Content[i].Value)
+			if err := decryptSOPSNode(node.Content[i+1], childPath, key, mac, onlyEncrypted); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.HasPrefix(node.Value, "ENC[") {
+			if !onlyEncrypted {
+				var value interface{}
+				if err := node.Decode(&value); err != nil {
+					return err
+				}
+				mac.Write([]byte(sopsMACString(value)))
+			}
+			return nil
+		}
+		plaintext, valueType, err := decryptSOPSValue(node.Value, key, strings.Join(path, ":")+":")
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+		}
+		node.Value, node.Style = plaintext, 0
+		switch valueType {
+		case "int":
+			node.Tag = "!!int"
+		case "float":
+			node.Tag = "!!float"
+		case "bool":
+			node.Tag = "!!bool"
+			// SOPS adds booleans to the MAC as True and False.
+			b, err := strconv.ParseBool(plaintext)
+			if err != nil {
+				return fmt.Errorf("%s: invalid bool '%s'", strings.Join(path, "."), plaintext)
+			}
+			plaintext = sopsMACString(b)
+		default:
+			node.Tag = "!!str"
+		}
+		mac.Write([]byte(plaintext))
+	}
+	return nil
+}
+
+// Returns a value the way SOPS adds it to the MAC.
+func sopsMACString(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// Decrypts an ENC[...] value with the data key, returning it with its type.
+func decryptSOPSValue(value string, key []byte, additionalData string) (string, string, error) {
+	match := sopsValueRegex.FindStringSubmatch(value)
+	if match == nil {
+		return "", "", fmt.Errorf("invalid encrypted value")
+	}
+	var parts [3][]byte
+	for i, encoded := range match[1:4] {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid encrypted value: %w", err)
+		}
+		parts[i] = decoded
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", "", err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt value")
+	}
+	return string(plaintext), match[4], nil
+}
+
+// Reads the age identities to decrypt with.
+func sopsAgeIdentities() ([]age.Identity, error) {
+	if key := os.Getenv("SOPS_AGE_KEY"); key != "" {
+		return parseAgeIdentities(key)
+	}
+	path := os.Getenv("SOPS_AGE_KEY_FILE")
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("set SOPS_AGE_KEY or SOPS_AGE_KEY_FILE to the age key")
+		}
+		path = filepath.Join(dir, "sops", "age", "keys.txt")
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age key (set SOPS_AGE_KEY or SOPS_AGE_KEY_FILE): %w", err)
+	}
+	return parseAgeIdentities(string(text))
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestReadFile_SOPS(t *testing.T) {
+	identity := newAgeIdentityForTest(t)
+	plain := `
+server_port: 8080
+redis_url: redis://:hunter2@localhost:6379/0
+projects:
+  - name: avatars
+    route: /avatars/{id}
+    db_dsn: postgres://app:hunter2@db/app
+    cache_ttl: 60
+    hide_on_error: true
+    fields: [id, data]
+`
+	encrypted := sopsEncryptForTest(t, plain, identity, `^(redis_url|db_dsn|cache_ttl|hide_on_error|fields)$`)
+	assert.NotContains(t, encrypted, "hunter2")
+	path := writeConfigFile(t, "config.enc.yaml", encrypted)
+
+	t.Setenv("SOPS_AGE_KEY", "# created: today\n"+identity.String()+"\n")
+	values, err := ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"SERVER_PORT":                   "8080",
+		"REDIS_URL":                     "redis://:hunter2@localhost:6379/0",
+		"PROJECT_AVATARS_ROUTE":         "/avatars/{id}",
+		"PROJECT_AVATARS_DB_DSN":        "postgres://app:hunter2@db/app",
+		"PROJECT_AVATARS_CACHE_TTL":     "60",
+		"PROJECT_AVATARS_HIDE_ON_ERROR": "true",
+		"PROJECT_AVATARS_FIELDS":        "id,data",
+	}, values)
+
+	t.Run("Key File", func(t *testing.T) {
+		t.Setenv("SOPS_AGE_KEY", "")
+		t.Setenv("SOPS_AGE_KEY_FILE", writeConfigFile(t, "keys.txt", identity.String()))
+		_, err := ReadFile(path)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Wrong Key", func(t *testing.T) {
+		t.Setenv("SOPS_AGE_KEY", newAgeIdentityForTest(t).String())
+		_, err := ReadFile(path)
+		assert.ErrorContains(t, err, "no age identity matches")
+	})
+
+	t.Run("Invalid Key", func(t *testing.T) {
+		t.Setenv("SOPS_AGE_KEY", "AGE-SECRET-KEY-1INVALID")
+		_, err := ReadFile(path)
+		assert.ErrorContains(t, err, "invalid age identity")
+	})
+
+	t.Run("Tampered Value", func(t *testing.T) {
+		// Encrypted values are bound to their keys, so they can't be moved.
+		lines := strings.Split(encrypted, "\n")
+		var dsn, redis int
+		for i, line := range lines {
+			if strings.Contains(line, "db_dsn:") {
+				dsn = i
+			}
+			if strings.HasPrefix(line, "redis_url:") {
+				redis = i
+			}
+		}
+		lines[dsn] = strings.SplitN(lines[dsn], ":", 2)[0] + ":" + strings.SplitN(lines[redis], ":", 2)[1]
+		_, err := ReadFile(writeConfigFile(t, "tampered.yaml", strings.Join(lines, "\n")))
+		assert.ErrorContains(t, err, "projects.db_dsn: failed to decrypt value")
+	})
+
+	t.Run("Tampered Plain Value", func(t *testing.T) {
+		tampered := strings.Replace(encrypted, "/avatars/{id}", "/admin/{id}", 1)
+		_, err := ReadFile(writeConfigFile(t, "tampered.yaml", tampered))
+		assert.ErrorContains(t, err, "sops MAC mismatch")
+	})
+}
+
+func newAgeIdentityForTest(t *testing.T) *age.X25519Identity {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	return identity
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
+
+// Encrypts a config file the way sops does with an age key, encrypting the
+// values whose keys match encryptedRegex.
+func sopsEncryptForTest(t *testing.T, plain string, identity *age.X25519Identity, encryptedRegex string) string {
+	t.Helper()
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(plain), &doc))
+	dataKey := randomBytes(t, 32)
+	encrypted := regexp.MustCompile(encryptedRegex)
+	mac := sha512.New()
+
+	var walk func(node *yaml.Node, path []string, encrypt bool)
+	walk = func(node *yaml.Node, path []string, encrypt bool) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child, path, encrypt)
+			}
+		case yaml.MappingNode:
+			for i := 0; i < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				walk(node.Content[i+1], append(append([]string{}, path...), key), encrypt || encrypted.MatchString(key))
+			}
+		case yaml.ScalarNode:
+			var value interface{}
+			require.NoError(t, node.Decode(&value))
+			mac.Write([]byte(sopsMACString(value)))
+			if !encrypt {
+				return
+			}
+			valueType := map[string]string{"!!int": "int", "!!float": "float", "!!bool": "bool"}[node.Tag]
+			if valueType == "" {
+				valueType = "str"
+			}
+			node.Value = sopsEncryptValueForTest(t, node.Value, dataKey, strings.Join(path, ":")+":", valueType)
+			node.Tag, node.Style = "!!str", 0
+		}
+	}
+	walk(&doc, nil, false)
+
+	const lastModified = "2026-01-02T03:04:05Z"
+	metadata := map[string]interface{}{
+		"age": []map[string]string{{
+			"recipient": "age1test",
+			"enc":       ageEncryptForTest(t, identity, dataKey),
+		}},
+		"lastmodified": lastModified,
+		"mac":          sopsEncryptValueForTest(t, strings.ToUpper(hex.EncodeToString(mac.Sum(nil))), dataKey, lastModified, "str"),
+		"version":      "3.9.0",
+	}
+	var sops yaml.Node
+	require.NoError(t, sops.Encode(metadata))
+	root := doc.Content[0]
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "sops"}, &sops)
+
+	out, err := yaml.Marshal(&doc)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func sopsEncryptValueForTest(t *testing.T, value string, key []byte, additionalData, valueType string) string {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	iv := randomBytes(t, 32)
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	require.NoError(t, err)
+	sealed := gcm.Seal(nil, iv, []byte(value), []byte(additionalData))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data), base64.StdEncoding.EncodeToString(iv), base64.StdEncoding.EncodeToString(tag), valueType)
+}
+
+// Encrypts to the X25519 recipient of an identity, armored, as sops does.
+func ageEncryptForTest(t *testing.T, identity *age.X25519Identity, plaintext []byte) string {
+	t.Helper()
+	var b strings.Builder
+	armored := armor.NewWriter(&b)
+	w, err := age.Encrypt(armored, identity.Recipient())
+	require.NoError(t, err)
+	_, err = w.Write(plaintext)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, armored.Close())
+	return b.String()
+}
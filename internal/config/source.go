@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// Source loads the configuration from the environment, a .env file and an
+// optional configuration file, and loads it again after they change. Process
+// environment variables take precedence over the .env file, which takes
+// precedence over the configuration file.
+type Source struct {
+	// Configuration file or URL. When empty, STRATUM_CONFIG or
+	// STRATUM_CONFIG_URL names it, if set.
+	Path string
+	// Defaults to ".env" in the working directory.
+	DotenvPath string
+
+	mu     sync.Mutex
+	dotenv map[string]string // variables last set from the .env file
+}
+
+// Load reads the .env file into the environment and builds the
+// configuration.
+func (s *Source) Load() (*AppConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadDotenv(); err != nil {
+		return nil, err
+	}
+	if path := s.ConfigPath(); path != "" {
+		return LoadFile(path)
+	}
+	return Load()
+}
+
+// ConfigPath returns the configuration file in use, or "" for none.
+func (s *Source) ConfigPath() string {
+	if s.Path != "" {
+		return s.Path
+	}
+	if path := os.Getenv("STRATUM_CONFIG"); path != "" {
+		return path
+	}
+	return os.Getenv("STRATUM_CONFIG_URL")
+}
+
+// Files returns the files the configuration is read from: the .env file and
+// the configuration file, if any and not remote. Either may not exist.
+func (s *Source) Files() []string {
+	files := []string{s.dotenvPath()}
+	if path := s.ConfigPath(); path != "" && !IsRemote(path) {
+		files = append(files, path)
+	}
+	return files
+}
+
+func (s *Source) dotenvPath() string {
+	if s.DotenvPath != "" {
+		return s.DotenvPath
+	}
+	return ".env"
+}
+
+// Sets the variables of the .env file that are not set in the process
+// environment. Variables set from an earlier version of the file are
+// updated, or unset if they were removed from it.
+func (s *Source) loadDotenv() error {
+	path := s.dotenvPath()
+	vars, err := godotenv.Read(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	for key, value := range s.dotenv {
+		if _, ok := vars[key]; !ok && os.Getenv(key) == value {
+			os.Unsetenv(key)
+		}
+	}
+	loaded := make(map[string]string)
+	for key, value := range vars {
+		current, set := os.LookupEnv(key)
+		if set && current != s.dotenv[key] {
+			continue // set by the process
+		}
+		os.Setenv(key, value)
+		loaded[key] = value
+	}
+	s.dotenv = loaded
+	return nil
+}
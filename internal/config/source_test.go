@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	dotenv := filepath.Join(t.TempDir(), ".env")
+	t.Cleanup(func() {
+		os.Unsetenv("SERVER_PORT")
+		os.Unsetenv("CACHE_KEY_PREFIX")
+	})
+	os.Unsetenv("SERVER_PORT")
+	os.Unsetenv("CACHE_KEY_PREFIX")
+	source := &Source{DotenvPath: dotenv}
+
+	os.WriteFile(dotenv, []byte("SERVER_PORT=9090\nCACHE_KEY_PREFIX=one\n"), 0o600)
+	cfg, err := source.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "9090", cfg.ServerPort)
+	assert.Equal(t, "one", cfg.CacheKeyPrefix)
+
+	t.Run("Changed .env File", func(t *testing.T) {
+		os.WriteFile(dotenv, []byte("SERVER_PORT=9091\n"), 0o600)
+		cfg, err := source.Load()
+		assert.NoError(t, err)
+		assert.Equal(t, "9091", cfg.ServerPort)
+		assert.Empty(t, cfg.CacheKeyPrefix, "variables removed from the file are unset")
+	})
+
+	t.Run("Process Environment Wins", func(t *testing.T) {
+		t.Setenv("CACHE_KEY_PREFIX", "process")
+		os.WriteFile(dotenv, []byte("CACHE_KEY_PREFIX=file\n"), 0o600)
+		cfg, err := source.Load()
+		assert.NoError(t, err)
+		assert.Equal(t, "process", cfg.CacheKeyPrefix)
+	})
+
+	t.Run("Configuration File", func(t *testing.T) {
+		os.WriteFile(dotenv, nil, 0o600)
+		source.Path = writeConfigFile(t, "stratum.yaml", "server_port: 7070\n")
+		cfg, err := source.Load()
+		assert.NoError(t, err)
+		assert.Equal(t, "7070", cfg.ServerPort)
+
+		os.WriteFile(source.Path, []byte("server_port: 7071\n"), 0o600)
+		cfg, err = source.Load()
+		assert.NoError(t, err)
+		assert.Equal(t, "7071", cfg.ServerPort)
+	})
+}
@@ -0,0 +1,26 @@
+package config
+
+import "sync"
+
+// Source types implemented outside Stratum, by programs that build it with
+// sources of their own.
+var (
+	customSourceTypesMu sync.RWMutex
+	customSourceTypes   = make(map[string]bool)
+)
+
+// RegisterSourceType lets projects use a SOURCE_TYPE other than "database"
+// and "api". Its settings are not validated; they are passed to the source
+// as Project.SourceSettings. It must be called before the configuration is
+// loaded.
+func RegisterSourceType(name string) {
+	customSourceTypesMu.Lock()
+	defer customSourceTypesMu.Unlock()
+	customSourceTypes[name] = true
+}
+
+func isCustomSourceType(name string) bool {
+	customSourceTypesMu.RLock()
+	defer customSourceTypesMu.RUnlock()
+	return customSourceTypes[name]
+}
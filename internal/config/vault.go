@@ -0,0 +1,108 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reads secrets from HashiCorp Vault with its HTTP API. References are the
+// path of a secret and, unless it has a single field, the field to use:
+// "vault:secret/data/stratum#token". The server and token are set with the
+// usual VAULT_ADDR, VAULT_TOKEN and (for Vault Enterprise) VAULT_NAMESPACE
+// variables.
+type vaultStore struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+func newVaultStore(getenv func(string) string) secretStore {
+	return &vaultStore{
+		addr:      strings.TrimRight(getenv("VAULT_ADDR"), "/"),
+		token:     getenv("VAULT_TOKEN"),
+		namespace: getenv("VAULT_NAMESPACE"),
+		client:    &http.Client{},
+	}
+}
+
+func (v *vaultStore) fetch(ctx context.Context, ref string) (string, time.Duration, error) {
+	if v.addr == "" {
+		return "", 0, fmt.Errorf("VAULT_ADDR is not set")
+	}
+	path, field, _ := strings.Cut(ref, "#")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid vault reference '%s': %w", ref, err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		LeaseDuration int                    `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+		Errors        []string               `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return "", 0, fmt.Errorf("invalid vault response for '%s': %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("vault returned %s for '%s' %v", resp.Status, path, body.Errors)
+	}
+
+	// Version 2 of the KV engine nests the secret's fields under data.data.
+	data := body.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	value, err := secretField(data, field)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w in vault secret '%s'", err, path)
+	}
+	// Leased secrets are refreshed when two thirds of the lease have passed,
+	// leaving time to retry before it runs out.
+	return value, time.Duration(body.LeaseDuration) * time.Second * 2 / 3, nil
+}
+
+// Picks a field of a secret, which may be left out if there is only one.
+func secretField(data map[string]interface{}, field string) (string, error) {
+	if field == "" {
+		if len(data) != 1 {
+			names := make([]string, 0, len(data))
+			for name := range data {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return "", fmt.Errorf("no field selected among %v", names)
+		}
+		for name := range data {
+			field = name
+		}
+	}
+
+	switch value := data[field].(type) {
+	case string:
+		return value, nil
+	case nil:
+		return "", fmt.Errorf("no field '%s'", field)
+	default:
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	}
+}
@@ -1,20 +1,31 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/PythonicVarun/Stratum/pkg/utils"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 )
 
-// DBLoader defines the interface for fetching data from a database.
+// DBLoader defines the interface for fetching data from a database. Fetch
+// reads serveColumn of the row whose idColumns hold idValues, one value per
+// column, and which also matches the where condition, if it is not empty.
+// serveColumn may be "column->>path" to read the field at a dot path of a
+// JSON column instead, e.g. "profile->>avatar.url"; see serveExpression.
+// The match mode compares the columns to the values: "exact" (or empty),
+// "case-insensitive", or "prefix", where the row whose columns sort first
+// is read if several match.
 type DBLoader interface {
-	Fetch(table, idColumn, serveColumn, idValue string) ([]byte, error)
+	Fetch(ctx context.Context, table string, idColumns []string, serveColumn, where, match string, idValues []string) ([]byte, error)
 	Close()
 }
 
@@ -22,63 +33,304 @@ type DBLoader interface {
 type GenericDB struct {
 	db         *sql.DB
 	driverName string
+	dialect    string // "postgres", "mysql" or "cockroachdb"
+	host       string // for logs and statuses
+
+	// Set while the database is checked in the background
+	health   *health
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// Prepared Fetch statements by query. A statement is prepared on each
+	// connection of the pool as it is first used there.
+	stmts   map[string]*sql.Stmt
+	stmtsMu sync.Mutex
 }
 
 var validIdentifierRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
 
-// NewDBLoader creates and returns a new DBLoader instance for the given DSN.
-// It currently supports "postgres" and "mysql".
-func NewDBLoader(dsn string) (DBLoader, error) {
-	var driverName string
-	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
-		driverName = "postgres"
-	} else if strings.Contains(dsn, "@tcp(") {
-		driverName = "mysql"
-	} else {
-		return nil, fmt.Errorf("unsupported database dialect for DSN")
+// Escapes the wildcards of LIKE patterns, with "!" as the escape character.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// NewDBLoader creates and returns a new DBLoader instance for the given DSN
+// and options. It currently supports PostgreSQL and databases speaking its
+// wire protocol, such as CockroachDB, and MySQL.
+func NewDBLoader(dsn string, options Options) (DBLoader, error) {
+	g, err := openDB(dsn, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.connect(context.Background()); err != nil {
+		g.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// Checks that the database can be reached.
+func (g *GenericDB) connect(ctx context.Context) error {
+	if err := g.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	utils.StratumLog("INFO", "Successfully connected to %s database.", g.dialect)
+	return nil
+}
 
-	db, err := sql.Open(driverName, dsn)
+// Opens a database without connecting to it yet.
+func openDB(dsn string, options Options) (*GenericDB, error) {
+	driverName, dialect, driverDSN, err := detectDialect(dsn, options.Driver)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
+	g := &GenericDB{driverName: driverName, dialect: dialect, host: dsnHost(dsn)}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	if options.TLS.enabled() || options.Password != nil {
+		g.db, err = openWithOptions(driverName, driverDSN, options)
+		if err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+	g.db, err = sql.Open(driverName, driverDSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	return g, nil
+}
 
-	log.Printf("Successfully connected to %s database.", driverName)
-	return &GenericDB{db: db, driverName: driverName}, nil
+func (g *GenericDB) Fetch(ctx context.Context, table string, idColumns []string, serveColumn, where, match string, idValues []string) ([]byte, error) {
+	data, _, err := g.FetchModified(ctx, table, idColumns, serveColumn, "", where, match, idValues)
+	return data, err
 }
 
-func (g *GenericDB) Fetch(table, idColumn, serveColumn, idValue string) ([]byte, error) {
-	if !isValidIdentifier(table) || !isValidIdentifier(idColumn) || !isValidIdentifier(serveColumn) {
-		return nil, fmt.Errorf("invalid table or column name")
+// ModifiedFetcher is implemented by loaders that can also read when a row
+// was last modified. FetchModified is like Fetch, additionally reading
+// modifiedColumn, if not empty; the time is zero if the column is NULL.
+type ModifiedFetcher interface {
+	FetchModified(ctx context.Context, table string, idColumns []string, serveColumn, modifiedColumn, where, match string, idValues []string) ([]byte, time.Time, error)
+}
+
+func (g *GenericDB) FetchModified(ctx context.Context, table string, idColumns []string, serveColumn, modifiedColumn, where, match string, idValues []string) ([]byte, time.Time, error) {
+	if !isValidIdentifier(table) || len(idColumns) == 0 {
+		return nil, time.Time{}, fmt.Errorf("invalid table or column name")
+	}
+	selected, err := g.serveExpression(serveColumn)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if modifiedColumn != "" && !isValidIdentifier(modifiedColumn) {
+		return nil, time.Time{}, fmt.Errorf("invalid table or column name")
+	}
+	if len(idValues) != len(idColumns) {
+		return nil, time.Time{}, fmt.Errorf("expected %d ID values, got %d", len(idColumns), len(idValues))
 	}
 
 	// Securely quote identifiers
+	conditions := make([]string, len(idColumns))
+	quotedColumns := make([]string, len(idColumns))
+	args := make([]interface{}, len(idValues))
+	for i, idColumn := range idColumns {
+		if !isValidIdentifier(idColumn) {
+			return nil, time.Time{}, fmt.Errorf("invalid table or column name")
+		}
+		placeholder := "?"
+		if g.driverName == "postgres" {
+			placeholder = fmt.Sprintf("$%d", i+1)
+		}
+		quotedColumns[i] = g.QuoteIdentifier(idColumn)
+		args[i] = idValues[i]
+		switch match {
+		case "", "exact":
+			conditions[i] = quotedColumns[i] + " = " + placeholder
+		case "case-insensitive":
+			conditions[i] = "LOWER(" + quotedColumns[i] + ") = LOWER(" + placeholder + ")"
+		case "prefix":
+			// "!" rather than a backslash escapes, as backslashes in
+			// string literals are read differently by MySQL and PostgreSQL.
+			conditions[i] = quotedColumns[i] + " LIKE " + placeholder + " ESCAPE '!'"
+			args[i] = likeEscaper.Replace(idValues[i]) + "%"
+		default:
+			return nil, time.Time{}, fmt.Errorf("unknown ID match mode '%s'", match)
+		}
+	}
 	quotedTable := g.QuoteIdentifier(table)
-	quotedIDColumn := g.QuoteIdentifier(idColumn)
-	quotedServeColumn := g.QuoteIdentifier(serveColumn)
+	if modifiedColumn != "" {
+		selected += ", " + g.QuoteIdentifier(modifiedColumn)
+	}
 
-	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", quotedServeColumn, quotedTable, quotedIDColumn)
-	if g.driverName == "postgres" {
-		query = strings.Replace(query, "?", "$1", 1)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", selected, quotedTable, strings.Join(conditions, " AND "))
+	if where != "" {
+		// Validated with the configuration; parenthesized so an OR in it
+		// cannot widen the lookup beyond the ID.
+		query += " AND (" + where + ")"
+	}
+	if match == "prefix" {
+		// An exact match sorts before the longer IDs it is a prefix of.
+		query += " ORDER BY " + strings.Join(quotedColumns, ", ") + " LIMIT 1"
+	}
+
+	stmt, err := g.prepare(ctx, query)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("database query failed: %w", err)
 	}
 
 	var result []byte
-	err := g.db.QueryRow(query, idValue).Scan(&result)
+	var modified interface{}
+	dest := []interface{}{&result}
+	if modifiedColumn != "" {
+		dest = append(dest, &modified)
+	}
+	scan := func() error {
+		return stmt.QueryRowContext(ctx, args...).Scan(dest...)
+	}
+	err = retryQuery(ctx, scan)
+	if err != nil && ctx.Err() == nil && isConnectionError(err) {
+		// The pool may hold more connections broken by an outage; drop
+		// them and retry once on a new one.
+		g.dropIdle()
+		err = retryQuery(ctx, scan)
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil
+			return nil, time.Time{}, nil
 		}
+		return nil, time.Time{}, fmt.Errorf("database query failed: %w", err)
+	}
+	modifiedAt, err := timeOf(modified)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid value in column %s: %w", modifiedColumn, err)
+	}
+	return result, modifiedAt, nil
+}
+
+// Returns the SQL selecting a serve column. A serve column of the form
+// "column->>path" reads the field at a dot path of a JSON column as text,
+// with numeric path segments indexing arrays, e.g. "data->>images.0.url".
+// The field is NULL, so the row is not found, if the path does not exist.
+func (g *GenericDB) serveExpression(serveColumn string) (string, error) {
+	column, path, isJSON := strings.Cut(serveColumn, "->>")
+	if !isValidIdentifier(column) {
+		return "", fmt.Errorf("invalid table or column name")
+	}
+	quoted := g.QuoteIdentifier(column)
+	if !isJSON {
+		return quoted, nil
+	}
+	// Segments are identifiers, so they are safe inside string literals.
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if !isValidIdentifier(segment) {
+			return "", fmt.Errorf("invalid JSON path '%s'", path)
+		}
+	}
+	if g.driverName == "mysql" {
+		jsonPath := "$"
+		for _, segment := range segments {
+			if _, err := strconv.Atoi(segment); err == nil {
+				jsonPath += "[" + segment + "]"
+			} else {
+				jsonPath += "." + segment
+			}
+		}
+		return "JSON_UNQUOTE(JSON_EXTRACT(" + quoted + ", '" + jsonPath + "'))", nil
+	}
+	return quoted + " #>> '{" + strings.Join(segments, ",") + "}'", nil
+}
+
+// Layouts of timestamps read as text, e.g. by the MySQL driver without
+// parseTime. They are read as UTC unless they carry a zone.
+var timestampLayouts = []string{"2006-01-02 15:04:05.999999999", time.RFC3339Nano, "2006-01-02"}
+
+// Converts a scanned timestamp to a time: a time as read by lib/pq or by
+// the MySQL driver with parseTime, text, or a Unix time in seconds.
+func timeOf(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case []byte:
+		return timeOf(string(v))
+	case string:
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("'%s' is not a timestamp", v)
+	default:
+		return time.Time{}, fmt.Errorf("%T is not a timestamp", value)
+	}
+}
+
+// Returns the prepared statement for a query, preparing it on first use.
+func (g *GenericDB) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	g.stmtsMu.Lock()
+	defer g.stmtsMu.Unlock()
+	if stmt, ok := g.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := g.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if g.stmts == nil {
+		g.stmts = make(map[string]*sql.Stmt)
+	}
+	g.stmts[query] = stmt
+	return stmt, nil
+}
+
+// IDQuerier is implemented by loaders that can run a query returning IDs.
+type IDQuerier interface {
+	QueryIDs(ctx context.Context, query string) ([]string, error)
+}
+
+// QueryIDs runs a query and returns the first column of every row as a
+// string. The query comes from the configuration, never from requests.
+func (g *GenericDB) QueryIDs(ctx context.Context, query string) ([]string, error) {
+	rows, err := g.db.QueryContext(ctx, query)
+	if err != nil {
 		return nil, fmt.Errorf("database query failed: %w", err)
 	}
-	return result, nil
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Ping checks that the database can still be reached. Databases checked in
+// the background report their last check rather than being pinged again.
+func (g *GenericDB) Ping(ctx context.Context) error {
+	if g.health != nil {
+		if status := g.health.get(); !status.Healthy {
+			return errors.New(status.Error)
+		}
+		return nil
+	}
+	return g.db.PingContext(ctx)
 }
 
-// Close closes the database connection.
+// Close closes the prepared statements and the database connection.
 func (g *GenericDB) Close() {
+	if g.stop != nil {
+		g.stopOnce.Do(func() { close(g.stop) })
+	}
+	g.stmtsMu.Lock()
+	for query, stmt := range g.stmts {
+		stmt.Close()
+		delete(g.stmts, query)
+	}
+	g.stmtsMu.Unlock()
 	if g.db != nil {
 		g.db.Close()
 	}
@@ -104,48 +356,83 @@ func isValidIdentifier(name string) bool {
 
 // ConnectionManager handles multiple database connections.
 type ConnectionManager struct {
-	connections map[string]DBLoader
+	connections map[string]*managed
 	mu          sync.RWMutex
+
+	// Set by SetLimits
+	limits    Limits
+	evictOnce sync.Once
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
 func NewConnectionManager() *ConnectionManager {
 	return &ConnectionManager{
-		connections: make(map[string]DBLoader),
+		connections: make(map[string]*managed),
+		stop:        make(chan struct{}),
 	}
 }
 
-// Get returns an existing database connection or creates a new one.
-func (cm *ConnectionManager) Get(dsn string) (DBLoader, error) {
-	cm.mu.RLock()
-	conn, ok := cm.connections[dsn]
-	cm.mu.RUnlock()
-
-	if ok {
-		return conn, nil
-	}
-
+// Get returns an existing database connection or creates a new one. It
+// fails if the database cannot be reached, unless the options are lazy:
+// the database is then returned anyway, and its queries fail until the
+// background checks find it up.
+func (cm *ConnectionManager) Get(dsn string, options Options) (DBLoader, error) {
+	key := dsn + options.key()
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	conn, ok = cm.connections[dsn]
-	if ok {
-		return conn, nil
+	if m, ok := cm.connections[key]; ok {
+		m.use()
+		return reuse(m.conn, options)
 	}
 
-	newConn, err := NewDBLoader(dsn)
+	if err := cm.makeRoom(1); err != nil {
+		return nil, err
+	}
+	g, err := cm.open(dsn, options)
 	if err != nil {
 		return nil, err
 	}
+	ctx := context.Background()
+	if options.Lazy {
+		// Does not hold up startup for a database that is down.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+	}
+	err = g.connect(ctx)
+	if err != nil && !options.Lazy {
+		g.Close()
+		return nil, err
+	}
+	g.watch(healthCheckInterval)
+	if err != nil {
+		g.health.record(err)
+		utils.StratumLog("WARN", "Database %s is down, connecting in the background: %v", g.host, err)
+	}
+
+	cm.connections[key] = newManaged(g)
+	return g, nil
+}
 
-	cm.connections[dsn] = newConn
-	return newConn, nil
+// Returns a managed connection, which for eager options must have been up
+// at its last check, e.g. when a project opened it lazily before.
+func reuse(conn DBLoader, options Options) (DBLoader, error) {
+	if g, ok := conn.(*GenericDB); ok && !options.Lazy {
+		if status := g.Status(); !status.Healthy {
+			return nil, fmt.Errorf("failed to connect to database: %s", status.Error)
+		}
+	}
+	return conn, nil
 }
 
 // CloseAll closes all managed database connections.
 func (cm *ConnectionManager) CloseAll() {
+	cm.closeOnce.Do(func() { close(cm.stop) })
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
-	for _, conn := range cm.connections {
-		conn.Close()
+	for _, m := range cm.connections {
+		m.conn.Close()
 	}
 }
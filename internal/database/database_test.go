@@ -1,9 +1,11 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -43,7 +45,7 @@ func TestGenericDB_Fetch(t *testing.T) {
 
 	t.Run("Invalid Identifier", func(t *testing.T) {
 		gdb := &GenericDB{db: db}
-		_, err := gdb.Fetch("invalid-table", "id", "data", "1")
+		_, err := gdb.Fetch(context.Background(), "invalid-table", []string{"id"}, "data", "", "", []string{"1"})
 		assert.Error(t, err)
 		assert.Equal(t, "invalid table or column name", err.Error())
 	})
@@ -51,9 +53,9 @@ func TestGenericDB_Fetch(t *testing.T) {
 	t.Run("Successful Fetch MySQL", func(t *testing.T) {
 		gdb := &GenericDB{db: db, driverName: "mysql"}
 		rows := sqlmock.NewRows([]string{"data"}).AddRow([]byte("test_data"))
-		mock.ExpectQuery("SELECT data FROM users WHERE id = ?").WithArgs("1").WillReturnRows(rows)
+		mock.ExpectPrepare("SELECT `data` FROM `users` WHERE `id` = \\?").ExpectQuery().WithArgs("1").WillReturnRows(rows)
 
-		data, err := gdb.Fetch("users", "id", "data", "1")
+		data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "", []string{"1"})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("test_data"), data)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -62,19 +64,95 @@ func TestGenericDB_Fetch(t *testing.T) {
 	t.Run("Successful Fetch Postgres", func(t *testing.T) {
 		gdb := &GenericDB{db: db, driverName: "postgres"}
 		rows := sqlmock.NewRows([]string{"data"}).AddRow([]byte("test_data_pg"))
-		mock.ExpectQuery("SELECT data FROM users WHERE id = \\$1").WithArgs("2").WillReturnRows(rows)
+		mock.ExpectPrepare(`SELECT "data" FROM "users" WHERE "id" = \$1`).ExpectQuery().WithArgs("2").WillReturnRows(rows)
 
-		data, err := gdb.Fetch("users", "id", "data", "2")
+		data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "", []string{"2"})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("test_data_pg"), data)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("Where Extra", func(t *testing.T) {
+		gdb := &GenericDB{db: db, driverName: "postgres"}
+		rows := sqlmock.NewRows([]string{"data"}).AddRow([]byte("visible"))
+		mock.ExpectPrepare(`SELECT "data" FROM "users" WHERE "id" = \$1 AND \(deleted_at IS NULL\)`).ExpectQuery().WithArgs("7").WillReturnRows(rows)
+
+		data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "deleted_at IS NULL", "", []string{"7"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("visible"), data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Composite Key", func(t *testing.T) {
+		gdb := &GenericDB{db: db, driverName: "postgres"}
+		rows := sqlmock.NewRows([]string{"data"}).AddRow([]byte("tenant_row"))
+		mock.ExpectPrepare(`SELECT "data" FROM "users" WHERE "tenant_id" = \$1 AND "user_id" = \$2`).ExpectQuery().WithArgs("acme", "8").WillReturnRows(rows)
+
+		data, err := gdb.Fetch(context.Background(), "users", []string{"tenant_id", "user_id"}, "data", "", "", []string{"acme", "8"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("tenant_row"), data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		_, err = gdb.Fetch(context.Background(), "users", []string{"tenant_id", "user_id"}, "data", "", "", []string{"8"})
+		assert.EqualError(t, err, "expected 2 ID values, got 1")
+	})
+
+	t.Run("Case-Insensitive Match", func(t *testing.T) {
+		gdb := &GenericDB{db: db, driverName: "postgres"}
+		rows := sqlmock.NewRows([]string{"data"}).AddRow([]byte("legacy"))
+		mock.ExpectPrepare(`SELECT "data" FROM "users" WHERE LOWER\("id"\) = LOWER\(\$1\)`).ExpectQuery().WithArgs("AbC").WillReturnRows(rows)
+
+		data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "case-insensitive", []string{"AbC"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("legacy"), data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Prefix Match", func(t *testing.T) {
+		gdb := &GenericDB{db: db, driverName: "mysql"}
+		rows := sqlmock.NewRows([]string{"data"}).AddRow([]byte("first"))
+		mock.ExpectPrepare("SELECT `data` FROM `users` WHERE `id` LIKE \\? ESCAPE '!' ORDER BY `id` LIMIT 1").
+			ExpectQuery().WithArgs("50!%!_off!!!_%").WillReturnRows(rows)
+
+		data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "prefix", []string{"50%_off!_"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("first"), data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		_, err = gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "fuzzy", []string{"1"})
+		assert.EqualError(t, err, "unknown ID match mode 'fuzzy'")
+	})
+
+	t.Run("JSON Field", func(t *testing.T) {
+		gdb := &GenericDB{db: db, driverName: "postgres"}
+		rows := sqlmock.NewRows([]string{"data"}).AddRow([]byte("https://cdn.example.com/a.png"))
+		mock.ExpectPrepare(`SELECT "profile" #>> '\{images,0,url\}' FROM "users" WHERE "id" = \$1`).ExpectQuery().WithArgs("5").WillReturnRows(rows)
+
+		data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "profile->>images.0.url", "", "", []string{"5"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("https://cdn.example.com/a.png"), data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		gdb.driverName = "mysql"
+		rows = sqlmock.NewRows([]string{"data"}).AddRow([]byte("https://cdn.example.com/b.png"))
+		mock.ExpectPrepare("SELECT JSON_UNQUOTE\\(JSON_EXTRACT\\(`profile`, '\\$.images\\[0\\].url'\\)\\) FROM `users` WHERE `id` = \\?").ExpectQuery().WithArgs("6").WillReturnRows(rows)
+
+		data, err = gdb.Fetch(context.Background(), "users", []string{"id"}, "profile->>images.0.url", "", "", []string{"6"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("https://cdn.example.com/b.png"), data)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		_, err = gdb.Fetch(context.Background(), "users", []string{"id"}, "profile->>avatar.'url", "", "", []string{"6"})
+		assert.EqualError(t, err, "invalid JSON path 'avatar.'url'")
+		_, err = gdb.Fetch(context.Background(), "users", []string{"id"}, "pro-file->>avatar", "", "", []string{"6"})
+		assert.EqualError(t, err, "invalid table or column name")
+	})
+
 	t.Run("No Rows Found", func(t *testing.T) {
 		gdb := &GenericDB{db: db, driverName: "mysql"}
-		mock.ExpectQuery("SELECT data FROM users WHERE id = ?").WithArgs("3").WillReturnError(sql.ErrNoRows)
+		mock.ExpectPrepare("SELECT `data` FROM `users` WHERE `id` = \\?").ExpectQuery().WithArgs("3").WillReturnError(sql.ErrNoRows)
 
-		data, err := gdb.Fetch("users", "id", "data", "3")
+		data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "", []string{"3"})
 		assert.NoError(t, err)
 		assert.Nil(t, data)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -82,16 +160,120 @@ func TestGenericDB_Fetch(t *testing.T) {
 
 	t.Run("Query Error", func(t *testing.T) {
 		gdb := &GenericDB{db: db, driverName: "mysql"}
-		mock.ExpectQuery("SELECT data FROM users WHERE id = ?").WithArgs("4").WillReturnError(errors.New("db error"))
+		mock.ExpectPrepare("SELECT `data` FROM `users` WHERE `id` = \\?").ExpectQuery().WithArgs("4").WillReturnError(errors.New("db error"))
 
-		_, err := gdb.Fetch("users", "id", "data", "4")
+		_, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "", []string{"4"})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "database query failed")
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
+
+}
+
+func TestGenericDB_FetchModified(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	gdb := &GenericDB{db: db, driverName: "mysql"}
+	modified := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	mock.ExpectPrepare("SELECT `data`, `updated_at` FROM `users` WHERE `id` = \\?").ExpectQuery().WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"data", "updated_at"}).AddRow([]byte("row"), modified))
+	data, modifiedAt, err := gdb.FetchModified(context.Background(), "users", []string{"id"}, "data", "updated_at", "", "", []string{"1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("row"), data)
+	assert.Equal(t, modified, modifiedAt)
+
+	// Without parseTime, the MySQL driver returns timestamps as text.
+	mock.ExpectQuery("SELECT `data`, `updated_at`").WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"data", "updated_at"}).AddRow([]byte("row"), []byte("2026-03-01 12:30:00")))
+	_, modifiedAt, err = gdb.FetchModified(context.Background(), "users", []string{"id"}, "data", "updated_at", "", "", []string{"2"})
+	assert.NoError(t, err)
+	assert.Equal(t, modified, modifiedAt)
+
+	mock.ExpectQuery("SELECT `data`, `updated_at`").WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"data", "updated_at"}).AddRow([]byte("row"), nil))
+	_, modifiedAt, err = gdb.FetchModified(context.Background(), "users", []string{"id"}, "data", "updated_at", "", "", []string{"3"})
+	assert.NoError(t, err)
+	assert.True(t, modifiedAt.IsZero())
+
+	mock.ExpectQuery("SELECT `data`, `updated_at`").WithArgs("4").
+		WillReturnRows(sqlmock.NewRows([]string{"data", "updated_at"}).AddRow([]byte("row"), []byte("yesterday")))
+	_, _, err = gdb.FetchModified(context.Background(), "users", []string{"id"}, "data", "updated_at", "", "", []string{"4"})
+	assert.EqualError(t, err, "invalid value in column updated_at: 'yesterday' is not a timestamp")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, _, err = gdb.FetchModified(context.Background(), "users", []string{"id"}, "data", "updated-at", "", "", []string{"1"})
+	assert.EqualError(t, err, "invalid table or column name")
+}
+
+func TestGenericDB_FetchReusesStatement(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	gdb := &GenericDB{db: db, driverName: "mysql"}
+	prep := mock.ExpectPrepare("SELECT `data` FROM `users` WHERE `id` = \\?").WillBeClosed()
+	prep.ExpectQuery().WithArgs("5").WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte("five")))
+	prep.ExpectQuery().WithArgs("6").WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte("six")))
+	mock.ExpectClose()
+
+	data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "", []string{"5"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("five"), data)
+	data, err = gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "", []string{"6"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("six"), data)
+
+	gdb.Close()
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGenericDB_QueryIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	gdb := &GenericDB{db: db, driverName: "postgres"}
+	rows := sqlmock.NewRows([]string{"id"}).AddRow("7").AddRow(42)
+	mock.ExpectQuery("SELECT id FROM users ORDER BY views DESC LIMIT 2").WillReturnRows(rows)
+
+	ids, err := gdb.QueryIDs(context.Background(), "SELECT id FROM users ORDER BY views DESC LIMIT 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"7", "42"}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // Note: Testing NewDBLoader and ConnectionManager is complex due to the direct
 // use of sql.Open and the lack of dependency injection for the DBLoader constructor.
 // A refactor would be needed to make these components more testable.
 // Given the constraint not to change project logic, these tests are omitted.
+
+func TestConnectionManager_Get(t *testing.T) {
+	// Nothing listens on port 1, so connecting fails right away.
+	dsn := "postgres://app@127.0.0.1:1/shop?sslmode=disable"
+	cm := NewConnectionManager()
+	defer cm.CloseAll()
+
+	_, err := cm.Get(dsn, Options{})
+	assert.ErrorContains(t, err, "failed to connect to database")
+
+	conn, err := cm.Get(dsn, Options{Lazy: true})
+	assert.NoError(t, err)
+	status := conn.(*GenericDB).Status()
+	assert.False(t, status.Healthy)
+	assert.Contains(t, status.Error, "connection refused")
+	assert.Equal(t, []Status{status}, cm.Statuses())
+
+	// Eager projects do not get the connection while it is down.
+	_, err = cm.Get(dsn, Options{})
+	assert.ErrorContains(t, err, "failed to connect to database")
+	again, err := cm.Get(dsn, Options{Lazy: true})
+	assert.NoError(t, err)
+	assert.Same(t, conn, again)
+}
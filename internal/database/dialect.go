@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CockroachDB's default port, used for cockroachdb:// DSNs without one.
+const cockroachPort = "26257"
+
+// How often a query that failed with a retryable error is run again, and
+// how long to wait before the first retry; later ones wait twice as long
+// as the one before.
+const (
+	queryRetries      = 2
+	queryRetryBackoff = 10 * time.Millisecond
+)
+
+// Returns the database/sql driver for a DSN, with the dialect it speaks
+// ("postgres", "mysql" or "cockroachdb") and the DSN as the driver takes it.
+// The dialect is the driver override if set, and otherwise sniffed from
+// the DSN: postgres://, postgresql://, cockroachdb:// and cockroach://
+// URLs, MySQL's user:pass@tcp(host)/db, and lib/pq's key=value form.
+func detectDialect(dsn, driver string) (driverName, dialect, driverDSN string, err error) {
+	dialect = driver
+	if dialect == "" {
+		switch {
+		case hasScheme(dsn, "postgres", "postgresql"):
+			dialect = "postgres"
+		case hasScheme(dsn, "cockroachdb", "cockroach"):
+			dialect = "cockroachdb"
+		case strings.Contains(dsn, "@tcp("):
+			dialect = "mysql"
+		case isKeyValueDSN(dsn):
+			dialect = "postgres"
+		default:
+			return "", "", "", fmt.Errorf("unsupported database dialect for DSN")
+		}
+	}
+
+	switch dialect {
+	case "postgres":
+		return "postgres", dialect, dsn, nil
+	case "mysql":
+		return "mysql", dialect, dsn, nil
+	case "cockroachdb":
+		// CockroachDB speaks the PostgreSQL wire protocol, so lib/pq
+		// connects to it once the URL has a scheme lib/pq accepts.
+		if !strings.Contains(dsn, "://") {
+			return "postgres", dialect, dsn, nil
+		}
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to parse DSN: %w", err)
+		}
+		if hasScheme(dsn, "cockroachdb", "cockroach") {
+			u.Scheme = "postgres"
+			if u.Port() == "" && u.Host != "" {
+				u.Host = net.JoinHostPort(u.Hostname(), cockroachPort)
+			}
+		}
+		return "postgres", dialect, u.String(), nil
+	}
+	return "", "", "", fmt.Errorf("unsupported database driver '%s'", driver)
+}
+
+func hasScheme(dsn string, schemes ...string) bool {
+	for _, scheme := range schemes {
+		if strings.HasPrefix(strings.ToLower(dsn), scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a DSN is in lib/pq's key=value form, e.g.
+// "host=db user=app dbname=shop".
+func isKeyValueDSN(dsn string) bool {
+	for _, field := range strings.Fields(dsn) {
+		key, _, ok := strings.Cut(field, "=")
+		if ok && (key == "host" || key == "dbname") {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the value of a key in a key=value DSN, if set.
+func keyValueDSNParam(dsn, key string) string {
+	for _, field := range strings.Fields(dsn) {
+		if k, value, ok := strings.Cut(field, "="); ok && k == key {
+			return strings.Trim(value, "'")
+		}
+	}
+	return ""
+}
+
+// Reports whether a query failed in a way that running it again can fix:
+// a serialization failure, which CockroachDB returns for any transaction,
+// single reads included, that conflicts with another and must be
+// restarted, or a deadlock.
+func isRetryableError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// serialization_failure and deadlock_detected
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
+
+// Runs a query until it succeeds, fails with an error that is not
+// retryable, or has been retried queryRetries times, waiting longer after
+// each failure. The context's error is returned if it ends while waiting.
+func retryQuery(ctx context.Context, query func() error) error {
+	backoff := queryRetryBackoff
+	err := query()
+	for i := 0; i < queryRetries && isRetryableError(err); i++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+		err = query()
+	}
+	return err
+}
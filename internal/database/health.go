@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// How often databases and replicas are pinged in the background to notice
+// failures and recoveries, and how long each ping may take.
+const (
+	healthCheckInterval = 5 * time.Second
+	healthCheckTimeout  = 2 * time.Second
+)
+
+// database/sql's default number of idle connections, restored after the
+// idle connections of a database are dropped.
+const maxIdleConns = 2
+
+// Status is the health of a database connection pool as of its last check.
+type Status struct {
+	Host    string    `json:"host"`
+	Role    string    `json:"role"` // "primary" or "replica"
+	Healthy bool      `json:"healthy"`
+	Error   string    `json:"error,omitempty"`
+	Since   time.Time `json:"since"` // when it became healthy or not
+}
+
+// The health of a connection pool, updated by its checks.
+type health struct {
+	mu     sync.Mutex
+	status Status
+}
+
+// Records the outcome of a check, reporting whether the pool became
+// healthy or unhealthy with it.
+func (h *health) record(err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	healthy := err == nil
+	changed := healthy != h.status.Healthy
+	if changed {
+		h.status.Healthy = healthy
+		h.status.Since = time.Now()
+	}
+	h.status.Error = ""
+	if err != nil {
+		h.status.Error = err.Error()
+	}
+	return changed
+}
+
+func (h *health) get() Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// Pings the database every interval until it is closed, tracking its
+// health. Connections broken by an outage are dropped when a ping fails, so
+// requests after the database recovers get new ones.
+func (g *GenericDB) watch(interval time.Duration) {
+	g.health = &health{status: Status{Host: g.host, Role: "primary", Healthy: true, Since: time.Now()}}
+	g.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.check()
+			case <-g.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (g *GenericDB) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	err := g.db.PingContext(ctx)
+	cancel()
+	if err != nil {
+		g.dropIdle()
+	}
+	if !g.health.record(err) {
+		return
+	}
+	if err != nil {
+		utils.StratumLog("ERROR", "Database %s is down: %v", g.host, err)
+	} else {
+		utils.StratumLog("INFO", "Database %s is up again.", g.host)
+	}
+}
+
+// Closes the idle connections of the pool.
+func (g *GenericDB) dropIdle() {
+	g.db.SetMaxIdleConns(0)
+	g.db.SetMaxIdleConns(maxIdleConns)
+}
+
+// Status returns the health of the database as of its last background
+// check. Databases opened outside a ConnectionManager are not checked and
+// report healthy.
+func (g *GenericDB) Status() Status {
+	if g.health == nil {
+		return Status{Host: g.host, Role: "primary", Healthy: true}
+	}
+	return g.health.get()
+}
+
+// Reports whether a query failed because its connection broke, e.g. when
+// the database restarted, rather than because of the query itself.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return !netErr.Timeout()
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// connection_exception, admin_shutdown and crash_shutdown
+		return pqErr.Code.Class() == "08" || pqErr.Code == "57P01" || pqErr.Code == "57P02"
+	}
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// Statuses returns the health of every managed database and replica,
+// ordered by host.
+func (cm *ConnectionManager) Statuses() []Status {
+	cm.mu.RLock()
+	var statuses []Status
+	for _, m := range cm.connections {
+		switch conn := m.conn.(type) {
+		case *GenericDB:
+			statuses = append(statuses, conn.Status())
+		case *ReplicaSet:
+			statuses = append(statuses, conn.Statuses()...)
+		}
+	}
+	cm.mu.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Host != statuses[j].Host {
+			return statuses[i].Host < statuses[j].Host
+		}
+		return statuses[i].Role < statuses[j].Role
+	})
+	return statuses
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericDB_HealthChecks(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	// A failed check drops the idle connection, which sqlmock cannot
+	// reopen once all are closed, so one is held.
+	held, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	gdb := &GenericDB{db: db, driverName: "postgres", host: "db.internal:5432"}
+	gdb.watch(time.Hour)
+	defer gdb.Close()
+
+	status := gdb.Status()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, "db.internal:5432", status.Host)
+	assert.Equal(t, "primary", status.Role)
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	gdb.check()
+	status = gdb.Status()
+	assert.False(t, status.Healthy)
+	assert.Equal(t, "connection refused", status.Error)
+	// Ping reports the last check without pinging again.
+	assert.EqualError(t, gdb.Ping(context.Background()), "connection refused")
+
+	mock.ExpectPing()
+	gdb.check()
+	status = gdb.Status()
+	assert.True(t, status.Healthy)
+	assert.Empty(t, status.Error)
+	assert.NoError(t, gdb.Ping(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGenericDB_FetchRetriesBrokenConnection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// sqlmock cannot reopen a connection once all are closed, so one is
+	// held while the broken one is dropped and replaced.
+	held, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+
+	gdb := &GenericDB{db: db, driverName: "postgres"}
+	query := `SELECT "data" FROM "users" WHERE "id" = \$1`
+	mock.ExpectPrepare(query).ExpectQuery().WithArgs("1").WillReturnError(&pq.Error{Code: "57P01", Message: "terminating connection due to administrator command"})
+	mock.ExpectPrepare(query).ExpectQuery().WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte("recovered")))
+
+	data, err := gdb.Fetch(context.Background(), "users", []string{"id"}, "data", "", "", []string{"1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("recovered"), data)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsConnectionError(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected bool
+	}{
+		{driver.ErrBadConn, true},
+		{io.ErrUnexpectedEOF, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{&pq.Error{Code: "57P01"}, true},
+		{&pq.Error{Code: "08006"}, true},
+		{&pq.Error{Code: "42P01"}, false},
+		{errors.New("syntax error"), false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, isConnectionError(tc.err), "%v", tc.err)
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Options configures database connections beyond what their DSN holds.
+type Options struct {
+	TLS TLSOptions
+
+	// Returns the password for each new connection to the database at
+	// address (host:port) as user, such as a short-lived IAM token, in place
+	// of the DSN's. Connections using it are always encrypted. Auth names
+	// the password source, telling connections apart in the cache.
+	Password func(ctx context.Context, address, user string) (string, error)
+	Auth     string
+
+	// Lazy makes ConnectionManager.Get return a database that cannot be
+	// reached yet instead of failing, connecting once it can.
+	Lazy bool
+
+	// Driver is the dialect of the DSN, "postgres", "mysql" or
+	// "cockroachdb", for DSNs it cannot be told from. Empty to tell it from
+	// the DSN.
+	Driver string
+}
+
+// Identifies the options in connection cache keys.
+func (o Options) key() string {
+	key := o.TLS.key()
+	if o.Password != nil {
+		key += "|auth|" + o.Auth
+	}
+	if o.Driver != "" {
+		key += "|driver|" + o.Driver
+	}
+	return key
+}
+
+// Opens a database with TLS options or a password source. The MySQL driver
+// takes the TLS configuration directly, which also allows profiles a DSN
+// cannot name. lib/pq reads certificates from files named in the DSN, so
+// they are added to it, after checking here that they load.
+func openWithOptions(driverName, dsn string, o Options) (*sql.DB, error) {
+	tlsConfig, err := o.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+
+	if driverName == "mysql" {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DSN: %w", err)
+		}
+		cfg.TLS = tlsConfig
+		if o.Password != nil {
+			// IAM tokens are sent with the cleartext plugin, over TLS.
+			cfg.AllowCleartextPasswords = true
+			address, user := cfg.Addr, cfg.User
+			err := cfg.Apply(mysql.BeforeConnect(func(ctx context.Context, c *mysql.Config) error {
+				password, err := o.Password(ctx, address, user)
+				c.Passwd = password
+				return err
+			}))
+			if err != nil {
+				return nil, fmt.Errorf("failed to open database: %w", err)
+			}
+		}
+		connector, err := mysql.NewConnector(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		return sql.OpenDB(connector), nil
+	}
+
+	dsn, err = postgresTLSDSN(dsn, o.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if o.Password != nil {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DSN: %w", err)
+		}
+		return sql.OpenDB(&passwordConnector{dsn: u, password: o.Password}), nil
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// Connects to PostgreSQL with a password fetched for each connection, as
+// lib/pq has no hook to change it.
+type passwordConnector struct {
+	dsn      *url.URL
+	password func(ctx context.Context, address, user string) (string, error)
+}
+
+func (c *passwordConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	address := c.dsn.Host
+	if c.dsn.Port() == "" {
+		address += ":5432"
+	}
+	user := c.dsn.User.Username()
+	password, err := c.password(ctx, address, user)
+	if err != nil {
+		return nil, err
+	}
+	dsn := *c.dsn
+	dsn.User = url.UserPassword(user, password)
+	connector, err := pq.NewConnector(dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *passwordConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
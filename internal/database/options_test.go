@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenDB_Password(t *testing.T) {
+	testCases := []struct {
+		dsn     string
+		address string
+	}{
+		{"stratum@tcp(orders.example:3306)/shop", "orders.example:3306"},
+		{"postgres://stratum@orders.example/shop", "orders.example:5432"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.dsn, func(t *testing.T) {
+			var address, user string
+			options := Options{
+				Auth: "test",
+				Password: func(ctx context.Context, a, u string) (string, error) {
+					address, user = a, u
+					return "", errors.New("no token")
+				},
+			}
+			g, err := openDB(tc.dsn, options)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer g.Close()
+
+			// The password is fetched before connecting, so the failure
+			// comes from the password source.
+			err = g.Ping(context.Background())
+			assert.ErrorContains(t, err, "no token")
+			assert.Equal(t, tc.address, address)
+			assert.Equal(t, "stratum", user)
+		})
+	}
+
+	assert.NotEqual(t, Options{}.key(), Options{Auth: "rds-iam", Password: func(context.Context, string, string) (string, error) { return "", nil }}.key())
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// How often pools no project uses anymore are looked for, at most.
+const evictionInterval = time.Minute
+
+// Limits bounds the database pools a ConnectionManager keeps open. Each
+// DSN, primary or replica, has a pool of its own.
+type Limits struct {
+	// Pools open at once; zero for unlimited. Opening one more closes the
+	// pool unused for longest, and fails if every pool is in use.
+	MaxPools int
+
+	// How long connections may sit idle in a pool, and pools no project
+	// uses anymore stay open; zero for forever.
+	IdleTimeout time.Duration
+}
+
+// Pool describes an open database pool.
+type Pool struct {
+	Host  string `json:"host"`
+	Role  string `json:"role"`   // "primary" or "replica"
+	InUse bool   `json:"in_use"` // by a project of the running configuration
+
+	// When the last project using the pool went away, if it has
+	UnusedSince *time.Time `json:"unused_since,omitempty"`
+
+	OpenConnections int   `json:"open_connections"`
+	BusyConnections int   `json:"busy_connections"`
+	IdleConnections int   `json:"idle_connections"`
+	WaitCount       int64 `json:"wait_count"` // connections waited for, in total
+}
+
+// A connection held by a ConnectionManager, with whether projects use it.
+type managed struct {
+	conn   DBLoader
+	inUse  bool
+	unused time.Time // when inUse was last cleared
+}
+
+func newManaged(conn DBLoader) *managed {
+	return &managed{conn: conn, inUse: true}
+}
+
+func (m *managed) use() {
+	m.inUse = true
+}
+
+// Returns the pools of the connection: one for a database, one per
+// replica for a ReplicaSet, whose primary is managed separately.
+func (m *managed) pools() []*GenericDB {
+	switch conn := m.conn.(type) {
+	case *GenericDB:
+		return []*GenericDB{conn}
+	case *ReplicaSet:
+		var pools []*GenericDB
+		for _, rep := range conn.replicas {
+			if g, ok := rep.db.(*GenericDB); ok {
+				pools = append(pools, g)
+			}
+		}
+		return pools
+	}
+	return nil
+}
+
+// SetLimits applies limits to the open pools and those opened from now on,
+// and starts closing the pools no project uses anymore once they have been
+// unused for the idle timeout.
+func (cm *ConnectionManager) SetLimits(limits Limits) {
+	cm.mu.Lock()
+	cm.limits = limits
+	for _, m := range cm.connections {
+		for _, g := range m.pools() {
+			g.db.SetConnMaxIdleTime(limits.IdleTimeout)
+		}
+	}
+	cm.mu.Unlock()
+
+	cm.evictOnce.Do(func() { go cm.evictLoop() })
+}
+
+// Closes unused connections every evictionInterval until the manager is
+// closed.
+func (cm *ConnectionManager) evictLoop() {
+	ticker := time.NewTicker(evictionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cm.evictUnused(time.Now())
+		case <-cm.stop:
+			return
+		}
+	}
+}
+
+// Opens a database with the idle timeout of the limits.
+func (cm *ConnectionManager) open(dsn string, options Options) (*GenericDB, error) {
+	g, err := openDB(dsn, options)
+	if err != nil {
+		return nil, err
+	}
+	g.db.SetConnMaxIdleTime(cm.limits.IdleTimeout)
+	return g, nil
+}
+
+// Retain marks the connections in use, along with the primaries of the
+// replica sets among them, and every other connection unused. It is called
+// with the connections of the running projects whenever they change, so
+// pools of removed projects can be closed.
+func (cm *ConnectionManager) Retain(conns []DBLoader) {
+	retained := make(map[DBLoader]bool, len(conns))
+	for _, conn := range conns {
+		retained[conn] = true
+		if set, ok := conn.(*ReplicaSet); ok {
+			retained[set.primary] = true
+		}
+	}
+	now := time.Now()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for _, m := range cm.connections {
+		if retained[m.conn] {
+			m.inUse = true
+		} else if m.inUse {
+			m.inUse = false
+			m.unused = now
+		}
+	}
+}
+
+// Closes the connections unused for longer than the idle timeout as of now.
+func (cm *ConnectionManager) evictUnused(now time.Time) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.limits.IdleTimeout <= 0 {
+		return
+	}
+	for key, m := range cm.connections {
+		if !m.inUse && now.Sub(m.unused) >= cm.limits.IdleTimeout {
+			cm.evict(key)
+		}
+	}
+}
+
+// Closes unused connections, those unused for longest first, until n more
+// pools can be opened within the limits. Called with cm.mu held.
+func (cm *ConnectionManager) makeRoom(n int) error {
+	if cm.limits.MaxPools <= 0 {
+		return nil
+	}
+	open := 0
+	var unused []string
+	for key, m := range cm.connections {
+		open += len(m.pools())
+		if !m.inUse {
+			unused = append(unused, key)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return cm.connections[unused[i]].unused.Before(cm.connections[unused[j]].unused)
+	})
+	for _, key := range unused {
+		if open+n <= cm.limits.MaxPools {
+			break
+		}
+		open -= len(cm.connections[key].pools())
+		cm.evict(key)
+	}
+	if open+n > cm.limits.MaxPools {
+		return fmt.Errorf("too many database pools: %d are open and in use, the limit is %d", open, cm.limits.MaxPools)
+	}
+	return nil
+}
+
+// Closes a connection and forgets it. Called with cm.mu held.
+func (cm *ConnectionManager) evict(key string) {
+	m := cm.connections[key]
+	delete(cm.connections, key)
+	m.conn.Close()
+	for _, g := range m.pools() {
+		utils.StratumLog("INFO", "Closed the unused pool of database %s.", g.host)
+	}
+}
+
+// Pools describes the open pools, ordered by host.
+func (cm *ConnectionManager) Pools() []Pool {
+	cm.mu.RLock()
+	var pools []Pool
+	for _, m := range cm.connections {
+		role := "primary"
+		if _, ok := m.conn.(*ReplicaSet); ok {
+			role = "replica"
+		}
+		for _, g := range m.pools() {
+			stats := g.db.Stats()
+			pool := Pool{
+				Host:            g.host,
+				Role:            role,
+				InUse:           m.inUse,
+				OpenConnections: stats.OpenConnections,
+				BusyConnections: stats.InUse,
+				IdleConnections: stats.Idle,
+				WaitCount:       stats.WaitCount,
+			}
+			if !m.inUse {
+				unused := m.unused
+				pool.UnusedSince = &unused
+			}
+			pools = append(pools, pool)
+		}
+	}
+	cm.mu.RUnlock()
+	sort.Slice(pools, func(i, j int) bool {
+		if pools[i].Host != pools[j].Host {
+			return pools[i].Host < pools[j].Host
+		}
+		return pools[i].Role < pools[j].Role
+	})
+	return pools
+}
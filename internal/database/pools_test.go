@@ -0,0 +1,52 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionManager_Limits(t *testing.T) {
+	// Nothing listens on port 1; the pools are opened lazily.
+	dsn := func(name string) string {
+		return "postgres://app@127.0.0.1:1/" + name + "?sslmode=disable"
+	}
+	cm := NewConnectionManager()
+	defer cm.CloseAll()
+	cm.SetLimits(Limits{MaxPools: 2, IdleTimeout: time.Minute})
+
+	shop, err := cm.Get(dsn("shop"), Options{Lazy: true})
+	assert.NoError(t, err)
+	_, err = cm.Get(dsn("blog"), Options{Lazy: true})
+	assert.NoError(t, err)
+	_, err = cm.Get(dsn("wiki"), Options{Lazy: true})
+	assert.ErrorContains(t, err, "too many database pools: 2 are open and in use, the limit is 2")
+
+	// The blog project went away, so its pool makes room for the wiki's.
+	cm.Retain([]DBLoader{shop})
+	pools := cm.Pools()
+	if assert.Len(t, pools, 2) {
+		assert.NotEqual(t, pools[0].InUse, pools[1].InUse)
+	}
+	_, err = cm.Get(dsn("wiki"), Options{Lazy: true})
+	assert.NoError(t, err)
+	pools = cm.Pools()
+	if assert.Len(t, pools, 2) {
+		assert.True(t, pools[0].InUse && pools[1].InUse)
+	}
+
+	// Unused pools are closed once they have been unused for the timeout.
+	cm.Retain([]DBLoader{shop})
+	cm.evictUnused(time.Now())
+	assert.Len(t, cm.Pools(), 2)
+	cm.evictUnused(time.Now().Add(2 * time.Minute))
+	pools = cm.Pools()
+	if assert.Len(t, pools, 1) {
+		assert.True(t, pools[0].InUse)
+		assert.Nil(t, pools[0].UnusedSince)
+	}
+	again, err := cm.Get(dsn("shop"), Options{Lazy: true})
+	assert.NoError(t, err)
+	assert.Same(t, shop, again)
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ReplicaSet reads from the replicas of a primary database in turn. A
+// replica whose query fails is skipped until it answers a ping again, and
+// the primary is read from when no replica is healthy.
+type ReplicaSet struct {
+	primary  DBLoader
+	replicas []*replica
+	next     atomic.Uint32
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+type replica struct {
+	name    string // host, for logs
+	db      DBLoader
+	healthy atomic.Bool
+	health  health // for statuses
+}
+
+// Creates a ReplicaSet and checks its replicas once before returning, so
+// reads do not start on a replica that is down. The set owns the replicas
+// but not the primary.
+func newReplicaSet(primary DBLoader, replicas []DBLoader, names []string, interval time.Duration) *ReplicaSet {
+	r := &ReplicaSet{primary: primary, stop: make(chan struct{})}
+	for i, db := range replicas {
+		rep := &replica{name: names[i], db: db}
+		rep.health.status = Status{Host: names[i], Role: "replica"}
+		r.replicas = append(r.replicas, rep)
+	}
+	r.check()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.check()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// Pings every replica, marking it healthy or not.
+func (r *ReplicaSet) check() {
+	for _, rep := range r.replicas {
+		var err error
+		if p, ok := rep.db.(pinger); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+			err = p.Ping(ctx)
+			cancel()
+		}
+		if err != nil {
+			rep.markDown(err)
+			continue
+		}
+		rep.health.record(nil)
+		if !rep.healthy.Swap(true) {
+			utils.StratumLog("INFO", "Database replica %s is up.", rep.name)
+		}
+	}
+}
+
+func (rep *replica) markDown(err error) {
+	rep.health.record(err)
+	if rep.healthy.Swap(false) {
+		utils.StratumLog("WARN", "Database replica %s is down, reading elsewhere until it recovers: %v", rep.name, err)
+	}
+}
+
+// Runs a read on the next healthy replica, moving on to the others and
+// then to the primary as they fail.
+func (r *ReplicaSet) read(ctx context.Context, fn func(DBLoader) error) error {
+	if n := len(r.replicas); n > 0 {
+		start := int(r.next.Add(1) % uint32(n))
+		for i := 0; i < n; i++ {
+			rep := r.replicas[(start+i)%n]
+			if !rep.healthy.Load() {
+				continue
+			}
+			err := fn(rep.db)
+			if err == nil || ctx.Err() != nil {
+				// A caller giving up is not the replica's fault.
+				return err
+			}
+			rep.markDown(err)
+		}
+	}
+	return fn(r.primary)
+}
+
+func (r *ReplicaSet) Fetch(ctx context.Context, table string, idColumns []string, serveColumn, where, match string, idValues []string) ([]byte, error) {
+	var data []byte
+	err := r.read(ctx, func(db DBLoader) error {
+		var err error
+		data, err = db.Fetch(ctx, table, idColumns, serveColumn, where, match, idValues)
+		return err
+	})
+	return data, err
+}
+
+// FetchModified reads a row and its modification time like
+// GenericDB.FetchModified, on a replica if one is healthy.
+func (r *ReplicaSet) FetchModified(ctx context.Context, table string, idColumns []string, serveColumn, modifiedColumn, where, match string, idValues []string) ([]byte, time.Time, error) {
+	var data []byte
+	var modified time.Time
+	err := r.read(ctx, func(db DBLoader) error {
+		fetcher, ok := db.(ModifiedFetcher)
+		if !ok {
+			return errors.New("database does not support modification times")
+		}
+		var err error
+		data, modified, err = fetcher.FetchModified(ctx, table, idColumns, serveColumn, modifiedColumn, where, match, idValues)
+		return err
+	})
+	return data, modified, err
+}
+
+// QueryIDs runs a query for IDs like GenericDB.QueryIDs, on a replica if
+// one is healthy.
+func (r *ReplicaSet) QueryIDs(ctx context.Context, query string) ([]string, error) {
+	var ids []string
+	err := r.read(ctx, func(db DBLoader) error {
+		querier, ok := db.(IDQuerier)
+		if !ok {
+			return errors.New("database does not support ID queries")
+		}
+		var err error
+		ids, err = querier.QueryIDs(ctx, query)
+		return err
+	})
+	return ids, err
+}
+
+// Statuses returns the health of the replicas. The primary's is reported by
+// the ConnectionManager.
+func (r *ReplicaSet) Statuses() []Status {
+	statuses := make([]Status, len(r.replicas))
+	for i, rep := range r.replicas {
+		statuses[i] = rep.health.get()
+	}
+	return statuses
+}
+
+// Ping succeeds while the primary or any replica can be reached, since
+// either can serve reads.
+func (r *ReplicaSet) Ping(ctx context.Context) error {
+	p, ok := r.primary.(pinger)
+	if !ok {
+		return nil
+	}
+	err := p.Ping(ctx)
+	if err == nil {
+		return nil
+	}
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			return nil
+		}
+	}
+	return err
+}
+
+// Close stops checking the replicas and closes them. The primary is left
+// open, as it is shared through the ConnectionManager.
+func (r *ReplicaSet) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		for _, rep := range r.replicas {
+			rep.db.Close()
+		}
+	})
+}
+
+// GetWithReplicas returns a loader for a primary database that reads from
+// its replicas, or the primary's loader if there are none. The primary must
+// be reachable unless the options are lazy; replicas that are not are read
+// from once they recover. The options apply to the replicas too.
+func (cm *ConnectionManager) GetWithReplicas(dsn string, replicaDSNs []string, options Options) (DBLoader, error) {
+	primary, err := cm.Get(dsn, options)
+	if err != nil || len(replicaDSNs) == 0 {
+		return primary, err
+	}
+
+	key := dsn + "|replicas|" + strings.Join(replicaDSNs, "|") + options.key()
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if m, ok := cm.connections[key]; ok {
+		if m.conn.(*ReplicaSet).primary == primary {
+			m.use()
+			return m.conn, nil
+		}
+		// The primary was closed as unused and has been opened again.
+		cm.evict(key)
+	}
+
+	if err := cm.makeRoom(len(replicaDSNs)); err != nil {
+		return nil, err
+	}
+	replicas := make([]DBLoader, 0, len(replicaDSNs))
+	names := make([]string, 0, len(replicaDSNs))
+	for _, replicaDSN := range replicaDSNs {
+		db, err := cm.open(replicaDSN, options)
+		if err != nil {
+			for _, opened := range replicas {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("replica %s: %w", dsnHost(replicaDSN), err)
+		}
+		replicas = append(replicas, db)
+		names = append(names, dsnHost(replicaDSN))
+	}
+	set := newReplicaSet(primary, replicas, names, healthCheckInterval)
+	cm.connections[key] = newManaged(set)
+	return set, nil
+}
+
+// Returns the host of a DSN, which unlike the DSN is safe to log.
+func dsnHost(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Host != "" {
+		return u.Host
+	}
+	if _, rest, ok := strings.Cut(dsn, "@tcp("); ok {
+		host, _, _ := strings.Cut(rest, ")")
+		return host
+	}
+	if host := keyValueDSNParam(dsn, "host"); host != "" {
+		if port := keyValueDSNParam(dsn, "port"); port != "" {
+			return net.JoinHostPort(host, port)
+		}
+		return host
+	}
+	return "(unknown host)"
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresTLSDSN(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  TLSOptions
+		expected string
+	}{
+		{
+			"CA bundle",
+			TLSOptions{CAFile: "/etc/ca.pem"},
+			"postgres://app@db/shop?connect_timeout=5&sslmode=verify-full&sslrootcert=%2Fetc%2Fca.pem",
+		},
+		{
+			"Client certificate",
+			TLSOptions{CertFile: "/etc/client.crt", KeyFile: "/etc/client.key"},
+			"postgres://app@db/shop?connect_timeout=5&sslcert=%2Fetc%2Fclient.crt&sslkey=%2Fetc%2Fclient.key&sslmode=verify-full",
+		},
+		{
+			"Skip verify",
+			TLSOptions{SkipVerify: true},
+			"postgres://app@db/shop?connect_timeout=5&sslmode=require",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dsn, err := postgresTLSDSN("postgres://app@db/shop?sslmode=disable&connect_timeout=5", tc.options)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, dsn)
+		})
+	}
+}
+
+func TestTLSOptions_Check(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, TLSOptions{}.Check())
+	assert.NoError(t, TLSOptions{CAFile: caFile}.Check())
+	assert.ErrorContains(t, TLSOptions{CAFile: emptyFile}.Check(), "no certificates found in database CA bundle")
+	assert.ErrorContains(t, TLSOptions{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")}.Check(),
+		"failed to load database client certificate")
+}
+
+func TestOpenDB_TLS(t *testing.T) {
+	for _, dsn := range []string{"app:pass@tcp(db:3306)/shop", "postgres://app@db/shop"} {
+		g, err := openDB(dsn, Options{TLS: TLSOptions{SkipVerify: true}})
+		assert.NoError(t, err, dsn)
+		g.Close()
+	}
+
+	_, err := openDB("app:pass@tcp(db:3306)/shop", Options{TLS: TLSOptions{CAFile: "/nonexistent/ca.pem"}})
+	assert.ErrorContains(t, err, "failed to read database CA bundle")
+}
@@ -1,38 +1,162 @@
 package datasource
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PythonicVarun/Stratum/internal/config"
 	"github.com/PythonicVarun/Stratum/internal/database"
+	"github.com/PythonicVarun/Stratum/internal/transform"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
 )
 
 // DataSource defines the interface for any data source (DB, API, etc.).
 type DataSource interface {
-	Fetch(idValue string) ([]byte, error)
+	Fetch(ctx context.Context, idValue string, params Params) ([]byte, error)
 }
 
+// Params carries request-scoped values a source may forward upstream.
+type Params struct {
+	// Allowlisted query parameters from the incoming request.
+	Query url.Values
+
+	// Allowlisted headers from the incoming request.
+	Header http.Header
+}
+
+// ErrNotModified is returned by conditional fetches when the upstream
+// reports that the payload has not changed.
+var ErrNotModified = errors.New("not modified")
+
+// StatusError is returned when an upstream answers with an error status
+// other than 404, which is reported as a missing payload instead.
+type StatusError struct {
+	StatusCode int
+	Status     string // e.g. "503 Service Unavailable"
+	RetryAfter string // the upstream's Retry-After header, if any
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+func statusErrorOf(resp *http.Response) *StatusError {
+	return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: resp.Header.Get("Retry-After")}
+}
+
+// Origin describes the URL a payload was fetched from, along with the
+// validators the origin returned for it.
+type Origin struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"-"`
+
+	ETag          string `json:"etag,omitempty"`
+	LastModified  string `json:"last_modified,omitempty"`
+	ContentLength int64  `json:"content_length"` // -1 if unknown
+}
+
+// OriginSource is implemented by sources whose payloads are fetched from a
+// URL, so cached copies can be revalidated against the origin.
+type OriginSource interface {
+	DataSource
+
+	// FetchWithOrigin is like Fetch but also describes where the payload
+	// came from. The origin is nil if the payload was not fetched from a URL,
+	// except for database rows with a modified column, described by an
+	// origin without a URL.
+	// If previous describes the same URL, the request is made conditional on
+	// its validators, and ErrNotModified is returned if nothing changed.
+	FetchWithOrigin(ctx context.Context, idValue string, params Params, previous *Origin) ([]byte, *Origin, error)
+
+	// CheckOrigin issues a HEAD request to the origin and reports whether
+	// its validators no longer match.
+	CheckOrigin(ctx context.Context, origin *Origin) (bool, error)
+}
+
+// RangeResponse is an upstream's answer to a ranged request. The caller must
+// close Body.
+type RangeResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+}
+
+// RangeSource is implemented by sources that can pass a client's Range
+// request through to an HTTP upstream.
+type RangeSource interface {
+	// FetchRange forwards the Range and If-Range headers in rangeHeader. It
+	// returns nil if the ID was not found or its payload does not come
+	// from a URL.
+	FetchRange(ctx context.Context, idValue string, params Params, rangeHeader http.Header) (*RangeResponse, error)
+}
+
+// IDLister is implemented by sources that can list IDs with a query, used to
+// warm the cache on startup.
+type IDLister interface {
+	ListIDs(ctx context.Context, query string) ([]string, error)
+}
+
+// Pinger is implemented by sources that can check that their database or
+// upstream can be reached, for readiness probes.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Factory creates the source of a project whose SOURCE_TYPE is implemented
+// outside Stratum; see config.RegisterSourceType.
+type Factory func(p config.Project, cfg *config.AppConfig) (DataSource, error)
+
 // Factory function that returns the correct data source based on the project's configuration.
 func NewDataSource(p config.Project, dbManager *database.ConnectionManager, config *config.AppConfig) (DataSource, error) {
 	switch p.SourceType {
 	case "database":
-		db, err := dbManager.Get(p.DB_DSN)
+		db, err := dbManager.GetWithReplicas(p.DB_DSN, p.DBReplicaDSNs, DBOptions(p))
 		if err != nil {
 			return nil, fmt.Errorf("failed to get DB connection: %w", err)
 		}
+		queryChain, err := transform.Parse(p.QueryTransform)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query transform: %w", err)
+		}
+		urls := newURLPolicy(p)
+		client, err := newHTTPClient(p, config.UpstreamTransport, urls)
+		if err != nil {
+			return nil, err
+		}
 		return &DatabaseSource{
-			db:      db,
-			project: p,
-			config:  config,
+			db:         db,
+			project:    p,
+			client:     client,
+			urls:       urls,
+			config:     config,
+			queryChain: queryChain,
 		}, nil
 	case "api":
+		client, err := newHTTPClient(p, config.UpstreamTransport, nil)
+		if err != nil {
+			return nil, err
+		}
 		return &APISource{
 			project: p,
-			client:  &http.Client{},
+			client:  client,
 			config:  config,
 		}, nil
 	default:
@@ -40,66 +164,363 @@ func NewDataSource(p config.Project, dbManager *database.ConnectionManager, conf
 	}
 }
 
+// Creates the HTTP client a project's source uses to reach upstreams. The
+// upstream timeout bounds connecting, the TLS handshake, waiting for
+// response headers and the request as a whole; zero means no timeout. A
+// client certificate and CA bundle are loaded for mTLS when configured. A
+// URL policy, if any, is enforced on redirects and on every connection.
+// Redirects are followed as configured by the project.
+func newHTTPClient(p config.Project, pool config.TransportConfig, urls *urlPolicy) (*http.Client, error) {
+	transport, err := sharedTransport(p, pool, urls != nil && urls.blockPrivate)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       p.UpstreamTimeout,
+		CheckRedirect: redirectPolicy(p, urls),
+	}, nil
+}
+
+// DBOptions returns the TLS, authentication and connection options of a
+// database project's connections.
+func DBOptions(p config.Project) database.Options {
+	options := database.Options{
+		TLS: database.TLSOptions{
+			CAFile:     p.DBCAFile,
+			CertFile:   p.DBTLSCertFile,
+			KeyFile:    p.DBTLSKeyFile,
+			SkipVerify: p.DBTLSSkipVerify,
+		},
+		Lazy:   p.DBConnect != "eager",
+		Driver: p.DBDriver,
+	}
+	switch p.DBAuth {
+	case "rds-iam":
+		options.Password, options.Auth = config.RDSAuthToken, p.DBAuth
+	case "cloudsql-iam":
+		options.Password, options.Auth = config.CloudSQLAuthToken, p.DBAuth
+	}
+	return options
+}
+
+// CheckUpstreamTLS loads a project's client certificate and CA bundle, if
+// any, reporting the first problem found.
+func CheckUpstreamTLS(p config.Project) error {
+	_, err := upstreamTLSConfig(p)
+	return err
+}
+
+// ProbeUpstream checks that an API project's upstream can be reached by
+// sending a HEAD request to the root of its endpoint's host, through the
+// same client (proxy, mTLS, timeouts) the source uses. Any HTTP response
+// counts as reachable; the endpoint itself is not requested, since fetching
+// a made-up ID could have side effects.
+func ProbeUpstream(ctx context.Context, p config.Project, pool config.TransportConfig) error {
+	client, err := newHTTPClient(p, pool, nil)
+	if err != nil {
+		return err
+	}
+	return probeUpstream(ctx, client, p.APIEndpoint)
+}
+
+func probeUpstream(ctx context.Context, client *http.Client, apiEndpoint string) error {
+	endpoint, err := url.Parse(strings.NewReplacer("{", "", "}", "").Replace(apiEndpoint))
+	if err != nil || endpoint.Host == "" {
+		return fmt.Errorf("invalid API endpoint '%s'", apiEndpoint)
+	}
+	probe := *client
+	probe.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	root := &url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host, Path: "/"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, root.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := probe.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Builds the TLS configuration for a project's upstream, or nil to use the
+// defaults.
+func upstreamTLSConfig(p config.Project) (*tls.Config, error) {
+	if p.UpstreamTLSCertFile == "" && p.UpstreamCAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if p.UpstreamTLSCertFile != "" {
+		cert, err := newClientCertificate(p.UpstreamTLSCertFile, p.UpstreamTLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = cert.get
+	}
+	if p.UpstreamCAFile != "" {
+		pem, err := os.ReadFile(p.UpstreamCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upstream CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in upstream CA bundle %s", p.UpstreamCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
 type DatabaseSource struct {
 	db      database.DBLoader
 	project config.Project
+	client  *http.Client // For payloads stored as URLs
+	urls    *urlPolicy   // Hosts those URLs may point at
 	config  *config.AppConfig
+
+	// Applied to the serve column's value as read; see QUERY_TRANSFORM
+	queryChain transform.Chain
+
+	// OnQuery, if set, is called after each row lookup with the ID looked
+	// up, how long the query took and its error, if any.
+	OnQuery func(ctx context.Context, idValue string, elapsed time.Duration, err error)
 }
 
-func (s *DatabaseSource) Fetch(idValue string) ([]byte, error) {
-	data, err := s.db.Fetch(s.project.Table, s.project.IdColumn, s.project.ServeColumn, idValue)
+// Ping checks the database connection. Hosts of payloads stored as URLs
+// are not checked, since they differ by row.
+func (s *DatabaseSource) Ping(ctx context.Context) error {
+	if pinger, ok := s.db.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// DB returns the database the source reads from.
+func (s *DatabaseSource) DB() database.DBLoader {
+	return s.db
+}
+
+func (s *DatabaseSource) Fetch(ctx context.Context, idValue string, params Params) ([]byte, error) {
+	data, _, err := s.FetchWithOrigin(ctx, idValue, params, nil)
+	return data, err
+}
+
+// Reads the serve column of an ID's row, and the modified column if the
+// project has one. IDs of composite keys hold the value of each key column,
+// in route order, separated by "/".
+func (s *DatabaseSource) fetchRow(ctx context.Context, idValue string) ([]byte, time.Time, error) {
+	columns, values := []string{s.project.IdColumn}, []string{idValue}
+	if len(s.project.IdColumns) > 1 {
+		columns = s.project.IdColumns
+		values = strings.SplitN(idValue, "/", len(columns))
+		if len(values) != len(columns) {
+			return nil, time.Time{}, fmt.Errorf("ID '%s' must have a value for each of %s, separated by '/'", idValue, strings.Join(columns, ", "))
+		}
+	}
+	start := time.Now()
+	var data []byte
+	var modified time.Time
+	var err error
+	if s.project.ModifiedColumn != "" {
+		fetcher, ok := s.db.(database.ModifiedFetcher)
+		if !ok {
+			return nil, time.Time{}, fmt.Errorf("database does not support MODIFIED_COLUMN")
+		}
+		data, modified, err = fetcher.FetchModified(ctx, s.project.Table, columns, s.project.ServeColumn, s.project.ModifiedColumn, s.project.WhereExtra, s.project.IDMatch, values)
+	} else {
+		data, err = s.db.Fetch(ctx, s.project.Table, columns, s.project.ServeColumn, s.project.WhereExtra, s.project.IDMatch, values)
+	}
+	if s.OnQuery != nil {
+		s.OnQuery(ctx, idValue, time.Since(start), err)
+	}
+	if err == nil && data != nil && len(s.queryChain) > 0 {
+		data, err = s.queryChain.Transform(data)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("query transform failed: %w", err)
+		}
+	}
+	return data, modified, err
+}
+
+// Describes a row modified at the given time, with its Last-Modified and
+// an ETag that tells apart changes within the same second. It returns nil
+// if the time is unknown.
+func rowOrigin(modified time.Time) *Origin {
+	if modified.IsZero() {
+		return nil
+	}
+	return &Origin{
+		ETag:          `"` + strconv.FormatInt(modified.UnixNano(), 36) + `"`,
+		LastModified:  modified.UTC().Format(http.TimeFormat),
+		ContentLength: -1,
+	}
+}
+
+// FetchWithOrigin reads an ID's row. Payloads stored in the row are
+// described by an origin without a URL when the project has a modified
+// column, and if previous describes the same modification, ErrNotModified
+// is returned. Payloads stored as URLs are fetched from there.
+func (s *DatabaseSource) FetchWithOrigin(ctx context.Context, idValue string, params Params, previous *Origin) ([]byte, *Origin, error) {
+	data, modified, err := s.fetchRow(ctx, idValue)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if data == nil {
-		return nil, nil
+		return nil, nil, nil
 	}
 
-	content := string(data)
-
-	if strings.HasPrefix(content, "data:") {
-		parts := strings.SplitN(content, ",", 2)
-		if len(parts) == 2 && strings.Contains(parts[0], ";base64") {
-			base64Data := parts[1]
-			decodedData, err := base64.StdEncoding.DecodeString(base64Data)
-			if err == nil {
-				return decodedData, nil
-			}
+	data, rawURL, err := s.decodeValue(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rawURL == "" {
+		origin := rowOrigin(modified)
+		if origin != nil && previous != nil && previous.URL == "" && previous.ETag == origin.ETag {
+			return nil, origin, ErrNotModified
 		}
+		return data, origin, nil
 	}
 
-	if strings.HasPrefix(content, "http://") || strings.HasPrefix(content, "https://") {
-		req, err := http.NewRequest("GET", content, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request for URL %s: %w", content, err)
-		}
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request for URL %s: %w", rawURL, err)
+	}
+	if err := s.urls.checkURL(req.URL); err != nil {
+		return nil, nil, err
+	}
+
+	if s.config.ApiClientUserAgent != "" {
+		req.Header.Set("User-Agent", s.config.ApiClientUserAgent)
+	}
+	setUpstreamHeaders(req, s.project)
+	setConditionalHeaders(req, previous)
 
-		if s.config.ApiClientUserAgent != "" {
-			req.Header.Set("User-Agent", s.config.ApiClientUserAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch data from URL %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && previous != nil {
+		return nil, originOf(req, resp).withValidatorsFrom(previous), ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil, nil
 		}
+		return nil, nil, fmt.Errorf("URL fetch returned non-200 status: %w", statusErrorOf(resp))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, originOf(req, resp), nil
+}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
+// Interprets a stored value according to the project's VALUE_ENCODING. For
+// URL values the URL to fetch is returned instead of the payload. Binary
+// serve columns are returned as they are.
+func (s *DatabaseSource) decodeValue(data []byte) ([]byte, string, error) {
+	if s.project.ServeColumnBinary {
+		return data, "", nil
+	}
+	content := string(data)
+	switch s.project.ValueEncoding {
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch data from URL %s: %w", content, err)
+			return nil, "", fmt.Errorf("stored value is not valid base64: %w", err)
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			if resp.StatusCode == http.StatusNotFound {
-				return nil, nil
-			}
-			return nil, fmt.Errorf("URL fetch returned non-200 status: %d", resp.StatusCode)
+		return decoded, "", nil
+	case "data-uri":
+		decoded, ok := decodeDataURI(content)
+		if !ok {
+			return nil, "", fmt.Errorf("stored value is not a valid data URI")
+		}
+		return decoded, "", nil
+	case "url":
+		content = strings.TrimSpace(content)
+		if !isHTTPURL(content) {
+			return nil, "", fmt.Errorf("stored value is not an http(s) URL")
+		}
+		return nil, content, nil
+	case "auto":
+		// Guesses the encoding. Raw values that happen to be valid base64
+		// are decoded too, so this is opt-in.
+		if decoded, ok := decodeDataURI(content); ok {
+			return decoded, "", nil
+		}
+		if isHTTPURL(content) {
+			return nil, content, nil
 		}
-		return io.ReadAll(resp.Body)
+		if decoded, err := base64.StdEncoding.DecodeString(content); err == nil {
+			return decoded, "", nil
+		}
+		return data, "", nil
+	default:
+		return data, "", nil
 	}
+}
 
-	decodedData, err := base64.StdEncoding.DecodeString(content)
-	if err == nil {
-		return decodedData, nil
+// Decodes a data URI, either base64 or percent-encoded.
+func decodeDataURI(content string) ([]byte, bool) {
+	if !strings.HasPrefix(content, "data:") {
+		return nil, false
+	}
+	meta, payload, found := strings.Cut(content[len("data:"):], ",")
+	if !found {
+		return nil, false
 	}
+	if strings.HasSuffix(meta, ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(payload)
+		return decoded, err == nil
+	}
+	decoded, err := url.PathUnescape(payload)
+	return []byte(decoded), err == nil
+}
 
-	return data, nil
+func isHTTPURL(content string) bool {
+	return strings.HasPrefix(content, "http://") || strings.HasPrefix(content, "https://")
+}
+
+// FetchRange passes a Range request through to the URL stored for an ID. It
+// returns nil if the stored payload is not a URL.
+func (s *DatabaseSource) FetchRange(ctx context.Context, idValue string, params Params, rangeHeader http.Header) (*RangeResponse, error) {
+	data, _, err := s.fetchRow(ctx, idValue)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	_, rawURL, err := s.decodeValue(data)
+	if err != nil || rawURL == "" {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for URL %s: %w", rawURL, err)
+	}
+	if err := s.urls.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	if s.config.ApiClientUserAgent != "" {
+		req.Header.Set("User-Agent", s.config.ApiClientUserAgent)
+	}
+	setUpstreamHeaders(req, s.project)
+	return fetchRange(s.client, req, rangeHeader)
+}
+
+func (s *DatabaseSource) ListIDs(ctx context.Context, query string) ([]string, error) {
+	querier, ok := s.db.(database.IDQuerier)
+	if !ok {
+		return nil, fmt.Errorf("database does not support ID queries")
+	}
+	return querier.QueryIDs(ctx, query)
+}
+
+func (s *DatabaseSource) CheckOrigin(ctx context.Context, origin *Origin) (bool, error) {
+	return checkOrigin(ctx, s.client, origin, nil)
 }
 
 type APISource struct {
@@ -108,46 +529,312 @@ type APISource struct {
 	config  *config.AppConfig
 }
 
-func (s *APISource) Fetch(idValue string) ([]byte, error) {
+// Ping probes the upstream like ProbeUpstream.
+func (s *APISource) Ping(ctx context.Context) error {
+	return probeUpstream(ctx, s.client, s.project.APIEndpoint)
+}
+
+func (s *APISource) Fetch(ctx context.Context, idValue string, params Params) ([]byte, error) {
+	data, _, err := s.FetchWithOrigin(ctx, idValue, params, nil)
+	return data, err
+}
+
+// FetchWithOrigin reports an origin only for GET requests, since the
+// response to a POST or PUT cannot be revalidated with HEAD.
+func (s *APISource) FetchWithOrigin(ctx context.Context, idValue string, params Params, previous *Origin) ([]byte, *Origin, error) {
+	req, err := s.newRequest(ctx, idValue, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetURL := req.URL.String()
+	if req.Method == http.MethodGet {
+		setConditionalHeaders(req, previous)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute API request to %s: %w", targetURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && previous != nil {
+		return nil, originOf(req, resp).withValidatorsFrom(previous), ErrNotModified
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("API request to %s returned non-200 status: %w", targetURL, statusErrorOf(resp))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read API response body: %w", err)
+	}
+
+	if req.Method != http.MethodGet {
+		return data, nil, nil
+	}
+	return data, originOf(req, resp), nil
+}
+
+// FetchRange passes a Range request through to the API. Only GET endpoints
+// support ranges.
+func (s *APISource) FetchRange(ctx context.Context, idValue string, params Params, rangeHeader http.Header) (*RangeResponse, error) {
+	req, err := s.newRequest(ctx, idValue, params)
+	if err != nil {
+		return nil, err
+	}
+	if req.Method != http.MethodGet {
+		return nil, nil
+	}
+	return fetchRange(s.client, req, rangeHeader)
+}
+
+// Builds the upstream request for an ID, with forwarded query parameters and
+// headers, the request body and authorization.
+func (s *APISource) newRequest(ctx context.Context, idValue string, params Params) (*http.Request, error) {
 	targetURL := strings.Replace(s.project.APIEndpoint, "{"+s.project.IdColumn+"}", idValue, 1)
 
-	req, err := http.NewRequest("GET", targetURL, nil)
+	if len(params.Query) > 0 {
+		u, err := url.Parse(targetURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API endpoint %s: %w", targetURL, err)
+		}
+		query := u.Query()
+		for key, values := range params.Query {
+			query[key] = values
+		}
+		u.RawQuery = query.Encode()
+		targetURL = u.String()
+	}
+
+	method := s.project.APIMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if s.project.APIBody != "" {
+		body = strings.NewReader(s.renderBody(idValue))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create API request: %w", err)
 	}
 
 	if s.config.ApiClientUserAgent != "" {
-		req.Header.Add("User-Agent", s.config.ApiClientUserAgent)
+		req.Header.Set("User-Agent", s.config.ApiClientUserAgent)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", s.project.APIBodyContentType)
+	}
+	setForwardedHeaders(req, s.project, params.Header)
+	setUpstreamHeaders(req, s.project)
+
+	// Credentials go last, so nothing a client sends can replace them.
+	s.authorize(req)
+	return req, nil
+}
+
+// Copies the request headers forwarded from a client to an upstream
+// request. Headers carrying the project's upstream credentials are never
+// taken from the client, nor are Authorization and Proxy-Authorization
+// unless the project forwards them by name.
+func setForwardedHeaders(req *http.Request, p config.Project, header http.Header) {
+	skip := authHeaders(p)
+	for name, values := range header {
+		if containsHeader(skip, name) {
+			continue
+		}
+		if (name == "Authorization" || name == "Proxy-Authorization") && !containsHeader(p.ForwardHeaders, name) {
+			continue
+		}
+		req.Header.Del(name)
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
 	}
+}
 
-	// Add authorization headers based on the project's config
+// Reports whether a list of header names includes name, ignoring case.
+func containsHeader(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *APISource) CheckOrigin(ctx context.Context, origin *Origin) (bool, error) {
+	return checkOrigin(ctx, s.client, origin, s.authorize)
+}
+
+// Adds authorization headers based on the project's config.
+func (s *APISource) authorize(req *http.Request) {
 	switch s.project.APIAuthType {
 	case "bearer":
 		authHeader := fmt.Sprintf("Bearer %s", s.project.APIAuthSecret)
-		req.Header.Add("Authorization", authHeader)
+		req.Header.Set("Authorization", authHeader)
 	case "header":
-		req.Header.Add(s.project.APIAuthHeaderName, s.project.APIAuthSecret)
+		req.Header.Set(s.project.APIAuthHeaderName, s.project.APIAuthSecret)
+	case "basic":
+		req.SetBasicAuth(s.project.APIAuthUsername, s.project.APIAuthPassword)
+	case "hmac":
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(s.project.APIAuthTimestampHeader, timestamp)
+		req.Header.Set(s.project.APIAuthHeaderName, signRequest(s.project.APIAuthAlgorithm, s.project.APIAuthSecret, timestamp, req.URL))
 	case "none":
 		// No auth header needed
 	}
+}
 
-	resp, err := s.client.Do(req)
+// Signs an upstream request as the hex-encoded HMAC of the timestamp
+// followed by the request path and query.
+func signRequest(algorithm, secret, timestamp string, u *url.URL) string {
+	var newHash func() hash.Hash
+	switch algorithm {
+	case "sha1":
+		newHash = sha1.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		newHash = sha256.New
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(timestamp + u.RequestURI()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Fills the ID into the project's request body template. For JSON bodies the
+// ID is escaped so it can be placed inside a string literal safely.
+func (s *APISource) renderBody(idValue string) string {
+	value := idValue
+	if strings.Contains(s.project.APIBodyContentType, "json") {
+		escaped, _ := json.Marshal(idValue)
+		value = string(escaped[1 : len(escaped)-1])
+	}
+	return strings.ReplaceAll(s.project.APIBody, "{"+s.project.IdColumn+"}", value)
+}
+
+// Describes the origin of a successful GET response. The request headers are
+// kept so revalidation sends the same credentials, but not the ID of the
+// request that caused the fetch.
+func originOf(req *http.Request, resp *http.Response) *Origin {
+	header := req.Header.Clone()
+	header.Del("If-None-Match")
+	header.Del("If-Modified-Since")
+	header.Del("X-Request-ID")
+	return &Origin{
+		URL:           req.URL.String(),
+		Header:        header,
+		ETag:          resp.Header.Get("ETag"),
+		LastModified:  resp.Header.Get("Last-Modified"),
+		ContentLength: resp.ContentLength,
+	}
+}
+
+// Fills in the validators a 304 response left out from the response it
+// revalidated.
+func (o *Origin) withValidatorsFrom(previous *Origin) *Origin {
+	if o.ETag == "" {
+		o.ETag = previous.ETag
+	}
+	if o.LastModified == "" {
+		o.LastModified = previous.LastModified
+	}
+	o.ContentLength = previous.ContentLength
+	return o
+}
+
+// Adds the X-Request-ID of the request being served and a project's static
+// upstream headers to a request, overriding the defaults (such as
+// User-Agent) set before.
+func setUpstreamHeaders(req *http.Request, p config.Project) {
+	if id := utils.RequestID(req.Context()); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	for name, value := range p.UpstreamHeaders {
+		req.Header.Set(name, value)
+	}
+}
+
+// Makes a request conditional on the validators of a previous response from
+// the same URL.
+func setConditionalHeaders(req *http.Request, previous *Origin) {
+	if previous == nil || previous.URL != req.URL.String() {
+		return
+	}
+	if previous.ETag != "" {
+		req.Header.Set("If-None-Match", previous.ETag)
+	}
+	if previous.LastModified != "" {
+		req.Header.Set("If-Modified-Since", previous.LastModified)
+	}
+}
+
+// Issues a HEAD request to an origin and compares its ETag, or its
+// Content-Length if either side has no ETag. A payload that disappeared
+// counts as changed; one without comparable validators does not. If
+// authorize is set, it refreshes credentials such as request signatures.
+func checkOrigin(ctx context.Context, client *http.Client, origin *Origin, authorize func(*http.Request)) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, origin.URL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute API request to %s: %w", targetURL, err)
+		return false, fmt.Errorf("failed to create HEAD request for URL %s: %w", origin.URL, err)
+	}
+	req.Header = origin.Header.Clone()
+	if authorize != nil {
+		authorize(req)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, nil
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to revalidate URL %s: %w", origin.URL, err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return true, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("HEAD request to %s returned non-200 status: %s", origin.URL, resp.Status)
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" && origin.ETag != "" {
+		return etag != origin.ETag, nil
+	}
+	if resp.ContentLength >= 0 && origin.ContentLength >= 0 {
+		return resp.ContentLength != origin.ContentLength, nil
+	}
+	return false, nil
+}
+
+// Sends a request with the client's Range and If-Range headers. Upstreams
+// that ignore the range answer 200 with the full payload, which is passed
+// on as well.
+func fetchRange(client *http.Client, req *http.Request, rangeHeader http.Header) (*RangeResponse, error) {
+	for _, name := range []string{"Range", "If-Range"} {
+		if value := rangeHeader.Get(name); value != "" {
+			req.Header.Set(name, value)
 		}
-		return nil, fmt.Errorf("API request to %s returned non-200 status: %s", targetURL, resp.Status)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read API response body: %w", err)
+		return nil, fmt.Errorf("failed to execute range request to %s: %w", req.URL, err)
 	}
 
-	return body, nil
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		return &RangeResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: resp.Body}, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, nil
+	}
+	resp.Body.Close()
+	return nil, fmt.Errorf("range request to %s returned unexpected status: %w", req.URL, statusErrorOf(resp))
 }
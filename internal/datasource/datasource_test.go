@@ -1,12 +1,34 @@
 package datasource
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
 	"errors"
+	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/internal/transform"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,9 +37,9 @@ type mockDBLoader struct {
 	FetchFunc func(table, idColumn, serveColumn, idValue string) ([]byte, error)
 }
 
-func (m *mockDBLoader) Fetch(table, idColumn, serveColumn, idValue string) ([]byte, error) {
+func (m *mockDBLoader) Fetch(ctx context.Context, table string, idColumns []string, serveColumn, where, match string, idValues []string) ([]byte, error) {
 	if m.FetchFunc != nil {
-		return m.FetchFunc(table, idColumn, serveColumn, idValue)
+		return m.FetchFunc(table, strings.Join(idColumns, ","), serveColumn, strings.Join(idValues, "/"))
 	}
 	return nil, errors.New("FetchFunc not implemented")
 }
@@ -32,7 +54,7 @@ func TestDatabaseSource_Fetch(t *testing.T) {
 			},
 		}
 		ds := &DatabaseSource{db: mockDB}
-		data, err := ds.Fetch("1")
+		data, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("direct_data"), data)
 	})
@@ -44,8 +66,8 @@ func TestDatabaseSource_Fetch(t *testing.T) {
 				return []byte("dGVzdA=="), nil
 			},
 		}
-		ds := &DatabaseSource{db: mockDB}
-		data, err := ds.Fetch("1")
+		ds := &DatabaseSource{db: mockDB, project: config.Project{ValueEncoding: "base64"}}
+		data, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("test"), data)
 	})
@@ -57,8 +79,8 @@ func TestDatabaseSource_Fetch(t *testing.T) {
 				return []byte("data:text/plain;base64,dGVzdA=="), nil
 			},
 		}
-		ds := &DatabaseSource{db: mockDB}
-		data, err := ds.Fetch("1")
+		ds := &DatabaseSource{db: mockDB, project: config.Project{ValueEncoding: "data-uri"}}
+		data, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("test"), data)
 	})
@@ -74,12 +96,86 @@ func TestDatabaseSource_Fetch(t *testing.T) {
 				return []byte(server.URL), nil
 			},
 		}
-		ds := &DatabaseSource{db: mockDB, config: &config.AppConfig{}}
-		data, err := ds.Fetch("1")
+		ds := &DatabaseSource{db: mockDB, project: config.Project{ValueEncoding: "url"}, client: server.Client(), config: &config.AppConfig{}}
+		data, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("http_data"), data)
 	})
 
+	t.Run("Value Encodings", func(t *testing.T) {
+		testCases := []struct {
+			encoding string
+			stored   string
+			expected string
+			err      string
+		}{
+			{"raw", "dGVzdA==", "dGVzdA==", ""},
+			{"base64", "dGVzdA==\n", "test", ""},
+			{"base64", "not base64!", "", "not valid base64"},
+			{"data-uri", "data:text/plain,hello%20world", "hello world", ""},
+			{"data-uri", "dGVzdA==", "", "not a valid data URI"},
+			{"url", "ftp://example.com/file", "", "not an http(s) URL"},
+			{"auto", "dGVzdA==", "test", ""},
+			{"auto", "data:text/plain;base64,dGVzdA==", "test", ""},
+			{"auto", "plain text", "plain text", ""},
+		}
+		for _, tc := range testCases {
+			mockDB := &mockDBLoader{
+				FetchFunc: func(table, idColumn, serveColumn, idValue string) ([]byte, error) {
+					return []byte(tc.stored), nil
+				},
+			}
+			ds := &DatabaseSource{db: mockDB, project: config.Project{ValueEncoding: tc.encoding}}
+			data, err := ds.Fetch(context.Background(), "1", Params{})
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err, "%s %q", tc.encoding, tc.stored)
+				continue
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(data), "%s %q", tc.encoding, tc.stored)
+		}
+	})
+
+	t.Run("Binary Serve Column", func(t *testing.T) {
+		// Bytes that happen to look like base64 or a URL are not touched.
+		for _, stored := range []string{"dGVzdA==", "https://example.com/a.png", "\x89PNG\r\n\x1a\n\x00"} {
+			mockDB := &mockDBLoader{
+				FetchFunc: func(table, idColumn, serveColumn, idValue string) ([]byte, error) {
+					return []byte(stored), nil
+				},
+			}
+			ds := &DatabaseSource{db: mockDB, project: config.Project{ValueEncoding: "auto", ServeColumnBinary: true}}
+			data, err := ds.Fetch(context.Background(), "1", Params{})
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(stored), data)
+		}
+	})
+
+	t.Run("Query Transform", func(t *testing.T) {
+		// A gzipped base64 value is decompressed before it is decoded.
+		var stored bytes.Buffer
+		zw := gzip.NewWriter(&stored)
+		zw.Write([]byte("dGVzdA=="))
+		zw.Close()
+		mockDB := &mockDBLoader{
+			FetchFunc: func(table, idColumn, serveColumn, idValue string) ([]byte, error) {
+				return stored.Bytes(), nil
+			},
+		}
+		chain, err := transform.Parse("gzip-decode")
+		assert.NoError(t, err)
+		ds := &DatabaseSource{db: mockDB, project: config.Project{ValueEncoding: "base64"}, queryChain: chain}
+		data, err := ds.Fetch(context.Background(), "1", Params{})
+		assert.NoError(t, err)
+		assert.Equal(t, "test", string(data))
+
+		mockDB.FetchFunc = func(table, idColumn, serveColumn, idValue string) ([]byte, error) {
+			return []byte("not gzip"), nil
+		}
+		_, err = ds.Fetch(context.Background(), "1", Params{})
+		assert.ErrorContains(t, err, "query transform failed")
+	})
+
 	t.Run("Fetch Error", func(t *testing.T) {
 		mockDB := &mockDBLoader{
 			FetchFunc: func(table, idColumn, serveColumn, idValue string) ([]byte, error) {
@@ -87,12 +183,37 @@ func TestDatabaseSource_Fetch(t *testing.T) {
 			},
 		}
 		ds := &DatabaseSource{db: mockDB}
-		_, err := ds.Fetch("1")
+		_, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.Error(t, err)
 		assert.Equal(t, "db error", err.Error())
 	})
+
+	t.Run("Composite Key", func(t *testing.T) {
+		loader := &keyRecorder{}
+		ds := &DatabaseSource{db: loader, project: config.Project{IdColumn: "user_id,tenant_id", IdColumns: []string{"tenant_id", "user_id"}, ValueEncoding: "raw"}}
+		data, err := ds.Fetch(context.Background(), "acme/docs/42", Params{})
+		assert.NoError(t, err)
+		assert.Equal(t, "row", string(data))
+		assert.Equal(t, []string{"tenant_id", "user_id"}, loader.columns)
+		assert.Equal(t, []string{"acme", "docs/42"}, loader.values)
+
+		_, err = ds.Fetch(context.Background(), "42", Params{})
+		assert.ErrorContains(t, err, "must have a value for each of tenant_id, user_id")
+	})
+}
+
+// keyRecorder records the key columns and values of the last fetch.
+type keyRecorder struct {
+	columns, values []string
+}
+
+func (k *keyRecorder) Fetch(ctx context.Context, table string, idColumns []string, serveColumn, where, match string, idValues []string) ([]byte, error) {
+	k.columns, k.values = idColumns, idValues
+	return []byte("row"), nil
 }
 
+func (k *keyRecorder) Close() {}
+
 func TestAPISource_Fetch(t *testing.T) {
 	t.Run("Successful Fetch", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -105,7 +226,7 @@ func TestAPISource_Fetch(t *testing.T) {
 		cfg := &config.AppConfig{ApiClientUserAgent: "test-agent"}
 		ds := &APISource{project: p, client: server.Client(), config: cfg}
 
-		data, err := ds.Fetch("1")
+		data, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("api_data"), data)
 	})
@@ -125,7 +246,7 @@ func TestAPISource_Fetch(t *testing.T) {
 		}
 		ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
 
-		data, err := ds.Fetch("1")
+		data, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("authed_data"), data)
 	})
@@ -146,11 +267,63 @@ func TestAPISource_Fetch(t *testing.T) {
 		}
 		ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
 
-		data, err := ds.Fetch("1")
+		data, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.NoError(t, err)
 		assert.Equal(t, []byte("header_authed_data"), data)
 	})
 
+	t.Run("Basic Auth", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "legacy", username)
+			assert.Equal(t, "s3cret", password)
+			w.Write([]byte("basic_authed_data"))
+		}))
+		defer server.Close()
+
+		p := config.Project{
+			APIEndpoint:     server.URL,
+			IdColumn:        "id",
+			APIAuthType:     "basic",
+			APIAuthUsername: "legacy",
+			APIAuthPassword: "s3cret",
+		}
+		ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
+
+		data, err := ds.Fetch(context.Background(), "1", Params{})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("basic_authed_data"), data)
+	})
+
+	t.Run("HMAC Signing", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timestamp := r.Header.Get("X-Origin-Time")
+			assert.NotEmpty(t, timestamp)
+
+			mac := hmac.New(sha256.New, []byte("signing-key"))
+			mac.Write([]byte(timestamp + "/assets/1?v=2"))
+			assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Origin-Signature"))
+			w.Write([]byte("signed_data"))
+		}))
+		defer server.Close()
+
+		p := config.Project{
+			APIEndpoint:            server.URL + "/assets/{id}?v=2",
+			IdColumn:               "id",
+			APIAuthType:            "hmac",
+			APIAuthSecret:          "signing-key",
+			APIAuthHeaderName:      "X-Origin-Signature",
+			APIAuthTimestampHeader: "X-Origin-Time",
+			APIAuthAlgorithm:       "sha256",
+		}
+		ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
+
+		data, err := ds.Fetch(context.Background(), "1", Params{})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("signed_data"), data)
+	})
+
 	t.Run("Not Found", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusNotFound)
@@ -160,7 +333,7 @@ func TestAPISource_Fetch(t *testing.T) {
 		p := config.Project{APIEndpoint: server.URL, IdColumn: "id"}
 		ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
 
-		data, err := ds.Fetch("1")
+		data, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.NoError(t, err)
 		assert.Nil(t, data)
 	})
@@ -174,8 +347,386 @@ func TestAPISource_Fetch(t *testing.T) {
 		p := config.Project{APIEndpoint: server.URL, IdColumn: "id"}
 		ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
 
-		_, err := ds.Fetch("1")
+		_, err := ds.Fetch(context.Background(), "1", Params{})
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "non-200 status")
 	})
 }
+
+func TestAPISource_QueryParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/avatars/1", r.URL.Path)
+		assert.Equal(t, "64", r.URL.Query().Get("size"))
+		assert.Equal(t, "json", r.URL.Query().Get("format"))
+		w.Write([]byte("sized"))
+	}))
+	defer server.Close()
+
+	p := config.Project{APIEndpoint: server.URL + "/avatars/{id}?format=json", IdColumn: "id"}
+	ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
+
+	data, err := ds.Fetch(context.Background(), "1", Params{Query: url.Values{"size": {"64"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("sized"), data)
+}
+
+func TestAPISource_PostBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	p := config.Project{
+		APIEndpoint:        server.URL + "/lookup",
+		IdColumn:           "id",
+		APIMethod:          "POST",
+		APIBody:            `{"query": {"id": "{id}"}}`,
+		APIBodyContentType: "application/json",
+	}
+	ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
+
+	data, err := ds.Fetch(context.Background(), `a"b`, Params{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"query": {"id": "a\"b"}}`, string(data))
+}
+
+func TestAPISource_UpstreamHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "stratum", r.Header.Get("X-Internal-Caller"))
+		assert.Equal(t, "custom-agent", r.Header.Get("User-Agent"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "req-42", r.Header.Get("X-Request-ID"))
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	p := config.Project{
+		APIEndpoint:   server.URL + "/{id}",
+		IdColumn:      "id",
+		APIAuthType:   "bearer",
+		APIAuthSecret: "token",
+		UpstreamHeaders: map[string]string{
+			"X-Internal-Caller": "stratum",
+			"User-Agent":        "custom-agent",
+			"Authorization":     "overridden by auth",
+		},
+	}
+	ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{ApiClientUserAgent: "stratum/1.0"}}
+
+	data, err := ds.Fetch(utils.WithRequestID(context.Background(), "req-42"), "1", Params{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ok"), data)
+}
+
+func TestAPISource_ForwardedHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	forwarded := http.Header{"X-Tenant": {"acme"}, "Authorization": {"Bearer client"}}
+	p := config.Project{APIEndpoint: server.URL + "/{id}", IdColumn: "id", APIAuthType: "bearer", APIAuthSecret: "token"}
+	ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
+	_, err := ds.Fetch(context.Background(), "1", Params{Header: forwarded})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", received.Get("X-Tenant"))
+	assert.Equal(t, []string{"Bearer token"}, received.Values("Authorization"), "credentials are never the client's")
+
+	p.APIAuthType = "none"
+	ds.project = p
+	_, err = ds.Fetch(context.Background(), "1", Params{Header: forwarded})
+	assert.NoError(t, err)
+	assert.Empty(t, received.Values("Authorization"), "not forwarded unless listed")
+
+	p.ForwardHeaders = []string{"X-Tenant", "authorization"}
+	ds.project = p
+	_, err = ds.Fetch(context.Background(), "1", Params{Header: forwarded})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer client"}, received.Values("Authorization"))
+}
+
+func TestCheckOrigin(t *testing.T) {
+	etag, length := `"v1"`, "7"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, http.MethodHead, r.Method)
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		w.Header().Set("Content-Length", length)
+	}))
+	defer server.Close()
+
+	origin := &Origin{
+		URL:           server.URL + "/image.png",
+		Header:        http.Header{"X-Api-Key": {"secret"}},
+		ETag:          `"v1"`,
+		ContentLength: 7,
+	}
+
+	changed, err := checkOrigin(context.Background(), server.Client(), origin, nil)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	etag = `"v2"`
+	changed, err = checkOrigin(context.Background(), server.Client(), origin, nil)
+	assert.NoError(t, err)
+	assert.True(t, changed, "ETag changed")
+
+	etag, length = "", "8"
+	changed, err = checkOrigin(context.Background(), server.Client(), origin, nil)
+	assert.NoError(t, err)
+	assert.True(t, changed, "Content-Length changed without ETag")
+
+	changed, err = checkOrigin(context.Background(), server.Client(), &Origin{URL: server.URL + "/gone"}, nil)
+	assert.NoError(t, err)
+	assert.True(t, changed, "origin disappeared")
+}
+
+func TestAPISource_ConditionalFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2026 00:00:00 GMT")
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	p := config.Project{APIEndpoint: server.URL + "/items/{id}", IdColumn: "id"}
+	ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
+
+	data, origin, err := ds.FetchWithOrigin(context.Background(), "1", Params{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("payload"), data)
+	assert.Equal(t, `"v1"`, origin.ETag)
+	assert.Equal(t, "Wed, 01 Jan 2026 00:00:00 GMT", origin.LastModified)
+
+	data, revalidated, err := ds.FetchWithOrigin(context.Background(), "1", Params{}, origin)
+	assert.ErrorIs(t, err, ErrNotModified)
+	assert.Nil(t, data)
+	assert.Equal(t, `"v1"`, revalidated.ETag, "validators carry over from the previous origin")
+	assert.Empty(t, revalidated.Header.Get("If-None-Match"))
+
+	// Validators of a different URL are not sent.
+	data, _, err = ds.FetchWithOrigin(context.Background(), "2", Params{}, origin)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("payload"), data)
+}
+
+// modifiedRow is a database whose only row was last modified at a given
+// time.
+type modifiedRow struct {
+	mockDBLoader
+	modified time.Time
+}
+
+func (m *modifiedRow) FetchModified(ctx context.Context, table string, idColumns []string, serveColumn, modifiedColumn, where, match string, idValues []string) ([]byte, time.Time, error) {
+	return []byte("row"), m.modified, nil
+}
+
+func TestDatabaseSource_ModifiedColumn(t *testing.T) {
+	db := &modifiedRow{modified: time.Date(2026, 3, 1, 12, 30, 0, 500, time.UTC)}
+	ds := &DatabaseSource{db: db, project: config.Project{IdColumn: "id", ModifiedColumn: "updated_at"}, config: &config.AppConfig{}}
+
+	data, origin, err := ds.FetchWithOrigin(context.Background(), "1", Params{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("row"), data)
+	assert.Empty(t, origin.URL)
+	assert.Equal(t, "Sun, 01 Mar 2026 12:30:00 GMT", origin.LastModified)
+	assert.NotEmpty(t, origin.ETag)
+
+	data, revalidated, err := ds.FetchWithOrigin(context.Background(), "1", Params{}, origin)
+	assert.ErrorIs(t, err, ErrNotModified)
+	assert.Nil(t, data)
+	assert.Equal(t, origin, revalidated)
+
+	// A change within the same second is still noticed.
+	db.modified = db.modified.Add(time.Millisecond)
+	data, changed, err := ds.FetchWithOrigin(context.Background(), "1", Params{}, origin)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("row"), data)
+	assert.Equal(t, origin.LastModified, changed.LastModified)
+	assert.NotEqual(t, origin.ETag, changed.ETag)
+
+	// Rows without a modification time have no validators.
+	db.modified = time.Time{}
+	_, origin, err = ds.FetchWithOrigin(context.Background(), "1", Params{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, origin)
+
+	ds.db = &mockDBLoader{}
+	_, _, err = ds.FetchWithOrigin(context.Background(), "1", Params{}, nil)
+	assert.EqualError(t, err, "database does not support MODIFIED_COLUMN")
+}
+
+func TestAPISource_ContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p := config.Project{APIEndpoint: server.URL + "/{id}", IdColumn: "id"}
+	ds := &APISource{project: p, client: server.Client(), config: &config.AppConfig{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := ds.Fetch(ctx, "1", Params{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the client going away cancels the upstream request")
+}
+
+func TestProbeUpstream(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer server.Close()
+
+	p := config.Project{APIEndpoint: server.URL + "/items/{id}"}
+	assert.NoError(t, ProbeUpstream(context.Background(), p, config.TransportConfig{}))
+	assert.Equal(t, []string{"HEAD /"}, requests, "only the host's root is requested, without following redirects")
+
+	server.Close()
+	assert.Error(t, ProbeUpstream(context.Background(), p, config.TransportConfig{}))
+
+	p.APIEndpoint = "/relative/{id}"
+	assert.ErrorContains(t, ProbeUpstream(context.Background(), p, config.TransportConfig{}), "invalid API endpoint")
+}
+
+func TestAPISource_UpstreamTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p := config.Project{APIEndpoint: server.URL + "/{id}", IdColumn: "id", UpstreamTimeout: 50 * time.Millisecond}
+	client, err := newHTTPClient(p, config.TransportConfig{}, nil)
+	assert.NoError(t, err)
+	ds := &APISource{project: p, client: client, config: &config.AppConfig{}}
+
+	start := time.Now()
+	_, err = ds.Fetch(context.Background(), "1", Params{})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// Writes a self-signed client certificate and its key as PEM files.
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stratum"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return cert, certFile, keyFile
+}
+
+func TestAPISource_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("mtls_data"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+
+	p := config.Project{
+		SourceType:          "api",
+		APIEndpoint:         server.URL + "/{id}",
+		IdColumn:            "id",
+		UpstreamCAFile:      caFile,
+		UpstreamTLSCertFile: certFile,
+		UpstreamTLSKeyFile:  keyFile,
+	}
+	ds, err := NewDataSource(p, nil, &config.AppConfig{})
+	assert.NoError(t, err)
+
+	data, err := ds.Fetch(context.Background(), "1", Params{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("mtls_data"), data)
+
+	t.Run("Without Client Certificate", func(t *testing.T) {
+		p.UpstreamTLSCertFile, p.UpstreamTLSKeyFile = "", ""
+		ds, err := NewDataSource(p, nil, &config.AppConfig{})
+		assert.NoError(t, err)
+		_, err = ds.Fetch(context.Background(), "1", Params{})
+		assert.Error(t, err)
+	})
+
+	t.Run("Missing Files", func(t *testing.T) {
+		p.UpstreamCAFile = filepath.Join(dir, "missing.pem")
+		_, err := NewDataSource(p, nil, &config.AppConfig{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "upstream CA bundle")
+	})
+}
+
+func TestDatabaseSource_FetchRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "clip.mp3", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer server.Close()
+
+	stored := server.URL + "/clip.mp3"
+	mockDB := &mockDBLoader{
+		FetchFunc: func(table, idColumn, serveColumn, idValue string) ([]byte, error) {
+			return []byte(stored), nil
+		},
+	}
+	ds := &DatabaseSource{db: mockDB, project: config.Project{ValueEncoding: "auto"}, client: server.Client(), config: &config.AppConfig{}}
+
+	resp, err := ds.FetchRange(context.Background(), "1", Params{}, http.Header{"Range": {"bytes=2-4"}})
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "234", string(body))
+
+	stored = "aGVsbG8="
+	resp, err = ds.FetchRange(context.Background(), "1", Params{}, http.Header{"Range": {"bytes=2-4"}})
+	assert.NoError(t, err)
+	assert.Nil(t, resp, "payloads stored inline have no upstream to range")
+}
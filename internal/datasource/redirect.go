@@ -0,0 +1,72 @@
+package datasource
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+)
+
+// Builds the redirect policy of a project's upstream client. It limits the
+// number of hops, enforces the URL policy (if any) on every hop and decides
+// whether credentials, and the static upstream headers that often carry
+// them, follow redirects to another origin.
+func redirectPolicy(p config.Project, urls *urlPolicy) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > p.UpstreamMaxRedirects {
+			if p.UpstreamMaxRedirects == 0 {
+				// Hand the redirect back to the caller, which reports it
+				// as an unexpected status.
+				return http.ErrUseLastResponse
+			}
+			return fmt.Errorf("stopped after %d redirects", p.UpstreamMaxRedirects)
+		}
+		if err := urls.checkURL(req.URL); err != nil {
+			return err
+		}
+
+		first := via[0]
+		if sameOrigin(req.URL, first.URL) {
+			return nil
+		}
+		for _, name := range crossOriginHeaders(p) {
+			if p.UpstreamRedirectAuth == "keep" {
+				if values := first.Header.Values(name); len(values) > 0 {
+					req.Header[http.CanonicalHeaderKey(name)] = values
+				}
+			} else {
+				req.Header.Del(name)
+			}
+		}
+		return nil
+	}
+}
+
+// Returns the headers a redirect to another origin strips, unless
+// credentials are kept: those carrying the project's upstream credentials
+// and its static upstream headers, such as X-Api-Key.
+func crossOriginHeaders(p config.Project) []string {
+	names := authHeaders(p)
+	for name := range p.UpstreamHeaders {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Returns the headers carrying a project's upstream credentials.
+func authHeaders(p config.Project) []string {
+	switch p.APIAuthType {
+	case "bearer", "basic":
+		return []string{"Authorization"}
+	case "header":
+		return []string{p.APIAuthHeaderName}
+	case "hmac":
+		return []string{p.APIAuthHeaderName, p.APIAuthTimestampHeader}
+	}
+	return nil
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
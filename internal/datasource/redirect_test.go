@@ -0,0 +1,78 @@
+package datasource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	var received, receivedTenant string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-Api-Key")
+		receivedTenant = r.Header.Get("X-Tenant-Key")
+		w.Write([]byte("moved"))
+	}))
+	defer other.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items/1":
+			http.Redirect(w, r, "/items/1/v2", http.StatusFound)
+		case "/items/1/v2":
+			assert.Equal(t, "secret", r.Header.Get("X-Api-Key"), "same-origin redirects keep credentials")
+			assert.Equal(t, "acme", r.Header.Get("X-Tenant-Key"))
+			http.Redirect(w, r, other.URL+"/items/1", http.StatusFound)
+		}
+	}))
+	defer origin.Close()
+
+	fetch := func(configure func(p *config.Project)) ([]byte, error) {
+		p := config.Project{
+			APIEndpoint:          origin.URL + "/items/{id}",
+			IdColumn:             "id",
+			APIAuthType:          "header",
+			APIAuthHeaderName:    "X-Api-Key",
+			APIAuthSecret:        "secret",
+			UpstreamMaxRedirects: 10,
+			UpstreamRedirectAuth: "strip",
+			UpstreamHeaders:      map[string]string{"X-Tenant-Key": "acme"},
+		}
+		configure(&p)
+		client, err := newHTTPClient(p, config.TransportConfig{}, nil)
+		assert.NoError(t, err)
+		ds := &APISource{project: p, client: client, config: &config.AppConfig{}}
+		return ds.Fetch(context.Background(), "1", Params{})
+	}
+
+	t.Run("Strip Credentials Across Origins", func(t *testing.T) {
+		received, receivedTenant = "unset", "unset"
+		data, err := fetch(func(p *config.Project) {})
+		assert.NoError(t, err)
+		assert.Equal(t, "moved", string(data))
+		assert.Empty(t, received)
+		assert.Empty(t, receivedTenant, "static upstream headers are stripped too")
+	})
+
+	t.Run("Keep Credentials", func(t *testing.T) {
+		data, err := fetch(func(p *config.Project) { p.UpstreamRedirectAuth = "keep" })
+		assert.NoError(t, err)
+		assert.Equal(t, "moved", string(data))
+		assert.Equal(t, "secret", received)
+		assert.Equal(t, "acme", receivedTenant)
+	})
+
+	t.Run("Hop Limit", func(t *testing.T) {
+		_, err := fetch(func(p *config.Project) { p.UpstreamMaxRedirects = 1 })
+		assert.ErrorContains(t, err, "stopped after 1 redirects")
+	})
+
+	t.Run("Not Followed", func(t *testing.T) {
+		_, err := fetch(func(p *config.Project) { p.UpstreamMaxRedirects = 0 })
+		assert.ErrorContains(t, err, "302")
+	})
+}
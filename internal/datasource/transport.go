@@ -0,0 +1,187 @@
+package datasource
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/PythonicVarun/Stratum/pkg/utils"
+)
+
+// transportKey holds the connection settings of an upstream transport.
+// Projects with equal settings share a transport, and so its idle
+// connections. The CA bundle is told by its content, so a bundle replaced
+// in place gets a new transport on the next reload; client certificates
+// are loaded again by the transport itself when their files change.
+type transportKey struct {
+	pool         config.TransportConfig
+	timeout      time.Duration
+	certFile     string
+	keyFile      string
+	caFile       string
+	caHash       [sha256.Size]byte
+	proxy        string
+	blockPrivate bool
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[transportKey]*http.Transport)
+	// Transports asked for since the last PruneTransports
+	transportsUsed = make(map[transportKey]bool)
+)
+
+// PruneTransports forgets the transports no data source was created with
+// since it was last called, and closes their idle connections. Servers call
+// it once the sources of a new configuration are in place, so transports of
+// removed projects and replaced CA bundles do not pile up. Requests still
+// using a forgotten transport finish normally.
+func PruneTransports() {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	for key, transport := range transports {
+		if !transportsUsed[key] {
+			transport.CloseIdleConnections()
+			delete(transports, key)
+		}
+	}
+	transportsUsed = make(map[transportKey]bool)
+}
+
+// Returns the transport for a project's connection settings, creating it on
+// first use.
+func sharedTransport(p config.Project, pool config.TransportConfig, blockPrivate bool) (*http.Transport, error) {
+	key := transportKey{
+		pool:         pool,
+		timeout:      p.UpstreamTimeout,
+		certFile:     p.UpstreamTLSCertFile,
+		keyFile:      p.UpstreamTLSKeyFile,
+		caFile:       p.UpstreamCAFile,
+		proxy:        p.UpstreamProxy,
+		blockPrivate: blockPrivate,
+	}
+	if p.UpstreamCAFile != "" {
+		// A bundle that cannot be read fails in upstreamTLSConfig.
+		if pem, err := os.ReadFile(p.UpstreamCAFile); err == nil {
+			key.caHash = sha256.Sum256(pem)
+		}
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	transportsUsed[key] = true
+	if transport, ok := transports[key]; ok {
+		return transport, nil
+	}
+
+	tlsConfig, err := upstreamTLSConfig(p)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   p.UpstreamTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch p.UpstreamProxy {
+	case "":
+	case "none":
+		transport.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(p.UpstreamProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	// Through a project proxy, only the proxy's address is dialed. Targets
+	// are then checked by host name alone. Without one, HTTP_PROXY and
+	// HTTPS_PROXY are ignored, so the addresses checked are the targets'.
+	if blockPrivate && (p.UpstreamProxy == "" || p.UpstreamProxy == "none") {
+		transport.Proxy = nil
+		dialer.Control = denyPrivateAddresses
+	}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = p.UpstreamTimeout
+	transport.ResponseHeaderTimeout = p.UpstreamTimeout
+	transport.TLSClientConfig = tlsConfig
+	if pool.MaxIdleConns > 0 {
+		transport.MaxIdleConns = pool.MaxIdleConns
+	}
+	if pool.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	}
+	transport.MaxConnsPerHost = pool.MaxConnsPerHost
+	if pool.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = pool.IdleConnTimeout
+	}
+	transport.DisableKeepAlives = pool.DisableKeepAlives
+
+	transports[key] = transport
+	return transport, nil
+}
+
+// Serves an upstream client certificate, loading it again when its files
+// change, so certificates rotated in place are used without a restart.
+type clientCertificate struct {
+	certFile, keyFile string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time // latest modification time of the two files
+}
+
+func newClientCertificate(certFile, keyFile string) (*clientCertificate, error) {
+	c := &clientCertificate{certFile: certFile, keyFile: keyFile}
+	if _, err := c.get(nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Implements tls.Config.GetClientCertificate. A certificate that fails to
+// load is logged and the previous one kept.
+func (c *clientCertificate) get(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var modified time.Time
+	for _, path := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			if c.cert != nil {
+				return c.cert, nil
+			}
+			return nil, fmt.Errorf("failed to load upstream client certificate: %w", err)
+		}
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	if c.cert != nil && modified.Equal(c.modified) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			utils.StratumLog("ERROR", "Failed to load the renewed upstream client certificate %s, keeping the previous one: %v", c.certFile, err)
+			c.modified = modified
+			return c.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load upstream client certificate: %w", err)
+	}
+	if c.cert != nil {
+		utils.StratumLog("INFO", "Loaded the renewed upstream client certificate %s.", c.certFile)
+	}
+	c.cert, c.modified = &cert, modified
+	return c.cert, nil
+}
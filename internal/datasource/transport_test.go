@@ -0,0 +1,156 @@
+package datasource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedTransport(t *testing.T) {
+	pool := config.TransportConfig{
+		MaxIdleConns:        50,
+		MaxIdleConnsPerHost: 8,
+		MaxConnsPerHost:     20,
+		IdleConnTimeout:     time.Minute,
+	}
+	avatars := config.Project{Name: "avatars", UpstreamTimeout: 5 * time.Second}
+	docs := config.Project{Name: "docs", UpstreamTimeout: 5 * time.Second}
+
+	first, err := sharedTransport(avatars, pool, false)
+	assert.NoError(t, err)
+	second, err := sharedTransport(docs, pool, false)
+	assert.NoError(t, err)
+	assert.Same(t, first, second, "projects with the same settings share connections")
+
+	assert.Equal(t, 50, first.MaxIdleConns)
+	assert.Equal(t, 8, first.MaxIdleConnsPerHost)
+	assert.Equal(t, 20, first.MaxConnsPerHost)
+	assert.Equal(t, time.Minute, first.IdleConnTimeout)
+	assert.Equal(t, 5*time.Second, first.ResponseHeaderTimeout)
+
+	docs.UpstreamTimeout = time.Second
+	other, err := sharedTransport(docs, pool, false)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, other)
+
+	guarded, err := sharedTransport(avatars, pool, true)
+	assert.NoError(t, err)
+	assert.NotSame(t, first, guarded, "private address checks need their own dialer")
+}
+
+func TestSharedTransport_Proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Proxied requests carry the absolute target URL.
+		w.Write([]byte("via proxy: " + r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	p := config.Project{
+		APIEndpoint:   "http://upstream.internal/items/{id}",
+		IdColumn:      "id",
+		UpstreamProxy: proxy.URL,
+	}
+	client, err := newHTTPClient(p, config.TransportConfig{}, nil)
+	assert.NoError(t, err)
+	ds := &APISource{project: p, client: client, config: &config.AppConfig{}}
+
+	data, err := ds.Fetch(context.Background(), "7", Params{})
+	assert.NoError(t, err)
+	assert.Equal(t, "via proxy: http://upstream.internal/items/7", string(data))
+
+	p.UpstreamProxy = "none"
+	direct, err := sharedTransport(p, config.TransportConfig{}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, direct.Proxy, "environment proxies are bypassed")
+
+	// Private address checks dial the targets themselves, not a proxy
+	// from the environment.
+	p.UpstreamProxy = ""
+	env, err := sharedTransport(p, config.TransportConfig{}, false)
+	assert.NoError(t, err)
+	assert.NotNil(t, env.Proxy)
+	guarded, err := sharedTransport(p, config.TransportConfig{}, true)
+	assert.NoError(t, err)
+	assert.Nil(t, guarded.Proxy)
+}
+
+func TestPruneTransports(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	_, certFile, _ := writeClientCert(t, dir)
+	pem, _ := os.ReadFile(certFile)
+	os.WriteFile(caFile, pem, 0600)
+
+	pool := config.TransportConfig{MaxIdleConns: 7}
+	kept := config.Project{Name: "kept", UpstreamTimeout: 3 * time.Second}
+	removed := config.Project{Name: "removed", UpstreamTimeout: 4 * time.Second}
+	bundled := config.Project{Name: "bundled", UpstreamTimeout: 3 * time.Second, UpstreamCAFile: caFile}
+
+	first, err := sharedTransport(kept, pool, false)
+	assert.NoError(t, err)
+	_, err = sharedTransport(removed, pool, false)
+	assert.NoError(t, err)
+	ca, err := sharedTransport(bundled, pool, false)
+	assert.NoError(t, err)
+	PruneTransports()
+
+	// A reload that no longer has the second project, and a CA bundle
+	// replaced in place
+	_, otherCert, _ := writeClientCert(t, t.TempDir())
+	pem, _ = os.ReadFile(otherCert)
+	os.WriteFile(caFile, pem, 0600)
+	again, err := sharedTransport(kept, pool, false)
+	assert.NoError(t, err)
+	assert.Same(t, first, again)
+	renewed, err := sharedTransport(bundled, pool, false)
+	assert.NoError(t, err)
+	assert.NotSame(t, ca, renewed, "a changed CA bundle gets a new transport")
+	PruneTransports()
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	assert.Len(t, transportsWith(pool), 2)
+}
+
+// Returns the cached transports of a connection pool.
+func transportsWith(pool config.TransportConfig) []*http.Transport {
+	var found []*http.Transport
+	for key, transport := range transports {
+		if key.pool == pool {
+			found = append(found, transport)
+		}
+	}
+	return found
+}
+
+func TestClientCertificate_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	first, certFile, keyFile := writeClientCert(t, dir)
+
+	c, err := newClientCertificate(certFile, keyFile)
+	assert.NoError(t, err)
+	cert, err := c.get(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Raw, cert.Certificate[0])
+
+	second, _, _ := writeClientCert(t, dir)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	cert, err = c.get(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, second.Raw, cert.Certificate[0], "a rotated certificate is loaded again")
+
+	os.WriteFile(keyFile, []byte("garbage"), 0600)
+	later = later.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	cert, err = c.get(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, second.Raw, cert.Certificate[0], "a broken renewal keeps the previous certificate")
+}
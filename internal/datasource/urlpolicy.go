@@ -0,0 +1,102 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+)
+
+// ErrURLNotAllowed is returned when a URL stored in the database points at a
+// host the project's URL policy refuses.
+var ErrURLNotAllowed = errors.New("URL not allowed")
+
+// urlPolicy restricts the hosts URLs stored in the database may point at, so
+// a tampered row cannot make Stratum probe internal services.
+type urlPolicy struct {
+	allowed      []string
+	denied       []string
+	blockPrivate bool
+}
+
+// Returns the URL policy of a project, or nil if it has none.
+func newURLPolicy(p config.Project) *urlPolicy {
+	if len(p.URLAllowedHosts) == 0 && len(p.URLDeniedHosts) == 0 && !p.URLBlockPrivate {
+		return nil
+	}
+	return &urlPolicy{
+		allowed:      p.URLAllowedHosts,
+		denied:       p.URLDeniedHosts,
+		blockPrivate: p.URLBlockPrivate,
+	}
+}
+
+// Checks the host of a URL against the allow and deny lists. Hostnames are
+// checked against private ranges again once resolved, see
+// denyPrivateAddresses.
+func (u *urlPolicy) checkURL(target *url.URL) error {
+	if u == nil {
+		return nil
+	}
+	host := strings.ToLower(target.Hostname())
+	if matchHost(u.denied, host) {
+		return fmt.Errorf("%w: host %s is denied", ErrURLNotAllowed, host)
+	}
+	if len(u.allowed) > 0 && !matchHost(u.allowed, host) {
+		return fmt.Errorf("%w: host %s is not allowed", ErrURLNotAllowed, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && u.blockPrivate && isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s is a private address", ErrURLNotAllowed, host)
+	}
+	return nil
+}
+
+// Refuses connections to private addresses. It runs after DNS resolution,
+// so hostnames resolving (or rebinding) to internal addresses are caught.
+func denyPrivateAddresses(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s is a private address", ErrURLNotAllowed, host)
+	}
+	return nil
+}
+
+// Reports whether a host matches a list of hostnames, "*.domain" wildcards
+// and CIDR ranges.
+func matchHost(patterns []string, host string) bool {
+	ip := net.ParseIP(host)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case strings.Contains(pattern, "/"):
+			if _, network, err := net.ParseCIDR(pattern); err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		case pattern == host:
+			return true
+		}
+	}
+	return false
+}
+
+// Shared address space of carrier-grade NAT (RFC 6598), also used for
+// internal services such as Alibaba Cloud's metadata endpoint at
+// 100.100.100.200 and Tailscale addresses
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip)
+}
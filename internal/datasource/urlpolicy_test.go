@@ -0,0 +1,91 @@
+package datasource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PythonicVarun/Stratum/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLPolicy_CheckURL(t *testing.T) {
+	policy := newURLPolicy(config.Project{
+		URLAllowedHosts: []string{"cdn.example.com", "*.images.example.com", "203.0.113.0/24"},
+		URLDeniedHosts:  []string{"private.images.example.com"},
+		URLBlockPrivate: true,
+	})
+
+	testCases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://cdn.example.com/a.png", true},
+		{"https://CDN.example.com/a.png", true},
+		{"https://eu.images.example.com/a.png", true},
+		{"https://203.0.113.7/a.png", true},
+		{"https://private.images.example.com/a.png", false},
+		{"https://example.com/a.png", false},
+		{"https://images.example.com.evil.test/a.png", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://100.100.100.200/latest/meta-data/", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			target, _ := url.Parse(tc.url)
+			err := policy.checkURL(target)
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrURLNotAllowed)
+			}
+		})
+	}
+
+	assert.Nil(t, newURLPolicy(config.Project{}), "no policy without settings")
+
+	blockOnly := newURLPolicy(config.Project{URLBlockPrivate: true})
+	for _, host := range []string{"10.0.0.1", "100.100.100.200", "100.64.0.1", "[fd00::1]"} {
+		target, _ := url.Parse("http://" + host + "/")
+		assert.ErrorIs(t, blockOnly.checkURL(target), ErrURLNotAllowed, host)
+	}
+	for _, host := range []string{"100.63.255.255", "100.128.0.1", "203.0.113.7"} {
+		target, _ := url.Parse("http://" + host + "/")
+		assert.NoError(t, blockOnly.checkURL(target), host)
+	}
+}
+
+func TestDatabaseSource_BlockPrivate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	p := config.Project{ValueEncoding: "url", URLBlockPrivate: true}
+	urls := newURLPolicy(p)
+	client, err := newHTTPClient(p, config.TransportConfig{}, urls)
+	assert.NoError(t, err)
+
+	// Hostnames are checked once resolved.
+	stored := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	ds := &DatabaseSource{
+		db: &mockDBLoader{FetchFunc: func(table, idColumn, serveColumn, idValue string) ([]byte, error) {
+			return []byte(stored), nil
+		}},
+		project: p,
+		client:  client,
+		urls:    urls,
+		config:  &config.AppConfig{},
+	}
+
+	data, err := ds.Fetch(context.Background(), "1", Params{})
+	assert.ErrorIs(t, err, ErrURLNotAllowed)
+	assert.Nil(t, data)
+
+	stored = server.URL
+	_, err = ds.Fetch(context.Background(), "1", Params{})
+	assert.ErrorIs(t, err, ErrURLNotAllowed)
+}
@@ -0,0 +1,161 @@
+package hooks
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Spec holds the source of a project's hook expressions. Empty expressions
+// are not evaluated.
+type Spec struct {
+	Reject   string
+	CacheTTL string
+	Headers  string
+	Source   string
+}
+
+// Request describes the incoming request to hook expressions.
+type Request struct {
+	Method   string            `expr:"method"`
+	Path     string            `expr:"path"`
+	Query    map[string]string `expr:"query"`
+	Header   map[string]string `expr:"header"`
+	ClientIP string            `expr:"client_ip"`
+}
+
+// Env is the environment hook expressions are evaluated in, e.g.
+// `request.header["X-Tenant"] == "acme" && id startsWith "internal-"`.
+type Env struct {
+	Project string  `expr:"project"`
+	ID      string  `expr:"id"`
+	Request Request `expr:"request"`
+}
+
+// Hooks are the compiled hook expressions of a project.
+type Hooks struct {
+	reject   *vm.Program
+	cacheTTL *vm.Program
+	headers  *vm.Program
+	source   *vm.Program
+}
+
+// Compile compiles and type-checks the expressions of a Spec. It returns
+// nil if the spec contains no expressions.
+func Compile(spec Spec) (*Hooks, error) {
+	if spec == (Spec{}) {
+		return nil, nil
+	}
+
+	var h Hooks
+	var err error
+	if h.reject, err = compile("REJECT", spec.Reject, expr.AsBool()); err != nil {
+		return nil, err
+	}
+	if h.cacheTTL, err = compile("CACHE_TTL", spec.CacheTTL); err != nil {
+		return nil, err
+	}
+	if h.headers, err = compile("HEADERS", spec.Headers); err != nil {
+		return nil, err
+	}
+	if h.source, err = compile("SOURCE", spec.Source, expr.AsKind(reflect.String)); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func compile(name, source string, opts ...expr.Option) (*vm.Program, error) {
+	if source == "" {
+		return nil, nil
+	}
+	program, err := expr.Compile(source, append([]expr.Option{expr.Env(Env{})}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s hook: %w", name, err)
+	}
+	return program, nil
+}
+
+// Reject reports whether the request should be refused.
+func (h *Hooks) Reject(env Env) (bool, error) {
+	if h == nil || h.reject == nil {
+		return false, nil
+	}
+	out, err := expr.Run(h.reject, env)
+	if err != nil {
+		return false, fmt.Errorf("REJECT hook: %w", err)
+	}
+	return out.(bool), nil
+}
+
+// NoCache is the TTL CacheTTL returns for responses that must not be
+// cached, which the expression asks for with zero or a negative number.
+const NoCache time.Duration = -1
+
+// CacheTTL computes the cache TTL for the request. The expression returns a
+// number of seconds; fallback is returned when no expression is configured.
+func (h *Hooks) CacheTTL(env Env, fallback time.Duration) (time.Duration, error) {
+	if h == nil || h.cacheTTL == nil {
+		return fallback, nil
+	}
+	out, err := expr.Run(h.cacheTTL, env)
+	if err != nil {
+		return 0, fmt.Errorf("CACHE_TTL hook: %w", err)
+	}
+
+	var seconds float64
+	switch v := out.(type) {
+	case int:
+		seconds = float64(v)
+	case int64:
+		seconds = float64(v)
+	case float64:
+		seconds = v
+	default:
+		return 0, fmt.Errorf("CACHE_TTL hook returned %T, expected a number of seconds", out)
+	}
+	if seconds <= 0 {
+		return NoCache, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Headers computes additional response headers. The expression returns a map
+// of header names to values, e.g. `{"X-Robots-Tag": "noindex"}`.
+func (h *Hooks) Headers(env Env) (map[string]string, error) {
+	if h == nil || h.headers == nil {
+		return nil, nil
+	}
+	out, err := expr.Run(h.headers, env)
+	if err != nil {
+		return nil, fmt.Errorf("HEADERS hook: %w", err)
+	}
+	if out == nil {
+		return nil, nil
+	}
+
+	m, ok := out.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("HEADERS hook returned %T, expected a map", out)
+	}
+	headers := make(map[string]string, len(m))
+	for name, value := range m {
+		headers[name] = fmt.Sprint(value)
+	}
+	return headers, nil
+}
+
+// Source returns the name of the project whose source should serve the
+// request, or an empty string to use the project's own source.
+func (h *Hooks) Source(env Env) (string, error) {
+	if h == nil || h.source == nil {
+		return "", nil
+	}
+	out, err := expr.Run(h.source, env)
+	if err != nil {
+		return "", fmt.Errorf("SOURCE hook: %w", err)
+	}
+	return out.(string), nil
+}
@@ -0,0 +1,91 @@
+package hooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testEnv() Env {
+	return Env{
+		Project: "avatars",
+		ID:      "internal-42",
+		Request: Request{
+			Method: "GET",
+			Path:   "/avatars/internal-42",
+			Query:  map[string]string{"size": "64"},
+			Header: map[string]string{"X-Tenant": "acme"},
+		},
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Run("Empty Spec", func(t *testing.T) {
+		h, err := Compile(Spec{})
+		assert.NoError(t, err)
+		assert.Nil(t, h)
+
+		// A nil *Hooks is safe to use.
+		reject, err := h.Reject(testEnv())
+		assert.NoError(t, err)
+		assert.False(t, reject)
+	})
+
+	t.Run("Syntax Error", func(t *testing.T) {
+		_, err := Compile(Spec{Reject: "id ==="})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid REJECT hook")
+	})
+
+	t.Run("Reject Must Be Boolean", func(t *testing.T) {
+		_, err := Compile(Spec{Reject: `"yes"`})
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown Variable", func(t *testing.T) {
+		_, err := Compile(Spec{Source: "tenant"})
+		assert.Error(t, err)
+	})
+}
+
+func TestHooks(t *testing.T) {
+	h, err := Compile(Spec{
+		Reject:   `id startsWith "internal-" && request.header["X-Tenant"] != "acme"`,
+		CacheTTL: `request.query["size"] == "64" ? 60 : 3600`,
+		Headers:  `{"X-Tenant": request.header["X-Tenant"], "X-Size": request.query["size"]}`,
+		Source:   `request.header["X-Tenant"] == "acme" ? "avatars_acme" : ""`,
+	})
+	assert.NoError(t, err)
+
+	env := testEnv()
+
+	reject, err := h.Reject(env)
+	assert.NoError(t, err)
+	assert.False(t, reject)
+
+	ttl, err := h.CacheTTL(env, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 60*time.Second, ttl)
+
+	for _, expression := range []string{"0", "-1"} {
+		noCache, err := Compile(Spec{CacheTTL: expression})
+		assert.NoError(t, err)
+		ttl, err = noCache.CacheTTL(env, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, NoCache, ttl, expression)
+	}
+
+	headers, err := h.Headers(env)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme", "X-Size": "64"}, headers)
+
+	source, err := h.Source(env)
+	assert.NoError(t, err)
+	assert.Equal(t, "avatars_acme", source)
+
+	env.Request.Header = map[string]string{}
+	reject, err = h.Reject(env)
+	assert.NoError(t, err)
+	assert.True(t, reject)
+}
@@ -0,0 +1,229 @@
+package idcodec
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	hashidsAlphabet       = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
+	hashidsSeparators     = "cfhistuCFHISTU"
+	hashidsSeparatorRatio = 3.5
+	hashidsGuardRatio     = 12
+)
+
+// Hashids decodes IDs produced by the hashids algorithm (hashids.org) with
+// the default alphabet. Only single-number hashids are accepted, as each
+// public ID maps to one database key.
+type Hashids struct {
+	salt      []byte
+	minLength int
+	alphabet  []byte
+	seps      []byte
+	guards    []byte
+}
+
+// NewHashids prepares a hashids codec for a salt and minimum hash length.
+func NewHashids(salt string, minLength int) (*Hashids, error) {
+	if minLength < 0 {
+		return nil, fmt.Errorf("hashids minimum length must not be negative")
+	}
+
+	h := &Hashids{salt: []byte(salt), minLength: minLength}
+	alphabet := []byte(hashidsAlphabet)
+
+	// Separators must come from the alphabet and are removed from it.
+	var seps []byte
+	for _, c := range []byte(hashidsSeparators) {
+		if i := strings.IndexByte(string(alphabet), c); i != -1 {
+			seps = append(seps, c)
+			alphabet = append(alphabet[:i:i], alphabet[i+1:]...)
+		}
+	}
+	seps = shuffle(seps, h.salt)
+
+	if len(seps) == 0 || float64(len(alphabet))/float64(len(seps)) > hashidsSeparatorRatio {
+		sepsLength := int(math.Ceil(float64(len(alphabet)) / hashidsSeparatorRatio))
+		if sepsLength == 1 {
+			sepsLength = 2
+		}
+		if sepsLength > len(seps) {
+			diff := sepsLength - len(seps)
+			seps = append(seps, alphabet[:diff]...)
+			alphabet = alphabet[diff:]
+		} else {
+			seps = seps[:sepsLength]
+		}
+	}
+
+	alphabet = shuffle(alphabet, h.salt)
+	guardCount := int(math.Ceil(float64(len(alphabet)) / hashidsGuardRatio))
+	if len(alphabet) < 3 {
+		h.guards, seps = seps[:guardCount], seps[guardCount:]
+	} else {
+		h.guards, alphabet = alphabet[:guardCount], alphabet[guardCount:]
+	}
+
+	h.alphabet, h.seps = alphabet, seps
+	return h, nil
+}
+
+// Decode returns the number encoded in a hashid as a decimal string.
+func (h *Hashids) Decode(publicID string) (string, error) {
+	numbers, err := h.decode(publicID)
+	if err != nil || len(numbers) != 1 {
+		return "", ErrInvalidID
+	}
+	return strconv.FormatInt(numbers[0], 10), nil
+}
+
+// Encode returns the hashid of a number. It is the inverse of Decode.
+func (h *Hashids) Encode(number int64) (string, error) {
+	if number < 0 {
+		return "", fmt.Errorf("hashids cannot encode negative numbers")
+	}
+	return h.encode([]int64{number}), nil
+}
+
+func (h *Hashids) encode(numbers []int64) string {
+	var numbersHash int64
+	for i, n := range numbers {
+		numbersHash += n % int64(i+100)
+	}
+
+	alphabet := append([]byte(nil), h.alphabet...)
+	lottery := alphabet[numbersHash%int64(len(alphabet))]
+	result := []byte{lottery}
+
+	for i, n := range numbers {
+		alphabet = shuffle(alphabet, h.buffer(lottery, alphabet))
+		last := hashNumber(n, alphabet)
+		result = append(result, last...)
+
+		if i+1 < len(numbers) {
+			n %= int64(last[0]) + int64(i)
+			result = append(result, h.seps[n%int64(len(h.seps))])
+		}
+	}
+
+	if len(result) < h.minLength {
+		guard := h.guards[(numbersHash+int64(result[0]))%int64(len(h.guards))]
+		result = append([]byte{guard}, result...)
+
+		if len(result) < h.minLength {
+			guard = h.guards[(numbersHash+int64(result[2]))%int64(len(h.guards))]
+			result = append(result, guard)
+		}
+	}
+
+	half := len(alphabet) / 2
+	for len(result) < h.minLength {
+		alphabet = shuffle(alphabet, alphabet)
+		padded := append([]byte(nil), alphabet[half:]...)
+		padded = append(padded, result...)
+		result = append(padded, alphabet[:half]...)
+
+		if excess := len(result) - h.minLength; excess > 0 {
+			result = result[excess/2 : excess/2+h.minLength]
+		}
+	}
+
+	return string(result)
+}
+
+func (h *Hashids) decode(hash string) ([]int64, error) {
+	// The core of the hash sits between the guards added for padding.
+	breakdown := hash
+	if fields := splitAny(hash, h.guards); len(fields) == 2 || len(fields) == 3 {
+		breakdown = fields[1]
+	}
+	if breakdown == "" {
+		return nil, ErrInvalidID
+	}
+
+	alphabet := append([]byte(nil), h.alphabet...)
+	lottery := breakdown[0]
+
+	var numbers []int64
+	for _, sub := range splitAny(breakdown[1:], h.seps) {
+		alphabet = shuffle(alphabet, h.buffer(lottery, alphabet))
+		n, ok := unhashNumber(sub, alphabet)
+		if !ok {
+			return nil, ErrInvalidID
+		}
+		numbers = append(numbers, n)
+	}
+
+	// Reject hashes that decode but are not the canonical encoding.
+	if len(numbers) == 0 || h.encode(numbers) != hash {
+		return nil, ErrInvalidID
+	}
+	return numbers, nil
+}
+
+// Returns the lottery character, salt and alphabet truncated to the length
+// of the alphabet, used as the shuffle key for each number.
+func (h *Hashids) buffer(lottery byte, alphabet []byte) []byte {
+	buffer := append([]byte{lottery}, h.salt...)
+	buffer = append(buffer, alphabet...)
+	return buffer[:len(alphabet)]
+}
+
+// Splits s at every byte in separators, keeping empty fields.
+func splitAny(s string, separators []byte) []string {
+	var fields []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(string(separators), s[i]) != -1 {
+			fields = append(fields, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, s[start:])
+}
+
+// Shuffles a copy of alphabet deterministically based on salt.
+func shuffle(alphabet, salt []byte) []byte {
+	result := append([]byte(nil), alphabet...)
+	if len(salt) == 0 {
+		return result
+	}
+	for i, v, p := len(result)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+		v %= len(salt)
+		c := int(salt[v])
+		p += c
+		j := (c + v + p) % i
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+func hashNumber(n int64, alphabet []byte) []byte {
+	base := int64(len(alphabet))
+	var hash []byte
+	for {
+		hash = append([]byte{alphabet[n%base]}, hash...)
+		n /= base
+		if n == 0 {
+			return hash
+		}
+	}
+}
+
+func unhashNumber(hash string, alphabet []byte) (int64, bool) {
+	if hash == "" {
+		return 0, false
+	}
+	base := int64(len(alphabet))
+	var n int64
+	for i := 0; i < len(hash); i++ {
+		pos := strings.IndexByte(string(alphabet), hash[i])
+		if pos == -1 || n > (math.MaxInt64-int64(pos))/base {
+			return 0, false
+		}
+		n = n*base + int64(pos)
+	}
+	return n, true
+}
@@ -0,0 +1,70 @@
+package idcodec
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrInvalidID is returned when a public ID cannot be decoded. Callers should
+// treat it like an unknown ID rather than a bad request, so probing does not
+// reveal which IDs are well-formed.
+var ErrInvalidID = errors.New("invalid public ID")
+
+// Codec translates the public IDs used in URLs into the keys payloads are
+// looked up by, e.g. a hashid into a sequential database key.
+type Codec interface {
+	Decode(publicID string) (string, error)
+}
+
+// Options configures a codec.
+type Options struct {
+	Salt      string
+	MinLength int
+}
+
+// Factory builds a Codec from its options.
+type Factory func(opts Options) (Codec, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a codec available under the given name. Registering an
+// existing name replaces the previous factory.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Names returns the names of all registered codecs.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the codec registered under name.
+func New(name string, opts Options) (Codec, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown ID codec '%s'", name)
+	}
+	return factory(opts)
+}
+
+func init() {
+	Register("hashids", func(opts Options) (Codec, error) {
+		return NewHashids(opts.Salt, opts.MinLength)
+	})
+}
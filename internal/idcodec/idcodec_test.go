@@ -0,0 +1,70 @@
+package idcodec
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashids(t *testing.T) {
+	testCases := []struct {
+		name      string
+		salt      string
+		minLength int
+		number    int64
+		hash      string
+	}{
+		{"Reference vector", "this is my salt", 0, 12345, "NkK9"},
+		{"Minimum length", "this is my salt", 8, 1, "gB0NV05e"},
+		{"No salt", "", 0, 1, "jR"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := NewHashids(tc.salt, tc.minLength)
+			assert.NoError(t, err)
+
+			hash, err := h.Encode(tc.number)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.hash, hash)
+
+			id, err := h.Decode(tc.hash)
+			assert.NoError(t, err)
+			assert.Equal(t, strconv.FormatInt(tc.number, 10), id)
+		})
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		h, _ := NewHashids("pepper", 6)
+		for _, n := range []int64{0, 7, 99, 100, 123456789} {
+			hash, _ := h.Encode(n)
+			id, err := h.Decode(hash)
+			assert.NoError(t, err)
+			assert.Equal(t, strconv.FormatInt(n, 10), id)
+		}
+	})
+
+	t.Run("Invalid IDs", func(t *testing.T) {
+		h, _ := NewHashids("this is my salt", 0)
+		other, _ := NewHashids("another salt", 0)
+		foreign, _ := other.Encode(12345)
+
+		for _, id := range []string{"", "12345", "NkK9x", "!!", foreign} {
+			_, err := h.Decode(id)
+			assert.ErrorIs(t, err, ErrInvalidID, id)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	codec, err := New("hashids", Options{Salt: "this is my salt"})
+	assert.NoError(t, err)
+	id, err := codec.Decode("NkK9")
+	assert.NoError(t, err)
+	assert.Equal(t, "12345", id)
+
+	_, err = New("rot13", Options{})
+	assert.Error(t, err)
+	assert.Contains(t, Names(), "hashids")
+}
@@ -0,0 +1,166 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrUnsupportedFormat is returned for output formats that cannot be encoded.
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// ErrTooLarge is returned for images with more pixels than Resize is allowed
+// to decode.
+var ErrTooLarge = errors.New("image is too large")
+
+var contentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+}
+
+// Options describes a requested image variant.
+type Options struct {
+	Width  int
+	Height int
+	Format string
+}
+
+// ParseOptions reads the w, h and format query parameters. Dimensions must be
+// positive and no larger than maxDimension.
+func ParseOptions(query url.Values, maxDimension int) (Options, error) {
+	var opts Options
+	var err error
+
+	if opts.Width, err = parseDimension(query.Get("w"), maxDimension); err != nil {
+		return Options{}, fmt.Errorf("invalid width: %w", err)
+	}
+	if opts.Height, err = parseDimension(query.Get("h"), maxDimension); err != nil {
+		return Options{}, fmt.Errorf("invalid height: %w", err)
+	}
+
+	opts.Format = strings.ToLower(query.Get("format"))
+	if opts.Format == "jpg" {
+		opts.Format = "jpeg"
+	}
+	if opts.Format != "" {
+		if _, ok := contentTypes[opts.Format]; !ok {
+			return Options{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, opts.Format)
+		}
+		if opts.Format == "webp" {
+			// WebP sources are decoded, but golang.org/x/image has no WebP
+			// encoder, and the ones that exist need cgo and libwebp, which
+			// the static builds of Stratum do without.
+			return Options{}, fmt.Errorf("%w: webp output, use format=png or format=jpeg", ErrUnsupportedFormat)
+		}
+	}
+	return opts, nil
+}
+
+func parseDimension(value string, max int) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("'%s' is not a positive integer", value)
+	}
+	if max > 0 && n > max {
+		return 0, fmt.Errorf("%d exceeds the maximum of %d", n, max)
+	}
+	return n, nil
+}
+
+// IsZero reports whether no transformation was requested.
+func (o Options) IsZero() bool {
+	return o.Width == 0 && o.Height == 0 && o.Format == ""
+}
+
+// Key returns a stable identifier for the variant, suitable for cache keys.
+func (o Options) Key() string {
+	return fmt.Sprintf("w=%d,h=%d,f=%s", o.Width, o.Height, o.Format)
+}
+
+// Resize decodes an image, scales it to fit within the requested dimensions
+// (keeping the aspect ratio) and encodes it in the requested format, or the
+// source format if none was given. It returns the encoded bytes and their
+// content type. Images with more than maxPixels pixels are refused before
+// being decoded, as a small file can declare huge dimensions.
+func Resize(data []byte, opts Options, maxPixels int) ([]byte, string, error) {
+	header, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if maxPixels > 0 && int64(header.Width)*int64(header.Height) > int64(maxPixels) {
+		return nil, "", fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrTooLarge, header.Width, header.Height, maxPixels)
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	if opts.Format != "" {
+		format = opts.Format
+	}
+
+	img := src
+	if w, h := fitDimensions(src.Bounds().Dx(), src.Bounds().Dy(), opts.Width, opts.Height); w != src.Bounds().Dx() || h != src.Bounds().Dy() {
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+		img = dst
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, "", fmt.Errorf("%w: %s output", ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), contentTypes[format], nil
+}
+
+// Computes the size of an image scaled to fit within maxW x maxH. A zero bound
+// is unconstrained. Images are never upscaled.
+func fitDimensions(w, h, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 && w > maxW {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && h > maxH {
+		if s := float64(maxH) / float64(h); s < scale {
+			scale = s
+		}
+	}
+	if scale == 1.0 {
+		return w, h
+	}
+
+	nw, nh := int(float64(w)*scale+0.5), int(float64(h)*scale+0.5)
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+	return nw, nh
+}
@@ -0,0 +1,107 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseOptions(t *testing.T) {
+	testCases := []struct {
+		name        string
+		query       string
+		expected    Options
+		expectError bool
+	}{
+		{"Empty", "", Options{}, false},
+		{"Width only", "w=100", Options{Width: 100}, false},
+		{"All options", "w=100&h=50&format=JPG", Options{Width: 100, Height: 50, Format: "jpeg"}, false},
+		{"Negative width", "w=-1", Options{}, true},
+		{"Too large", "h=5000", Options{}, true},
+		{"Unknown format", "format=bmp", Options{}, true},
+		{"WebP output", "format=webp", Options{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tc.query)
+			opts, err := ParseOptions(query, 2048)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, opts)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	src := testPNG(t, 200, 100)
+
+	t.Run("Fit Width", func(t *testing.T) {
+		out, contentType, err := Resize(src, Options{Width: 50}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", contentType)
+
+		img, err := png.Decode(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, 50, img.Bounds().Dx())
+		assert.Equal(t, 25, img.Bounds().Dy())
+	})
+
+	t.Run("Fit Box And Convert", func(t *testing.T) {
+		out, contentType, err := Resize(src, Options{Width: 100, Height: 20, Format: "jpeg"}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "image/jpeg", contentType)
+
+		img, err := jpeg.Decode(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, 40, img.Bounds().Dx())
+		assert.Equal(t, 20, img.Bounds().Dy())
+	})
+
+	t.Run("No Upscaling", func(t *testing.T) {
+		out, _, err := Resize(src, Options{Width: 1000}, 0)
+		assert.NoError(t, err)
+		img, err := png.Decode(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, img.Bounds().Dx())
+	})
+
+	t.Run("Not An Image", func(t *testing.T) {
+		_, _, err := Resize([]byte("<html>error</html>"), Options{Width: 10}, 0)
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrUnsupportedFormat))
+	})
+
+	t.Run("Too Many Pixels", func(t *testing.T) {
+		_, _, err := Resize(src, Options{Width: 10}, 200*100-1)
+		assert.ErrorIs(t, err, ErrTooLarge)
+
+		_, _, err = Resize(src, Options{Width: 10}, 200*100)
+		assert.NoError(t, err)
+	})
+}
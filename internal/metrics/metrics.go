@@ -0,0 +1,493 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SizeBuckets are the upper bounds (in bytes) of the payload size histogram.
+var SizeBuckets = []float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+// QueryBuckets are the upper bounds (in seconds) of the database query
+// duration histogram.
+var QueryBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+const (
+	// Number of days of upstream usage totals kept per project.
+	upstreamHistoryDays = 31
+
+	// Number of payloads observed before size shift detection kicks in.
+	sizeWarmupSamples = 20
+	// Smoothing factor for the moving average of payload sizes.
+	sizeEWMAAlpha = 0.1
+)
+
+// Registry collects per-project statistics about served payloads.
+type Registry struct {
+	mu       sync.RWMutex
+	projects map[string]*projectStats
+
+	// SizeAlertRatio is the factor by which a payload has to deviate from the
+	// moving average size before it is reported as a size shift. Zero disables
+	// the detection.
+	SizeAlertRatio float64
+
+	// OnSizeShift is called whenever a payload size shift is detected.
+	OnSizeShift func(project string, size int, average float64)
+
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+}
+
+type projectStats struct {
+	mu sync.Mutex
+
+	payloads    uint64
+	bytes       uint64
+	buckets     []uint64
+	minSize     int
+	maxSize     int
+	averageSize float64
+	sizeShifts  uint64
+
+	contentTypeMismatches uint64
+
+	cacheHits     uint64
+	cacheHitBytes uint64
+	cacheMisses   uint64
+
+	inFlight     int64
+	requestsShed uint64
+
+	slowRequests   uint64
+	largeResponses uint64
+
+	queries      uint64
+	querySeconds float64
+	queryBuckets []uint64
+	queryErrors  uint64
+	slowQueries  uint64
+
+	upstream      UpstreamUsage
+	upstreamDaily map[string]*UpstreamUsage
+}
+
+// UpstreamUsage counts the fetches made against a project's source.
+type UpstreamUsage struct {
+	Fetches uint64 `json:"fetches"`
+	Bytes   uint64 `json:"bytes"`
+	Errors  uint64 `json:"errors"`
+}
+
+// ProjectSnapshot is a point-in-time view of a project's statistics.
+type ProjectSnapshot struct {
+	Payloads      uint64            `json:"payloads"`
+	Bytes         uint64            `json:"bytes"`
+	MinSize       int               `json:"min_size"`
+	MaxSize       int               `json:"max_size"`
+	AverageSize   float64           `json:"average_size"`
+	SizeHistogram map[string]uint64 `json:"size_histogram"`
+	SizeShifts    uint64            `json:"size_shifts"`
+
+	ContentTypeMismatches uint64 `json:"content_type_mismatches"`
+
+	// Requests and bytes served from the cache instead of the source, and
+	// requests that looked in the cache and had to go to the source.
+	CacheHits     uint64 `json:"cache_hits"`
+	CacheHitBytes uint64 `json:"cache_hit_bytes"`
+	CacheMisses   uint64 `json:"cache_misses"`
+
+	// Requests being handled, and requests turned away for being over the
+	// concurrency limits.
+	InFlight     int64  `json:"in_flight"`
+	RequestsShed uint64 `json:"requests_shed"`
+
+	// Requests over the project's slow request threshold, and responses
+	// over its large response threshold.
+	SlowRequests   uint64 `json:"slow_requests"`
+	LargeResponses uint64 `json:"large_responses"`
+
+	// Database queries made for the project and how long they took, the
+	// ones that failed, and the ones over its slow query threshold.
+	Queries        uint64            `json:"queries"`
+	QuerySeconds   float64           `json:"query_seconds"`
+	QueryHistogram map[string]uint64 `json:"query_histogram"`
+	QueryErrors    uint64            `json:"query_errors"`
+	SlowQueries    uint64            `json:"slow_queries"`
+
+	Upstream      UpstreamUsage            `json:"upstream"`
+	UpstreamDaily map[string]UpstreamUsage `json:"upstream_daily"`
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		projects:       make(map[string]*projectStats),
+		SizeAlertRatio: 10,
+		now:            time.Now,
+	}
+}
+
+func (r *Registry) project(name string) *projectStats {
+	r.mu.RLock()
+	ps, ok := r.projects[name]
+	r.mu.RUnlock()
+	if ok {
+		return ps
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ps, ok = r.projects[name]; ok {
+		return ps
+	}
+	ps = &projectStats{
+		buckets:       make([]uint64, len(SizeBuckets)+1),
+		queryBuckets:  make([]uint64, len(QueryBuckets)+1),
+		upstreamDaily: make(map[string]*UpstreamUsage),
+	}
+	r.projects[name] = ps
+	return ps
+}
+
+// ObservePayload records the size of a payload served for a project.
+func (r *Registry) ObservePayload(project string, size int) {
+	ps := r.project(project)
+
+	ps.mu.Lock()
+	ps.payloads++
+	ps.bytes += uint64(size)
+	ps.buckets[bucketIndex(size)]++
+	if ps.payloads == 1 || size < ps.minSize {
+		ps.minSize = size
+	}
+	if size > ps.maxSize {
+		ps.maxSize = size
+	}
+
+	shifted := false
+	average := ps.averageSize
+	if ps.payloads == 1 {
+		ps.averageSize = float64(size)
+	} else {
+		shifted = ps.payloads > sizeWarmupSamples && isSizeShift(size, average, r.SizeAlertRatio)
+		ps.averageSize = sizeEWMAAlpha*float64(size) + (1-sizeEWMAAlpha)*ps.averageSize
+	}
+	if shifted {
+		ps.sizeShifts++
+	}
+	ps.mu.Unlock()
+
+	if shifted && r.OnSizeShift != nil {
+		r.OnSizeShift(project, size, average)
+	}
+}
+
+// ObserveContentTypeMismatch records a response whose payload was flagged, or
+// a fetch rejected, because its sniffed content type did not match the
+// project's configured one.
+func (r *Registry) ObserveContentTypeMismatch(project string) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.contentTypeMismatches++
+	ps.mu.Unlock()
+}
+
+// ObserveCacheHit records a payload served from the cache.
+func (r *Registry) ObserveCacheHit(project string, size int) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.cacheHits++
+	ps.cacheHitBytes += uint64(size)
+	ps.mu.Unlock()
+}
+
+// ObserveCacheMiss records a request that was not found in the cache.
+// Prefetches, warming and revalidation are not requests and are left out.
+func (r *Registry) ObserveCacheMiss(project string) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.cacheMisses++
+	ps.mu.Unlock()
+}
+
+// ObserveRequestStart records that a request to a project is being
+// handled, until ObserveRequestEnd is called for it.
+func (r *Registry) ObserveRequestStart(project string) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.inFlight++
+	ps.mu.Unlock()
+}
+
+// ObserveRequestEnd records that a request to a project has been handled.
+func (r *Registry) ObserveRequestEnd(project string) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.inFlight--
+	ps.mu.Unlock()
+}
+
+// ObserveRequestShed records a request to a project turned away because too
+// many requests were being handled.
+func (r *Registry) ObserveRequestShed(project string) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.requestsShed++
+	ps.mu.Unlock()
+}
+
+// ObserveSlowRequest records a request to a project that took longer than
+// its threshold.
+func (r *Registry) ObserveSlowRequest(project string) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.slowRequests++
+	ps.mu.Unlock()
+}
+
+// ObserveLargeResponse records a response of a project larger than its
+// threshold.
+func (r *Registry) ObserveLargeResponse(project string) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.largeResponses++
+	ps.mu.Unlock()
+}
+
+// ObserveQuery records a database query made for a project and how long it
+// took.
+func (r *Registry) ObserveQuery(project string, elapsed time.Duration, failed bool) {
+	ps := r.project(project)
+	seconds := elapsed.Seconds()
+	ps.mu.Lock()
+	ps.queries++
+	ps.querySeconds += seconds
+	ps.queryBuckets[sort.SearchFloat64s(QueryBuckets, seconds)]++
+	if failed {
+		ps.queryErrors++
+	}
+	ps.mu.Unlock()
+}
+
+// ObserveSlowQuery records a database query made for a project that took
+// longer than its threshold.
+func (r *Registry) ObserveSlowQuery(project string) {
+	ps := r.project(project)
+	ps.mu.Lock()
+	ps.slowQueries++
+	ps.mu.Unlock()
+}
+
+// ObserveUpstreamFetch records a fetch against a project's source (a database
+// query or an API call) and the number of bytes it returned.
+func (r *Registry) ObserveUpstreamFetch(project string, size int, failed bool) {
+	ps := r.project(project)
+	day := r.now().UTC().Format("2006-01-02")
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	daily, ok := ps.upstreamDaily[day]
+	if !ok {
+		daily = &UpstreamUsage{}
+		ps.upstreamDaily[day] = daily
+		pruneDays(ps.upstreamDaily, upstreamHistoryDays)
+	}
+
+	for _, usage := range []*UpstreamUsage{&ps.upstream, daily} {
+		usage.Fetches++
+		usage.Bytes += uint64(size)
+		if failed {
+			usage.Errors++
+		}
+	}
+}
+
+// Drops the oldest entries of a map keyed by YYYY-MM-DD until at most keep
+// entries remain.
+func pruneDays(days map[string]*UpstreamUsage, keep int) {
+	if len(days) <= keep {
+		return
+	}
+	keys := make([]string, 0, len(days))
+	for day := range days {
+		keys = append(keys, day)
+	}
+	sort.Strings(keys)
+	for _, day := range keys[:len(keys)-keep] {
+		delete(days, day)
+	}
+}
+
+// Snapshot returns the current statistics for every project.
+func (r *Registry) Snapshot() map[string]ProjectSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string]ProjectSnapshot, len(r.projects))
+	for name, ps := range r.projects {
+		ps.mu.Lock()
+		hist := make(map[string]uint64, len(ps.buckets))
+		for i, count := range ps.buckets {
+			hist[bucketLabel(SizeBuckets, i)] = count
+		}
+		queryHist := make(map[string]uint64, len(ps.queryBuckets))
+		for i, count := range ps.queryBuckets {
+			queryHist[bucketLabel(QueryBuckets, i)] = count
+		}
+		daily := make(map[string]UpstreamUsage, len(ps.upstreamDaily))
+		for day, usage := range ps.upstreamDaily {
+			daily[day] = *usage
+		}
+		out[name] = ProjectSnapshot{
+			Payloads:      ps.payloads,
+			Bytes:         ps.bytes,
+			MinSize:       ps.minSize,
+			MaxSize:       ps.maxSize,
+			AverageSize:   math.Round(ps.averageSize),
+			SizeHistogram: hist,
+			SizeShifts:    ps.sizeShifts,
+
+			ContentTypeMismatches: ps.contentTypeMismatches,
+
+			CacheHits:     ps.cacheHits,
+			CacheHitBytes: ps.cacheHitBytes,
+			CacheMisses:   ps.cacheMisses,
+
+			InFlight:     ps.inFlight,
+			RequestsShed: ps.requestsShed,
+
+			SlowRequests:   ps.slowRequests,
+			LargeResponses: ps.largeResponses,
+
+			Queries:        ps.queries,
+			QuerySeconds:   ps.querySeconds,
+			QueryHistogram: queryHist,
+			QueryErrors:    ps.queryErrors,
+			SlowQueries:    ps.slowQueries,
+
+			Upstream:      ps.upstream,
+			UpstreamDaily: daily,
+		}
+		ps.mu.Unlock()
+	}
+	return out
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	snapshot := r.Snapshot()
+	names := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP stratum_payload_size_bytes Size of payloads served per project.")
+	fmt.Fprintln(w, "# TYPE stratum_payload_size_bytes histogram")
+	for _, name := range names {
+		s := snapshot[name]
+		var cumulative uint64
+		for i := range SizeBuckets {
+			cumulative += s.SizeHistogram[bucketLabel(SizeBuckets, i)]
+			fmt.Fprintf(w, "stratum_payload_size_bytes_bucket{project=%q,le=%q} %d\n", name, bucketLabel(SizeBuckets, i), cumulative)
+		}
+		fmt.Fprintf(w, "stratum_payload_size_bytes_bucket{project=%q,le=\"+Inf\"} %d\n", name, s.Payloads)
+		fmt.Fprintf(w, "stratum_payload_size_bytes_sum{project=%q} %d\n", name, s.Bytes)
+		fmt.Fprintf(w, "stratum_payload_size_bytes_count{project=%q} %d\n", name, s.Payloads)
+	}
+
+	fmt.Fprintln(w, "# HELP stratum_db_query_duration_seconds Duration of database queries per project.")
+	fmt.Fprintln(w, "# TYPE stratum_db_query_duration_seconds histogram")
+	for _, name := range names {
+		s := snapshot[name]
+		if s.Queries == 0 {
+			continue
+		}
+		var cumulative uint64
+		for i := range QueryBuckets {
+			cumulative += s.QueryHistogram[bucketLabel(QueryBuckets, i)]
+			fmt.Fprintf(w, "stratum_db_query_duration_seconds_bucket{project=%q,le=%q} %d\n", name, bucketLabel(QueryBuckets, i), cumulative)
+		}
+		fmt.Fprintf(w, "stratum_db_query_duration_seconds_bucket{project=%q,le=\"+Inf\"} %d\n", name, s.Queries)
+		fmt.Fprintf(w, "stratum_db_query_duration_seconds_sum{project=%q} %s\n", name, strconv.FormatFloat(s.QuerySeconds, 'f', -1, 64))
+		fmt.Fprintf(w, "stratum_db_query_duration_seconds_count{project=%q} %d\n", name, s.Queries)
+	}
+
+	counters := []struct {
+		name, help string
+		value      func(ProjectSnapshot) uint64
+	}{
+		{"stratum_payload_size_shifts_total", "Payloads whose size deviated sharply from the moving average.",
+			func(s ProjectSnapshot) uint64 { return s.SizeShifts }},
+		{"stratum_content_type_mismatches_total", "Responses and rejected fetches whose payload's sniffed content type did not match the configured one.",
+			func(s ProjectSnapshot) uint64 { return s.ContentTypeMismatches }},
+		{"stratum_cache_hits_total", "Requests served from the cache.",
+			func(s ProjectSnapshot) uint64 { return s.CacheHits }},
+		{"stratum_cache_hit_bytes_total", "Bytes served from the cache instead of the source.",
+			func(s ProjectSnapshot) uint64 { return s.CacheHitBytes }},
+		{"stratum_cache_misses_total", "Requests not found in the cache.",
+			func(s ProjectSnapshot) uint64 { return s.CacheMisses }},
+		{"stratum_requests_shed_total", "Requests answered with 503 for exceeding the concurrency limits.",
+			func(s ProjectSnapshot) uint64 { return s.RequestsShed }},
+		{"stratum_slow_requests_total", "Requests that took longer than the project's slow request threshold.",
+			func(s ProjectSnapshot) uint64 { return s.SlowRequests }},
+		{"stratum_large_responses_total", "Responses larger than the project's large response threshold.",
+			func(s ProjectSnapshot) uint64 { return s.LargeResponses }},
+		{"stratum_db_query_errors_total", "Failed database queries made for the project.",
+			func(s ProjectSnapshot) uint64 { return s.QueryErrors }},
+		{"stratum_slow_queries_total", "Database queries that took longer than the project's slow query threshold.",
+			func(s ProjectSnapshot) uint64 { return s.SlowQueries }},
+		{"stratum_upstream_fetches_total", "Fetches made against the project's source.",
+			func(s ProjectSnapshot) uint64 { return s.Upstream.Fetches }},
+		{"stratum_upstream_bytes_total", "Bytes returned by the project's source.",
+			func(s ProjectSnapshot) uint64 { return s.Upstream.Bytes }},
+		{"stratum_upstream_errors_total", "Failed fetches against the project's source.",
+			func(s ProjectSnapshot) uint64 { return s.Upstream.Errors }},
+	}
+
+	fmt.Fprintln(w, "# HELP stratum_requests_in_flight Requests being handled per project.")
+	fmt.Fprintln(w, "# TYPE stratum_requests_in_flight gauge")
+	for _, name := range names {
+		fmt.Fprintf(w, "stratum_requests_in_flight{project=%q} %d\n", name, snapshot[name].InFlight)
+	}
+
+	for _, counter := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n", counter.name, counter.help)
+		fmt.Fprintf(w, "# TYPE %s counter\n", counter.name)
+		for _, name := range names {
+			if _, err := fmt.Fprintf(w, "%s{project=%q} %d\n", counter.name, name, counter.value(snapshot[name])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the histogram bucket for a payload size.
+func bucketIndex(size int) int {
+	return sort.SearchFloat64s(SizeBuckets, float64(size))
+}
+
+// Returns the upper bound of a histogram's bucket, as used in its "le" label.
+func bucketLabel(buckets []float64, i int) string {
+	if i >= len(buckets) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(buckets[i], 'f', -1, 64)
+}
+
+// Reports whether size differs from the average by at least the given ratio.
+func isSizeShift(size int, average, ratio float64) bool {
+	if ratio <= 0 || average <= 0 {
+		return false
+	}
+	s := math.Max(float64(size), 1)
+	return s >= average*ratio || s <= average/ratio
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_ObservePayload(t *testing.T) {
+	r := NewRegistry()
+	r.ObservePayload("avatars", 500)
+	r.ObservePayload("avatars", 2000)
+	r.ObservePayload("avatars", 2<<20)
+
+	s := r.Snapshot()["avatars"]
+	assert.Equal(t, uint64(3), s.Payloads)
+	assert.Equal(t, uint64(500+2000+(2<<20)), s.Bytes)
+	assert.Equal(t, 500, s.MinSize)
+	assert.Equal(t, 2<<20, s.MaxSize)
+	assert.Equal(t, uint64(1), s.SizeHistogram["1024"])
+	assert.Equal(t, uint64(1), s.SizeHistogram["4096"])
+	assert.Equal(t, uint64(1), s.SizeHistogram["4194304"])
+	assert.Equal(t, uint64(0), s.SizeHistogram["+Inf"])
+}
+
+func TestRegistry_SizeShift(t *testing.T) {
+	r := NewRegistry()
+	var shifted []int
+	r.OnSizeShift = func(project string, size int, average float64) {
+		shifted = append(shifted, size)
+	}
+
+	for i := 0; i < sizeWarmupSamples+5; i++ {
+		r.ObservePayload("avatars", 50000)
+	}
+	assert.Empty(t, shifted)
+
+	// An upstream error page is much smaller than the usual image.
+	r.ObservePayload("avatars", 300)
+	assert.Equal(t, []int{300}, shifted)
+	assert.Equal(t, uint64(1), r.Snapshot()["avatars"].SizeShifts)
+
+	t.Run("Disabled", func(t *testing.T) {
+		r := NewRegistry()
+		r.SizeAlertRatio = 0
+		for i := 0; i < sizeWarmupSamples+5; i++ {
+			r.ObservePayload("avatars", 50000)
+		}
+		r.ObservePayload("avatars", 1)
+		assert.Equal(t, uint64(0), r.Snapshot()["avatars"].SizeShifts)
+	})
+}
+
+func TestRegistry_WritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	r.ObservePayload("avatars", 100)
+	r.ObservePayload("avatars", 5000)
+	r.ObserveContentTypeMismatch("avatars")
+	r.ObserveRequestStart("avatars")
+	r.ObserveRequestStart("avatars")
+	r.ObserveRequestEnd("avatars")
+	r.ObserveRequestShed("avatars")
+	r.ObserveSlowRequest("avatars")
+	r.ObserveLargeResponse("avatars")
+	r.ObserveLargeResponse("avatars")
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WritePrometheus(&buf))
+	out := buf.String()
+	assert.Contains(t, out, `stratum_payload_size_bytes_bucket{project="avatars",le="1024"} 1`)
+	assert.Contains(t, out, `stratum_payload_size_bytes_bucket{project="avatars",le="16384"} 2`)
+	assert.Contains(t, out, `stratum_payload_size_bytes_bucket{project="avatars",le="+Inf"} 2`)
+	assert.Contains(t, out, `stratum_payload_size_bytes_count{project="avatars"} 2`)
+	assert.Contains(t, out, `stratum_payload_size_shifts_total{project="avatars"} 0`)
+	assert.Contains(t, out, `stratum_content_type_mismatches_total{project="avatars"} 1`)
+	assert.Contains(t, out, `stratum_requests_in_flight{project="avatars"} 1`)
+	assert.Contains(t, out, `stratum_requests_shed_total{project="avatars"} 1`)
+	assert.Contains(t, out, `stratum_slow_requests_total{project="avatars"} 1`)
+	assert.Contains(t, out, `stratum_large_responses_total{project="avatars"} 2`)
+}
+
+func TestRegistry_UpstreamUsage(t *testing.T) {
+	r := NewRegistry()
+	day := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return day }
+
+	r.ObserveUpstreamFetch("avatars", 100, false)
+	r.ObserveUpstreamFetch("avatars", 0, true)
+	day = day.AddDate(0, 0, 1)
+	r.ObserveUpstreamFetch("avatars", 50, false)
+	r.ObserveCacheHit("avatars", 100)
+	r.ObserveCacheMiss("avatars")
+
+	s := r.Snapshot()["avatars"]
+	assert.Equal(t, UpstreamUsage{Fetches: 3, Bytes: 150, Errors: 1}, s.Upstream)
+	assert.Equal(t, UpstreamUsage{Fetches: 2, Bytes: 100, Errors: 1}, s.UpstreamDaily["2026-01-01"])
+	assert.Equal(t, UpstreamUsage{Fetches: 1, Bytes: 50}, s.UpstreamDaily["2026-01-02"])
+	assert.Equal(t, uint64(1), s.CacheHits)
+	assert.Equal(t, uint64(100), s.CacheHitBytes)
+	assert.Equal(t, uint64(1), s.CacheMisses)
+
+	t.Run("Old Days Are Pruned", func(t *testing.T) {
+		for i := 0; i < upstreamHistoryDays+5; i++ {
+			day = day.AddDate(0, 0, 1)
+			r.ObserveUpstreamFetch("avatars", 1, false)
+		}
+		daily := r.Snapshot()["avatars"].UpstreamDaily
+		assert.Len(t, daily, upstreamHistoryDays)
+		assert.NotContains(t, daily, "2026-01-01")
+	})
+}
+
+func TestRegistry_Queries(t *testing.T) {
+	r := NewRegistry()
+	r.ObserveQuery("avatars", 3*time.Millisecond, false)
+	r.ObserveQuery("avatars", 2*time.Second, true)
+	r.ObserveSlowQuery("avatars")
+	r.ObservePayload("docs", 100)
+
+	s := r.Snapshot()["avatars"]
+	assert.Equal(t, uint64(2), s.Queries)
+	assert.InDelta(t, 2.003, s.QuerySeconds, 1e-9)
+	assert.Equal(t, uint64(1), s.QueryHistogram["0.005"])
+	assert.Equal(t, uint64(1), s.QueryHistogram["2.5"])
+	assert.Equal(t, uint64(1), s.QueryErrors)
+	assert.Equal(t, uint64(1), s.SlowQueries)
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WritePrometheus(&buf))
+	out := buf.String()
+	assert.Contains(t, out, `stratum_db_query_duration_seconds_bucket{project="avatars",le="0.001"} 0`)
+	assert.Contains(t, out, `stratum_db_query_duration_seconds_bucket{project="avatars",le="0.005"} 1`)
+	assert.Contains(t, out, `stratum_db_query_duration_seconds_bucket{project="avatars",le="+Inf"} 2`)
+	assert.Contains(t, out, `stratum_db_query_duration_seconds_sum{project="avatars"} 2.003`)
+	assert.Contains(t, out, `stratum_db_query_duration_seconds_count{project="avatars"} 2`)
+	assert.Contains(t, out, `stratum_db_query_errors_total{project="avatars"} 1`)
+	assert.Contains(t, out, `stratum_slow_queries_total{project="avatars"} 1`)
+	// Projects without queries have no query histogram.
+	assert.NotContains(t, out, `stratum_db_query_duration_seconds_count{project="docs"}`)
+}
@@ -0,0 +1,307 @@
+package transform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Transformer modifies fetched bytes before they are cached and served.
+type Transformer interface {
+	Transform(data []byte) ([]byte, error)
+}
+
+// Func adapts an ordinary function to the Transformer interface.
+type Func func(data []byte) ([]byte, error)
+
+func (f Func) Transform(data []byte) ([]byte, error) {
+	return f(data)
+}
+
+// Factory builds a Transformer from the argument given after the ':' in a
+// chain step, e.g. "data.url" for "json-extract:data.url".
+type Factory func(arg string) (Transformer, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a transformer available under the given name. Registering
+// an existing name replaces the previous factory.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Names returns the names of all registered transformers.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Chain applies a list of transformers in order.
+type Chain []Transformer
+
+func (c Chain) Transform(data []byte) ([]byte, error) {
+	var err error
+	for i, t := range c {
+		data, err = t.Transform(data)
+		if err != nil {
+			return nil, fmt.Errorf("transform step %d: %w", i+1, err)
+		}
+	}
+	return data, nil
+}
+
+// Parse builds a Chain from a spec such as
+// "base64-decode | json-extract:data.avatar | template:{{.Text}}".
+// An empty spec yields an empty chain.
+func Parse(spec string) (Chain, error) {
+	var chain Chain
+	for _, step := range splitSteps(spec) {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			continue
+		}
+
+		name, arg, _ := strings.Cut(step, ":")
+		name = strings.TrimSpace(name)
+
+		registryMu.RLock()
+		factory, ok := registry[name]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown transformer '%s'", name)
+		}
+
+		t, err := factory(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transformer '%s': %w", name, err)
+		}
+		chain = append(chain, t)
+	}
+	return chain, nil
+}
+
+// Splits a chain spec on '|', ignoring pipes inside template actions ({{ }}).
+func splitSteps(spec string) []string {
+	var steps []string
+	depth, start := 0, 0
+	for i := 0; i < len(spec); i++ {
+		switch {
+		case strings.HasPrefix(spec[i:], "{{"):
+			depth++
+			i++
+		case strings.HasPrefix(spec[i:], "}}") && depth > 0:
+			depth--
+			i++
+		case spec[i] == '|' && depth == 0:
+			steps = append(steps, spec[start:i])
+			start = i + 1
+		}
+	}
+	return append(steps, spec[start:])
+}
+
+func init() {
+	Register("base64-decode", func(string) (Transformer, error) {
+		return Func(base64Decode), nil
+	})
+	Register("base64-encode", func(string) (Transformer, error) {
+		return Func(func(data []byte) ([]byte, error) {
+			return []byte(base64.StdEncoding.EncodeToString(data)), nil
+		}), nil
+	})
+	Register("gzip-decode", func(string) (Transformer, error) {
+		return Func(gzipDecode), nil
+	})
+	Register("trim", func(string) (Transformer, error) {
+		return Func(func(data []byte) ([]byte, error) {
+			return bytes.TrimSpace(data), nil
+		}), nil
+	})
+	Register("lowercase", func(string) (Transformer, error) {
+		return Func(func(data []byte) ([]byte, error) {
+			return bytes.ToLower(data), nil
+		}), nil
+	})
+	Register("uppercase", func(string) (Transformer, error) {
+		return Func(func(data []byte) ([]byte, error) {
+			return bytes.ToUpper(data), nil
+		}), nil
+	})
+	Register("url-decode", func(string) (Transformer, error) {
+		return Func(func(data []byte) ([]byte, error) {
+			decoded, err := url.PathUnescape(string(data))
+			return []byte(decoded), err
+		}), nil
+	})
+	Register("strip-extension", newStripExtension)
+	Register("to-utf8", newToUTF8)
+	Register("json-extract", newJSONExtract)
+	Register("template", newTemplate)
+}
+
+// Removes a file extension such as ".png". With a comma-separated list of
+// extensions as the argument, only those are removed (ignoring case).
+func newStripExtension(arg string) (Transformer, error) {
+	var extensions []string
+	for _, ext := range strings.Split(arg, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			extensions = append(extensions, "."+strings.TrimPrefix(ext, "."))
+		}
+	}
+	return Func(func(data []byte) ([]byte, error) {
+		dot := bytes.LastIndexByte(data, '.')
+		if dot <= 0 || bytes.ContainsRune(data[dot:], '/') {
+			return data, nil
+		}
+		if len(extensions) == 0 {
+			return data[:dot], nil
+		}
+		for _, ext := range extensions {
+			if strings.EqualFold(string(data[dot:]), ext) {
+				return data[:dot], nil
+			}
+		}
+		return data, nil
+	}), nil
+}
+
+func base64Decode(data []byte) ([]byte, error) {
+	content := strings.TrimSpace(string(data))
+	if strings.HasPrefix(content, "data:") {
+		if _, payload, ok := strings.Cut(content, ","); ok {
+			content = payload
+		}
+	}
+	return base64.StdEncoding.DecodeString(content)
+}
+
+func gzipDecode(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Transcodes text to UTF-8 from the named charset (any WHATWG label, e.g.
+// "latin1" or "shift_jis"). With no charset or "auto", payloads that are
+// valid UTF-8 are kept, UTF-16 is recognized by its byte order mark, and
+// anything else is read as Windows-1252, a superset of Latin-1.
+func newToUTF8(charset string) (Transformer, error) {
+	charset = strings.TrimSpace(charset)
+	if charset == "" || charset == "auto" {
+		return Func(autoToUTF8), nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unknown charset '%s'", charset)
+	}
+	return Func(func(data []byte) ([]byte, error) {
+		return enc.NewDecoder().Bytes(data)
+	}), nil
+}
+
+func autoToUTF8(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xef\xbb\xbf")):
+		return data[3:], nil
+	case bytes.HasPrefix(data, []byte("\xff\xfe")), bytes.HasPrefix(data, []byte("\xfe\xff")):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+	case utf8.Valid(data):
+		return data, nil
+	}
+	return charmap.Windows1252.NewDecoder().Bytes(data)
+}
+
+// Extracts a value from a JSON document by dot-separated path, e.g.
+// "data.images.0.url". Strings are returned as-is, other values as JSON.
+func newJSONExtract(path string) (Transformer, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("a JSON path is required")
+	}
+	keys := strings.Split(path, ".")
+
+	return Func(func(data []byte) ([]byte, error) {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		for _, key := range keys {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				next, ok := v[key]
+				if !ok {
+					return nil, fmt.Errorf("key '%s' not found", key)
+				}
+				value = next
+			case []interface{}:
+				idx, err := strconv.Atoi(key)
+				if err != nil || idx < 0 || idx >= len(v) {
+					return nil, fmt.Errorf("invalid array index '%s'", key)
+				}
+				value = v[idx]
+			default:
+				return nil, fmt.Errorf("cannot descend into '%s'", key)
+			}
+		}
+
+		if s, ok := value.(string); ok {
+			return []byte(s), nil
+		}
+		return json.Marshal(value)
+	}), nil
+}
+
+// templateData is the value passed to template transformers. Text is the
+// payload as a string and JSON is the decoded payload when it is valid JSON.
+type templateData struct {
+	Text string
+	JSON interface{}
+}
+
+func newTemplate(text string) (Transformer, error) {
+	tmpl, err := template.New("transform").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return Func(func(data []byte) ([]byte, error) {
+		td := templateData{Text: string(data)}
+		_ = json.Unmarshal(data, &td.JSON)
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, td); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}), nil
+}
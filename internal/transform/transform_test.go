@@ -0,0 +1,120 @@
+package transform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("Empty Spec", func(t *testing.T) {
+		chain, err := Parse("")
+		assert.NoError(t, err)
+		assert.Empty(t, chain)
+	})
+
+	t.Run("Unknown Transformer", func(t *testing.T) {
+		_, err := Parse("base64-decode | nope")
+		assert.Error(t, err)
+		assert.Equal(t, "unknown transformer 'nope'", err.Error())
+	})
+
+	t.Run("Missing JSON Path", func(t *testing.T) {
+		_, err := Parse("json-extract")
+		assert.Error(t, err)
+	})
+
+	t.Run("Pipes Inside Templates", func(t *testing.T) {
+		chain, err := Parse(`trim | template:{{.Text | printf "%q"}}`)
+		assert.NoError(t, err)
+		assert.Len(t, chain, 2)
+
+		out, err := chain.Transform([]byte("  hi  "))
+		assert.NoError(t, err)
+		assert.Equal(t, `"hi"`, string(out))
+	})
+}
+
+func TestChain_Transform(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     string
+		input    string
+		expected string
+	}{
+		{"Base64 decode", "base64-decode", "dGVzdA==", "test"},
+		{"Base64 decode data URI", "base64-decode", "data:text/plain;base64,dGVzdA==", "test"},
+		{"Base64 encode", "base64-encode", "test", "dGVzdA=="},
+		{"JSON extract string", "json-extract:data.url", `{"data":{"url":"https://x/y.png"}}`, "https://x/y.png"},
+		{"JSON extract array", "json-extract:items.1", `{"items":[1,{"a":true}]}`, `{"a":true}`},
+		{"Template with JSON", "template:<b>{{.JSON.name}}</b>", `{"name":"Ada"}`, "<b>Ada</b>"},
+		{"Latin-1 to UTF-8", "to-utf8:latin1", "caf\xe9", "café"},
+		{"Detect UTF-8", "to-utf8", "café", "café"},
+		{"Detect Windows-1252", "to-utf8:auto", "\x93quoted\x94", "\u201cquoted\u201d"},
+		{"Detect UTF-16", "to-utf8", "\xff\xfeh\x00i\x00", "hi"},
+		{"Lowercase", "lowercase", "ABC", "abc"},
+		{"Uppercase", "uppercase", "abc", "ABC"},
+		{"URL decode", "url-decode", "caf%C3%A9%20au%20lait", "café au lait"},
+		{"Strip extension", "strip-extension", "abc.png", "abc"},
+		{"Strip listed extension", "strip-extension:png, .jpg", "abc.JPG", "abc"},
+		{"Keep unlisted extension", "strip-extension:png", "abc.gif", "abc.gif"},
+		{"Keep leading dot", "strip-extension", ".hidden", ".hidden"},
+		{"Normalize ID", "url-decode | trim | strip-extension:png | lowercase", "%20ABC.png", "abc"},
+		{"Chained", "base64-decode | json-extract:name | template:Hello {{.Text}}", "eyJuYW1lIjoiQWRhIn0=", "Hello Ada"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chain, err := Parse(tc.spec)
+			assert.NoError(t, err)
+			out, err := chain.Transform([]byte(tc.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(out))
+		})
+	}
+
+	t.Run("Gzip Decode", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte("compressed"))
+		zw.Close()
+
+		chain, err := Parse("gzip-decode")
+		assert.NoError(t, err)
+		out, err := chain.Transform(buf.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, "compressed", string(out))
+	})
+
+	t.Run("Unknown Charset", func(t *testing.T) {
+		_, err := Parse("to-utf8:klingon")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown charset 'klingon'")
+	})
+
+	t.Run("Step Error", func(t *testing.T) {
+		chain, err := Parse("trim | json-extract:missing")
+		assert.NoError(t, err)
+		_, err = chain.Transform([]byte(`{"other":1}`))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "transform step 2")
+	})
+}
+
+func TestRegister(t *testing.T) {
+	Register("upper", func(string) (Transformer, error) {
+		return Func(func(data []byte) ([]byte, error) {
+			return []byte(strings.ToUpper(string(data))), nil
+		}), nil
+	})
+	assert.Contains(t, Names(), "upper")
+
+	chain, err := Parse("upper")
+	assert.NoError(t, err)
+	out, err := chain.Transform([]byte("shout"))
+	assert.NoError(t, err)
+	assert.Equal(t, "SHOUT", string(out))
+}
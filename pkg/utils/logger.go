@@ -1,17 +1,255 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Formats and prints a log message in the application's standard format.
+// LevelFatal is the level of messages logged right before exiting.
+const LevelFatal = slog.LevelError + 4
+
+// LogOptions configures the logger behind StratumLog.
+type LogOptions struct {
+	// Messages below this level are dropped: "debug", "info" (the
+	// default), "warn" or "error"
+	Level string
+
+	// "console" (the default) for Stratum's own line format, or "json" for
+	// one JSON object per line
+	Format string
+
+	// Levels overriding Level for modules, the Go packages logging, e.g.
+	// {"database": "debug"}
+	Modules map[string]string
+}
+
+// The current logger, swapped by ConfigureLogging.
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	logger.Store(slog.New(&moduleHandler{handler: &consoleHandler{}, level: slog.LevelInfo}))
+}
+
+// ConfigureLogging replaces the logger behind StratumLog, and the default
+// slog and log loggers, so messages of libraries are formatted alike.
+func ConfigureLogging(options LogOptions) error {
+	level, err := ParseLogLevel(options.Level)
+	if err != nil {
+		return err
+	}
+	h := &moduleHandler{level: level, modules: make(map[string]slog.Level, len(options.Modules))}
+	for module, name := range options.Modules {
+		if h.modules[module], err = ParseLogLevel(name); err != nil {
+			return fmt.Errorf("%w for module %s", err, module)
+		}
+	}
+	switch options.Format {
+	case "", "console":
+		h.handler = &consoleHandler{}
+	case "json":
+		h.handler = slog.NewJSONHandler(ginWriter{}, &slog.HandlerOptions{
+			Level:       slog.LevelDebug, // filtered by the module handler
+			ReplaceAttr: replaceLevel,
+		})
+	default:
+		return fmt.Errorf("unknown log format '%s'", options.Format)
+	}
+	l := slog.New(h)
+	logger.Store(l)
+	slog.SetDefault(l)
+	return nil
+}
+
+// ParseLogLevel returns the level of a name such as "debug" or "WARN". An
+// empty name is "info".
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	case "fatal":
+		return LevelFatal, nil
+	}
+	return 0, fmt.Errorf("unknown log level '%s'", name)
+}
+
+// Formats and logs a message at a level such as "INFO" or "WARN", in the
+// format configured with ConfigureLogging.
 func StratumLog(level string, format string, args ...interface{}) {
-	fmt.Fprintf(gin.DefaultWriter, "[STRATUM] %s | %-5s | %s\n",
-		time.Now().Format("2006/01/02 - 15:04:05"),
-		level,
-		fmt.Sprintf(format, args...),
+	logAt(context.Background(), level, format, args)
+}
+
+// Logs a message on behalf of the function calling StratumLog or
+// StratumLogContext, which the message's module is told from.
+func logAt(ctx context.Context, levelName string, format string, args []interface{}) {
+	level, err := ParseLogLevel(levelName)
+	if err != nil {
+		level = slog.LevelInfo
+	}
+	l := logger.Load()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip Callers, logAt and StratumLog(Context)
+	record := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, args...), pcs[0])
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	l.Handler().Handle(ctx, record)
+}
+
+// Returns the module of the function at pc: the name of its package, e.g.
+// "database" for github.com/PythonicVarun/Stratum/internal/database.
+func moduleOf(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	module, _, _ := strings.Cut(name, ".")
+	return module
+}
+
+// Drops records below the level of their module and adds the module to the
+// others before passing them on.
+type moduleHandler struct {
+	handler slog.Handler
+	level   slog.Level
+	modules map[string]slog.Level
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if level >= h.level {
+		return true
+	}
+	for _, minLevel := range h.modules {
+		if level >= minLevel {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	module := moduleOf(r.PC)
+	minLevel, ok := h.modules[module]
+	if !ok {
+		minLevel = h.level
+	}
+	if r.Level < minLevel {
+		return nil
+	}
+	if module != "" {
+		r.AddAttrs(slog.String("module", module))
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &moduleHandler{handler: h.handler.WithAttrs(attrs), level: h.level, modules: h.modules}
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return &moduleHandler{handler: h.handler.WithGroup(name), level: h.level, modules: h.modules}
+}
+
+// Names LevelFatal in JSON logs, which slog would call "ERROR+4".
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(levelName(level))
+		}
+	}
+	return a
+}
+
+func levelName(level slog.Level) string {
+	if level >= LevelFatal {
+		return "FATAL"
+	}
+	return level.String()
+}
+
+// Writes lines in Stratum's console format:
+//
+//	[STRATUM] 2006/01/02 - 15:04:05 | INFO  | [request ID] message key=value
+//
+// Modules are left out; attributes other than the request ID follow the
+// message.
+type consoleHandler struct {
+	attrs []slog.Attr
+	group string
+}
+
+// Serializes writes of console lines.
+var consoleMu sync.Mutex
+
+func (h *consoleHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var requestID string
+	var extra strings.Builder
+	add := func(a slog.Attr) bool {
+		switch {
+		case a.Key == "request_id" && h.group == "":
+			requestID = a.Value.String()
+		case a.Key != "module" || h.group != "":
+			fmt.Fprintf(&extra, " %s%s=%v", h.group, a.Key, a.Value)
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+
+	message := r.Message
+	if requestID != "" {
+		message = "[" + requestID + "] " + message
+	}
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	_, err := fmt.Fprintf(gin.DefaultWriter, "[STRATUM] %s | %-5s | %s%s\n",
+		r.Time.Format("2006/01/02 - 15:04:05"),
+		levelName(r.Level),
+		message,
+		extra.String(),
 	)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &consoleHandler{attrs: append(append([]slog.Attr{}, h.attrs...), attrs...), group: h.group}
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	return &consoleHandler{attrs: h.attrs, group: h.group + name + "."}
+}
+
+// Writes to gin.DefaultWriter as it is at the time of writing, so logs
+// follow it when it is replaced.
+type ginWriter struct{}
+
+func (ginWriter) Write(p []byte) (int, error) {
+	return gin.DefaultWriter.Write(p)
 }
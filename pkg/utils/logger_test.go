@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var logs bytes.Buffer
+	writer := gin.DefaultWriter
+	gin.DefaultWriter = &logs
+	t.Cleanup(func() {
+		gin.DefaultWriter = writer
+		ConfigureLogging(LogOptions{})
+	})
+	return &logs
+}
+
+func TestStratumLog_Levels(t *testing.T) {
+	logs := captureLogs(t)
+	assert.NoError(t, ConfigureLogging(LogOptions{Level: "warn"}))
+
+	StratumLog("INFO", "dropped")
+	StratumLogContext(WithRequestID(context.Background(), "req-1"), "WARN", "cache %s is slow", "redis")
+	assert.NotContains(t, logs.String(), "dropped")
+	assert.Regexp(t, `^\[STRATUM\] \d{4}/\d\d/\d\d - \d\d:\d\d:\d\d \| WARN  \| \[req-1\] cache redis is slow\n$`, logs.String())
+
+	// Module levels override the minimum level for their package only.
+	logs.Reset()
+	assert.NoError(t, ConfigureLogging(LogOptions{Level: "warn", Modules: map[string]string{"utils": "debug"}}))
+	StratumLog("DEBUG", "kept")
+	assert.Contains(t, logs.String(), "| DEBUG | kept")
+	logs.Reset()
+	assert.NoError(t, ConfigureLogging(LogOptions{Level: "warn", Modules: map[string]string{"database": "debug"}}))
+	StratumLog("DEBUG", "dropped")
+	assert.Empty(t, logs.String())
+
+	assert.EqualError(t, ConfigureLogging(LogOptions{Level: "loud"}), "unknown log level 'loud'")
+	assert.EqualError(t, ConfigureLogging(LogOptions{Modules: map[string]string{"api": "loud"}}), "unknown log level 'loud' for module api")
+	assert.EqualError(t, ConfigureLogging(LogOptions{Format: "xml"}), "unknown log format 'xml'")
+}
+
+func TestStratumLog_JSON(t *testing.T) {
+	logs := captureLogs(t)
+	assert.NoError(t, ConfigureLogging(LogOptions{Format: "json"}))
+
+	StratumLogContext(WithRequestID(context.Background(), "req-2"), "FATAL", "cannot start: %v", "port in use")
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, "FATAL", line["level"])
+	assert.Equal(t, "cannot start: port in use", line["msg"])
+	assert.Equal(t, "req-2", line["request_id"])
+	assert.Equal(t, "utils", line["module"])
+	assert.NotEmpty(t, line["time"])
+}
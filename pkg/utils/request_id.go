@@ -0,0 +1,24 @@
+package utils
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the request it belongs
+// to, which StratumLogContext adds to log lines and upstream requests carry
+// in X-Request-ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by a context, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// StratumLogContext logs like StratumLog, along with the ID of the request
+// ctx belongs to, if any.
+func StratumLogContext(ctx context.Context, level string, format string, args ...interface{}) {
+	logAt(ctx, level, format, args)
+}